@@ -11,8 +11,12 @@ import (
 	"prodigo/internal/app/usecases"
 	"prodigo/pkg/config"
 	"prodigo/pkg/db"
+	"prodigo/pkg/imageproc"
 	"prodigo/pkg/jwt"
+	"prodigo/pkg/metrics"
 	"prodigo/pkg/migration"
+	"prodigo/pkg/notifier"
+	"prodigo/pkg/storage"
 
 	"go.uber.org/fx"
 )
@@ -30,6 +34,10 @@ func main() {
 		middleware.Module,
 		jwt.Module,
 		casbin.Module,
+		storage.Module,
+		imageproc.Module,
+		notifier.Module,
+		metrics.Module(func(conf *config.Config) string { return conf.AppMetricsAddr }),
 		fx.Invoke(func(lc fx.Lifecycle, conf *config.Config) {
 			lc.Append(fx.Hook{
 				OnStart: func(_ context.Context) error {
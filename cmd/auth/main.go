@@ -10,6 +10,7 @@ import (
 	"prodigo/pkg/config"
 	"prodigo/pkg/db"
 	"prodigo/pkg/jwt"
+	"prodigo/pkg/metrics"
 	"prodigo/pkg/migration"
 
 	"go.uber.org/fx"
@@ -24,6 +25,7 @@ func main() {
 		handlers.Module,
 		rest.Module,
 		jwt.Module,
+		metrics.Module(func(conf *config.Config) string { return conf.AuthMetricsAddr }),
 		fx.Invoke(func(lc fx.Lifecycle, conf *config.Config) {
 			lc.Append(fx.Hook{
 				OnStart: func(_ context.Context) error {
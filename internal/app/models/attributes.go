@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Attribute types, named like the jsonb_typeof of the values they allow.
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+)
+
+var (
+	ErrInvalidAttributes = errors.New("invalid attribute definitions")
+	ErrInvalidAttribute  = errors.New("invalid attribute")
+)
+
+// attributeName keeps names usable as query parameters, a name can not end
+// in _gte or _lte since those suffixes make a filter a range.
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// AttributeDefinition is an attribute the products of a category can
+// have, like brand or ram, and the type of its values.
+type AttributeDefinition struct {
+	Name       string `json:"name" example:"brand"`
+	Type       string `json:"type" enums:"string,number,boolean"`
+	ID         int64  `json:"id"`
+	CategoryID int64  `json:"category_id"`
+}
+
+// CheckDefinitions requires unique valid names and known types.
+func CheckDefinitions(defs []*AttributeDefinition) error {
+	names := make(map[string]bool, len(defs))
+	for _, d := range defs {
+		if !ValidAttributeName(d.Name) {
+			return fmt.Errorf("%w: invalid name %q", ErrInvalidAttributes, d.Name)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("%w: duplicate attribute %q", ErrInvalidAttributes, d.Name)
+		}
+		names[d.Name] = true
+
+		switch d.Type {
+		case AttributeString, AttributeNumber, AttributeBoolean:
+		default:
+			return fmt.Errorf("%w: attribute %q has an unknown type %q", ErrInvalidAttributes, d.Name, d.Type)
+		}
+	}
+	return nil
+}
+
+func ValidAttributeName(name string) bool {
+	return attributeName.MatchString(name) && !strings.HasSuffix(name, "_gte") && !strings.HasSuffix(name, "_lte")
+}
+
+// CheckAttributes reports the first value of attrs that is not defined in
+// defs or does not have the defined type. Values come decoded from JSON.
+func CheckAttributes(defs []*AttributeDefinition, attrs map[string]any) error {
+	types := make(map[string]string, len(defs))
+	for _, d := range defs {
+		types[d.Name] = d.Type
+	}
+	for name, value := range attrs {
+		typ, ok := types[name]
+		if !ok {
+			return fmt.Errorf("%w: %q is not defined for the category", ErrInvalidAttribute, name)
+		}
+		var valid bool
+		switch value.(type) {
+		case string:
+			valid = typ == AttributeString
+		case float64:
+			valid = typ == AttributeNumber
+		case bool:
+			valid = typ == AttributeBoolean
+		}
+		if !valid {
+			return fmt.Errorf("%w: %q has to be a %s", ErrInvalidAttribute, name, typ)
+		}
+	}
+	return nil
+}
+
+// AttributeFilter narrows a listing by one attribute. A product matches
+// when its value equals any of Values, and for numbers lies within the
+// bounds that are set.
+type AttributeFilter struct {
+	Min    *float64
+	Max    *float64
+	Name   string
+	Values []string
+}
+
+// Facet counts the products of a listing per value of one attribute, the
+// most common values first.
+type Facet struct {
+	Attribute string        `json:"attribute"`
+	Values    []*FacetValue `json:"values"`
+}
+
+type FacetValue struct {
+	Value any `json:"value" swaggertype:"string"`
+	Count int `json:"count"`
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionImport  = "import"
+)
+
+const (
+	AuditEntityProduct  = "product"
+	AuditEntityCategory = "category"
+	AuditEntityVariant  = "variant"
+	AuditEntityPrice    = "product_price"
+	// AuditEntityOptions entries are keyed by the product ID.
+	AuditEntityOptions = "product_options"
+	// AuditEntityTranslation entries are keyed by the product ID.
+	AuditEntityTranslation = "product_translation"
+	// AuditEntityAttributes entries are keyed by the category ID.
+	AuditEntityAttributes = "category_attributes"
+)
+
+// auditOmit lists the fields of an entity that are not part of its stored
+// state, they are dropped from snapshots.
+var auditOmit = map[string][]string{
+	// price follows the schedule, regular_price is the stored one
+	AuditEntityProduct: {"images", "category_name", "rank", "available", "price"},
+	AuditEntityVariant: {"effective_price"},
+}
+
+// auditIgnore lists fields that change on every write and would only add
+// noise to a diff.
+var auditIgnore = []string{"updated_at"}
+
+// AuditEntry is one catalog mutation. Before is empty for creations and
+// After for deletions, Changes holds every field that differs between the
+// two as {"field": {"from": ..., "to": ...}}.
+type AuditEntry struct {
+	CreatedAt time.Time       `json:"created_at"`
+	ActorID   *int64          `json:"actor_id"`
+	EntityID  *int64          `json:"entity_id"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	Before    json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After     json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	Changes   json.RawMessage `json:"changes" swaggertype:"object"`
+	ID        int64           `json:"id"`
+}
+
+// AuditChange is the value of a single field before and after a mutation.
+type AuditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// AuditFilter narrows the audit trail, zero fields match everything.
+// Entries come newest first, BeforeID continues after the last one seen.
+type AuditFilter struct {
+	From     time.Time
+	To       time.Time
+	EntityID *int64
+	ActorID  *int64
+	Entity   string
+	BeforeID int64
+	Limit    int
+}
+
+type AuditPage struct {
+	Items    []*AuditEntry `json:"items"`
+	BeforeID int64         `json:"before_id,omitempty"`
+	HasMore  bool          `json:"has_more"`
+}
+
+// NewAuditEntry snapshots before and after, either may be nil, and records
+// the caller in ctx as the actor.
+func NewAuditEntry(ctx context.Context, action, entity string, entityID *int64, before, after any) (*AuditEntry, error) {
+	from, err := auditSnapshot(entity, before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := auditSnapshot(entity, after)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &AuditEntry{ActorID: ActorID(ctx), EntityID: entityID, Action: action, Entity: entity}
+	if e.Before, err = marshalSnapshot(from); err != nil {
+		return nil, err
+	}
+	if e.After, err = marshalSnapshot(to); err != nil {
+		return nil, err
+	}
+	if e.Changes, err = json.Marshal(AuditDiff(from, to)); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// AuditDiff returns the fields whose values differ between two snapshots.
+func AuditDiff(before, after map[string]any) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+	for k, v := range before {
+		if w := after[k]; !reflect.DeepEqual(v, w) {
+			changes[k] = AuditChange{From: v, To: w}
+		}
+	}
+	for k, w := range after {
+		if _, ok := before[k]; !ok && w != nil {
+			changes[k] = AuditChange{To: w}
+		}
+	}
+	for _, k := range auditIgnore {
+		delete(changes, k)
+	}
+	return changes
+}
+
+func auditSnapshot(entity string, v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot map[string]any
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	for _, k := range auditOmit[entity] {
+		delete(snapshot, k)
+	}
+	return snapshot, nil
+}
+
+func marshalSnapshot(snapshot map[string]any) (json.RawMessage, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	return json.Marshal(snapshot)
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	BatchActionUpdate  = "update"
+	BatchActionStatus  = "status"
+	BatchActionDelete  = "delete"
+	BatchActionRestore = "restore"
+)
+
+// MaxBatchItems bounds the IDs of a batch summed over its operations.
+const MaxBatchItems = 1000
+
+var (
+	ErrInvalidBatch = errors.New("invalid batch")
+	// ErrBatchRolledBack marks the items of an all or nothing batch that
+	// were undone or never run because another item failed.
+	ErrBatchRolledBack = errors.New("rolled back with the batch")
+)
+
+// BatchOperation applies one action to every product in IDs. Fields are
+// merged into each product like PUT /products/{id} does for update,
+// Status is the target status for status.
+type BatchOperation struct {
+	Fields *Product `json:"fields,omitempty"`
+	Action string   `json:"action" enums:"update,status,delete,restore"`
+	Status string   `json:"status,omitempty" example:"active"`
+	IDs    []int64  `json:"ids"`
+}
+
+// Batch runs its operations in order. With AllOrNothing the whole batch
+// is one transaction and the first failure rolls it back, otherwise every
+// item commits on its own.
+type Batch struct {
+	Operations   []BatchOperation `json:"operations"`
+	AllOrNothing bool             `json:"all_or_nothing"`
+}
+
+// BatchResult is the outcome of one action on one product. Code is the
+// HTTP status the single product endpoint would have answered with.
+type BatchResult struct {
+	Err    error  `json:"-"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+	ID     int64  `json:"id"`
+	Code   int    `json:"code" example:"200"`
+}
+
+type BatchReport struct {
+	Results    []BatchResult `json:"results"`
+	Succeeded  int           `json:"succeeded"`
+	Failed     int           `json:"failed"`
+	RolledBack bool          `json:"rolled_back"`
+}
+
+// CheckBatch validates the shape of b. Identifiers are unique per product,
+// so an update can not set the SKU, GTIN or slug of many products at once.
+func CheckBatch(b *Batch) error {
+	if len(b.Operations) == 0 {
+		return fmt.Errorf("%w: no operations", ErrInvalidBatch)
+	}
+	items := 0
+	for i, op := range b.Operations {
+		if len(op.IDs) == 0 {
+			return fmt.Errorf("%w: operation %d has no ids", ErrInvalidBatch, i)
+		}
+		for _, id := range op.IDs {
+			if id <= 0 {
+				return fmt.Errorf("%w: operation %d has invalid id %d", ErrInvalidBatch, i, id)
+			}
+		}
+		if items += len(op.IDs); items > MaxBatchItems {
+			return fmt.Errorf("%w: more than %d items", ErrInvalidBatch, MaxBatchItems)
+		}
+
+		switch op.Action {
+		case BatchActionUpdate:
+			if op.Fields == nil {
+				return fmt.Errorf("%w: operation %d needs fields", ErrInvalidBatch, i)
+			}
+			if op.Fields.SKU != "" || op.Fields.GTIN != "" || op.Fields.Slug != "" {
+				return fmt.Errorf("%w: operation %d can not set sku, gtin or slug", ErrInvalidBatch, i)
+			}
+			if op.Fields.Version != 0 {
+				return fmt.Errorf("%w: operation %d can not set a version", ErrInvalidBatch, i)
+			}
+			if err := CheckDetails(op.Fields); err != nil {
+				return err
+			}
+			if op.Fields.ReorderThreshold != nil && *op.Fields.ReorderThreshold < 0 {
+				return ErrInvalidThreshold
+			}
+		case BatchActionStatus:
+			if !ValidStatus(op.Status) {
+				return fmt.Errorf("%w %q", ErrInvalidStatus, op.Status)
+			}
+		case BatchActionDelete, BatchActionRestore:
+		default:
+			return fmt.Errorf("%w: unknown action %q", ErrInvalidBatch, op.Action)
+		}
+		if op.Action != BatchActionUpdate && op.Fields != nil {
+			return fmt.Errorf("%w: fields are for update only", ErrInvalidBatch)
+		}
+		if op.Action != BatchActionStatus && op.Status != "" {
+			return fmt.Errorf("%w: status is for the status action only", ErrInvalidBatch)
+		}
+	}
+	return nil
+}
@@ -2,21 +2,92 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"slices"
+	"strings"
 	"time"
 )
 
+var (
+	ErrParentNotFound = errors.New("parent category not found")
+	ErrCategoryCycle  = errors.New("category can not be moved under itself or its subcategories")
+)
+
+// What happens to the products and subcategories of a deleted category:
+// block refuses to delete a category that still has any, reassign moves
+// them to another category and cascade deletes them along with it.
+const (
+	DeletePolicyBlock    = "block"
+	DeletePolicyReassign = "reassign"
+	DeletePolicyCascade  = "cascade"
+)
+
+// CategoryDelete is how a category is deleted, TargetID is the category
+// that receives the contents with DeletePolicyReassign. Version is the
+// version the category must still be at.
+type CategoryDelete struct {
+	Policy   string
+	TargetID int64
+	Version  int64
+}
+
+// Category is a node of the category tree, ParentID is empty for roots.
 type Category struct {
 	CreatedAt time.Time    `json:"created_at"`
 	UpdatedAt time.Time    `json:"updated_at"`
 	DeletedAt sql.NullTime `json:"deleted_at"`
+	ParentID  *int64       `json:"parent_id"`
 	Name      string       `json:"name"`
 	ID        int64        `json:"id"`
+	Version   int64        `json:"version"`
 }
 
+// CategoryNode is a category with its subcategories, both sorted by name.
+type CategoryNode struct {
+	Children []*CategoryNode `json:"children"`
+	Category
+}
+
+// CategoryStats sums the products of a category, with rollup the products
+// of its subcategories count as well. Prices in different currencies do
+// not add up, TotalValue holds one sum per currency.
 type CategoryStats struct {
-	CategoryName  string `json:"category_name"`
-	CategoryID    int64  `json:"category_id"`
-	ProductCount  int    `json:"product_count"`
-	TotalQuantity int    `json:"total_quantity"`
-	TotalValue    int    `json:"total_value"`
+	ParentID      *int64  `json:"parent_id"`
+	CategoryName  string  `json:"category_name"`
+	TotalValue    []Money `json:"total_value"`
+	CategoryID    int64   `json:"category_id"`
+	ProductCount  int     `json:"product_count"`
+	TotalQuantity int     `json:"total_quantity"`
+}
+
+// BuildCategoryTree links cats into trees. A category whose parent is not
+// in cats becomes a root, so a subtree comes back as a single root.
+func BuildCategoryTree(cats []*Category) []*CategoryNode {
+	nodes := make(map[int64]*CategoryNode, len(cats))
+	for _, c := range cats {
+		nodes[c.ID] = &CategoryNode{Category: *c, Children: []*CategoryNode{}}
+	}
+
+	roots := []*CategoryNode{}
+	for _, c := range cats {
+		node := nodes[c.ID]
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	sortCategoryNodes(roots)
+	return roots
+}
+
+func sortCategoryNodes(nodes []*CategoryNode) {
+	slices.SortFunc(nodes, func(a, b *CategoryNode) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for _, n := range nodes {
+		sortCategoryNodes(n.Children)
+	}
 }
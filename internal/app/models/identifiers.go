@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrInvalidSKU  = errors.New("invalid sku")
+	ErrInvalidGTIN = errors.New("invalid gtin")
+	ErrInvalidSlug = errors.New("invalid slug")
+)
+
+// maxSlugLength keeps generated slugs readable, the suffix of a taken
+// slug comes on top.
+const maxSlugLength = 80
+
+// sku keeps a SKU usable as a path segment of /products/by-sku/:sku.
+var sku = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// CheckSKU accepts up to 64 letters, digits, dots, dashes and underscores.
+func CheckSKU(s string) error {
+	if !sku.MatchString(s) {
+		return fmt.Errorf("%w %q: use up to 64 letters, digits, '.', '-' and '_'", ErrInvalidSKU, s)
+	}
+	return nil
+}
+
+// NormalizeGTIN checks the length and check digit of an EAN-8, UPC-A,
+// EAN-13 or GTIN-14 and returns it as GTIN-14, padded with leading zeros,
+// so the UPC-A and EAN-13 forms of the same barcode are equal.
+func NormalizeGTIN(code string) (string, error) {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return "", fmt.Errorf("%w %q: a gtin has 8, 12, 13 or 14 digits", ErrInvalidGTIN, code)
+	}
+	if !isDigits(code) {
+		return "", fmt.Errorf("%w %q: a gtin has only digits", ErrInvalidGTIN, code)
+	}
+
+	// from the right, digits before the check digit weigh 3, 1, 3, ...
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		d := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	if check := (10 - sum%10) % 10; strconv.Itoa(check) != code[len(code)-1:] {
+		return "", fmt.Errorf("%w %q: wrong check digit", ErrInvalidGTIN, code)
+	}
+	return strings.Repeat("0", 14-len(code)) + code, nil
+}
+
+// Slugify turns a title into a slug of lower case letters and digits
+// joined by dashes, "Smart Phone X" becomes "smart-phone-x". A title
+// without letters or digits gives "product".
+func Slugify(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, w := range words {
+		if b.Len() > 0 && b.Len()+1+len(w) > maxSlugLength {
+			break
+		}
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(w)
+	}
+	if b.Len() == 0 {
+		return "product"
+	}
+	return b.String()
+}
+
+// CheckSlug accepts what Slugify can produce, words of lower case
+// letters and digits joined by single dashes.
+func CheckSlug(slug string) error {
+	if slug == "" || Slugify(slug) != slug {
+		return fmt.Errorf("%w %q: use lower case letters and digits joined by '-'", ErrInvalidSlug, slug)
+	}
+	return nil
+}
+
+// NextSlug returns base, or base with the first free numeric suffix when
+// it is taken, "phone-2" after "phone". The result is marked taken.
+func NextSlug(base string, taken map[string]bool) string {
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	taken[slug] = true
+	return slug
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeGTIN(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		err  error
+	}{
+		{"96385074", "00000096385074", nil},
+		{"036000291452", "00036000291452", nil},
+		{"4006381333931", "04006381333931", nil},
+		{"10614141000415", "10614141000415", nil},
+		{"4006381333932", "", ErrInvalidGTIN},
+		{"400638133393", "", ErrInvalidGTIN},
+		{"40063813339a1", "", ErrInvalidGTIN},
+		{"", "", ErrInvalidGTIN},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeGTIN(tt.code)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.code)
+			continue
+		}
+		assert.NoError(t, err, tt.code)
+		assert.Equal(t, tt.want, got, tt.code)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "smart-phone-x-2", Slugify("  Smart Phone: X (2)!"))
+	assert.Equal(t, "телефон-pro", Slugify("Телефон Pro"))
+	assert.Equal(t, "product", Slugify("!!!"))
+	assert.LessOrEqual(t, len(Slugify(strings.Repeat("word ", 30))), maxSlugLength)
+}
+
+func TestCheckSlug(t *testing.T) {
+	assert.NoError(t, CheckSlug("smart-phone-2"))
+	for _, slug := range []string{"", "Smart", "smart--phone", "-smart", "smart phone"} {
+		assert.ErrorIs(t, CheckSlug(slug), ErrInvalidSlug, slug)
+	}
+}
+
+func TestNextSlug(t *testing.T) {
+	taken := map[string]bool{"phone": true, "phone-2": true}
+	assert.Equal(t, "phone-3", NextSlug("phone", taken))
+	assert.Equal(t, "phone-4", NextSlug("phone", taken))
+	assert.Equal(t, "tablet", NextSlug("tablet", taken))
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type ProductImage struct {
+	CreatedAt   time.Time `json:"created_at"`
+	Key         string    `json:"-"`
+	ContentType string    `json:"content_type"`
+	AltText     string    `json:"alt_text"`
+	Renditions  []string  `json:"renditions"`
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"product_id"`
+	Position    int       `json:"position"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Primary     bool      `json:"is_primary"`
+}
@@ -0,0 +1,26 @@
+package models
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+type ImportOptions struct {
+	Format       string
+	DryRun       bool
+	AllOrNothing bool
+}
+
+// ImportRowError points at a rejected row by its line in the uploaded file.
+type ImportRowError struct {
+	Error string `json:"error"`
+	Line  int    `json:"line"`
+}
+
+type ImportReport struct {
+	Errors   []ImportRowError `json:"errors"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Inserted int              `json:"inserted"`
+	DryRun   bool             `json:"dry_run"`
+}
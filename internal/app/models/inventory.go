@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EventLowStock is sent with a LowStock when a product drops below its
+// reorder threshold.
+const EventLowStock = "product.low_stock"
+
+const (
+	MovementReceipt    = "receipt"
+	MovementSale       = "sale"
+	MovementAdjustment = "adjustment"
+	MovementReturn     = "return"
+)
+
+var (
+	ErrInvalidMovement   = errors.New("invalid stock movement")
+	ErrInsufficientStock = errors.New("not enough stock")
+	ErrStockManaged      = errors.New("quantity can only be changed through stock movements")
+	ErrInvalidThreshold  = errors.New("reorder threshold can not be negative")
+)
+
+// StockMovement is one change of the stock of a product, or of one of its
+// variants when VariantID is set. Balance is the stock right after it.
+type StockMovement struct {
+	CreatedAt time.Time `json:"created_at"`
+	ActorID   *int64    `json:"actor_id"`
+	VariantID *int64    `json:"variant_id"`
+	Kind      string    `json:"kind"`
+	Reason    string    `json:"reason"`
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	Delta     int       `json:"delta"`
+	Balance   int       `json:"balance"`
+}
+
+// StockFilter selects the movements of a product, newest first. BeforeID
+// continues after the last movement seen.
+type StockFilter struct {
+	VariantID *int64
+	ProductID int64
+	BeforeID  int64
+	Limit     int
+}
+
+type StockPage struct {
+	Items    []*StockMovement `json:"items"`
+	BeforeID int64            `json:"before_id,omitempty"`
+	HasMore  bool             `json:"has_more"`
+}
+
+// StockLevel is the stock on hand of a product, summed up from its
+// movements. For a product with variants it is the sum of the variants.
+type StockLevel struct {
+	Variants  []*VariantStock `json:"variants,omitempty"`
+	ProductID int64           `json:"product_id"`
+	OnHand    int             `json:"on_hand"`
+}
+
+type VariantStock struct {
+	SKU       string `json:"sku"`
+	VariantID int64  `json:"variant_id"`
+	OnHand    int    `json:"on_hand"`
+}
+
+// MovementDelta turns the quantity of a movement into the change of stock.
+// Receipts and returns add a positive quantity, sales take it away and
+// adjustments apply a signed quantity as is.
+func MovementDelta(kind string, quantity int) (int, error) {
+	switch kind {
+	case MovementReceipt, MovementReturn:
+		if quantity <= 0 {
+			return 0, fmt.Errorf("%w: %s quantity must be positive", ErrInvalidMovement, kind)
+		}
+		return quantity, nil
+	case MovementSale:
+		if quantity <= 0 {
+			return 0, fmt.Errorf("%w: %s quantity must be positive", ErrInvalidMovement, kind)
+		}
+		return -quantity, nil
+	case MovementAdjustment:
+		if quantity == 0 {
+			return 0, fmt.Errorf("%w: adjustment quantity can not be zero", ErrInvalidMovement)
+		}
+		return quantity, nil
+	}
+	return 0, fmt.Errorf("%w: unknown kind %q", ErrInvalidMovement, kind)
+}
+
+// LowStock is a product whose stock on hand is below its reorder
+// threshold. Since is when it dropped there, it is unknown for products
+// that have not changed since the threshold was set.
+type LowStock struct {
+	Since     *time.Time `json:"since"`
+	Title     string     `json:"title"`
+	ProductID int64      `json:"product_id"`
+	OnHand    int        `json:"on_hand"`
+	Threshold int        `json:"reorder_threshold"`
+}
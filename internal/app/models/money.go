@@ -0,0 +1,201 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCurrency  = errors.New("invalid currency")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("currencies do not match")
+	ErrAmountOverflow   = errors.New("amount out of range")
+	ErrInvalidPrice     = errors.New("invalid price")
+)
+
+// currencies maps the active ISO 4217 codes to the number of digits of
+// their minor unit.
+var currencies = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2, "AWG": 2, "AZN": 2,
+	"BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0, "BMD": 2, "BND": 2, "BOB": 2, "BOV": 2,
+	"BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2, "BZD": 2, "CAD": 2, "CDF": 2, "CHE": 2, "CHF": 2,
+	"CHW": 2, "CLF": 4, "CLP": 0, "CNY": 2, "COP": 2, "COU": 2, "CRC": 2, "CUP": 2, "CVE": 2, "CZK": 2,
+	"DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2, "ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2,
+	"GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2,
+	"HTG": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2, "JOD": 3,
+	"JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0, "KWD": 3, "KYD": 2, "KZT": 2,
+	"LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2, "LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2,
+	"MMK": 2, "MNT": 2, "MOP": 2, "MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MXV": 2, "MYR": 2,
+	"MZN": 2, "NAD": 2, "NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2,
+	"PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2, "RUB": 2, "RWF": 0,
+	"SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2, "SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2,
+	"SSP": 2, "STN": 2, "SVC": 2, "SYP": 2, "SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2,
+	"TRY": 2, "TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "USN": 2, "UYI": 0, "UYU": 2,
+	"UYW": 4, "UZS": 2, "VED": 2, "VES": 2, "VND": 0, "VUV": 0, "WST": 2, "XAF": 0, "XCD": 2, "XOF": 0,
+	"XPF": 0, "YER": 2, "ZAR": 2, "ZMW": 2, "ZWL": 2,
+}
+
+// MinorDigits returns the number of digits of the minor unit of an ISO
+// 4217 currency, ok is false for an unknown code.
+func MinorDigits(currency string) (digits int, ok bool) {
+	digits, ok = currencies[currency]
+	return digits, ok
+}
+
+// Money is an amount in the minor units of its currency, 19.99 USD is
+// {Currency: "USD", Amount: 1999}. In JSON the amount is a decimal
+// string, {"amount": "19.99", "currency": "USD"}.
+type Money struct {
+	Currency string `json:"currency" example:"USD"`
+	Amount   int64  `json:"amount" swaggertype:"string" example:"19.99"`
+}
+
+// NewMoney checks that currency is a known ISO 4217 code.
+func NewMoney(amount int64, currency string) (Money, error) {
+	if _, ok := currencies[currency]; !ok {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidCurrency, currency)
+	}
+	return Money{Currency: currency, Amount: amount}, nil
+}
+
+// ParseMoney parses a decimal amount like "19.99" or "-5" in currency. It
+// accepts at most as many fractional digits as the currency has.
+func ParseMoney(amount, currency string) (Money, error) {
+	digits, ok := currencies[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidCurrency, currency)
+	}
+
+	s, negative := strings.CutPrefix(amount, "-")
+	whole, frac, dot := strings.Cut(s, ".")
+	if whole == "" || (dot && frac == "") || len(frac) > digits || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w %q for %s", ErrInvalidAmount, amount, currency)
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrAmountOverflow, amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Currency: currency, Amount: minor}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckPrice requires a known currency and a positive amount.
+func CheckPrice(m Money) error {
+	if _, ok := currencies[m.Currency]; !ok {
+		return fmt.Errorf("%w: unknown currency %q", ErrInvalidPrice, m.Currency)
+	}
+	if m.Amount <= 0 {
+		return fmt.Errorf("%w: price must be positive", ErrInvalidPrice)
+	}
+	return nil
+}
+
+// IsZero reports whether m is unset, an amount of zero with a currency is
+// a price of zero.
+func (m Money) IsZero() bool {
+	return m.Currency == "" && m.Amount == 0
+}
+
+// Decimal formats the amount with the digits of its currency, "19.99".
+func (m Money) Decimal() string {
+	digits := currencies[m.Currency]
+	if digits == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+
+	// the magnitude as uint64, math.MinInt64 has no positive int64
+	abs := uint64(m.Amount)
+	sign := ""
+	if m.Amount < 0 {
+		abs, sign = -abs, "-"
+	}
+	s := strconv.FormatUint(abs, 10)
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// Add returns m + o. Both need the same currency and the sum has to fit
+// into an int64.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Currency: m.Currency, Amount: m.Amount + o.Amount}, nil
+}
+
+// Sub returns m - o under the rules of Add.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrAmountOverflow
+	}
+	return m.Add(Money{Currency: o.Currency, Amount: -o.Amount})
+}
+
+// Mul returns m times n, for example the value of n units at price m.
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount == 0 || n == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	product := m.Amount * n
+	// the division misses only math.MinInt64 * -1, which wraps to itself
+	if product/n != m.Amount || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Currency: m.Currency, Amount: product}, nil
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+// UnmarshalJSON requires both the amount, as a decimal string, and the
+// currency, the currency decides how many fractional digits it may have.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("%w: want {\"amount\": \"19.99\", \"currency\": \"USD\"}", ErrInvalidAmount)
+	}
+	if v.Currency == "" {
+		return fmt.Errorf("%w: currency is required", ErrInvalidCurrency)
+	}
+	parsed, err := ParseMoney(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
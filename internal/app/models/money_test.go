@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"19.99", "USD", 1999, nil},
+		{"19.9", "USD", 1990, nil},
+		{"19", "USD", 1900, nil},
+		{"-0.05", "EUR", -5, nil},
+		{"1500", "JPY", 1500, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"1.5", "JPY", 0, ErrInvalidAmount},
+		{"19.999", "USD", 0, ErrInvalidAmount},
+		{"19.", "USD", 0, ErrInvalidAmount},
+		{".5", "USD", 0, ErrInvalidAmount},
+		{"1e3", "USD", 0, ErrInvalidAmount},
+		{"", "USD", 0, ErrInvalidAmount},
+		{"92233720368547758.08", "USD", 0, ErrAmountOverflow},
+		{"1", "usd", 0, ErrInvalidCurrency},
+		{"1", "XYZ", 0, ErrInvalidCurrency},
+	}
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.amount, tt.currency)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.amount)
+			continue
+		}
+		assert.NoError(t, err, tt.amount)
+		assert.Equal(t, Money{Currency: tt.currency, Amount: tt.want}, m, tt.amount)
+	}
+}
+
+func TestMoney_Decimal(t *testing.T) {
+	assert.Equal(t, "19.99", Money{Currency: "USD", Amount: 1999}.Decimal())
+	assert.Equal(t, "0.05", Money{Currency: "USD", Amount: 5}.Decimal())
+	assert.Equal(t, "-0.05", Money{Currency: "USD", Amount: -5}.Decimal())
+	assert.Equal(t, "1500", Money{Currency: "JPY", Amount: 1500}.Decimal())
+	assert.Equal(t, "1.234", Money{Currency: "KWD", Amount: 1234}.Decimal())
+	assert.Equal(t, "-92233720368547758.08", Money{Currency: "USD", Amount: math.MinInt64}.Decimal())
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	usd := func(amount int64) Money { return Money{Currency: "USD", Amount: amount} }
+
+	sum, err := usd(1999).Add(usd(1))
+	assert.NoError(t, err)
+	assert.Equal(t, usd(2000), sum)
+
+	diff, err := usd(5).Sub(usd(10))
+	assert.NoError(t, err)
+	assert.Equal(t, usd(-5), diff)
+
+	total, err := usd(1999).Mul(3)
+	assert.NoError(t, err)
+	assert.Equal(t, usd(5997), total)
+
+	_, err = usd(1).Add(Money{Currency: "EUR", Amount: 1})
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = usd(math.MaxInt64).Add(usd(1))
+	assert.ErrorIs(t, err, ErrAmountOverflow)
+	_, err = usd(math.MinInt64).Sub(usd(1))
+	assert.ErrorIs(t, err, ErrAmountOverflow)
+	_, err = usd(math.MaxInt64 / 2).Mul(3)
+	assert.ErrorIs(t, err, ErrAmountOverflow)
+	_, err = usd(math.MinInt64).Mul(-1)
+	assert.ErrorIs(t, err, ErrAmountOverflow)
+}
+
+func TestMoney_JSON(t *testing.T) {
+	data, err := json.Marshal(Money{Currency: "USD", Amount: 1999})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"19.99","currency":"USD"}`, string(data))
+
+	var m Money
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":"0.5","currency":"EUR"}`), &m))
+	assert.Equal(t, Money{Currency: "EUR", Amount: 50}, m)
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"1"}`), &m), ErrInvalidCurrency)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount":19.99,"currency":"USD"}`), &m), ErrInvalidAmount)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`1999`), &m), ErrInvalidAmount)
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+const (
+	SortByID        = "id"
+	SortByPrice     = "price"
+	SortByCreatedAt = "created_at"
+	SortByTitle     = "title"
+	SortByRelevance = "relevance"
+)
+
+type ProductSort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseProductSort parses values like "price" or "-created_at",
+// a leading minus means descending order. Relevance is always
+// ranked from the best match down.
+func ParseProductSort(s string) (ProductSort, error) {
+	switch s {
+	case "":
+		return ProductSort{Field: SortByID}, nil
+	case SortByRelevance:
+		return ProductSort{Field: SortByRelevance, Desc: true}, nil
+	}
+
+	field, desc := strings.CutPrefix(s, "-")
+	switch field {
+	case SortByID, SortByPrice, SortByCreatedAt, SortByTitle:
+		return ProductSort{Field: field, Desc: desc}, nil
+	default:
+		return ProductSort{}, ErrInvalidSort
+	}
+}
+
+func (s ProductSort) String() string {
+	if s.Desc && s.Field != SortByRelevance {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// ProductCursor points at the row a page starts after. Only the value
+// of the sorted column is set, the id breaks ties between equal values.
+type ProductCursor struct {
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	Sort      string    `json:"sort"`
+	Title     string    `json:"title,omitempty"`
+	ID        int64     `json:"id"`
+	Price     int64     `json:"price,omitempty"`
+	Rank      float32   `json:"rank,omitempty"`
+	Backward  bool      `json:"backward,omitempty"`
+}
+
+func NewProductCursor(p *Product, sort ProductSort, backward bool) *ProductCursor {
+	cur := &ProductCursor{Sort: sort.String(), ID: p.ID, Backward: backward}
+	switch sort.Field {
+	case SortByPrice:
+		cur.Price = p.Price.Amount
+	case SortByCreatedAt:
+		cur.CreatedAt = p.CreatedAt
+	case SortByTitle:
+		cur.Title = p.Title
+	case SortByRelevance:
+		cur.Rank = p.Rank
+	}
+	return cur
+}
+
+func (c *ProductCursor) Encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func DecodeProductCursor(s string) (*ProductCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cur ProductCursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cur.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cur, nil
+}
+
+// ProductPage is a page of a listing. Facets come with the first page
+// only, they count the whole listing and do not change while paging.
+type ProductPage struct {
+	NextCursor string     `json:"next_cursor,omitempty"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+	Items      []*Product `json:"items"`
+	Facets     []*Facet   `json:"facets,omitempty"`
+	HasMore    bool       `json:"has_more"`
+}
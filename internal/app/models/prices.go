@@ -0,0 +1,38 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid price schedule")
+
+// ProductPrice is a price of a product from ValidFrom until ValidTo. A
+// regular price has no ValidTo and lasts until the next regular price, a
+// scheduled one with a ValidTo, like a sale, wins over the regular price
+// while it lasts. Of two prices of the same kind the later one wins.
+type ProductPrice struct {
+	ValidFrom time.Time  `json:"valid_from"`
+	CreatedAt time.Time  `json:"created_at"`
+	ValidTo   *time.Time `json:"valid_to"`
+	ActorID   *int64     `json:"actor_id"`
+	Price     Money      `json:"price"`
+	ID        int64      `json:"id"`
+	ProductID int64      `json:"product_id"`
+}
+
+// CheckSchedule validates a price scheduled at now, it has to start in the
+// future and end after it starts.
+func CheckSchedule(pr *ProductPrice, now time.Time) error {
+	if err := CheckPrice(pr.Price); err != nil {
+		return err
+	}
+	if !pr.ValidFrom.After(now) {
+		return fmt.Errorf("%w: valid_from has to be in the future", ErrInvalidSchedule)
+	}
+	if pr.ValidTo != nil && !pr.ValidTo.After(pr.ValidFrom) {
+		return fmt.Errorf("%w: valid_to has to be after valid_from", ErrInvalidSchedule)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import "context"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Role   string
+	UserID int64
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller the auth middleware stored in ctx.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// ActorID is the user ID of the caller, nil outside of a request.
+func ActorID(ctx context.Context) *int64 {
+	p, ok := PrincipalFrom(ctx)
+	if !ok {
+		return nil
+	}
+	return &p.UserID
+}
@@ -1,24 +1,111 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
+var ErrInvalidDetails = errors.New("invalid product details")
+
+// Product is a catalog item. Its currency is fixed once it is created,
+// every later price and the prices of its variants are in it. Price is
+// the price in effect now, RegularPrice the one without scheduled prices,
+// create and update set the regular price. Attributes hold the values of
+// the attributes defined for the category, a nil map leaves them
+// unchanged on update. A nil ReorderThreshold leaves the stored one
+// unchanged on update, 0 turns low stock alerts off. SKU, GTIN and Slug
+// are unique among all products, GTIN is stored as GTIN-14 and an empty
+// Slug is generated from the title on create. WeightGrams and Dimensions
+// are those of the packed product, nil leaves them unchanged on update.
+// Locale is the locale of the translation Title and Description come
+// from, empty for the text the product was created with.
 type Product struct {
-	CreatedAt  time.Time `json:"created_at"`
-	Title      string    `json:"title"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	Image      string    `json:"image"`
-	DeletedAt  time.Time `json:"deleted_at"`
-	Status     string    `json:"status"`
-	ID         int64     `json:"id"`
-	CategoryID int       `json:"category_id"`
-	Price      int       `json:"price"`
-	Quantity   int       `json:"quantity"`
+	CreatedAt        time.Time       `json:"created_at"`
+	Title            string          `json:"title"`
+	SKU              string          `json:"sku"`
+	GTIN             string          `json:"gtin" example:"04006381333931"`
+	Slug             string          `json:"slug" example:"smart-phone-x"`
+	Description      string          `json:"description"`
+	Brand            string          `json:"brand"`
+	Locale           string          `json:"locale,omitempty"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+	Image            string          `json:"image"`
+	DeletedAt        time.Time       `json:"deleted_at"`
+	Status           string          `json:"status"`
+	Price            Money           `json:"price"`
+	RegularPrice     Money           `json:"regular_price"`
+	CategoryName     string          `json:"category_name,omitempty"`
+	ReorderThreshold *int            `json:"reorder_threshold"`
+	WeightGrams      *int            `json:"weight_grams" example:"180"`
+	Dimensions       *Dimensions     `json:"dimensions"`
+	Attributes       map[string]any  `json:"attributes"`
+	Images           []*ProductImage `json:"images,omitempty"`
+	ID               int64           `json:"id"`
+	Version          int64           `json:"version"`
+	CategoryID       int             `json:"category_id"`
+	Quantity         int             `json:"quantity"`
+	Available        int             `json:"available"`
+	Rank             float32         `json:"rank,omitempty"`
+}
+
+// Dimensions are the outer measures of a product in millimetres.
+type Dimensions struct {
+	LengthMM int `json:"length_mm" example:"160"`
+	WidthMM  int `json:"width_mm" example:"80"`
+	HeightMM int `json:"height_mm" example:"10"`
+}
+
+// CheckDetails validates the weight and dimensions p has, a weight can be
+// zero but every dimension has to be positive.
+func CheckDetails(p *Product) error {
+	if p.WeightGrams != nil && *p.WeightGrams < 0 {
+		return fmt.Errorf("%w: weight_grams can not be negative", ErrInvalidDetails)
+	}
+	if d := p.Dimensions; d != nil && (d.LengthMM <= 0 || d.WidthMM <= 0 || d.HeightMM <= 0) {
+		return fmt.Errorf("%w: length_mm, width_mm and height_mm have to be positive", ErrInvalidDetails)
+	}
+	return nil
 }
 
+// Localize replaces the text of p with translation tr, a translation
+// without a description keeps the original one.
+func (p *Product) Localize(tr *ProductTranslation) {
+	p.Title = tr.Title
+	if tr.Description != "" {
+		p.Description = tr.Description
+	}
+	p.Locale = tr.Locale
+}
+
+// ProductFilterSearch narrows a product listing. With IncludeDescendants
+// the category filters also match the subcategories of a category. The
+// price bounds are minor units of Currency.
 type ProductFilterSearch struct {
-	Search       string
-	CategoryName string
-	Status       string
-	PriceMin     int
-	PriceMax     int
+	Cursor             *ProductCursor
+	Search             string
+	CategoryName       string
+	Status             string
+	Currency           string
+	Attributes         []AttributeFilter
+	Sort               ProductSort
+	CategoryID         int64
+	PriceMin           int64
+	PriceMax           int64
+	Limit              int
+	IncludeDescendants bool
+}
+
+// CheckSort rejects an order the filters can not back: relevance needs a
+// search, and price needs a currency as amounts in different currencies
+// do not compare.
+func (fs *ProductFilterSearch) CheckSort() error {
+	switch {
+	case fs.Sort.Field == SortByRelevance && strings.TrimSpace(fs.Search) == "":
+		return fmt.Errorf("%w: relevance needs a search", ErrInvalidSort)
+	case fs.Sort.Field == SortByPrice && fs.Currency == "":
+		return fmt.Errorf("%w: price needs a currency", ErrInvalidSort)
+	}
+	return nil
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	ReservationActive    = "active"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+var (
+	ErrInvalidReservation = errors.New("invalid reservation")
+	ErrReservationClosed  = errors.New("reservation is no longer active")
+	ErrReservationExpired = errors.New("reservation has expired")
+)
+
+// Reservation holds Quantity units of a product, or of one of its variants
+// when VariantID is set, until ExpiresAt. An active reservation lowers the
+// available quantity, confirming it records a sale.
+type Reservation struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ActorID   *int64    `json:"actor_id"`
+	VariantID *int64    `json:"variant_id"`
+	Status    string    `json:"status" enums:"active,confirmed,released,expired"`
+	Reference string    `json:"reference"`
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StatusDraft        = "draft"
+	StatusActive       = "active"
+	StatusOutOfStock   = "out_of_stock"
+	StatusDiscontinued = "discontinued"
+	StatusArchived     = "archived"
+)
+
+var (
+	ErrInvalidStatus     = errors.New("invalid status")
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// statusTransitions is the product lifecycle, every status a product can
+// move to from a given status. The products_status_check constraint in the
+// database lists the same statuses.
+var statusTransitions = map[string][]string{
+	StatusDraft:        {StatusActive, StatusArchived},
+	StatusActive:       {StatusOutOfStock, StatusDiscontinued, StatusArchived},
+	StatusOutOfStock:   {StatusActive, StatusDiscontinued, StatusArchived},
+	StatusDiscontinued: {StatusArchived},
+	StatusArchived:     {StatusDraft},
+}
+
+func ValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// StatusTransitions returns the statuses a product in status can move to.
+func StatusTransitions(status string) []string {
+	return statusTransitions[status]
+}
+
+// CheckTransition reports why a product can not move from one status to
+// the other, the error wraps ErrInvalidStatus or ErrInvalidTransition.
+func CheckTransition(from, to string) error {
+	if !ValidStatus(to) {
+		return fmt.Errorf("%w %q", ErrInvalidStatus, to)
+	}
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	allowed := strings.Join(statusTransitions[from], ", ")
+	if allowed == "" {
+		allowed = "none"
+	}
+	return fmt.Errorf("%w: %s can not change to %s, allowed: %s", ErrInvalidTransition, from, to, allowed)
+}
+
+// StatusChange is one entry of a product's status history. ActorID is
+// empty for changes no user made, like the ones migrations did.
+type StatusChange struct {
+	ChangedAt  time.Time `json:"changed_at"`
+	ActorID    *int64    `json:"actor_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ID         int64     `json:"id"`
+	ProductID  int64     `json:"product_id"`
+}
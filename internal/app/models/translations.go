@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidLocale      = errors.New("invalid locale")
+	ErrInvalidTranslation = errors.New("invalid translation")
+)
+
+// maxPreferredLocales caps the tags read from an Accept-Language header.
+const maxPreferredLocales = 10
+
+// locale matches a language tag made of a language, an optional script
+// and an optional region, "en", "zh-Hant", "pt-BR" or "es-419".
+var locale = regexp.MustCompile(`^([A-Za-z]{2,3})(?:[-_]([A-Za-z]{4}))?(?:[-_]([A-Za-z]{2}|[0-9]{3}))?$`)
+
+// ProductTranslation is the title and description of a product in a
+// locale. An empty Description falls back to the original description.
+type ProductTranslation struct {
+	UpdatedAt   time.Time `json:"updated_at"`
+	Locale      string    `json:"locale" example:"de-CH"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	ProductID   int64     `json:"product_id"`
+}
+
+// CheckTranslation normalizes the locale of tr and requires a title.
+func CheckTranslation(tr *ProductTranslation) error {
+	loc, err := NormalizeLocale(tr.Locale)
+	if err != nil {
+		return err
+	}
+	tr.Locale = loc
+	tr.Title = strings.TrimSpace(tr.Title)
+	if tr.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidTranslation)
+	}
+	return nil
+}
+
+// NormalizeLocale returns tag in its canonical case, "pt_br" becomes
+// "pt-BR" and "zh-hant" becomes "zh-Hant".
+func NormalizeLocale(tag string) (string, error) {
+	m := locale.FindStringSubmatch(tag)
+	if m == nil {
+		return "", fmt.Errorf("%w %q: use a language with an optional script and region, like pt-BR", ErrInvalidLocale, tag)
+	}
+	loc := strings.ToLower(m[1])
+	if m[2] != "" {
+		loc += "-" + strings.ToUpper(m[2][:1]) + strings.ToLower(m[2][1:])
+	}
+	if m[3] != "" {
+		loc += "-" + strings.ToUpper(m[3])
+	}
+	return loc, nil
+}
+
+// LocaleChain turns an Accept-Language header into the locales to try in
+// order. The tags go by their quality, each followed by the tags it falls
+// back to, "de-CH, fr;q=0.8" gives de-CH, de, fr. Invalid tags, the
+// wildcard and tags with q=0 are skipped.
+func LocaleChain(header string) []string {
+	type pref struct {
+		loc string
+		q   float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		loc, err := NormalizeLocale(strings.TrimSpace(tag))
+		if err != nil || q <= 0 {
+			continue
+		}
+		prefs = append(prefs, pref{loc: loc, q: q})
+		if len(prefs) == maxPreferredLocales {
+			break
+		}
+	}
+	slices.SortStableFunc(prefs, func(a, b pref) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	var chain []string
+	for _, p := range prefs {
+		for loc := p.loc; ; {
+			if !slices.Contains(chain, loc) {
+				chain = append(chain, loc)
+			}
+			i := strings.LastIndexByte(loc, '-')
+			if i < 0 {
+				break
+			}
+			loc = loc[:i]
+		}
+	}
+	return chain
+}
+
+type localesKey struct{}
+
+// WithLocales stores the locales a request prefers, most preferred first.
+func WithLocales(ctx context.Context, locales []string) context.Context {
+	return context.WithValue(ctx, localesKey{}, locales)
+}
+
+// LocalesFrom returns the locales stored in ctx, nil when the caller
+// asked for none.
+func LocalesFrom(ctx context.Context) []string {
+	locales, _ := ctx.Value(localesKey{}).([]string)
+	return locales
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		err  error
+	}{
+		{"en", "en", nil},
+		{"pt_br", "pt-BR", nil},
+		{"zh-hant-tw", "zh-Hant-TW", nil},
+		{"es-419", "es-419", nil},
+		{"english", "", ErrInvalidLocale},
+		{"en-", "", ErrInvalidLocale},
+		{"*", "", ErrInvalidLocale},
+		{"", "", ErrInvalidLocale},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeLocale(tt.tag)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.tag)
+			continue
+		}
+		assert.NoError(t, err, tt.tag)
+		assert.Equal(t, tt.want, got, tt.tag)
+	}
+}
+
+func TestLocaleChain(t *testing.T) {
+	assert.Equal(t, []string{"de-CH", "de", "fr"}, LocaleChain("de-CH, fr;q=0.8"))
+	assert.Equal(t, []string{"fr", "en-GB", "en"}, LocaleChain("en-GB;q=0.5, fr, en;q=0.4"))
+	assert.Equal(t, []string{"zh-Hant-TW", "zh-Hant", "zh"}, LocaleChain("zh-hant-tw"))
+	assert.Equal(t, []string{"ru"}, LocaleChain("*, ru;q=0.9, de;q=0, x;q=1"))
+	assert.Nil(t, LocaleChain(""))
+}
+
+func TestCheckTranslation(t *testing.T) {
+	tr := &ProductTranslation{Locale: "de_ch", Title: " Handy "}
+	assert.NoError(t, CheckTranslation(tr))
+	assert.Equal(t, "de-CH", tr.Locale)
+	assert.Equal(t, "Handy", tr.Title)
+
+	assert.ErrorIs(t, CheckTranslation(&ProductTranslation{Locale: "de", Title: " "}), ErrInvalidTranslation)
+	assert.ErrorIs(t, CheckTranslation(&ProductTranslation{Locale: "deutsch", Title: "Handy"}), ErrInvalidLocale)
+}
+
+func TestProduct_Localize(t *testing.T) {
+	p := &Product{Title: "Phone", Description: "A phone"}
+	p.Localize(&ProductTranslation{Locale: "de", Title: "Handy"})
+	assert.Equal(t, &Product{Title: "Handy", Description: "A phone", Locale: "de"}, p)
+}
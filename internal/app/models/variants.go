@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidOptions = errors.New("invalid product options")
+	ErrInvalidVariant = errors.New("invalid variant")
+)
+
+// ProductOption is a dimension the variants of a product differ in, like
+// size or color, with the values a variant can pick in display order.
+type ProductOption struct {
+	Name      string   `json:"name"`
+	Values    []string `json:"values"`
+	ID        int64    `json:"id"`
+	ProductID int64    `json:"product_id"`
+	Position  int      `json:"position"`
+}
+
+// ProductVariant is one combination of option values with its own SKU and
+// stock. A nil Price inherits the price of the product, EffectivePrice is
+// what the variant sells for either way. Both are in the product currency.
+type ProductVariant struct {
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	Options        map[string]string `json:"options"`
+	Price          *Money            `json:"price"`
+	SKU            string            `json:"sku"`
+	EffectivePrice Money             `json:"effective_price"`
+	ID             int64             `json:"id"`
+	ProductID      int64             `json:"product_id"`
+	Version        int64             `json:"version"`
+	Quantity       int               `json:"quantity"`
+}
+
+// CheckOptions requires every option to have a unique name and a non-empty
+// list of unique values.
+func CheckOptions(opts []*ProductOption) error {
+	names := make(map[string]bool, len(opts))
+	for _, opt := range opts {
+		if opt.Name == "" {
+			return fmt.Errorf("%w: option without a name", ErrInvalidOptions)
+		}
+		if names[opt.Name] {
+			return fmt.Errorf("%w: duplicate option %q", ErrInvalidOptions, opt.Name)
+		}
+		names[opt.Name] = true
+
+		if len(opt.Values) == 0 {
+			return fmt.Errorf("%w: option %q has no values", ErrInvalidOptions, opt.Name)
+		}
+		for i, value := range opt.Values {
+			if value == "" || slices.Contains(opt.Values[:i], value) {
+				return fmt.Errorf("%w: option %q has an empty or duplicate value", ErrInvalidOptions, opt.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckVariant reports why v does not fit the options of its product. A
+// variant needs a SKU and exactly one of the defined values per option.
+func CheckVariant(opts []*ProductOption, v *ProductVariant) error {
+	if v.SKU == "" {
+		return fmt.Errorf("%w: sku is required", ErrInvalidVariant)
+	}
+	if v.Quantity < 0 {
+		return fmt.Errorf("%w: quantity can not be negative", ErrInvalidVariant)
+	}
+	if v.Price != nil && v.Price.Amount < 0 {
+		return fmt.Errorf("%w: price can not be negative", ErrInvalidVariant)
+	}
+	if len(v.Options) != len(opts) {
+		return fmt.Errorf("%w: a value is required for each of the %d options", ErrInvalidVariant, len(opts))
+	}
+	for _, opt := range opts {
+		value, ok := v.Options[opt.Name]
+		if !ok {
+			return fmt.Errorf("%w: missing option %q", ErrInvalidVariant, opt.Name)
+		}
+		if !slices.Contains(opt.Values, value) {
+			return fmt.Errorf("%w: %q is not a value of option %q", ErrInvalidVariant, value, opt.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+	"strconv"
+	"strings"
+
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	Record(ctx context.Context, e *models.AuditEntry) error
+	List(ctx context.Context, f *models.AuditFilter) ([]*models.AuditEntry, error)
+}
+
+type Params struct {
+	fx.In
+
+	Pool postgres.Pool `name:"app_postgres"`
+}
+
+type repository struct {
+	pool postgres.Pool `name:"app_postgres"`
+}
+
+func New(p Params) Repository {
+	return &repository{pool: p.Pool}
+}
+
+// Record stores e in the transaction of ctx, so the entry is kept only if
+// the mutation it describes is.
+func (r *repository) Record(ctx context.Context, e *models.AuditEntry) error {
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	INSERT INTO audit_log (actor_id, action, entity, entity_id, before, after, changes)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at
+`, e.ActorID, e.Action, e.Entity, e.EntityID, jsonb(e.Before), jsonb(e.After), jsonb(e.Changes)).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return errors.New("failed to record audit entry: " + err.Error() + "")
+	}
+	return nil
+}
+
+// List returns the entries matching f, newest first.
+func (r *repository) List(ctx context.Context, f *models.AuditFilter) ([]*models.AuditEntry, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if f.Entity != "" {
+		add("entity = ?", f.Entity)
+	}
+	if f.EntityID != nil {
+		add("entity_id = ?", *f.EntityID)
+	}
+	if f.ActorID != nil {
+		add("actor_id = ?", *f.ActorID)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < ?", f.To)
+	}
+	if f.BeforeID > 0 {
+		add("id < ?", f.BeforeID)
+	}
+
+	query := `SELECT id, actor_id, action, entity, entity_id, before, after, changes, created_at FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit)
+	query += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New("failed to get audit log: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		var (
+			e                     models.AuditEntry
+			before, after, change []byte
+		)
+		if err = rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.Entity, &e.EntityID, &before, &after, &change,
+			&e.CreatedAt); err != nil {
+			return nil, errors.New("failed to scan audit entry: " + err.Error() + "")
+		}
+		e.Before, e.After, e.Changes = before, after, change
+		entries = append(entries, &e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get audit log: " + err.Error() + "")
+	}
+	return entries, nil
+}
+
+// jsonb keeps an empty document NULL instead of sending invalid JSON.
+func jsonb(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRepository_Record(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
+
+		actor := int64(3)
+		entityID := int64(9)
+		e := &models.AuditEntry{
+			ActorID: &actor, EntityID: &entityID, Action: models.AuditActionCreate, Entity: models.AuditEntityProduct,
+			After: []byte(`{"title":"Phone"}`), Changes: []byte(`{}`),
+		}
+
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO audit_log")
+		}), []any{&actor, "create", "product", &entityID, nil, `{"title":"Phone"}`, `{}`}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int64) = 42
+		}).Return(nil)
+
+		repo := New(Params{Pool: mockPool})
+		err := repo.Record(context.Background(), e)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), e.ID)
+	})
+	t.Run("error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(errors.New("db error"))
+
+		repo := New(Params{Pool: mockPool})
+		err := repo.Record(context.Background(), &models.AuditEntry{})
+
+		assert.ErrorContains(t, err, "failed to record audit entry")
+	})
+}
+
+func TestRepository_List(t *testing.T) {
+	t.Run("filters", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		entityID := int64(9)
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		f := &models.AuditFilter{Entity: models.AuditEntityProduct, EntityID: &entityID, From: from, BeforeID: 100, Limit: 21}
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHERE entity = $1 AND entity_id = $2 AND created_at >= $3 AND id < $4") &&
+				strings.Contains(sql, "ORDER BY id DESC LIMIT $5")
+		}), []any{"product", int64(9), from, int64(100), 21}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(2).(*string) = models.AuditActionUpdate
+			*args.Get(7).(*[]byte) = []byte(`{"price":{"from":1,"to":2}}`)
+		}).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		repo := New(Params{Pool: mockPool})
+		entries, err := repo.List(context.Background(), f)
+
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, models.AuditActionUpdate, entries[0].Action)
+			assert.JSONEq(t, `{"price":{"from":1,"to":2}}`, string(entries[0].Changes))
+		}
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return !strings.Contains(sql, "WHERE")
+		}), []any{20}).Return((*postgres.MockRow)(nil), errors.New("db error"))
+
+		repo := New(Params{Pool: mockPool})
+		_, err := repo.List(context.Background(), &models.AuditFilter{Limit: 20})
+
+		assert.ErrorContains(t, err, "failed to get audit log")
+	})
+}
@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Record(ctx context.Context, e *models.AuditEntry) error {
+	args := m.Called(ctx, e)
+	return args.Error(0)
+}
+
+func (m *MockRepo) List(ctx context.Context, f *models.AuditFilter) ([]*models.AuditEntry, error) {
+	args := m.Called(ctx, f)
+	if entries, ok := args.Get(0).([]*models.AuditEntry); ok {
+		return entries, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
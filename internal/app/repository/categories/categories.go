@@ -3,20 +3,46 @@ package categories
 import (
 	"context"
 	"errors"
+	"maps"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/products"
 	"prodigo/pkg/db/postgres"
+	"slices"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/fx"
 )
 
 type Repository interface {
 	CreateCategory(ctx context.Context, c *models.Category) error
+	GetCategoryByID(ctx context.Context, id int64) (*models.Category, error)
 	UpdateCategory(ctx context.Context, c *models.Category) error
 	GetAllCategories(ctx context.Context) ([]*models.Category, error)
-	DeleteCategory(ctx context.Context, id int64) error
-	CategoryStatistics(ctx context.Context) ([]*models.CategoryStats, error)
+	GetSubtree(ctx context.Context, id int64) ([]*models.Category, error)
+	GetPath(ctx context.Context, id int64) ([]*models.Category, error)
+	GetDeletedCategory(ctx context.Context, id int64) (*models.Category, error)
+	CategoryUsage(ctx context.Context, id int64) (products, children int64, err error)
+	ReassignContents(ctx context.Context, from, to int64) (int64, error)
+	ArchiveContents(ctx context.Context, id int64) (int64, error)
+	DeleteCategory(ctx context.Context, id, version int64) error
+	RestoreCategory(ctx context.Context, id, version int64, withProducts bool) (int64, error)
+	CategoryStatistics(ctx context.Context, rollup bool) ([]*models.CategoryStats, error)
+	ListAttributes(ctx context.Context, id int64) ([]*models.AttributeDefinition, error)
+	ReplaceAttributes(ctx context.Context, id int64, defs []*models.AttributeDefinition) error
+	AttributeMismatches(ctx context.Context, id int64, types map[string]string) (int64, error)
 }
 
+var (
+	ErrNotFound        = errors.New("category not found or deleted")
+	ErrVersionConflict = errors.New("category version conflict")
+	ErrDuplicateName   = errors.New("category name is already in use under the parent")
+)
+
+const uniqueViolation = "23505"
+
+const categoryColumns = `id, name, parent_id, created_at, updated_at, deleted_at, version`
+
 type Params struct {
 	fx.In
 
@@ -32,96 +58,483 @@ func New(p Params) Repository {
 }
 
 func (r *repository) CreateCategory(ctx context.Context, c *models.Category) error {
-	_, err := r.pool.Exec(ctx,
-		`INSERT INTO categories (name) 
-		 VALUES ($1) 
-		 RETURNING id, created_at, updated_at`,
-		c.Name,
-	)
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`INSERT INTO categories (name, parent_id) 
+		 VALUES ($1, $2) 
+		 RETURNING id, created_at, updated_at, version`,
+		c.Name, c.ParentID,
+	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt, &c.Version)
 	if err != nil {
+		if nameTaken(err) {
+			return ErrDuplicateName
+		}
 		return errors.New("failed to create category")
 	}
 	return nil
 }
 
+func (r *repository) GetCategoryByID(ctx context.Context, id int64) (*models.Category, error) {
+	var c models.Category
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`SELECT `+categoryColumns+`
+		 FROM categories
+		 WHERE id = $1 AND deleted_at IS NULL`,
+		id,
+	).Scan(&c.ID, &c.Name, &c.ParentID, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt, &c.Version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to get category: " + err.Error() + "")
+	}
+	return &c, nil
+}
+
+// UpdateCategory writes c only if the stored version still equals c.Version,
+// on success c.Version holds the new version.
 func (r *repository) UpdateCategory(ctx context.Context, c *models.Category) error {
-	cmd, err := r.pool.Exec(ctx,
-		`UPDATE categories 
-		 SET name = $1, updated_at = NOW() 
-		 WHERE id = $2 AND deleted_at IS NULL`,
-		c.Name, c.ID,
+	var (
+		version *int64
+		exists  bool
 	)
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`WITH upd AS (
+			UPDATE categories
+			SET name = $1, parent_id = $4, version = version + 1, updated_at = NOW()
+			WHERE id = $2 AND version = $3 AND deleted_at IS NULL
+			RETURNING version
+		 )
+		 SELECT (SELECT version FROM upd), EXISTS (SELECT 1 FROM categories WHERE id = $2 AND deleted_at IS NULL)`,
+		c.Name, c.ID, c.Version, c.ParentID,
+	).Scan(&version, &exists)
 	if err != nil {
+		if nameTaken(err) {
+			return ErrDuplicateName
+		}
 		return errors.New("failed to update category")
 	}
-	if cmd.RowsAffected() == 0 {
-		return errors.New("category not found or deleted")
+	if version == nil {
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
 	}
+	c.Version = *version
 	return nil
 }
 
 func (r *repository) GetAllCategories(ctx context.Context) ([]*models.Category, error) {
-	rows, err := r.pool.Query(ctx,
-		`SELECT id, name, created_at, updated_at, deleted_at 
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx,
+		`SELECT `+categoryColumns+`
 		 FROM categories 
 		 WHERE deleted_at IS NULL`,
 	)
 	if err != nil {
 		return nil, errors.New("failed to get all categories")
 	}
-	defer rows.Close()
+	return scanCategories(rows)
+}
 
-	var categories []*models.Category
-	for rows.Next() {
-		var c models.Category
-		if err = rows.Scan(&c.ID, &c.Name, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt); err != nil {
-			return nil, errors.New("failed to scan category")
-		}
-		categories = append(categories, &c)
+// GetSubtree returns the category id and everything below it, parents
+// before their children.
+func (r *repository) GetSubtree(ctx context.Context, id int64) ([]*models.Category, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx,
+		`WITH RECURSIVE subtree AS (
+			SELECT id, 0 AS depth FROM categories WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.id, s.depth + 1
+			FROM categories c
+			JOIN subtree s ON c.parent_id = s.id
+			WHERE c.deleted_at IS NULL
+		 )
+		 SELECT `+categoryColumns+`
+		 FROM categories
+		 JOIN subtree USING (id)
+		 ORDER BY subtree.depth, name`,
+		id,
+	)
+	if err != nil {
+		return nil, errors.New("failed to get category subtree: " + err.Error() + "")
 	}
-	return categories, nil
+	cats, err := scanCategories(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(cats) == 0 {
+		return nil, ErrNotFound
+	}
+	return cats, nil
 }
 
-func (r *repository) DeleteCategory(ctx context.Context, id int64) error {
-	cmd, err := r.pool.Exec(ctx,
-		`UPDATE categories 
-		 SET deleted_at = NOW(), updated_at = NOW() 
-		 WHERE id = $1 AND deleted_at IS NULL`,
+// GetPath returns the ancestors of category id from the root down,
+// ending with the category itself.
+func (r *repository) GetPath(ctx context.Context, id int64) ([]*models.Category, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx,
+		`WITH RECURSIVE path AS (
+			SELECT id, parent_id, 0 AS depth FROM categories WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.id, c.parent_id, p.depth + 1
+			FROM categories c
+			JOIN path p ON c.id = p.parent_id
+		 )
+		 SELECT `+categoryColumns+`
+		 FROM categories
+		 JOIN path USING (id)
+		 ORDER BY path.depth DESC`,
 		id,
 	)
+	if err != nil {
+		return nil, errors.New("failed to get category path: " + err.Error() + "")
+	}
+	cats, err := scanCategories(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(cats) == 0 {
+		return nil, ErrNotFound
+	}
+	return cats, nil
+}
+
+// DeleteCategory soft-deletes category id while it still is at version.
+func (r *repository) DeleteCategory(ctx context.Context, id, version int64) error {
+	var deleted, exists bool
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`WITH dlt AS (
+			UPDATE categories
+			SET deleted_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+			RETURNING id
+		 )
+		 SELECT EXISTS (SELECT 1 FROM dlt), EXISTS (SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NULL)`,
+		id, version,
+	).Scan(&deleted, &exists)
 	if err != nil {
 		return errors.New("failed to delete/archive category")
 	}
-	if cmd.RowsAffected() == 0 {
-		return errors.New("category not found or deleted")
+	if !deleted {
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
 	}
 	return nil
 }
 
-func (r *repository) CategoryStatistics(ctx context.Context) ([]*models.CategoryStats, error) {
-	rows, err := r.pool.Query(ctx, `
+// GetDeletedCategory returns category id only while it is soft-deleted.
+func (r *repository) GetDeletedCategory(ctx context.Context, id int64) (*models.Category, error) {
+	var c models.Category
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`SELECT `+categoryColumns+`
+		 FROM categories
+		 WHERE id = $1 AND deleted_at IS NOT NULL`,
+		id,
+	).Scan(&c.ID, &c.Name, &c.ParentID, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt, &c.Version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to get category: " + err.Error() + "")
+	}
+	return &c, nil
+}
+
+// CategoryUsage counts the live products, archived ones left out, and the
+// subcategories a category holds.
+func (r *repository) CategoryUsage(ctx context.Context, id int64) (products, children int64, err error) {
+	err = postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`SELECT
+			(SELECT COUNT(*) FROM products WHERE category_id = $1 AND deleted_at IS NULL AND status <> $2),
+			(SELECT COUNT(*) FROM categories WHERE parent_id = $1 AND deleted_at IS NULL)`,
+		id, models.StatusArchived,
+	).Scan(&products, &children)
+	if err != nil {
+		return 0, 0, errors.New("failed to count category contents: " + err.Error() + "")
+	}
+	return products, children, nil
+}
+
+// ReassignContents moves the products and the subcategories of from into
+// to and returns how many products moved.
+func (r *repository) ReassignContents(ctx context.Context, from, to int64) (int64, error) {
+	var moved int64
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`WITH cats AS (
+			UPDATE categories
+			SET parent_id = $2, version = version + 1, updated_at = NOW()
+			WHERE parent_id = $1 AND deleted_at IS NULL
+		 ), prods AS (
+			UPDATE products
+			SET category_id = $2, version = version + 1, updated_at = NOW()
+			WHERE category_id = $1 AND deleted_at IS NULL
+			RETURNING id
+		 )
+		 SELECT COUNT(*) FROM prods`,
+		from, to,
+	).Scan(&moved)
+	if err != nil {
+		return 0, errors.New("failed to reassign category contents: " + err.Error() + "")
+	}
+	return moved, nil
+}
+
+// ArchiveContents soft-deletes every subcategory of id, marking them as
+// deleted with id, and archives the products of the whole subtree that are
+// not archived yet, remembering the status they had. It returns how many
+// products were archived.
+func (r *repository) ArchiveContents(ctx context.Context, id int64) (int64, error) {
+	var archived int64
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id
+			FROM categories c
+			JOIN subtree s ON c.parent_id = s.id
+			WHERE c.deleted_at IS NULL
+		 ), cats AS (
+			UPDATE categories
+			SET deleted_at = NOW(), updated_at = NOW(), deleted_with_category = $1
+			WHERE id IN (SELECT id FROM subtree) AND id <> $1
+		 ), old AS (
+			SELECT id, status FROM products
+			WHERE category_id IN (SELECT id FROM subtree) AND deleted_at IS NULL AND status <> $2
+		 ), prods AS (
+			UPDATE products p
+			SET status = $2, archived_with_category = $1,
+				version = version + 1, updated_at = NOW()
+			FROM old
+			WHERE p.id = old.id
+			RETURNING p.id
+		 ), history AS (
+			INSERT INTO product_status_history (product_id, from_status, to_status, actor_id)
+			SELECT id, status, $2, $3 FROM old
+		 )
+		 SELECT COUNT(*) FROM prods`,
+		id, models.StatusArchived, models.ActorID(ctx),
+	).Scan(&archived)
+	if err != nil {
+		return 0, errors.New("failed to archive category contents: " + err.Error() + "")
+	}
+	return archived, nil
+}
+
+// RestoreCategory undeletes category id, while it still is at version,
+// together with the subcategories deleted with it. withProducts also moves
+// the products archived with it and still archived back to draft, the only
+// status the lifecycle allows after archived. It returns how many products
+// were restored.
+func (r *repository) RestoreCategory(ctx context.Context, id, version int64, withProducts bool) (int64, error) {
+	var (
+		restored, exists bool
+		products         int64
+	)
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`WITH cat AS (
+			UPDATE categories
+			SET deleted_at = NULL, deleted_with_category = NULL, version = version + 1, updated_at = NOW()
+			WHERE id = $1 AND version = $5 AND deleted_at IS NOT NULL
+			RETURNING id
+		 ), subs AS (
+			UPDATE categories
+			SET deleted_at = NULL, deleted_with_category = NULL, updated_at = NOW()
+			WHERE deleted_with_category = $1 AND EXISTS (SELECT 1 FROM cat)
+		 ), prods AS (
+			UPDATE products
+			SET status = $6, archived_with_category = NULL, version = version + 1, updated_at = NOW()
+			WHERE archived_with_category = $1 AND status = $3 AND deleted_at IS NULL AND $2
+				AND EXISTS (SELECT 1 FROM cat)
+			RETURNING id, status
+		 ), history AS (
+			INSERT INTO product_status_history (product_id, from_status, to_status, actor_id)
+			SELECT id, $3, status, $4 FROM prods
+		 )
+		 SELECT EXISTS (SELECT 1 FROM cat), (SELECT COUNT(*) FROM prods),
+			EXISTS (SELECT 1 FROM categories WHERE id = $1 AND deleted_at IS NOT NULL)`,
+		id, withProducts, models.StatusArchived, models.ActorID(ctx), version, models.StatusDraft,
+	).Scan(&restored, &products, &exists)
+	if err != nil {
+		return 0, errors.New("failed to restore category: " + err.Error() + "")
+	}
+	if !restored {
+		if !exists {
+			return 0, ErrNotFound
+		}
+		return 0, ErrVersionConflict
+	}
+	return products, nil
+}
+
+// CategoryStatistics sums the products of every category, with rollup
+// each category also counts the products of all its subcategories. A
+// product with variants counts the stock and prices of its variants, a
+// variant without a price of its own counts the current price of the
+// product. The value is summed per currency, the database fails the query
+// rather than let a sum overflow.
+func (r *repository) CategoryStatistics(ctx context.Context, rollup bool) ([]*models.CategoryStats, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id AS root_id, id FROM categories WHERE deleted_at IS NULL
+			UNION ALL
+			SELECT t.root_id, c.id
+			FROM tree t
+			JOIN categories c ON c.parent_id = t.id AND c.deleted_at IS NULL
+			WHERE $1
+		)
 		SELECT 
-			c.id, c.name,
+			c.id, c.name, c.parent_id, p.currency,
 			COUNT(p.id),
-			SUM(p.quantity),
-			SUM(p.price * p.quantity)
+			COALESCE(SUM(COALESCE(v.quantity, p.quantity)), 0),
+			COALESCE(SUM(COALESCE(v.value, cp.price * p.quantity)), 0)::BIGINT
 		FROM categories AS c
-		LEFT JOIN products AS p ON p.category_id = c.id AND p.deleted_at IS NULL
+		JOIN tree AS t ON t.root_id = c.id
+		LEFT JOIN products AS p ON p.category_id = t.id AND p.deleted_at IS NULL
+		LEFT JOIN LATERAL (SELECT `+products.CurrentPrice+` AS price) AS cp ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT SUM(pv.quantity) AS quantity, SUM(pv.quantity * COALESCE(pv.price, cp.price)) AS value
+			FROM product_variants AS pv
+			WHERE pv.product_id = p.id AND pv.deleted_at IS NULL
+		) AS v ON TRUE
 		WHERE c.deleted_at IS NULL
-		GROUP BY c.id, c.name
-		ORDER BY c.name`)
+		GROUP BY c.id, c.name, c.parent_id, p.currency
+		ORDER BY c.name, c.id, p.currency`, rollup)
 	if err != nil {
 		return nil, errors.New("failed to get category statistics" + err.Error() + "")
 	}
 	defer rows.Close()
 
+	// a category comes back as one row per currency, in consecutive rows
 	var stats []*models.CategoryStats
 	for rows.Next() {
-		var s models.CategoryStats
-		if err := rows.Scan(&s.CategoryID, &s.CategoryName, &s.ProductCount, &s.TotalQuantity, &s.TotalValue); err != nil {
+		var (
+			s        models.CategoryStats
+			currency *string
+			value    int64
+		)
+		if err := rows.Scan(&s.CategoryID, &s.CategoryName, &s.ParentID, &currency, &s.ProductCount,
+			&s.TotalQuantity, &value); err != nil {
 			return nil, errors.New("failed to scan category statistics")
 		}
-		stats = append(stats, &s)
+		last := len(stats) - 1
+		if last < 0 || stats[last].CategoryID != s.CategoryID {
+			s.TotalValue = []models.Money{}
+			stats = append(stats, &s)
+			last++
+		} else {
+			stats[last].ProductCount += s.ProductCount
+			stats[last].TotalQuantity += s.TotalQuantity
+		}
+		if currency != nil {
+			stats[last].TotalValue = append(stats[last].TotalValue, models.Money{Currency: *currency, Amount: value})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get category statistics: " + err.Error() + "")
 	}
 	return stats, nil
 }
+
+func (r *repository) ListAttributes(ctx context.Context, id int64) ([]*models.AttributeDefinition, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx,
+		`SELECT id, category_id, name, type
+		 FROM category_attributes
+		 WHERE category_id = $1
+		 ORDER BY name`,
+		id,
+	)
+	if err != nil {
+		return nil, errors.New("failed to get attributes: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	defs := []*models.AttributeDefinition{}
+	for rows.Next() {
+		var d models.AttributeDefinition
+		if err = rows.Scan(&d.ID, &d.CategoryID, &d.Name, &d.Type); err != nil {
+			return nil, errors.New("failed to scan attribute: " + err.Error() + "")
+		}
+		defs = append(defs, &d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get attributes: " + err.Error() + "")
+	}
+	return defs, nil
+}
+
+// ReplaceAttributes swaps the attribute definitions of a category for defs.
+func (r *repository) ReplaceAttributes(ctx context.Context, id int64, defs []*models.AttributeDefinition) error {
+	tx, err := postgres.Begin(ctx, r.pool)
+	if err != nil {
+		return errors.New("failed to begin transaction: " + err.Error() + "")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err = tx.Exec(ctx, `DELETE FROM category_attributes WHERE category_id = $1`, id); err != nil {
+		return errors.New("failed to replace attributes: " + err.Error() + "")
+	}
+	for _, d := range defs {
+		d.CategoryID = id
+		if err = tx.QueryRow(ctx,
+			`INSERT INTO category_attributes (category_id, name, type)
+			 VALUES ($1, $2, $3)
+			 RETURNING id`,
+			id, d.Name, d.Type,
+		).Scan(&d.ID); err != nil {
+			return errors.New("failed to replace attributes: " + err.Error() + "")
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.New("failed to commit transaction: " + err.Error() + "")
+	}
+	return nil
+}
+
+// AttributeMismatches counts the products of a category, deleted ones
+// included, holding an attribute types does not list or a value of
+// another type than the listed one.
+func (r *repository) AttributeMismatches(ctx context.Context, id int64, types map[string]string) (int64, error) {
+	names, kinds := make([]string, 0, len(types)), make([]string, 0, len(types))
+	for _, name := range slices.Sorted(maps.Keys(types)) {
+		names, kinds = append(names, name), append(kinds, types[name])
+	}
+	var count int64
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx,
+		`SELECT COUNT(DISTINCT p.id)
+		 FROM products p
+		 CROSS JOIN LATERAL jsonb_each(p.attributes) AS a
+		 LEFT JOIN unnest($2::text[], $3::text[]) AS d(name, type) ON d.name = a.key
+		 WHERE p.category_id = $1 AND (d.name IS NULL OR d.type <> jsonb_typeof(a.value))`,
+		id, names, kinds,
+	).Scan(&count)
+	if err != nil {
+		return 0, errors.New("failed to check attributes: " + err.Error() + "")
+	}
+	return count, nil
+}
+
+// nameTaken reports a violation of categories_parent_name_idx, the names
+// of the children of one parent are unique.
+func nameTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation &&
+		pgErr.ConstraintName == "categories_parent_name_idx"
+}
+
+func scanCategories(rows pgx.Rows) ([]*models.Category, error) {
+	defer rows.Close()
+
+	var categories []*models.Category
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.ParentID, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt,
+			&c.Version); err != nil {
+			return nil, errors.New("failed to scan category")
+		}
+		categories = append(categories, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to get categories: " + err.Error() + "")
+	}
+	return categories, nil
+}
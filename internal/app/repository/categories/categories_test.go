@@ -3,11 +3,14 @@ package categories
 import (
 	"context"
 	"errors"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/products"
 	"prodigo/pkg/db/postgres"
+	"strings"
 	"testing"
 )
 
@@ -20,8 +23,12 @@ func Test_repository_CreateCategory(t *testing.T) {
 		repo := New(Params{Pool: mockPool})
 		category := &models.Category{}
 
-		mockPool.On("Exec", mock.Anything, mock.Anything, mock.Anything).
-			Return(pgconn.CommandTag{}, errors.New("cannot create category"))
+		mockRow := new(postgres.MockRow)
+		defer mockRow.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(errors.New("cannot create category"))
 
 		err := repo.CreateCategory(context.Background(), category)
 
@@ -34,13 +41,70 @@ func Test_repository_CreateCategory(t *testing.T) {
 
 		defer mockPool.AssertExpectations(t)
 
-		mockPool.On("Exec", mock.Anything, mock.Anything, mock.Anything).
-			Return(pgconn.NewCommandTag("INSERT 1"), nil)
+		mockRow := new(postgres.MockRow)
+		defer mockRow.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(0).(*int64)) = 7
+			}).Return(nil)
+
+		repo := New(Params{Pool: mockPool})
+		c := &models.Category{}
+		err := repo.CreateCategory(context.Background(), c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), c.ID)
+	})
+	t.Run("name taken under the parent", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(&pgconn.PgError{Code: uniqueViolation, ConstraintName: "categories_parent_name_idx"})
+
+		err := New(Params{Pool: mockPool}).CreateCategory(context.Background(), &models.Category{Name: "Phones"})
+		assert.ErrorIs(t, err, ErrDuplicateName)
+	})
+}
+
+func TestRepository_GetCategoryByID(t *testing.T) {
+	t.Run("not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).
+			Return(pgx.ErrNoRows)
+
+		repo := New(Params{Pool: mockPool})
+		c, err := repo.GetCategoryByID(context.Background(), 1)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, c)
+	})
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(1).(*string)) = "Phones"
+			}).Return(nil)
 
 		repo := New(Params{Pool: mockPool})
-		err := repo.CreateCategory(context.Background(), &models.Category{})
+		c, err := repo.GetCategoryByID(context.Background(), 1)
 
 		assert.NoError(t, err)
+		assert.Equal(t, "Phones", c.Name)
 	})
 }
 
@@ -57,8 +121,10 @@ func TestRepository_GetAllCategories(t *testing.T) {
 		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(mockRows, nil)
 
 		mockRows.On("Next").Return(true).Once()
-		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).Return(nil).Once()
 		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil).Once()
 		mockRows.On("Close").Return(nil).Once()
 
 		categories, err := repo.GetAllCategories(context.Background())
@@ -90,7 +156,8 @@ func TestRepository_GetAllCategories(t *testing.T) {
 		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(mockRows, nil)
 
 		mockRows.On("Next").Return(true).Once()
-		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("scan failed")).Once()
+		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).Return(errors.New("scan failed")).Once()
 		mockRows.On("Close").Return(nil).Once()
 
 		categories, err := repo.GetAllCategories(context.Background())
@@ -102,30 +169,58 @@ func TestRepository_GetAllCategories(t *testing.T) {
 func Test_repository_UpdateCategory(t *testing.T) {
 	t.Run("not found cat", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 
 		repo := New(Params{Pool: mockPool})
 		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
 
 		ctx := context.Background()
-		tag := pgconn.NewCommandTag("UPDATE 0")
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(tag, nil)
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(nil)
+
 		err := repo.UpdateCategory(ctx, &models.Category{ID: 1})
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, ErrNotFound)
 		assert.Contains(t, err.Error(), "category not found or delete")
 	})
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
+
+		ctx := context.Background()
+		mockPool.On("QueryRow", ctx, mock.Anything, []any{"", int64(1), int64(2), (*int64)(nil)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		err := repo.UpdateCategory(ctx, &models.Category{ID: 1, Version: 2})
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
 	t.Run("success update of cat", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 
 		repo := New(Params{Pool: mockPool})
 
 		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
 
 		ctx := context.Background()
-		tag := pgconn.NewCommandTag("UPDATE 1")
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(tag, nil)
-
-		err := repo.UpdateCategory(ctx, &models.Category{ID: 1})
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			version := int64(3)
+			*args.Get(0).(**int64) = &version
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		category := &models.Category{ID: 1, Version: 2}
+		err := repo.UpdateCategory(ctx, category)
 		assert.NoError(t, err)
+		assert.Equal(t, int64(3), category.Version)
 	})
 	t.Run("exec error", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
@@ -134,8 +229,8 @@ func Test_repository_UpdateCategory(t *testing.T) {
 		defer mockRow.AssertExpectations(t)
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		tag := pgconn.NewCommandTag("UPDATE 0")
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(tag, errors.New("exec error"))
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(errors.New("exec error"))
 		err := repo.UpdateCategory(ctx, &models.Category{ID: 1})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to update category")
@@ -145,28 +240,33 @@ func Test_repository_UpdateCategory(t *testing.T) {
 func Test_repository_DeleteCategory(t *testing.T) {
 	t.Run("successful delete", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
-
+		mockRow := new(postgres.MockRow)
 		defer mockPool.AssertExpectations(t)
 
-		pool := New(Params{Pool: mockPool})
+		repo := New(Params{Pool: mockPool})
 
 		ctx := context.Background()
-		tag := pgconn.NewCommandTag("DELETE 1")
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(tag, nil)
-		err := pool.DeleteCategory(ctx, 1)
-		assert.NoError(t, err)
+		mockPool.On("QueryRow", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHERE id = $1 AND version = $2 AND deleted_at IS NULL")
+		}), []any{int64(1), int64(3)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*bool) = true
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		assert.NoError(t, repo.DeleteCategory(ctx, 1, 3))
 	})
-	t.Run("exec error", func(t *testing.T) {
+	t.Run("query error", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
 		mockRow := new(postgres.MockRow)
 		defer mockPool.AssertExpectations(t)
 		defer mockRow.AssertExpectations(t)
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		tag := pgconn.NewCommandTag("DELETE 0")
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(tag, errors.New("exec error"))
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(errors.New("query error"))
 
-		err := repo.DeleteCategory(ctx, 1)
+		err := repo.DeleteCategory(ctx, 1, 3)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to delete/archive category")
 	})
@@ -177,11 +277,25 @@ func Test_repository_DeleteCategory(t *testing.T) {
 		defer mockRow.AssertExpectations(t)
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		tag := pgconn.NewCommandTag("DELETE 0")
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(tag, nil)
-		err := repo.DeleteCategory(ctx, 1)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "category not found or delete")
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(nil)
+
+		err := repo.DeleteCategory(ctx, 1, 3)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		repo := New(Params{Pool: mockPool})
+		ctx := context.Background()
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		err := repo.DeleteCategory(ctx, 1, 2)
+		assert.ErrorIs(t, err, ErrVersionConflict)
 	})
 }
 
@@ -193,14 +307,119 @@ func Test_repository_CategoryStatistics(t *testing.T) {
 		defer mockRows.AssertExpectations(t)
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		mockPool.On("Query", ctx, mock.Anything, mock.Anything).Return(mockRows, nil)
+		mockPool.On("Query", ctx, mock.Anything, []any{false}).Return(mockRows, nil)
 		mockRows.On("Next").Return(true).Once()
 		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything,
-			mock.Anything, mock.Anything).Return(nil).Once()
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return(nil).Once()
+
+		stats, err := repo.CategoryStatistics(ctx, false)
+		assert.NoError(t, err)
+		assert.Len(t, stats, 1)
+		assert.Equal(t, []models.Money{}, stats[0].TotalValue)
+	})
+	t.Run("stream broken after a row", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockRows.AssertExpectations(t)
+		repo := New(Params{Pool: mockPool})
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(errors.New("connection reset"))
+		mockRows.On("Close").Return(nil).Once()
+
+		stats, err := repo.CategoryStatistics(context.Background(), false)
+		assert.ErrorContains(t, err, "failed to get category statistics")
+		assert.Nil(t, stats)
+	})
+	t.Run("values products at their current price", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		repo := New(Params{Pool: mockPool})
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "(SELECT "+products.CurrentPrice+" AS price) AS cp ON TRUE")
+		}), []any{true}).Return(mockRows, nil)
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return(nil).Once()
+
+		_, err := repo.CategoryStatistics(context.Background(), true)
+		assert.NoError(t, err)
+	})
+	t.Run("sums every currency apart", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockRows.AssertExpectations(t)
+		repo := New(Params{Pool: mockPool})
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "GROUP BY c.id, c.name, c.parent_id, p.currency")
+		}), []any{false}).Return(mockRows, nil)
+		for _, row := range []struct {
+			currency string
+			count    int
+			value    int64
+		}{{"EUR", 1, 500}, {"USD", 2, 1999}} {
+			mockRows.On("Next").Return(true).Once()
+			mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				currency := row.currency
+				*(args.Get(0).(*int64)) = 1
+				*(args.Get(3).(**string)) = &currency
+				*(args.Get(4).(*int)) = row.count
+				*(args.Get(5).(*int)) = row.count
+				*(args.Get(6).(*int64)) = row.value
+			}).Return(nil).Once()
+		}
 		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
 		mockRows.On("Close").Return(nil).Once()
 
-		_, err := repo.CategoryStatistics(ctx)
+		stats, err := repo.CategoryStatistics(context.Background(), false)
+		assert.NoError(t, err)
+		if assert.Len(t, stats, 1) {
+			assert.Equal(t, 3, stats[0].ProductCount)
+			assert.Equal(t, 3, stats[0].TotalQuantity)
+			assert.Equal(t, []models.Money{{Currency: "EUR", Amount: 500}, {Currency: "USD", Amount: 1999}},
+				stats[0].TotalValue)
+		}
+	})
+	t.Run("rollup walks the subcategories", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		repo := New(Params{Pool: mockPool})
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WITH RECURSIVE tree")
+		}), []any{true}).Return(mockRows, nil)
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return(nil).Once()
+
+		stats, err := repo.CategoryStatistics(context.Background(), true)
+		assert.NoError(t, err)
+		assert.Empty(t, stats)
+	})
+	t.Run("products with variants sum their variants", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		repo := New(Params{Pool: mockPool})
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM product_variants") &&
+				strings.Contains(sql, "COALESCE(v.value, cp.price * p.quantity)") &&
+				strings.Contains(sql, "COALESCE(pv.price, cp.price)")
+		}), []any{false}).Return(mockRows, nil)
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return(nil).Once()
+
+		_, err := repo.CategoryStatistics(context.Background(), false)
 		assert.NoError(t, err)
 	})
 	t.Run("query error", func(t *testing.T) {
@@ -212,8 +431,317 @@ func Test_repository_CategoryStatistics(t *testing.T) {
 		ctx := context.Background()
 		mockPool.On("Query", ctx, mock.Anything, mock.Anything).
 			Return(mockRows, errors.New("failed to get category statistics"))
-		_, err := repo.CategoryStatistics(ctx)
+		_, err := repo.CategoryStatistics(ctx, true)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to get category statistics")
 	})
 }
+
+func TestRepository_GetSubtree(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WITH RECURSIVE subtree")
+		}), []any{int64(1)}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Twice()
+		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).Return(nil).Twice()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil).Once()
+		mockRows.On("Close").Return().Once()
+
+		repo := New(Params{Pool: mockPool})
+		cats, err := repo.GetSubtree(context.Background(), 1)
+
+		assert.NoError(t, err)
+		assert.Len(t, cats, 2)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(mockRows, nil)
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil).Once()
+		mockRows.On("Close").Return().Once()
+
+		repo := New(Params{Pool: mockPool})
+		_, err := repo.GetSubtree(context.Background(), 1)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRepository_GetPath(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		names := []string{"Electronics", "Phones"}
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WITH RECURSIVE path") && strings.Contains(sql, "ORDER BY path.depth DESC")
+		}), []any{int64(2)}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Twice()
+		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(1).(*string) = names[0]
+			names = names[1:]
+		}).Return(nil).Twice()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil).Once()
+		mockRows.On("Close").Return().Once()
+
+		repo := New(Params{Pool: mockPool})
+		path, err := repo.GetPath(context.Background(), 2)
+
+		assert.NoError(t, err)
+		if assert.Len(t, path, 2) {
+			assert.Equal(t, "Electronics", path[0].Name)
+			assert.Equal(t, "Phones", path[1].Name)
+		}
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db error"))
+
+		repo := New(Params{Pool: mockPool})
+		_, err := repo.GetPath(context.Background(), 2)
+
+		assert.ErrorContains(t, err, "failed to get category path")
+	})
+}
+
+func TestRepository_GetDeletedCategory(t *testing.T) {
+	t.Run("not deleted", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything).
+			Return(pgx.ErrNoRows)
+
+		repo := New(Params{Pool: mockPool})
+		c, err := repo.GetDeletedCategory(context.Background(), 1)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, c)
+	})
+}
+
+func TestRepository_CategoryUsage(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, []any{int64(1), models.StatusArchived}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(0).(*int64)) = 3
+				*(args.Get(1).(*int64)) = 2
+			}).Return(nil)
+
+		repo := New(Params{Pool: mockPool})
+		products, children, err := repo.CategoryUsage(context.Background(), 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), products)
+		assert.Equal(t, int64(2), children)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(errors.New("db error"))
+
+		repo := New(Params{Pool: mockPool})
+		_, _, err := repo.CategoryUsage(context.Background(), 1)
+
+		assert.ErrorContains(t, err, "failed to count category contents")
+	})
+}
+
+func TestRepository_ReassignContents(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRow := new(postgres.MockRow)
+	defer mockPool.AssertExpectations(t)
+	defer mockRow.AssertExpectations(t)
+
+	mockPool.On("QueryRow", mock.Anything, mock.Anything, []any{int64(1), int64(2)}).Return(mockRow)
+	mockRow.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 4
+		}).Return(nil)
+
+	repo := New(Params{Pool: mockPool})
+	moved, err := repo.ReassignContents(context.Background(), 1, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), moved)
+}
+
+func TestRepository_ArchiveContents(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRow := new(postgres.MockRow)
+	defer mockPool.AssertExpectations(t)
+	defer mockRow.AssertExpectations(t)
+
+	mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "SET status = $2, archived_with_category = $1")
+	}), []any{int64(1), models.StatusArchived, (*int64)(nil)}).Return(mockRow)
+	mockRow.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 7
+		}).Return(nil)
+
+	repo := New(Params{Pool: mockPool})
+	archived, err := repo.ArchiveContents(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), archived)
+}
+
+func TestRepository_RestoreCategory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHERE id = $1 AND version = $5 AND deleted_at IS NOT NULL") &&
+				strings.Contains(sql, "SET status = $6, archived_with_category = NULL")
+		}), []any{int64(1), true, models.StatusArchived, (*int64)(nil), int64(2), models.StatusDraft}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(0).(*bool)) = true
+				*(args.Get(1).(*int64)) = 5
+				*(args.Get(2).(*bool)) = true
+			}).Return(nil)
+
+		repo := New(Params{Pool: mockPool})
+		restored, err := repo.RestoreCategory(context.Background(), 1, 2, true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), restored)
+	})
+	t.Run("not deleted", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRow.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		repo := New(Params{Pool: mockPool})
+		_, err := repo.RestoreCategory(context.Background(), 1, 2, false)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(2).(*bool)) = true
+			}).Return(nil)
+
+		repo := New(Params{Pool: mockPool})
+		_, err := repo.RestoreCategory(context.Background(), 1, 1, false)
+
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+}
+
+func TestRepository_ListAttributes(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRows := new(postgres.MockRow)
+	defer mockPool.AssertExpectations(t)
+	defer mockRows.AssertExpectations(t)
+
+	mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "FROM category_attributes")
+	}), []any{int64(1)}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(2).(*string)) = "ram"
+			*(args.Get(3).(*string)) = models.AttributeNumber
+		}).Return(nil).Once()
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return()
+
+	repo := New(Params{Pool: mockPool})
+	defs, err := repo.ListAttributes(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.AttributeDefinition{{Name: "ram", Type: models.AttributeNumber}}, defs)
+}
+
+func TestRepository_ReplaceAttributes(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockTx := new(postgres.MockTx)
+	mockRow := new(postgres.MockRow)
+	defer mockTx.AssertExpectations(t)
+
+	mockPool.On("Begin", mock.Anything).Return(mockTx, nil)
+	mockTx.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "DELETE FROM category_attributes")
+	}), []any{int64(1)}).Return(pgconn.NewCommandTag("DELETE 1"), nil)
+	mockTx.On("QueryRow", mock.Anything, mock.Anything, []any{int64(1), "brand", models.AttributeString}).
+		Return(mockRow)
+	mockRow.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 9
+		}).Return(nil)
+	mockTx.On("Commit", mock.Anything).Return(nil)
+	mockTx.On("Rollback", mock.Anything).Return(nil)
+
+	defs := []*models.AttributeDefinition{{Name: "brand", Type: models.AttributeString}}
+	err := New(Params{Pool: mockPool}).ReplaceAttributes(context.Background(), 1, defs)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), defs[0].ID)
+	assert.Equal(t, int64(1), defs[0].CategoryID)
+}
+
+func TestRepository_AttributeMismatches(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRow := new(postgres.MockRow)
+	defer mockPool.AssertExpectations(t)
+	defer mockRow.AssertExpectations(t)
+
+	mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "jsonb_each(p.attributes)") &&
+			strings.Contains(sql, "d.type <> jsonb_typeof(a.value)")
+	}), []any{int64(1), []string{"brand", "ram"}, []string{"string", "number"}}).Return(mockRow)
+	mockRow.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 2
+		}).Return(nil)
+
+	repo := New(Params{Pool: mockPool})
+	misfits, err := repo.AttributeMismatches(context.Background(), 1,
+		map[string]string{"ram": models.AttributeNumber, "brand": models.AttributeString})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), misfits)
+}
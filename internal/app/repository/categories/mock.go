@@ -16,6 +16,14 @@ func (m *MockRepo) CreateCategory(ctx context.Context, c *models.Category) error
 	return args.Error(0)
 }
 
+func (m *MockRepo) GetCategoryByID(ctx context.Context, id int64) (*models.Category, error) {
+	args := m.Called(ctx, id)
+	if c, ok := args.Get(0).(*models.Category); ok {
+		return c, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockRepo) GetAllCategories(ctx context.Context) ([]*models.Category, error) {
 	args := m.Called(ctx)
 	return args.Get(0).([]*models.Category), args.Error(1)
@@ -26,12 +34,74 @@ func (m *MockRepo) UpdateCategory(ctx context.Context, c *models.Category) error
 	return args.Error(0)
 }
 
-func (m *MockRepo) DeleteCategory(ctx context.Context, id int64) error {
-	args := m.Called(ctx, id)
+func (m *MockRepo) DeleteCategory(ctx context.Context, id, version int64) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
-func (m *MockRepo) CategoryStatistics(ctx context.Context) ([]*models.CategoryStats, error) {
-	args := m.Called(ctx)
+func (m *MockRepo) GetSubtree(ctx context.Context, id int64) ([]*models.Category, error) {
+	args := m.Called(ctx, id)
+	if cats, ok := args.Get(0).([]*models.Category); ok {
+		return cats, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) GetPath(ctx context.Context, id int64) ([]*models.Category, error) {
+	args := m.Called(ctx, id)
+	if cats, ok := args.Get(0).([]*models.Category); ok {
+		return cats, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) CategoryStatistics(ctx context.Context, rollup bool) ([]*models.CategoryStats, error) {
+	args := m.Called(ctx, rollup)
 	return args.Get(0).([]*models.CategoryStats), args.Error(1)
 }
+
+func (m *MockRepo) GetDeletedCategory(ctx context.Context, id int64) (*models.Category, error) {
+	args := m.Called(ctx, id)
+	if c, ok := args.Get(0).(*models.Category); ok {
+		return c, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) CategoryUsage(ctx context.Context, id int64) (products, children int64, err error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockRepo) ReassignContents(ctx context.Context, from, to int64) (int64, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) ArchiveContents(ctx context.Context, id int64) (int64, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) RestoreCategory(ctx context.Context, id, version int64, withProducts bool) (int64, error) {
+	args := m.Called(ctx, id, version, withProducts)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) ListAttributes(ctx context.Context, id int64) ([]*models.AttributeDefinition, error) {
+	args := m.Called(ctx, id)
+	if defs, ok := args.Get(0).([]*models.AttributeDefinition); ok {
+		return defs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) ReplaceAttributes(ctx context.Context, id int64, defs []*models.AttributeDefinition) error {
+	args := m.Called(ctx, id, defs)
+	return args.Error(0)
+}
+
+func (m *MockRepo) AttributeMismatches(ctx context.Context, id int64, types map[string]string) (int64, error) {
+	args := m.Called(ctx, id, types)
+	return args.Get(0).(int64), args.Error(1)
+}
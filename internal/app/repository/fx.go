@@ -2,13 +2,23 @@ package repository
 
 import (
 	"go.uber.org/fx"
+	"prodigo/internal/app/repository/audit"
 	"prodigo/internal/app/repository/categories"
+	"prodigo/internal/app/repository/images"
+	"prodigo/internal/app/repository/inventory"
 	"prodigo/internal/app/repository/products"
+	"prodigo/internal/app/repository/reservations"
+	"prodigo/internal/app/repository/variants"
 )
 
 var Module = fx.Module("repository",
 	fx.Provide(
+		audit.New,
 		categories.New,
+		images.New,
+		inventory.New,
 		products.New,
+		reservations.New,
+		variants.New,
 	),
 )
@@ -0,0 +1,271 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	ListImages(ctx context.Context, productID int64) ([]*models.ProductImage, error)
+	GetImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error)
+	GetPrimaryImage(ctx context.Context, productID int64) (*models.ProductImage, error)
+	AddImage(ctx context.Context, img *models.ProductImage) error
+	ReorderImages(ctx context.Context, productID int64, imageIDs []int64) error
+	SetPrimary(ctx context.Context, productID, imageID int64) error
+	DeleteImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error)
+}
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrNotFound        = errors.New("image not found")
+	ErrInvalidOrder    = errors.New("image order must list every image of the product once")
+)
+
+const imageColumns = `id, product_id, storage_key, content_type, alt_text, position, is_primary, created_at,
+	width, height, renditions`
+
+type Params struct {
+	fx.In
+
+	Pool postgres.Pool `name:"app_postgres"`
+}
+
+type repository struct {
+	pool postgres.Pool `name:"app_postgres"`
+}
+
+func New(p Params) Repository {
+	return &repository{pool: p.Pool}
+}
+
+func (r *repository) ListImages(ctx context.Context, productID int64) ([]*models.ProductImage, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT `+imageColumns+`
+	FROM product_images
+	WHERE product_id = $1
+	ORDER BY position, id
+`, productID)
+	if err != nil {
+		return nil, errors.New("failed to get images: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	images := []*models.ProductImage{}
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to get images: " + err.Error() + "")
+	}
+	return images, nil
+}
+
+func (r *repository) GetImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error) {
+	return scanImage(postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	SELECT `+imageColumns+`
+	FROM product_images
+	WHERE product_id = $1 AND id = $2
+`, productID, imageID))
+}
+
+func (r *repository) GetPrimaryImage(ctx context.Context, productID int64) (*models.ProductImage, error) {
+	return scanImage(postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	SELECT `+imageColumns+`
+	FROM product_images
+	WHERE product_id = $1 AND is_primary
+`, productID))
+}
+
+// AddImage appends img to the end of the gallery. The first image of a
+// product always becomes primary, later ones only when img.Primary is set.
+func (r *repository) AddImage(ctx context.Context, img *models.ProductImage) error {
+	return r.inTx(ctx, img.ProductID, func(tx pgx.Tx) error {
+		if img.Primary {
+			if _, err := tx.Exec(ctx, `
+	UPDATE product_images SET is_primary = FALSE WHERE product_id = $1 AND is_primary
+`, img.ProductID); err != nil {
+				return errors.New("failed to add image: " + err.Error() + "")
+			}
+		}
+
+		err := tx.QueryRow(ctx, `
+	INSERT INTO product_images (product_id, storage_key, content_type, alt_text, width, height, renditions,
+		position, is_primary)
+	VALUES ($1, $2, $3, $4, $5, $6, $7,
+		(SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = $1),
+		$8 OR NOT EXISTS (SELECT 1 FROM product_images WHERE product_id = $1 AND is_primary))
+	RETURNING id, position, is_primary, created_at
+`, img.ProductID, img.Key, img.ContentType, img.AltText, img.Width, img.Height, renditions(img.Renditions),
+			img.Primary).
+			Scan(&img.ID, &img.Position, &img.Primary, &img.CreatedAt)
+		if err != nil {
+			return errors.New("failed to add image: " + err.Error() + "")
+		}
+		return nil
+	})
+}
+
+// ReorderImages sets positions in the order of imageIDs, which has to
+// name every image of the product exactly once.
+func (r *repository) ReorderImages(ctx context.Context, productID int64, imageIDs []int64) error {
+	return r.inTx(ctx, productID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `SELECT id FROM product_images WHERE product_id = $1`, productID)
+		if err != nil {
+			return errors.New("failed to reorder images: " + err.Error() + "")
+		}
+		existing := make(map[int64]bool)
+		for rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				return errors.New("failed to reorder images: " + err.Error() + "")
+			}
+			existing[id] = true
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return errors.New("failed to reorder images: " + err.Error() + "")
+		}
+
+		if len(imageIDs) != len(existing) {
+			return ErrInvalidOrder
+		}
+		for _, id := range imageIDs {
+			if !existing[id] {
+				return ErrInvalidOrder
+			}
+			delete(existing, id)
+		}
+
+		if _, err = tx.Exec(ctx, `
+	UPDATE product_images AS i
+	SET position = o.ord - 1
+	FROM unnest($2::bigint[]) WITH ORDINALITY AS o(id, ord)
+	WHERE i.id = o.id AND i.product_id = $1
+`, productID, imageIDs); err != nil {
+			return errors.New("failed to reorder images: " + err.Error() + "")
+		}
+		return nil
+	})
+}
+
+func (r *repository) SetPrimary(ctx context.Context, productID, imageID int64) error {
+	return r.inTx(ctx, productID, func(tx pgx.Tx) error {
+		// clear the old flag first, the unique index allows one primary per product
+		if _, err := tx.Exec(ctx, `
+	UPDATE product_images SET is_primary = FALSE WHERE product_id = $1 AND is_primary AND id <> $2
+`, productID, imageID); err != nil {
+			return errors.New("failed to set primary image: " + err.Error() + "")
+		}
+
+		tag, err := tx.Exec(ctx, `
+	UPDATE product_images SET is_primary = TRUE WHERE product_id = $1 AND id = $2
+`, productID, imageID)
+		if err != nil {
+			return errors.New("failed to set primary image: " + err.Error() + "")
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// DeleteImage removes the image and returns it so the caller can drop the
+// stored object. Deleting the primary image promotes the next one.
+func (r *repository) DeleteImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error) {
+	var img *models.ProductImage
+	err := r.inTx(ctx, productID, func(tx pgx.Tx) error {
+		var err error
+		img, err = scanImage(tx.QueryRow(ctx, `
+	DELETE FROM product_images
+	WHERE product_id = $1 AND id = $2
+	RETURNING `+imageColumns+`
+`, productID, imageID))
+		if err != nil {
+			return err
+		}
+
+		if img.Primary {
+			if _, err = tx.Exec(ctx, `
+	UPDATE product_images SET is_primary = TRUE
+	WHERE id = (SELECT id FROM product_images WHERE product_id = $1 ORDER BY position, id LIMIT 1)
+`, productID); err != nil {
+				return errors.New("failed to delete image: " + err.Error() + "")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// inTx runs fn with the product row locked, so concurrent gallery changes
+// of one product apply one after another. Afterwards products.image is
+// pointed at the primary image, list responses keep showing it.
+func (r *repository) inTx(ctx context.Context, productID int64, fn func(tx pgx.Tx) error) error {
+	tx, err := postgres.Begin(ctx, r.pool)
+	if err != nil {
+		return errors.New("failed to begin transaction: " + err.Error() + "")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var id int64
+	if err = tx.QueryRow(ctx, `
+	SELECT id FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+`, productID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return errors.New("failed to lock product: " + err.Error() + "")
+	}
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, `
+	UPDATE products
+	SET image = COALESCE((SELECT storage_key FROM product_images WHERE product_id = $1 AND is_primary), '')
+	WHERE id = $1
+`, productID); err != nil {
+		return errors.New("failed to update product image: " + err.Error() + "")
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errors.New("failed to commit transaction: " + err.Error() + "")
+	}
+	return nil
+}
+
+func scanImage(row pgx.Row) (*models.ProductImage, error) {
+	var img models.ProductImage
+	err := row.Scan(&img.ID, &img.ProductID, &img.Key, &img.ContentType, &img.AltText,
+		&img.Position, &img.Primary, &img.CreatedAt, &img.Width, &img.Height, &img.Renditions)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to scan image: " + err.Error() + "")
+	}
+	return &img, nil
+}
+
+// renditions keeps a nil slice from being stored as NULL.
+func renditions(names []string) []string {
+	if names == nil {
+		return []string{}
+	}
+	return names
+}
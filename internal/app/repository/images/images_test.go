@@ -0,0 +1,277 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+	"strings"
+	"testing"
+)
+
+const imageColumnCount = 11
+
+func anything(n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = mock.Anything
+	}
+	return args
+}
+
+func sqlContains(part string) any {
+	return mock.MatchedBy(func(sql string) bool { return strings.Contains(sql, part) })
+}
+
+// lockedTx expects a transaction that locks product 1 and keeps
+// products.image in sync before committing.
+func lockedTx(mockPool *postgres.MockPool) (*postgres.MockTx, *postgres.MockRow) {
+	mockTx := new(postgres.MockTx)
+	lockRow := new(postgres.MockRow)
+
+	mockPool.On("Begin", mock.Anything).Return(mockTx, nil)
+	mockTx.On("QueryRow", mock.Anything, sqlContains("FOR UPDATE"), []any{int64(1)}).Return(lockRow)
+	lockRow.On("Scan", mock.Anything).Return(nil)
+	mockTx.On("Rollback", mock.Anything).Return(nil)
+	return mockTx, lockRow
+}
+
+func expectSync(mockTx *postgres.MockTx) {
+	mockTx.On("Exec", mock.Anything, sqlContains("UPDATE products"), []any{int64(1)}).
+		Return(pgconn.NewCommandTag("UPDATE 1"), nil)
+	mockTx.On("Commit", mock.Anything).Return(nil)
+}
+
+func TestRepository_ListImages(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, sqlContains("ORDER BY position, id"), []any{int64(1)}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anything(imageColumnCount)...).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		imgs, err := repo.ListImages(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Len(t, imgs, 1)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db error"))
+
+		_, err := repo.ListImages(context.Background(), 1)
+		assert.Error(t, err)
+	})
+}
+
+func TestRepository_GetImage(t *testing.T) {
+	t.Run("not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, []any{int64(1), int64(2)}).Return(mockRow)
+		mockRow.On("Scan", anything(imageColumnCount)...).Return(pgx.ErrNoRows)
+
+		_, err := repo.GetImage(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRepository_AddImage(t *testing.T) {
+	t.Run("appends and syncs primary", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx, _ := lockedTx(mockPool)
+		insertRow := new(postgres.MockRow)
+		defer mockTx.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		img := &models.ProductImage{
+			ProductID: 1, Key: "products/1/images/a.png", ContentType: "image/png",
+			Width: 640, Height: 480, Renditions: []string{"thumb"},
+		}
+		mockTx.On("QueryRow", mock.Anything, sqlContains("INSERT INTO product_images"),
+			[]any{int64(1), img.Key, img.ContentType, "", 640, 480, []string{"thumb"}, false}).Return(insertRow)
+		insertRow.On("Scan", anything(4)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int64) = 7
+			*args.Get(2).(*bool) = true
+		}).Return(nil)
+		expectSync(mockTx)
+
+		err := repo.AddImage(context.Background(), img)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), img.ID)
+		assert.True(t, img.Primary)
+	})
+	t.Run("requested primary clears the old one", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx, _ := lockedTx(mockPool)
+		insertRow := new(postgres.MockRow)
+		defer mockTx.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockTx.On("Exec", mock.Anything, sqlContains("SET is_primary = FALSE"), []any{int64(1)}).
+			Return(pgconn.NewCommandTag("UPDATE 1"), nil)
+		mockTx.On("QueryRow", mock.Anything, sqlContains("INSERT INTO product_images"),
+			[]any{int64(1), "", "", "", 0, 0, []string{}, true}).Return(insertRow)
+		insertRow.On("Scan", anything(4)...).Return(nil)
+		expectSync(mockTx)
+
+		err := repo.AddImage(context.Background(), &models.ProductImage{ProductID: 1, Primary: true})
+		assert.NoError(t, err)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx := new(postgres.MockTx)
+		lockRow := new(postgres.MockRow)
+		defer mockTx.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Begin", mock.Anything).Return(mockTx, nil)
+		mockTx.On("QueryRow", mock.Anything, sqlContains("FOR UPDATE"), mock.Anything).Return(lockRow)
+		lockRow.On("Scan", mock.Anything).Return(pgx.ErrNoRows)
+		mockTx.On("Rollback", mock.Anything).Return(nil)
+
+		err := repo.AddImage(context.Background(), &models.ProductImage{ProductID: 9})
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+}
+
+func TestRepository_ReorderImages(t *testing.T) {
+	existing := func(mockTx *postgres.MockTx, ids ...int64) {
+		rows := new(postgres.MockRow)
+		mockTx.On("Query", mock.Anything, sqlContains("SELECT id FROM product_images"), []any{int64(1)}).Return(rows, nil)
+		for _, id := range ids {
+			rows.On("Next").Return(true).Once()
+			rows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+				*args.Get(0).(*int64) = id
+			}).Return(nil).Once()
+		}
+		rows.On("Next").Return(false).Once()
+		rows.On("Close").Return()
+		rows.On("Err").Return(nil)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx, _ := lockedTx(mockPool)
+		defer mockTx.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		existing(mockTx, 1, 2, 3)
+		mockTx.On("Exec", mock.Anything, sqlContains("WITH ORDINALITY"), []any{int64(1), []int64{3, 1, 2}}).
+			Return(pgconn.NewCommandTag("UPDATE 3"), nil)
+		expectSync(mockTx)
+
+		err := repo.ReorderImages(context.Background(), 1, []int64{3, 1, 2})
+		assert.NoError(t, err)
+	})
+	for name, ids := range map[string][]int64{
+		"missing image":   {3, 1},
+		"duplicate image": {3, 1, 1},
+		"foreign image":   {3, 1, 9},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockPool := new(postgres.MockPool)
+			mockTx, _ := lockedTx(mockPool)
+
+			repo := New(Params{Pool: mockPool})
+
+			existing(mockTx, 1, 2, 3)
+
+			err := repo.ReorderImages(context.Background(), 1, ids)
+			assert.ErrorIs(t, err, ErrInvalidOrder)
+			mockTx.AssertNotCalled(t, "Commit", mock.Anything)
+		})
+	}
+}
+
+func TestRepository_SetPrimary(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx, _ := lockedTx(mockPool)
+		defer mockTx.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockTx.On("Exec", mock.Anything, sqlContains("id <> $2"), []any{int64(1), int64(2)}).
+			Return(pgconn.NewCommandTag("UPDATE 1"), nil)
+		mockTx.On("Exec", mock.Anything, sqlContains("SET is_primary = TRUE"), []any{int64(1), int64(2)}).
+			Return(pgconn.NewCommandTag("UPDATE 1"), nil)
+		expectSync(mockTx)
+
+		assert.NoError(t, repo.SetPrimary(context.Background(), 1, 2))
+	})
+	t.Run("image not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx, _ := lockedTx(mockPool)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockTx.On("Exec", mock.Anything, sqlContains("id <> $2"), mock.Anything).
+			Return(pgconn.NewCommandTag("UPDATE 1"), nil)
+		mockTx.On("Exec", mock.Anything, sqlContains("SET is_primary = TRUE"), mock.Anything).
+			Return(pgconn.NewCommandTag("UPDATE 0"), nil)
+
+		err := repo.SetPrimary(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, ErrNotFound)
+		mockTx.AssertNotCalled(t, "Commit", mock.Anything)
+	})
+}
+
+func TestRepository_DeleteImage(t *testing.T) {
+	t.Run("primary is handed to the next image", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx, _ := lockedTx(mockPool)
+		deleteRow := new(postgres.MockRow)
+		defer mockTx.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockTx.On("QueryRow", mock.Anything, sqlContains("DELETE FROM product_images"), []any{int64(1), int64(2)}).
+			Return(deleteRow)
+		deleteRow.On("Scan", anything(imageColumnCount)...).Run(func(args mock.Arguments) {
+			*args.Get(2).(*string) = "products/1/images/a.png"
+			*args.Get(6).(*bool) = true
+		}).Return(nil)
+		mockTx.On("Exec", mock.Anything, sqlContains("ORDER BY position, id LIMIT 1"), []any{int64(1)}).
+			Return(pgconn.NewCommandTag("UPDATE 1"), nil)
+		expectSync(mockTx)
+
+		img, err := repo.DeleteImage(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "products/1/images/a.png", img.Key)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx, _ := lockedTx(mockPool)
+		deleteRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockTx.On("QueryRow", mock.Anything, sqlContains("DELETE FROM product_images"), mock.Anything).Return(deleteRow)
+		deleteRow.On("Scan", anything(imageColumnCount)...).Return(pgx.ErrNoRows)
+
+		img, err := repo.DeleteImage(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Nil(t, img)
+	})
+}
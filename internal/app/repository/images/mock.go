@@ -0,0 +1,47 @@
+package images
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) ListImages(ctx context.Context, productID int64) ([]*models.ProductImage, error) {
+	args := m.Called(ctx, productID)
+	return args.Get(0).([]*models.ProductImage), args.Error(1)
+}
+
+func (m *MockRepo) GetImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error) {
+	args := m.Called(ctx, productID, imageID)
+	return args.Get(0).(*models.ProductImage), args.Error(1)
+}
+
+func (m *MockRepo) GetPrimaryImage(ctx context.Context, productID int64) (*models.ProductImage, error) {
+	args := m.Called(ctx, productID)
+	return args.Get(0).(*models.ProductImage), args.Error(1)
+}
+
+func (m *MockRepo) AddImage(ctx context.Context, img *models.ProductImage) error {
+	args := m.Called(ctx, img)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ReorderImages(ctx context.Context, productID int64, imageIDs []int64) error {
+	args := m.Called(ctx, productID, imageIDs)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SetPrimary(ctx context.Context, productID, imageID int64) error {
+	args := m.Called(ctx, productID, imageID)
+	return args.Error(0)
+}
+
+func (m *MockRepo) DeleteImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error) {
+	args := m.Called(ctx, productID, imageID)
+	return args.Get(0).(*models.ProductImage), args.Error(1)
+}
@@ -0,0 +1,286 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	HasVariants(ctx context.Context, productID int64) (bool, error)
+	ApplyMovement(ctx context.Context, m *models.StockMovement) error
+	ListMovements(ctx context.Context, f *models.StockFilter) ([]*models.StockMovement, error)
+	StockLevel(ctx context.Context, productID int64) (*models.StockLevel, error)
+	SyncLowStock(ctx context.Context, productID int64) (*models.LowStock, error)
+	ListLowStock(ctx context.Context) ([]*models.LowStock, error)
+}
+
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrVariantNotFound   = errors.New("variant not found")
+	ErrInsufficientStock = errors.New("not enough stock")
+)
+
+// applyProduct and applyVariant change the stock and record the movement
+// in one statement. The update only matches while the stock stays at zero
+// or above, so concurrent movements can never take it below zero. The
+// last column tells a missing row apart from missing stock.
+const (
+	applyProduct = `
+	WITH upd AS (
+		UPDATE products
+		SET quantity = quantity + $2
+		WHERE id = $1 AND deleted_at IS NULL AND quantity + $2 >= 0
+		RETURNING quantity
+	), ins AS (
+		INSERT INTO stock_movements (product_id, kind, delta, balance, reason, actor_id)
+		SELECT $1, $3, $2, quantity, $4, $5 FROM upd
+		RETURNING id, balance, created_at
+	)
+	SELECT (SELECT id FROM ins), (SELECT balance FROM ins), (SELECT created_at FROM ins),
+		EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)
+`
+	applyVariant = `
+	WITH upd AS (
+		UPDATE product_variants v
+		SET quantity = v.quantity + $2
+		FROM products p
+		WHERE v.id = $6 AND v.product_id = $1 AND v.deleted_at IS NULL AND p.id = v.product_id
+			AND p.deleted_at IS NULL AND v.quantity + $2 >= 0
+		RETURNING v.quantity
+	), ins AS (
+		INSERT INTO stock_movements (product_id, variant_id, kind, delta, balance, reason, actor_id)
+		SELECT $1, $6, $3, $2, quantity, $4, $5 FROM upd
+		RETURNING id, balance, created_at
+	)
+	SELECT (SELECT id FROM ins), (SELECT balance FROM ins), (SELECT created_at FROM ins),
+		EXISTS (
+			SELECT 1 FROM product_variants v JOIN products p ON p.id = v.product_id
+			WHERE v.id = $6 AND v.product_id = $1 AND v.deleted_at IS NULL AND p.deleted_at IS NULL
+		)
+`
+)
+
+// onHand is the stock on hand of product p, summed over its variants when
+// it has any.
+const onHand = `COALESCE((SELECT SUM(v.quantity) FROM product_variants v
+	WHERE v.product_id = p.id AND v.deleted_at IS NULL), p.quantity)`
+
+type Params struct {
+	fx.In
+
+	Pool postgres.Pool `name:"app_postgres"`
+}
+
+type repository struct {
+	pool postgres.Pool `name:"app_postgres"`
+}
+
+func New(p Params) Repository {
+	return &repository{pool: p.Pool}
+}
+
+func (r *repository) HasVariants(ctx context.Context, productID int64) (bool, error) {
+	var exists bool
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	SELECT EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1 AND deleted_at IS NULL)
+`, productID).Scan(&exists)
+	if err != nil {
+		return false, errors.New("failed to check variants: " + err.Error() + "")
+	}
+	return exists, nil
+}
+
+// ApplyMovement adds m.Delta to the stock of the product, or of the
+// variant m.VariantID, and records m. On success m holds its ID, the new
+// balance and the caller from ctx as actor.
+func (r *repository) ApplyMovement(ctx context.Context, m *models.StockMovement) error {
+	m.ActorID = models.ActorID(ctx)
+
+	query, args := applyProduct, []any{m.ProductID, m.Delta, m.Kind, m.Reason, m.ActorID}
+	if m.VariantID != nil {
+		query, args = applyVariant, append(args, *m.VariantID)
+	}
+
+	var (
+		id        *int64
+		balance   *int
+		createdAt *time.Time
+		exists    bool
+	)
+	if err := postgres.Conn(ctx, r.pool).QueryRow(ctx, query, args...).
+		Scan(&id, &balance, &createdAt, &exists); err != nil {
+		return errors.New("failed to apply stock movement: " + err.Error() + "")
+	}
+	if id == nil {
+		switch {
+		case exists:
+			return ErrInsufficientStock
+		case m.VariantID != nil:
+			return ErrVariantNotFound
+		}
+		return ErrProductNotFound
+	}
+	m.ID, m.Balance, m.CreatedAt = *id, *balance, *createdAt
+	return nil
+}
+
+func (r *repository) ListMovements(ctx context.Context, f *models.StockFilter) ([]*models.StockMovement, error) {
+	var (
+		conds = []string{"product_id = $1"}
+		args  = []any{f.ProductID}
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if f.VariantID != nil {
+		conds = append(conds, "variant_id = "+arg(*f.VariantID))
+	}
+	if f.BeforeID > 0 {
+		conds = append(conds, "id < "+arg(f.BeforeID))
+	}
+
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT id, product_id, variant_id, kind, delta, balance, reason, actor_id, created_at
+	FROM stock_movements
+	WHERE `+strings.Join(conds, " AND ")+`
+	ORDER BY id DESC
+	LIMIT `+arg(f.Limit), args...)
+	if err != nil {
+		return nil, errors.New("failed to get stock movements: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	movements := []*models.StockMovement{}
+	for rows.Next() {
+		var m models.StockMovement
+		if err = rows.Scan(&m.ID, &m.ProductID, &m.VariantID, &m.Kind, &m.Delta, &m.Balance, &m.Reason,
+			&m.ActorID, &m.CreatedAt); err != nil {
+			return nil, errors.New("failed to scan stock movement: " + err.Error() + "")
+		}
+		movements = append(movements, &m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get stock movements: " + err.Error() + "")
+	}
+	return movements, nil
+}
+
+// StockLevel sums the movements of a product and of each of its variants,
+// products.quantity and product_variants.quantity hold the same numbers.
+func (r *repository) StockLevel(ctx context.Context, productID int64) (*models.StockLevel, error) {
+	level := &models.StockLevel{ProductID: productID}
+	var exists bool
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	SELECT
+		EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL),
+		COALESCE((SELECT SUM(delta) FROM stock_movements WHERE product_id = $1 AND variant_id IS NULL), 0)
+`, productID).Scan(&exists, &level.OnHand)
+	if err != nil {
+		return nil, errors.New("failed to get stock level: " + err.Error() + "")
+	}
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT v.id, v.sku, COALESCE(SUM(m.delta), 0)
+	FROM product_variants v
+	LEFT JOIN stock_movements m ON m.variant_id = v.id
+	WHERE v.product_id = $1 AND v.deleted_at IS NULL
+	GROUP BY v.id, v.sku
+	ORDER BY v.id
+`, productID)
+	if err != nil {
+		return nil, errors.New("failed to get variant stock: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var vs models.VariantStock
+		if err = rows.Scan(&vs.VariantID, &vs.SKU, &vs.OnHand); err != nil {
+			return nil, errors.New("failed to scan variant stock: " + err.Error() + "")
+		}
+		level.Variants = append(level.Variants, &vs)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get variant stock: " + err.Error() + "")
+	}
+
+	if len(level.Variants) > 0 {
+		level.OnHand = 0
+		for _, vs := range level.Variants {
+			level.OnHand += vs.OnHand
+		}
+	}
+	return level, nil
+}
+
+// SyncLowStock marks the product low on stock when it is below its reorder
+// threshold and clears the mark once it is back. It returns the product
+// only when it has just dropped below, so a product that stays low is
+// reported once.
+func (r *repository) SyncLowStock(ctx context.Context, productID int64) (*models.LowStock, error) {
+	ls := &models.LowStock{ProductID: productID}
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	WITH s AS (
+		SELECT p.id, p.title, p.reorder_threshold, p.low_stock_at, `+onHand+` AS on_hand
+		FROM products p
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+		FOR UPDATE
+	), upd AS (
+		UPDATE products p
+		SET low_stock_at = CASE WHEN s.on_hand < s.reorder_threshold THEN NOW() END
+		FROM s
+		WHERE p.id = s.id AND (s.low_stock_at IS NULL) = (s.on_hand < s.reorder_threshold)
+		RETURNING p.low_stock_at
+	)
+	SELECT s.title, s.on_hand, s.reorder_threshold, upd.low_stock_at
+	FROM s JOIN upd ON upd.low_stock_at IS NOT NULL
+`, productID).Scan(&ls.Title, &ls.OnHand, &ls.Threshold, &ls.Since)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.New("failed to sync low stock: " + err.Error() + "")
+	}
+	return ls, nil
+}
+
+func (r *repository) ListLowStock(ctx context.Context) ([]*models.LowStock, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT id, title, on_hand, reorder_threshold, low_stock_at
+	FROM (
+		SELECT p.id, p.title, p.reorder_threshold, p.low_stock_at, `+onHand+` AS on_hand
+		FROM products p
+		WHERE p.deleted_at IS NULL AND p.reorder_threshold > 0
+	) s
+	WHERE on_hand < reorder_threshold
+	ORDER BY on_hand::float / reorder_threshold, id
+`)
+	if err != nil {
+		return nil, errors.New("failed to get low stock products: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	products := []*models.LowStock{}
+	for rows.Next() {
+		var ls models.LowStock
+		if err = rows.Scan(&ls.ProductID, &ls.Title, &ls.OnHand, &ls.Threshold, &ls.Since); err != nil {
+			return nil, errors.New("failed to scan low stock product: " + err.Error() + "")
+		}
+		products = append(products, &ls)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get low stock products: " + err.Error() + "")
+	}
+	return products, nil
+}
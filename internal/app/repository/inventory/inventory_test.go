@@ -0,0 +1,288 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+	"strings"
+	"testing"
+	"time"
+)
+
+func anything(n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = mock.Anything
+	}
+	return args
+}
+
+func sqlContains(part string) any {
+	return mock.MatchedBy(func(sql string) bool { return strings.Contains(sql, part) })
+}
+
+// applied fills the columns of a stored movement.
+func applied(id int64, balance int, exists bool) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		*(args.Get(0).(**int64)) = &id
+		*(args.Get(1).(**int)) = &balance
+		*(args.Get(2).(**time.Time)) = &createdAt
+		*(args.Get(3).(*bool)) = exists
+	}
+}
+
+func TestRepository_HasVariants(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRow := new(postgres.MockRow)
+
+	mockPool.On("QueryRow", mock.Anything, sqlContains("FROM product_variants"), []any{int64(1)}).Return(mockRow)
+	mockRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*(args.Get(0).(*bool)) = true
+	}).Return(nil)
+
+	has, err := New(Params{Pool: mockPool}).HasVariants(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.True(t, has)
+}
+
+func TestRepository_ApplyMovement(t *testing.T) {
+	t.Run("product stock", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		ctx := models.WithPrincipal(context.Background(), models.Principal{Role: "admin", UserID: 7})
+		actor := int64(7)
+		mockPool.On("QueryRow", ctx, sqlContains("quantity + $2 >= 0"),
+			[]any{int64(1), -2, models.MovementSale, "order 42", &actor}).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Run(applied(10, 3, true)).Return(nil)
+
+		m := &models.StockMovement{ProductID: 1, Kind: models.MovementSale, Delta: -2, Reason: "order 42"}
+		err := New(Params{Pool: mockPool}).ApplyMovement(ctx, m)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), m.ID)
+		assert.Equal(t, 3, m.Balance)
+		assert.Equal(t, &actor, m.ActorID)
+		assert.False(t, m.CreatedAt.IsZero())
+	})
+	t.Run("variant stock", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		variantID := int64(2)
+		mockPool.On("QueryRow", mock.Anything, sqlContains("UPDATE product_variants"),
+			[]any{int64(1), 5, models.MovementReceipt, "", (*int64)(nil), int64(2)}).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Run(applied(11, 5, true)).Return(nil)
+
+		m := &models.StockMovement{ProductID: 1, VariantID: &variantID, Kind: models.MovementReceipt, Delta: 5}
+		err := New(Params{Pool: mockPool}).ApplyMovement(context.Background(), m)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, m.Balance)
+	})
+	t.Run("insufficient stock", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Run(func(args mock.Arguments) {
+			*(args.Get(3).(*bool)) = true
+		}).Return(nil)
+
+		err := New(Params{Pool: mockPool}).ApplyMovement(context.Background(), &models.StockMovement{ProductID: 1, Delta: -5})
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(nil)
+
+		err := New(Params{Pool: mockPool}).ApplyMovement(context.Background(), &models.StockMovement{ProductID: 1, Delta: 1})
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+	t.Run("variant not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(nil)
+
+		variantID := int64(2)
+		err := New(Params{Pool: mockPool}).ApplyMovement(context.Background(),
+			&models.StockMovement{ProductID: 1, VariantID: &variantID, Delta: 1})
+		assert.ErrorIs(t, err, ErrVariantNotFound)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(errors.New("db down"))
+
+		err := New(Params{Pool: mockPool}).ApplyMovement(context.Background(), &models.StockMovement{ProductID: 1, Delta: 1})
+		assert.ErrorContains(t, err, "failed to apply stock movement")
+	})
+}
+
+func TestRepository_ListMovements(t *testing.T) {
+	t.Run("filters and pages", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "product_id = $1 AND variant_id = $2 AND id < $3") &&
+				strings.Contains(sql, "LIMIT $4")
+		}), []any{int64(1), int64(2), int64(30), 11}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anything(9)...).Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 29
+		}).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		variantID := int64(2)
+		movements, err := New(Params{Pool: mockPool}).ListMovements(context.Background(),
+			&models.StockFilter{ProductID: 1, VariantID: &variantID, BeforeID: 30, Limit: 11})
+		assert.NoError(t, err)
+		assert.Len(t, movements, 1)
+		assert.Equal(t, int64(29), movements[0].ID)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db down"))
+
+		_, err := New(Params{Pool: mockPool}).ListMovements(context.Background(), &models.StockFilter{ProductID: 1})
+		assert.ErrorContains(t, err, "failed to get stock movements")
+	})
+}
+
+func TestRepository_StockLevel(t *testing.T) {
+	t.Run("sums the variants", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		mockRows := new(postgres.MockRow)
+		defer mockRows.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, sqlContains("SUM(delta)"), []any{int64(1)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*(args.Get(0).(*bool)) = true
+		}).Return(nil)
+		mockPool.On("Query", mock.Anything, sqlContains("FROM product_variants"), []any{int64(1)}).Return(mockRows, nil)
+		for i, onHand := range []int{2, 3} {
+			mockRows.On("Next").Return(true).Once()
+			mockRows.On("Scan", anything(3)...).Run(func(args mock.Arguments) {
+				*(args.Get(0).(*int64)) = int64(i + 1)
+				*(args.Get(2).(*int)) = onHand
+			}).Return(nil).Once()
+		}
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		level, err := New(Params{Pool: mockPool}).StockLevel(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, level.OnHand)
+		assert.Len(t, level.Variants, 2)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(nil)
+
+		_, err := New(Params{Pool: mockPool}).StockLevel(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+}
+
+func TestRepository_SyncLowStock(t *testing.T) {
+	t.Run("dropped below the threshold", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, sqlContains("SET low_stock_at"), []any{int64(1)}).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Run(func(args mock.Arguments) {
+			since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			*(args.Get(0).(*string)) = "watch"
+			*(args.Get(1).(*int)) = 2
+			*(args.Get(2).(*int)) = 5
+			*(args.Get(3).(**time.Time)) = &since
+		}).Return(nil)
+
+		ls, err := New(Params{Pool: mockPool}).SyncLowStock(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.LowStock{
+			Since: ls.Since, Title: "watch", ProductID: 1, OnHand: 2, Threshold: 5,
+		}, ls)
+		assert.NotNil(t, ls.Since)
+	})
+	t.Run("nothing changed", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(pgx.ErrNoRows)
+
+		ls, err := New(Params{Pool: mockPool}).SyncLowStock(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Nil(t, ls)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(errors.New("db down"))
+
+		_, err := New(Params{Pool: mockPool}).SyncLowStock(context.Background(), 1)
+		assert.ErrorContains(t, err, "failed to sync low stock")
+	})
+}
+
+func TestRepository_ListLowStock(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockRows.AssertExpectations(t)
+
+		mockPool.On("Query", mock.Anything, sqlContains("on_hand < reorder_threshold"), []any(nil)).
+			Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anything(5)...).Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 3
+			*(args.Get(2).(*int)) = 1
+		}).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		products, err := New(Params{Pool: mockPool}).ListLowStock(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, products, 1)
+		assert.Equal(t, int64(3), products[0].ProductID)
+		assert.Equal(t, 1, products[0].OnHand)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db down"))
+
+		_, err := New(Params{Pool: mockPool}).ListLowStock(context.Background())
+		assert.ErrorContains(t, err, "failed to get low stock products")
+	})
+}
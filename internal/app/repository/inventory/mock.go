@@ -0,0 +1,54 @@
+package inventory
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) HasVariants(ctx context.Context, productID int64) (bool, error) {
+	args := m.Called(ctx, productID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) ApplyMovement(ctx context.Context, sm *models.StockMovement) error {
+	args := m.Called(ctx, sm)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ListMovements(ctx context.Context, f *models.StockFilter) ([]*models.StockMovement, error) {
+	args := m.Called(ctx, f)
+	if movements, ok := args.Get(0).([]*models.StockMovement); ok {
+		return movements, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) StockLevel(ctx context.Context, productID int64) (*models.StockLevel, error) {
+	args := m.Called(ctx, productID)
+	if level, ok := args.Get(0).(*models.StockLevel); ok {
+		return level, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) SyncLowStock(ctx context.Context, productID int64) (*models.LowStock, error) {
+	args := m.Called(ctx, productID)
+	if ls, ok := args.Get(0).(*models.LowStock); ok {
+		return ls, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) ListLowStock(ctx context.Context) ([]*models.LowStock, error) {
+	args := m.Called(ctx)
+	if products, ok := args.Get(0).([]*models.LowStock); ok {
+		return products, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
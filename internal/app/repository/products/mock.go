@@ -26,13 +26,44 @@ func (m *MockRepo) GetProductByID(ctx context.Context, id int64) (*models.Produc
 	return args.Get(0).(*models.Product), args.Error(1)
 }
 
+func (m *MockRepo) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	args := m.Called(ctx, sku)
+	if p, ok := args.Get(0).(*models.Product); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) GetProductByGTIN(ctx context.Context, gtin string) (*models.Product, error) {
+	args := m.Called(ctx, gtin)
+	if p, ok := args.Get(0).(*models.Product); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockRepo) UpdateProduct(ctx context.Context, p *models.Product) error {
 	args := m.Called(ctx, p)
 	return args.Error(0)
 }
 
-func (m *MockRepo) DeleteProduct(ctx context.Context, id int64) error {
+func (m *MockRepo) ListStatusHistory(ctx context.Context, id int64) ([]*models.StatusChange, error) {
 	args := m.Called(ctx, id)
+	return args.Get(0).([]*models.StatusChange), args.Error(1)
+}
+
+func (m *MockRepo) CreatePrice(ctx context.Context, pr *models.ProductPrice) error {
+	args := m.Called(ctx, pr)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ListPrices(ctx context.Context, id int64) ([]*models.ProductPrice, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]*models.ProductPrice), args.Error(1)
+}
+
+func (m *MockRepo) DeleteProduct(ctx context.Context, id, version int64) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
@@ -41,7 +72,83 @@ func (m *MockRepo) UpdateProductStatus(ctx context.Context, id int64, status str
 	return args.Error(0)
 }
 
-func (m *MockRepo) RestoreProduct(ctx context.Context, id int64) error {
+func (m *MockRepo) GetDeletedProduct(ctx context.Context, id int64) (*models.Product, error) {
+	args := m.Called(ctx, id)
+	if p, ok := args.Get(0).(*models.Product); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) RestoreProduct(ctx context.Context, id, version int64) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ExistingCategories(ctx context.Context, ids []int) (map[int]bool, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(map[int]bool), args.Error(1)
+}
+
+func (m *MockRepo) TakenSlugs(ctx context.Context, bases []string) (map[string]bool, error) {
+	args := m.Called(ctx, bases)
+	return args.Get(0).(map[string]bool), args.Error(1)
+}
+
+func (m *MockRepo) TakenIdentifiers(
+	ctx context.Context, skus, gtins []string,
+) (takenSKUs, takenGTINs map[string]bool, err error) {
+	args := m.Called(ctx, skus, gtins)
+	return args.Get(0).(map[string]bool), args.Get(1).(map[string]bool), args.Error(2)
+}
+
+func (m *MockRepo) ListTranslations(ctx context.Context, id int64) ([]*models.ProductTranslation, error) {
 	args := m.Called(ctx, id)
+	if trs, ok := args.Get(0).([]*models.ProductTranslation); ok {
+		return trs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) UpsertTranslation(ctx context.Context, tr *models.ProductTranslation) error {
+	args := m.Called(ctx, tr)
 	return args.Error(0)
 }
+
+func (m *MockRepo) BestTranslations(
+	ctx context.Context, ids []int64, locales []string,
+) (map[int64]*models.ProductTranslation, error) {
+	args := m.Called(ctx, ids, locales)
+	if best, ok := args.Get(0).(map[int64]*models.ProductTranslation); ok {
+		return best, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) ImportProducts(ctx context.Context, ps []*models.Product) (int64, error) {
+	args := m.Called(ctx, ps)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) ExportProducts(
+	ctx context.Context, fs *models.ProductFilterSearch, fn func(p *models.Product) error,
+) error {
+	args := m.Called(ctx, fs, fn)
+	return args.Error(0)
+}
+
+func (m *MockRepo) AttributeDefinitions(ctx context.Context, categoryID int) ([]*models.AttributeDefinition, error) {
+	args := m.Called(ctx, categoryID)
+	if defs, ok := args.Get(0).([]*models.AttributeDefinition); ok {
+		return defs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) ProductFacets(ctx context.Context, fs *models.ProductFilterSearch) ([]*models.Facet, error) {
+	args := m.Called(ctx, fs)
+	if facets, ok := args.Get(0).([]*models.Facet); ok {
+		return facets, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -2,26 +2,106 @@ package products
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"prodigo/internal/app/models"
 	"prodigo/pkg/db/postgres"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/fx"
 )
 
 type Repository interface {
 	CreateProduct(ctx context.Context, p *models.Product) error
 	GetProductByID(ctx context.Context, id int64) (*models.Product, error)
+	GetProductBySKU(ctx context.Context, sku string) (*models.Product, error)
+	GetProductByGTIN(ctx context.Context, gtin string) (*models.Product, error)
 	GetAllProducts(ctx context.Context, fs *models.ProductFilterSearch) ([]*models.Product, error)
 	UpdateProduct(ctx context.Context, p *models.Product) error
-	DeleteProduct(ctx context.Context, id int64) error
-	RestoreProduct(ctx context.Context, id int64) error
+	ListStatusHistory(ctx context.Context, id int64) ([]*models.StatusChange, error)
+	CreatePrice(ctx context.Context, pr *models.ProductPrice) error
+	ListPrices(ctx context.Context, id int64) ([]*models.ProductPrice, error)
+	DeleteProduct(ctx context.Context, id, version int64) error
+	GetDeletedProduct(ctx context.Context, id int64) (*models.Product, error)
+	RestoreProduct(ctx context.Context, id, version int64) error
+	ExistingCategories(ctx context.Context, ids []int) (map[int]bool, error)
+	TakenSlugs(ctx context.Context, bases []string) (map[string]bool, error)
+	TakenIdentifiers(ctx context.Context, skus, gtins []string) (takenSKUs, takenGTINs map[string]bool, err error)
+	ListTranslations(ctx context.Context, id int64) ([]*models.ProductTranslation, error)
+	UpsertTranslation(ctx context.Context, tr *models.ProductTranslation) error
+	BestTranslations(ctx context.Context, ids []int64, locales []string) (map[int64]*models.ProductTranslation, error)
+	ImportProducts(ctx context.Context, ps []*models.Product) (int64, error)
+	ExportProducts(ctx context.Context, fs *models.ProductFilterSearch, fn func(p *models.Product) error) error
+	AttributeDefinitions(ctx context.Context, categoryID int) ([]*models.AttributeDefinition, error)
+	ProductFacets(ctx context.Context, fs *models.ProductFilterSearch) ([]*models.Facet, error)
 }
 
-var ErrNotFound = errors.New("product not found")
+var (
+	ErrNotFound        = errors.New("product not found")
+	ErrVersionConflict = errors.New("product version conflict")
+	ErrDuplicateSKU    = errors.New("sku is already in use")
+	ErrDuplicateGTIN   = errors.New("gtin is already in use")
+	ErrDuplicateSlug   = errors.New("slug is already in use")
+)
+
+const uniqueViolation = "23505"
+
+// importBatchSize caps the rows sent in one COPY.
+const importBatchSize = 1000
+
+// maxFacetValues caps the values counted per attribute.
+const maxFacetValues = 20
+
+var importColumns = []string{
+	"title", "category_id", "price", "currency", "quantity", "image", "status", "sku", "gtin", "slug",
+}
+
+// availableQuantity is the stock on hand of product p, summed over its
+// variants when it has any, minus what active reservations hold.
+const availableQuantity = `COALESCE((SELECT SUM(v.quantity) FROM product_variants v
+				WHERE v.product_id = p.id AND v.deleted_at IS NULL), p.quantity) -
+			COALESCE((SELECT SUM(r.quantity) FROM stock_reservations r
+				WHERE r.product_id = p.id AND r.status = 'active' AND r.expires_at > NOW()), 0)`
+
+// CurrentPrice is the price of product p in effect now, a scheduled price
+// that has an end wins over the regular ones. regularPrice ignores the
+// scheduled prices. Both fall back to products.price. Other repositories
+// that value products use CurrentPrice too, so they agree with the lists.
+const CurrentPrice = `COALESCE((SELECT pp.amount FROM product_prices pp
+			WHERE pp.product_id = p.id AND pp.valid_from <= NOW() AND (pp.valid_to IS NULL OR pp.valid_to > NOW())
+			ORDER BY pp.valid_to IS NULL, pp.valid_from DESC, pp.id DESC LIMIT 1), p.price)`
+
+const regularPrice = `COALESCE((SELECT pp.amount FROM product_prices pp
+			WHERE pp.product_id = p.id AND pp.valid_from <= NOW() AND pp.valid_to IS NULL
+			ORDER BY pp.valid_from DESC, pp.id DESC LIMIT 1), p.price)`
+
+// attributeNumber is the value of attribute %[1]s of product p when it is
+// a number, NULL otherwise.
+const attributeNumber = `CASE WHEN jsonb_typeof(p.attributes->%[1]s) = 'number'
+			THEN (p.attributes->>%[1]s)::numeric END`
+
+// categorySubtree selects the IDs of the categories matching a condition
+// and of everything below them.
+const categorySubtree = `WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE %s AND deleted_at IS NULL
+			UNION
+			SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id WHERE c.deleted_at IS NULL
+		) SELECT id FROM subtree`
+
+var sortColumns = map[string]string{
+	models.SortByID:        "p.id",
+	models.SortByPrice:     CurrentPrice,
+	models.SortByCreatedAt: "p.created_at",
+	models.SortByTitle:     "p.title",
+}
 
 type Params struct {
 	fx.In
@@ -37,26 +117,80 @@ func New(p Params) Repository {
 	return &repository{pool: p.Pool}
 }
 
+// CreateProduct stores p, its initial quantity is recorded as a receipt in
+// the stock ledger and its price as the first regular price.
 func (r *repository) CreateProduct(ctx context.Context, p *models.Product) error {
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO products (title, category_id, price, quantity, image, status)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id
-`, p.Title, p.CategoryID, p.Price, p.Quantity, p.Image, p.Status)
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+		WITH p AS (
+			INSERT INTO products (title, category_id, price, currency, quantity, image, status, reorder_threshold,
+				attributes, sku, gtin, slug, description, brand, weight_grams, length_mm, width_mm, height_mm)
+			VALUES ($1, $2, $3, $9, $4, $5, $6, COALESCE($8, 0), $10, NULLIF($11, ''), NULLIF($12, ''), $13, $14, $15,
+				$16, $17, $18, $19)
+			ON CONFLICT (slug) DO NOTHING
+			RETURNING id, quantity, created_at, updated_at, version
+		), m AS (
+			INSERT INTO stock_movements (product_id, kind, delta, balance, reason, actor_id)
+			SELECT id, 'receipt', quantity, quantity, 'initial stock', $7 FROM p WHERE quantity > 0
+		), pr AS (
+			INSERT INTO product_prices (product_id, amount, currency, valid_from, actor_id)
+			SELECT id, $3, $9, created_at, $7 FROM p
+		)
+		SELECT id, created_at, updated_at, version FROM p
+`, p.Title, p.CategoryID, p.Price.Amount, p.Quantity, p.Image, p.Status, models.ActorID(ctx), p.ReorderThreshold,
+		p.Price.Currency, attributes(p.Attributes), p.SKU, p.GTIN, p.Slug, p.Description, p.Brand, p.WeightGrams,
+		dimensionArg(p.Dimensions, 0), dimensionArg(p.Dimensions, 1), dimensionArg(p.Dimensions, 2)).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt, &p.Version)
 	if err != nil {
-		return errors.New("failed to create product" + err.Error() + "")
+		// a taken slug inserts nothing instead of failing, so the caller can
+		// try another one in the same transaction
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrDuplicateSlug
+		}
+		return writeError("failed to create product", err)
 	}
+	p.RegularPrice = p.Price
 	return nil
 }
 
 func (r *repository) GetProductByID(ctx context.Context, id int64) (*models.Product, error) {
-	var p models.Product
-	err := r.pool.QueryRow(ctx, `
-		SELECT id, title, category_id, price, quantity, image, status, created_at, updated_at
-		FROM products
-		WHERE id = $1 AND deleted_at IS NULL
+	return r.getProduct(ctx, "id = $1 AND deleted_at IS NULL", id)
+}
 
-`, id).Scan(&p.ID, &p.Title, &p.CategoryID, &p.Price, &p.Quantity, &p.Image, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+func (r *repository) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	return r.getProduct(ctx, "sku = $1 AND deleted_at IS NULL", sku)
+}
+
+// GetProductByGTIN finds a product by its GTIN-14.
+func (r *repository) GetProductByGTIN(ctx context.Context, gtin string) (*models.Product, error) {
+	return r.getProduct(ctx, "gtin = $1 AND deleted_at IS NULL", gtin)
+}
+
+// getProduct returns the product that is not deleted and matches cond on
+// the parameter $1.
+func (r *repository) getProduct(ctx context.Context, cond string, arg any) (*models.Product, error) {
+	var (
+		p      models.Product
+		images []byte
+		dims   [3]*int
+	)
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+		SELECT id, title, category_id, `+CurrentPrice+`, `+regularPrice+`, currency, quantity, image, status,
+			created_at, updated_at, version, reorder_threshold, `+availableQuantity+`,
+			(SELECT COALESCE(json_agg(json_build_object(
+				'id', i.id, 'product_id', i.product_id, 'key', i.storage_key, 'content_type', i.content_type,
+				'alt_text', i.alt_text, 'position', i.position, 'is_primary', i.is_primary,
+				'created_at', i.created_at AT TIME ZONE 'UTC', 'width', i.width, 'height', i.height,
+				'renditions', i.renditions
+			) ORDER BY i.position, i.id), '[]')
+			FROM product_images i WHERE i.product_id = p.id), attributes, COALESCE(sku, ''), COALESCE(gtin, ''), slug,
+			description, brand, weight_grams, length_mm, width_mm, height_mm
+		FROM products p
+		WHERE `+cond+`
+
+`, arg).Scan(&p.ID, &p.Title, &p.CategoryID, &p.Price.Amount, &p.RegularPrice.Amount, &p.Price.Currency,
+		&p.Quantity, &p.Image, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.Version, &p.ReorderThreshold, &p.Available,
+		&images, &p.Attributes, &p.SKU, &p.GTIN, &p.Slug, &p.Description, &p.Brand, &p.WeightGrams,
+		&dims[0], &dims[1], &dims[2])
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -64,39 +198,41 @@ func (r *repository) GetProductByID(ctx context.Context, id int64) (*models.Prod
 		}
 		return nil, errors.New("failed to get product: " + err.Error() + "")
 	}
+	if p.Images, err = decodeImages(images); err != nil {
+		return nil, err
+	}
+	p.RegularPrice.Currency = p.Price.Currency
+	p.Dimensions = dimensions(dims)
 	return &p, nil
 }
 
 func (r *repository) GetAllProducts(ctx context.Context, fs *models.ProductFilterSearch) ([]*models.Product, error) {
-	var (
-		args  []interface{}
-		where []string
-		i     = 1
-	)
+	where, args, rank := productFilter(fs)
+	i := len(args) + 1
 
-	if fs.CategoryName != "" {
-		where = append(where, fmt.Sprintf("c.name ILIKE $%d", i))
-		args = append(args, "%"+fs.CategoryName+"%")
-		i++
-	}
-	if fs.Status != "" {
-		where = append(where, fmt.Sprintf("p.status = $%d", i))
-		args = append(args, fs.Status)
-		i++
-	}
-	if fs.PriceMin > 0 {
-		where = append(where, fmt.Sprintf("p.price >= $%d", i))
-		args = append(args, fs.PriceMin)
-		i++
+	column := sortColumn(fs.Sort, rank)
+
+	// walking backwards flips both the comparison and the order,
+	// the caller reverses the rows back into the requested order
+	desc := fs.Sort.Desc
+	if fs.Cursor != nil && fs.Cursor.Backward {
+		desc = !desc
 	}
-	if fs.PriceMax > 0 {
-		where = append(where, fmt.Sprintf("p.price <= $%d", i))
-		args = append(args, fs.PriceMax)
-		i++
+	op, order := ">", "ASC"
+	if desc {
+		op, order = "<", "DESC"
 	}
-	if fs.Search != "" {
-		where = append(where, fmt.Sprintf("p.title ILIKE $%d", i))
-		args = append(args, "%"+fs.Search+"%")
+
+	if fs.Cursor != nil {
+		if column == "p.id" {
+			where = append(where, fmt.Sprintf("p.id %s $%d", op, i))
+			args = append(args, fs.Cursor.ID)
+			i++
+		} else {
+			where = append(where, fmt.Sprintf("(%s, p.id) %s ($%d, $%d)", column, op, i, i+1))
+			args = append(args, cursorValue(fs.Cursor, fs.Sort.Field), fs.Cursor.ID)
+			i += 2
+		}
 	}
 
 	fullQuery := ""
@@ -104,12 +240,27 @@ func (r *repository) GetAllProducts(ctx context.Context, fs *models.ProductFilte
 		fullQuery = " AND " + strings.Join(where, " AND ")
 	}
 
-	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
-		SELECT p.id, p.title, p.category_id, p.price, p.quantity, p.image, p.status, p.created_at, p.updated_at
+	orderBy := fmt.Sprintf("p.id %s", order)
+	if column != "p.id" {
+		orderBy = fmt.Sprintf("%s %s, p.id %s", column, order, order)
+	}
+
+	limit := ""
+	if fs.Limit > 0 {
+		limit = fmt.Sprintf("LIMIT $%d", i)
+		args = append(args, fs.Limit)
+	}
+
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, fmt.Sprintf(`
+		SELECT p.id, p.title, p.category_id, %s, %s, p.currency, p.quantity, p.image, p.status, p.created_at,
+			p.updated_at, p.version, p.reorder_threshold, %s, %s, p.attributes, COALESCE(p.sku, ''),
+			COALESCE(p.gtin, ''), p.slug, p.description, p.brand, p.weight_grams, p.length_mm, p.width_mm, p.height_mm
 		FROM products as p
 		LEFT JOIN categories c ON p.category_id = c.id
 		WHERE p.deleted_at IS NULL %s
-	`, fullQuery), args...)
+		ORDER BY %s
+		%s
+	`, CurrentPrice, regularPrice, availableQuantity, rank, fullQuery, orderBy, limit), args...)
 	if err != nil {
 		return nil, errors.New("failed to get all products: " + err.Error() + "")
 	}
@@ -117,56 +268,775 @@ func (r *repository) GetAllProducts(ctx context.Context, fs *models.ProductFilte
 
 	var products []*models.Product
 	for rows.Next() {
-		var p models.Product
+		var (
+			p    models.Product
+			dims [3]*int
+		)
 		if err := rows.Scan(
-			&p.ID, &p.Title, &p.CategoryID, &p.Price,
+			&p.ID, &p.Title, &p.CategoryID, &p.Price.Amount, &p.RegularPrice.Amount, &p.Price.Currency,
 			&p.Quantity, &p.Image, &p.Status,
-			&p.CreatedAt, &p.UpdatedAt,
+			&p.CreatedAt, &p.UpdatedAt, &p.Version, &p.ReorderThreshold, &p.Available, &p.Rank, &p.Attributes,
+			&p.SKU, &p.GTIN, &p.Slug, &p.Description, &p.Brand, &p.WeightGrams, &dims[0], &dims[1], &dims[2],
 		); err != nil {
 			return nil, errors.New("failed to scan product: " + err.Error() + "")
 		}
+		p.RegularPrice.Currency = p.Price.Currency
+		p.Dimensions = dimensions(dims)
 		products = append(products, &p)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get all products: " + err.Error() + "")
+	}
 	return products, nil
 }
 
+// UpdateProduct writes p only if the stored version still equals p.Version,
+// on success p.Version holds the new version. A status change is recorded
+// with the caller from ctx as actor, and so is a new p.RegularPrice, which
+// ends the regular price before it. The quantity is left alone, only
+// stock movements change it, and so is the currency. An empty SKU or GTIN
+// clears it, and so does a nil weight or dimensions.
 func (r *repository) UpdateProduct(ctx context.Context, p *models.Product) error {
-	upd, err := r.pool.Exec(ctx, `
-	UPDATE products
-	SET title = $1, category_id = $2, price = $3, quantity = $4, image = $5, status = $6, updated_at = NOW()
-	WHERE id = $7 AND deleted_at IS NULL
-`, p.Title, p.CategoryID, p.Price, p.Quantity, p.Image, p.Status, p.ID)
+	var (
+		version *int64
+		exists  bool
+	)
+	// old sees the row as it was before upd, a changed status and a
+	// changed regular price are recorded within the same statement
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	WITH old AS (
+		SELECT status, `+regularPrice+` AS price
+		FROM products p WHERE id = $6 AND version = $7 AND deleted_at IS NULL
+	), upd AS (
+		UPDATE products
+		SET title = $1, category_id = $2, price = $3, image = $4, status = $5, reorder_threshold = COALESCE($9, reorder_threshold),
+			attributes = $10, sku = NULLIF($11, ''), gtin = NULLIF($12, ''), slug = $13, description = $14, brand = $15,
+			weight_grams = $16, length_mm = $17, width_mm = $18, height_mm = $19, version = version + 1,
+			updated_at = NOW()
+		WHERE id = $6 AND version = $7 AND deleted_at IS NULL
+		RETURNING version, status, currency
+	), history AS (
+		INSERT INTO product_status_history (product_id, from_status, to_status, actor_id)
+		SELECT $6, old.status, upd.status, $8
+		FROM old, upd
+		WHERE old.status <> upd.status
+	), ended AS (
+		UPDATE product_prices pp SET valid_to = NOW()
+		FROM old, upd
+		WHERE pp.product_id = $6 AND pp.valid_to IS NULL AND pp.valid_from < NOW() AND old.price <> $3
+	), price AS (
+		INSERT INTO product_prices (product_id, amount, currency, valid_from, actor_id)
+		SELECT $6, $3, upd.currency, NOW(), $8
+		FROM old, upd
+		WHERE old.price <> $3
+	)
+	SELECT (SELECT version FROM upd), EXISTS (SELECT 1 FROM products WHERE id = $6 AND deleted_at IS NULL)
+`, p.Title, p.CategoryID, p.RegularPrice.Amount, p.Image, p.Status, p.ID, p.Version, models.ActorID(ctx),
+		p.ReorderThreshold, attributes(p.Attributes), p.SKU, p.GTIN, p.Slug, p.Description, p.Brand, p.WeightGrams,
+		dimensionArg(p.Dimensions, 0), dimensionArg(p.Dimensions, 1), dimensionArg(p.Dimensions, 2)).
+		Scan(&version, &exists)
 	if err != nil {
-		return errors.New("failed to update product: " + err.Error() + "")
+		return writeError("failed to update product", err)
 	}
-	if upd.RowsAffected() == 0 {
-		return errors.New("product not found")
+	if version == nil {
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
 	}
+	p.Version = *version
 	return nil
 }
 
-func (r *repository) DeleteProduct(ctx context.Context, id int64) error {
-	dlt, err := r.pool.Exec(ctx, `
-	UPDATE products SET deleted_at = NOW() WHERE id = $1
+// ListStatusHistory returns the status changes of a product, oldest first.
+func (r *repository) ListStatusHistory(ctx context.Context, id int64) ([]*models.StatusChange, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT id, product_id, from_status, to_status, actor_id, changed_at
+	FROM product_status_history
+	WHERE product_id = $1
+	ORDER BY changed_at, id
 `, id)
 	if err != nil {
-		return errors.New("failed to delete product: " + err.Error() + "")
+		return nil, errors.New("failed to get status history: " + err.Error() + "")
 	}
-	if dlt.RowsAffected() == 0 {
-		return errors.New("product not found")
+	defer rows.Close()
+
+	history := []*models.StatusChange{}
+	for rows.Next() {
+		var ch models.StatusChange
+		if err = rows.Scan(&ch.ID, &ch.ProductID, &ch.FromStatus, &ch.ToStatus, &ch.ActorID, &ch.ChangedAt); err != nil {
+			return nil, errors.New("failed to scan status change: " + err.Error() + "")
+		}
+		history = append(history, &ch)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get status history: " + err.Error() + "")
+	}
+	return history, nil
+}
+
+// CreatePrice schedules pr for a product that is not deleted.
+func (r *repository) CreatePrice(ctx context.Context, pr *models.ProductPrice) error {
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	INSERT INTO product_prices (product_id, amount, currency, valid_from, valid_to, actor_id)
+	SELECT id, $2, $3, $4, $5, $6 FROM products WHERE id = $1 AND deleted_at IS NULL
+	RETURNING id, created_at
+`, pr.ProductID, pr.Price.Amount, pr.Price.Currency, pr.ValidFrom, pr.ValidTo, models.ActorID(ctx)).
+		Scan(&pr.ID, &pr.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.New("failed to create price: " + err.Error() + "")
 	}
 	return nil
 }
 
-func (r *repository) RestoreProduct(ctx context.Context, id int64) error {
-	restore, err := r.pool.Exec(ctx, `
-	UPDATE products SET deleted_at = NULL WHERE id = $1
+// ListPrices returns the past, current and scheduled prices of a product
+// in the order they start.
+func (r *repository) ListPrices(ctx context.Context, id int64) ([]*models.ProductPrice, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT id, product_id, amount, currency, valid_from, valid_to, actor_id, created_at
+	FROM product_prices
+	WHERE product_id = $1
+	ORDER BY valid_from, id
 `, id)
+	if err != nil {
+		return nil, errors.New("failed to get prices: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	prices := []*models.ProductPrice{}
+	for rows.Next() {
+		var pr models.ProductPrice
+		if err = rows.Scan(&pr.ID, &pr.ProductID, &pr.Price.Amount, &pr.Price.Currency, &pr.ValidFrom, &pr.ValidTo,
+			&pr.ActorID, &pr.CreatedAt); err != nil {
+			return nil, errors.New("failed to scan price: " + err.Error() + "")
+		}
+		prices = append(prices, &pr)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get prices: " + err.Error() + "")
+	}
+	return prices, nil
+}
+
+// DeleteProduct soft-deletes product id while it still is at version, the
+// version stays so a restore can name the product as it was deleted.
+func (r *repository) DeleteProduct(ctx context.Context, id, version int64) error {
+	var deleted, exists bool
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	WITH dlt AS (
+		UPDATE products SET deleted_at = NOW()
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		RETURNING id
+	)
+	SELECT EXISTS (SELECT 1 FROM dlt), EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)
+`, id, version).Scan(&deleted, &exists)
+	if err != nil {
+		return errors.New("failed to delete product: " + err.Error() + "")
+	}
+	if !deleted {
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// GetDeletedProduct returns product id only while it is soft-deleted.
+func (r *repository) GetDeletedProduct(ctx context.Context, id int64) (*models.Product, error) {
+	return r.getProduct(ctx, "id = $1 AND deleted_at IS NOT NULL", id)
+}
+
+// RestoreProduct undeletes product id while it still is at version.
+func (r *repository) RestoreProduct(ctx context.Context, id, version int64) error {
+	var restored, exists bool
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	WITH upd AS (
+		UPDATE products SET deleted_at = NULL, version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND version = $2 AND deleted_at IS NOT NULL
+		RETURNING id
+	)
+	SELECT EXISTS (SELECT 1 FROM upd), EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NOT NULL)
+`, id, version).Scan(&restored, &exists)
 	if err != nil {
 		return errors.New("failed to restore product: " + err.Error() + "")
 	}
-	if restore.RowsAffected() == 0 {
-		return errors.New("product not found")
+	if !restored {
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// ExistingCategories reports which of ids belong to categories that are not deleted.
+func (r *repository) ExistingCategories(ctx context.Context, ids []int) (map[int]bool, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT id FROM categories WHERE id = ANY($1) AND deleted_at IS NULL
+`, ids)
+	if err != nil {
+		return nil, errors.New("failed to get categories: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	existing := make(map[int]bool, len(ids))
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.New("failed to scan category: " + err.Error() + "")
+		}
+		existing[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to get categories: " + err.Error() + "")
+	}
+	return existing, nil
+}
+
+// TakenSlugs returns the slugs in use that equal one of bases or extend
+// it with a numeric suffix, deleted products keep theirs.
+func (r *repository) TakenSlugs(ctx context.Context, bases []string) (map[string]bool, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT slug FROM products WHERE slug = ANY($1) OR regexp_replace(slug, '-[0-9]+$', '') = ANY($1)
+`, bases)
+	if err != nil {
+		return nil, errors.New("failed to get slugs: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, errors.New("failed to scan slug: " + err.Error() + "")
+		}
+		taken[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to get slugs: " + err.Error() + "")
+	}
+	return taken, nil
+}
+
+// TakenIdentifiers reports which of skus and gtins belong to a product
+// already, deleted ones included.
+func (r *repository) TakenIdentifiers(
+	ctx context.Context, skus, gtins []string,
+) (takenSKUs, takenGTINs map[string]bool, err error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT COALESCE(sku, ''), COALESCE(gtin, '') FROM products WHERE sku = ANY($1) OR gtin = ANY($2)
+`, skus, gtins)
+	if err != nil {
+		return nil, nil, errors.New("failed to get identifiers: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	takenSKUs, takenGTINs = make(map[string]bool), make(map[string]bool)
+	for rows.Next() {
+		var sku, gtin string
+		if err := rows.Scan(&sku, &gtin); err != nil {
+			return nil, nil, errors.New("failed to scan identifiers: " + err.Error() + "")
+		}
+		if sku != "" {
+			takenSKUs[sku] = true
+		}
+		if gtin != "" {
+			takenGTINs[gtin] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.New("failed to get identifiers: " + err.Error() + "")
+	}
+	return takenSKUs, takenGTINs, nil
+}
+
+// ListTranslations returns the translations of a product by locale.
+func (r *repository) ListTranslations(ctx context.Context, id int64) ([]*models.ProductTranslation, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT product_id, locale, title, description, updated_at
+	FROM product_translations
+	WHERE product_id = $1
+	ORDER BY locale
+`, id)
+	if err != nil {
+		return nil, errors.New("failed to get translations: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	translations := []*models.ProductTranslation{}
+	for rows.Next() {
+		var tr models.ProductTranslation
+		if err = rows.Scan(&tr.ProductID, &tr.Locale, &tr.Title, &tr.Description, &tr.UpdatedAt); err != nil {
+			return nil, errors.New("failed to scan translation: " + err.Error() + "")
+		}
+		translations = append(translations, &tr)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get translations: " + err.Error() + "")
+	}
+	return translations, nil
+}
+
+// UpsertTranslation creates or replaces the translation of a product that
+// is not deleted in tr.Locale.
+func (r *repository) UpsertTranslation(ctx context.Context, tr *models.ProductTranslation) error {
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	INSERT INTO product_translations (product_id, locale, title, description)
+	SELECT id, $2, $3, $4 FROM products WHERE id = $1 AND deleted_at IS NULL
+	ON CONFLICT (product_id, locale) DO UPDATE
+	SET title = EXCLUDED.title, description = EXCLUDED.description, updated_at = NOW()
+	RETURNING updated_at
+`, tr.ProductID, tr.Locale, tr.Title, tr.Description).Scan(&tr.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.New("failed to upsert translation: " + err.Error() + "")
+	}
+	return nil
+}
+
+// BestTranslations returns for each of ids the translation in the first
+// of locales it has, products without any are left out.
+func (r *repository) BestTranslations(
+	ctx context.Context, ids []int64, locales []string,
+) (map[int64]*models.ProductTranslation, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT DISTINCT ON (product_id) product_id, locale, title, description, updated_at
+	FROM product_translations
+	WHERE product_id = ANY($1) AND locale = ANY($2)
+	ORDER BY product_id, array_position($2, locale)
+`, ids, locales)
+	if err != nil {
+		return nil, errors.New("failed to get translations: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	best := make(map[int64]*models.ProductTranslation, len(ids))
+	for rows.Next() {
+		var tr models.ProductTranslation
+		if err = rows.Scan(&tr.ProductID, &tr.Locale, &tr.Title, &tr.Description, &tr.UpdatedAt); err != nil {
+			return nil, errors.New("failed to scan translation: " + err.Error() + "")
+		}
+		best[tr.ProductID] = &tr
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get translations: " + err.Error() + "")
+	}
+	return best, nil
+}
+
+// AttributeDefinitions returns the attributes defined for category
+// categoryID.
+func (r *repository) AttributeDefinitions(ctx context.Context, categoryID int) ([]*models.AttributeDefinition, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT id, category_id, name, type FROM category_attributes WHERE category_id = $1
+`, categoryID)
+	if err != nil {
+		return nil, errors.New("failed to get attributes: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	var defs []*models.AttributeDefinition
+	for rows.Next() {
+		var d models.AttributeDefinition
+		if err := rows.Scan(&d.ID, &d.CategoryID, &d.Name, &d.Type); err != nil {
+			return nil, errors.New("failed to scan attribute: " + err.Error() + "")
+		}
+		defs = append(defs, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to get attributes: " + err.Error() + "")
+	}
+	return defs, nil
+}
+
+// ImportProducts copies ps in batches inside a single transaction,
+// so either every row is stored or none is. The quantities of the new
+// products are recorded as receipts in the stock ledger and their prices
+// as the first regular ones.
+func (r *repository) ImportProducts(ctx context.Context, ps []*models.Product) (int64, error) {
+	tx, err := postgres.Begin(ctx, r.pool)
+	if err != nil {
+		return 0, errors.New("failed to begin import: " + err.Error() + "")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var lastID int64
+	if err = tx.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM products`).Scan(&lastID); err != nil {
+		return 0, errors.New("failed to import products: " + err.Error() + "")
+	}
+
+	var inserted int64
+	for batch := range slices.Chunk(ps, importBatchSize) {
+		n, err := tx.CopyFrom(ctx, pgx.Identifier{"products"}, importColumns,
+			pgx.CopyFromSlice(len(batch), func(i int) ([]any, error) {
+				p := batch[i]
+				return []any{
+					p.Title, p.CategoryID, p.Price.Amount, p.Price.Currency, p.Quantity, p.Image, p.Status,
+					nullable(p.SKU), nullable(p.GTIN), p.Slug,
+				}, nil
+			}))
+		if err != nil {
+			return 0, errors.New("failed to import products: " + err.Error() + "")
+		}
+		inserted += n
+	}
+
+	// rows of other transactions only become visible with their own
+	// receipts, so the ones without a movement are the copied ones
+	if _, err = tx.Exec(ctx, `
+	INSERT INTO stock_movements (product_id, kind, delta, balance, reason, actor_id)
+	SELECT p.id, 'receipt', p.quantity, p.quantity, 'import', $2
+	FROM products p
+	WHERE p.id > $1 AND p.quantity > 0
+		AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id)
+`, lastID, models.ActorID(ctx)); err != nil {
+		return 0, errors.New("failed to record imported stock: " + err.Error() + "")
+	}
+	if _, err = tx.Exec(ctx, `
+	INSERT INTO product_prices (product_id, amount, currency, valid_from, actor_id)
+	SELECT p.id, p.price, p.currency, p.created_at, $2
+	FROM products p
+	WHERE p.id > $1 AND NOT EXISTS (SELECT 1 FROM product_prices pp WHERE pp.product_id = p.id)
+`, lastID, models.ActorID(ctx)); err != nil {
+		return 0, errors.New("failed to record imported prices: " + err.Error() + "")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, errors.New("failed to commit import: " + err.Error() + "")
+	}
+	return inserted, nil
+}
+
+// ExportProducts streams every product matching fs to fn in the requested
+// order, rows are read one by one so the result set is never held in memory.
+func (r *repository) ExportProducts(
+	ctx context.Context, fs *models.ProductFilterSearch, fn func(p *models.Product) error,
+) error {
+	where, args, rank := productFilter(fs)
+
+	fullQuery := ""
+	if len(where) > 0 {
+		fullQuery = " AND " + strings.Join(where, " AND ")
+	}
+
+	order := "ASC"
+	if fs.Sort.Desc {
+		order = "DESC"
+	}
+	orderBy := fmt.Sprintf("p.id %s", order)
+	if column := sortColumn(fs.Sort, rank); column != "p.id" {
+		orderBy = fmt.Sprintf("%s %s, p.id %s", column, order, order)
+	}
+
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, fmt.Sprintf(`
+		SELECT p.id, p.title, p.category_id, COALESCE(c.name, ''), %s, p.currency, p.quantity, p.image,
+			p.status, p.created_at, p.updated_at, p.version, COALESCE(p.sku, ''), COALESCE(p.gtin, ''), p.slug
+		FROM products as p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.deleted_at IS NULL %s
+		ORDER BY %s
+	`, CurrentPrice, fullQuery, orderBy), args...)
+	if err != nil {
+		return errors.New("failed to export products: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Product
+		if err := rows.Scan(
+			&p.ID, &p.Title, &p.CategoryID, &p.CategoryName, &p.Price.Amount, &p.Price.Currency,
+			&p.Quantity, &p.Image, &p.Status,
+			&p.CreatedAt, &p.UpdatedAt, &p.Version, &p.SKU, &p.GTIN, &p.Slug,
+		); err != nil {
+			return errors.New("failed to scan product: " + err.Error() + "")
+		}
+		if err := fn(&p); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.New("failed to export products: " + err.Error() + "")
 	}
 	return nil
 }
+
+// ProductFacets counts the products matching fs per attribute value, the
+// most common values of every attribute first. The filter on an attribute
+// does not narrow its own facet, so the other values of an attribute
+// already filtered on stay visible with the count they would add.
+func (r *repository) ProductFacets(ctx context.Context, fs *models.ProductFilterSearch) ([]*models.Facet, error) {
+	base := *fs
+	base.Attributes = nil
+	where, args, _ := productFilter(&base)
+	i := len(args) + 1
+
+	for _, f := range fs.Attributes {
+		cond, condArgs := attributeCondition(&f, i)
+		where = append(where, fmt.Sprintf("(%s OR a.key = $%d)", cond, i+len(condArgs)))
+		args = append(args, condArgs...)
+		args = append(args, f.Name)
+		i += len(condArgs) + 1
+	}
+
+	fullQuery := ""
+	if len(where) > 0 {
+		fullQuery = " AND " + strings.Join(where, " AND ")
+	}
+	args = append(args, maxFacetValues)
+
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, fmt.Sprintf(`
+		WITH counts AS (
+			SELECT a.key, a.value, COUNT(*) AS n,
+				ROW_NUMBER() OVER (PARTITION BY a.key ORDER BY COUNT(*) DESC, a.value) AS pos
+			FROM products as p
+			LEFT JOIN categories c ON p.category_id = c.id
+			CROSS JOIN LATERAL jsonb_each(p.attributes) AS a
+			WHERE p.deleted_at IS NULL %s
+			GROUP BY a.key, a.value
+		)
+		SELECT key, value, n FROM counts WHERE pos <= $%d ORDER BY key, pos
+	`, fullQuery, i), args...)
+	if err != nil {
+		return nil, errors.New("failed to get facets: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	facets := []*models.Facet{}
+	for rows.Next() {
+		var v models.FacetValue
+		var key string
+		if err := rows.Scan(&key, &v.Value, &v.Count); err != nil {
+			return nil, errors.New("failed to scan facet: " + err.Error() + "")
+		}
+		if last := len(facets) - 1; last < 0 || facets[last].Attribute != key {
+			facets = append(facets, &models.Facet{Attribute: key})
+		}
+		facets[len(facets)-1].Values = append(facets[len(facets)-1].Values, &v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("failed to get facets: " + err.Error() + "")
+	}
+	return facets, nil
+}
+
+// productFilter turns the filters shared by listing and export into WHERE
+// conditions, rank is the relevance expression for the search term.
+func productFilter(fs *models.ProductFilterSearch) (where []string, args []any, rank string) {
+	i := 1
+
+	if fs.CategoryID > 0 {
+		if fs.IncludeDescendants {
+			where = append(where, "p.category_id IN ("+fmt.Sprintf(categorySubtree, fmt.Sprintf("id = $%d", i))+")")
+		} else {
+			where = append(where, fmt.Sprintf("p.category_id = $%d", i))
+		}
+		args = append(args, fs.CategoryID)
+		i++
+	}
+	if fs.CategoryName != "" {
+		if fs.IncludeDescendants {
+			where = append(where, "p.category_id IN ("+fmt.Sprintf(categorySubtree, fmt.Sprintf("name ILIKE $%d", i))+")")
+		} else {
+			where = append(where, fmt.Sprintf("c.name ILIKE $%d", i))
+		}
+		args = append(args, "%"+fs.CategoryName+"%")
+		i++
+	}
+	if fs.Status != "" {
+		where = append(where, fmt.Sprintf("p.status = $%d", i))
+		args = append(args, fs.Status)
+		i++
+	}
+	if fs.Currency != "" {
+		where = append(where, fmt.Sprintf("p.currency = $%d", i))
+		args = append(args, fs.Currency)
+		i++
+	}
+	if fs.PriceMin > 0 {
+		where = append(where, fmt.Sprintf(CurrentPrice+" >= $%d", i))
+		args = append(args, fs.PriceMin)
+		i++
+	}
+	if fs.PriceMax > 0 {
+		where = append(where, fmt.Sprintf(CurrentPrice+" <= $%d", i))
+		args = append(args, fs.PriceMax)
+		i++
+	}
+
+	for _, f := range fs.Attributes {
+		cond, condArgs := attributeCondition(&f, i)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+		i += len(condArgs)
+	}
+
+	rank = "0::real"
+	if query := searchQuery(fs.Search); query != "" {
+		where = append(where, fmt.Sprintf("p.search_vector @@ to_tsquery('english', $%d)", i))
+		rank = fmt.Sprintf("ts_rank(p.search_vector, to_tsquery('english', $%d))", i)
+		args = append(args, query)
+	}
+	return where, args, rank
+}
+
+// attributeCondition matches the products passing filter f, its
+// parameters start at $i. A value that parses as a number or a boolean
+// matches those too, attr.ram=8 finds {"ram": 8} as well as {"ram": "8"}.
+func attributeCondition(f *models.AttributeFilter, i int) (cond string, args []any) {
+	var conds []string
+	if len(f.Values) > 0 {
+		var docs []map[string]any
+		for _, v := range f.Values {
+			docs = append(docs, map[string]any{f.Name: v})
+			if n, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+				docs = append(docs, map[string]any{f.Name: n})
+			}
+			if v == "true" || v == "false" {
+				docs = append(docs, map[string]any{f.Name: v == "true"})
+			}
+		}
+		conds = append(conds, fmt.Sprintf("p.attributes @> ANY($%d::jsonb[])", i+len(args)))
+		args = append(args, docs)
+	}
+	if f.Min != nil || f.Max != nil {
+		number := fmt.Sprintf(attributeNumber, fmt.Sprintf("$%d::text", i+len(args)))
+		args = append(args, f.Name)
+		if f.Min != nil {
+			conds = append(conds, fmt.Sprintf("%s >= $%d", number, i+len(args)))
+			args = append(args, *f.Min)
+		}
+		if f.Max != nil {
+			conds = append(conds, fmt.Sprintf("%s <= $%d", number, i+len(args)))
+			args = append(args, *f.Max)
+		}
+	}
+	return "(" + strings.Join(conds, " AND ") + ")", args
+}
+
+// nullable stores an empty identifier as NULL, NULLs do not collide in
+// the unique constraints.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// writeError maps the unique constraints on the identifiers to their
+// errors and wraps other failures.
+func writeError(msg string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		switch pgErr.ConstraintName {
+		case "products_sku_key":
+			return ErrDuplicateSKU
+		case "products_gtin_key":
+			return ErrDuplicateGTIN
+		case "products_slug_key":
+			return ErrDuplicateSlug
+		}
+	}
+	return errors.New(msg + ": " + err.Error() + "")
+}
+
+// dimensionArg is dimension i of d, length, width or height, as a column
+// value, NULL without dimensions.
+func dimensionArg(d *models.Dimensions, i int) *int {
+	if d == nil {
+		return nil
+	}
+	return &[]int{d.LengthMM, d.WidthMM, d.HeightMM}[i]
+}
+
+// dimensions builds the dimensions from the length, width and height
+// columns, which are all set or all NULL.
+func dimensions(cols [3]*int) *models.Dimensions {
+	if cols[0] == nil || cols[1] == nil || cols[2] == nil {
+		return nil
+	}
+	return &models.Dimensions{LengthMM: *cols[0], WidthMM: *cols[1], HeightMM: *cols[2]}
+}
+
+// attributes stores a product without attribute values as an empty
+// object rather than a JSON null.
+func attributes(attrs map[string]any) map[string]any {
+	if attrs == nil {
+		return map[string]any{}
+	}
+	return attrs
+}
+
+func sortColumn(sort models.ProductSort, rank string) string {
+	if sort.Field == models.SortByRelevance {
+		return rank
+	}
+	if column, ok := sortColumns[sort.Field]; ok {
+		return column
+	}
+	return sortColumns[models.SortByID]
+}
+
+// imageJSON is an element of the json_agg in GetProductByID, unlike
+// models.ProductImage it carries the storage key.
+type imageJSON struct {
+	CreatedAt   time.Time `json:"created_at"`
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type"`
+	AltText     string    `json:"alt_text"`
+	Renditions  []string  `json:"renditions"`
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"product_id"`
+	Position    int       `json:"position"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Primary     bool      `json:"is_primary"`
+}
+
+func decodeImages(data []byte) ([]*models.ProductImage, error) {
+	if len(data) == 0 {
+		return []*models.ProductImage{}, nil
+	}
+	var rows []imageJSON
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, errors.New("failed to decode product images: " + err.Error() + "")
+	}
+	images := make([]*models.ProductImage, len(rows))
+	for i, row := range rows {
+		img := models.ProductImage(row)
+		images[i] = &img
+	}
+	return images, nil
+}
+
+func cursorValue(cur *models.ProductCursor, field string) any {
+	switch field {
+	case models.SortByPrice:
+		return cur.Price
+	case models.SortByCreatedAt:
+		return cur.CreatedAt
+	case models.SortByTitle:
+		return cur.Title
+	case models.SortByRelevance:
+		return cur.Rank
+	default:
+		return cur.ID
+	}
+}
+
+// searchQuery turns free text into a tsquery where every word has to
+// match, each as a prefix so partially typed words still find results.
+func searchQuery(search string) string {
+	words := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
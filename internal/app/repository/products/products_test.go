@@ -10,16 +10,38 @@ import (
 	"prodigo/internal/app/models"
 
 	"prodigo/pkg/db/postgres"
+	"strings"
 	"testing"
+	"time"
 )
 
+const (
+	productColumns       = 11
+	productDetailColumns = productColumns + 14
+	productListColumns   = productColumns + 14
+	productExportColumns = productColumns + 4
+)
+
+func usd(amount int64) models.Money {
+	return models.Money{Currency: "USD", Amount: amount}
+}
+
+func anything(n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = mock.Anything
+	}
+	return args
+}
+
 func Test_repository_CreateProduct(t *testing.T) {
 	t.Run("error on insert", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
 		repo := New(Params{Pool: mockPool})
 
-		mockPool.On("Exec", mock.Anything, mock.Anything, mock.Anything).
-			Return(pgconn.CommandTag{}, errors.New("cannot create product"))
+		mockRow := new(postgres.MockRow)
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(errors.New("cannot create product"))
 
 		err := repo.CreateProduct(context.Background(), &models.Product{})
 
@@ -34,15 +56,114 @@ func Test_repository_CreateProduct(t *testing.T) {
 
 		repo := New(Params{Pool: mockPool})
 
-		mockPool.On("Exec", mock.Anything, mock.Anything, mock.Anything).
-			Return(pgconn.NewCommandTag("INSERT 1"), nil)
+		mockRow := new(postgres.MockRow)
+		defer mockRow.AssertExpectations(t)
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 12
+		}).Return(nil)
 
-		err := repo.CreateProduct(context.Background(), &models.Product{})
+		p := &models.Product{}
+		err := repo.CreateProduct(context.Background(), p)
 
 		assert.NoError(t, err)
+		assert.Equal(t, int64(12), p.ID)
+	})
+	t.Run("records the initial stock and price", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		defer mockPool.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		ctx := models.WithPrincipal(context.Background(), models.Principal{Role: "admin", UserID: 7})
+		actor, weight, length, width, height := int64(7), 120, 40, 38, 11
+		mockRow := new(postgres.MockRow)
+		mockPool.On("QueryRow", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO stock_movements") &&
+				strings.Contains(sql, "'receipt'") &&
+				strings.Contains(sql, "INSERT INTO product_prices")
+		}), []any{
+			"watch", 1, int64(1000), 3, "", "active", &actor, (*int)(nil), "USD", map[string]any{}, "", "", "watch",
+			"a watch", "Acme", &weight, &length, &width, &height,
+		}).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(nil)
+
+		p := &models.Product{
+			Title: "watch", CategoryID: 1, Price: usd(1000), Quantity: 3, Status: "active", Slug: "watch",
+			Description: "a watch", Brand: "Acme", WeightGrams: &weight,
+			Dimensions: &models.Dimensions{LengthMM: 40, WidthMM: 38, HeightMM: 11},
+		}
+		assert.NoError(t, repo.CreateProduct(ctx, p))
+	})
+	t.Run("duplicate identifiers", func(t *testing.T) {
+		for constraint, want := range map[string]error{
+			"products_sku_key":  ErrDuplicateSKU,
+			"products_gtin_key": ErrDuplicateGTIN,
+			"products_slug_key": ErrDuplicateSlug,
+		} {
+			mockPool := new(postgres.MockPool)
+			mockRow := new(postgres.MockRow)
+			repo := New(Params{Pool: mockPool})
+
+			mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+			mockRow.On("Scan", anything(4)...).
+				Return(&pgconn.PgError{Code: uniqueViolation, ConstraintName: constraint})
+
+			err := repo.CreateProduct(context.Background(), &models.Product{SKU: "W-1", Slug: "watch"})
+			assert.ErrorIs(t, err, want, constraint)
+		}
+	})
+	t.Run("taken slug inserts nothing", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "ON CONFLICT (slug) DO NOTHING")
+		}), mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(pgx.ErrNoRows)
+
+		err := repo.CreateProduct(context.Background(), &models.Product{Slug: "watch"})
+		assert.ErrorIs(t, err, ErrDuplicateSlug)
 	})
 }
 
+func TestRepository_GetProductBySKU(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRow := new(postgres.MockRow)
+	defer mockPool.AssertExpectations(t)
+
+	repo := New(Params{Pool: mockPool})
+
+	mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "WHERE sku = $1 AND deleted_at IS NULL")
+	}), []any{"W-1"}).Return(mockRow)
+	mockRow.On("Scan", anything(productDetailColumns)...).Run(func(args mock.Arguments) {
+		*args.Get(productDetailColumns - 9).(*string) = "W-1"
+	}).Return(nil)
+
+	p, err := repo.GetProductBySKU(context.Background(), "W-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "W-1", p.SKU)
+}
+
+func TestRepository_GetProductByGTIN(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRow := new(postgres.MockRow)
+
+	repo := New(Params{Pool: mockPool})
+
+	mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "WHERE gtin = $1 AND deleted_at IS NULL")
+	}), []any{"04006381333931"}).Return(mockRow)
+	mockRow.On("Scan", anything(productDetailColumns)...).Return(pgx.ErrNoRows)
+
+	p, err := repo.GetProductByGTIN(context.Background(), "04006381333931")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, p)
+}
+
 func TestRepository_GetProductByID(t *testing.T) {
 	t.Run("error on get product by id", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
@@ -54,8 +175,7 @@ func TestRepository_GetProductByID(t *testing.T) {
 
 		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).
 			Return(mockRow)
-		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
-			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(pgx.ErrNoRows)
+		mockRow.On("Scan", anything(productDetailColumns)...).Return(pgx.ErrNoRows)
 
 		task, err := pool.GetProductByID(context.Background(), 1)
 		assert.NotNil(t, err)
@@ -71,13 +191,78 @@ func TestRepository_GetProductByID(t *testing.T) {
 
 		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).
 			Return(mockRow)
-		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
-			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		mockRow.On("Scan", anything(productDetailColumns)...).Return(nil)
 
 		task, err := pool.GetProductByID(context.Background(), 1)
 		assert.Nil(t, err)
 		assert.NotNil(t, task)
 	})
+	t.Run("available subtracts active reservations", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM stock_reservations r") &&
+				strings.Contains(sql, "r.status = 'active' AND r.expires_at > NOW()")
+		}), mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(productDetailColumns)...).Run(func(args mock.Arguments) {
+			*args.Get(6).(*int) = 5
+			*args.Get(productColumns + 2).(*int) = 3
+			*args.Get(productColumns + 3).(*[]byte) = []byte(`[]`)
+		}).Return(nil)
+
+		p, err := repo.GetProductByID(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, p.Quantity)
+		assert.Equal(t, 3, p.Available)
+	})
+	t.Run("reads the dimensions", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		length, width, height := 160, 80, 10
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(productDetailColumns)...).Run(func(args mock.Arguments) {
+			*args.Get(productDetailColumns - 3).(**int) = &length
+			*args.Get(productDetailColumns - 2).(**int) = &width
+			*args.Get(productDetailColumns - 1).(**int) = &height
+		}).Return(nil)
+
+		p, err := repo.GetProductByID(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Dimensions{LengthMM: 160, WidthMM: 80, HeightMM: 10}, p.Dimensions)
+	})
+	t.Run("embeds images", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM product_images i WHERE i.product_id = p.id")
+		}), mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(productDetailColumns)...).Run(func(args mock.Arguments) {
+			*args.Get(productColumns + 3).(*[]byte) = []byte(`[
+				{"id": 3, "product_id": 1, "key": "products/1/images/a.png", "content_type": "image/png",
+				 "alt_text": "front", "position": 0, "is_primary": true, "created_at": "2024-05-01T10:00:00+00:00",
+				 "width": 640, "height": 480, "renditions": ["thumb"]}
+			]`)
+		}).Return(nil)
+
+		p, err := repo.GetProductByID(context.Background(), 1)
+		assert.NoError(t, err)
+		if assert.Len(t, p.Images, 1) {
+			assert.Equal(t, int64(3), p.Images[0].ID)
+			assert.Equal(t, "products/1/images/a.png", p.Images[0].Key)
+			assert.True(t, p.Images[0].Primary)
+			assert.Equal(t, 2024, p.Images[0].CreatedAt.Year())
+			assert.Equal(t, []string{"thumb"}, p.Images[0].Renditions)
+		}
+	})
 	t.Run("error on get task by id", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
 		mockRow := new(postgres.MockRow)
@@ -88,8 +273,7 @@ func TestRepository_GetProductByID(t *testing.T) {
 
 		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).
 			Return(mockRow)
-		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
-			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("error"))
+		mockRow.On("Scan", anything(productDetailColumns)...).Return(errors.New("error"))
 
 		task, err := pool.GetProductByID(context.Background(), 1)
 		assert.NotNil(t, err)
@@ -110,16 +294,89 @@ func TestRepository_GetAllProducts(t *testing.T) {
 		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(mockRows, nil)
 
 		mockRows.On("Next").Return(true).Once()
-		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
-			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+		mockRows.On("Scan", anything(productListColumns)...).Return(nil).Once()
 
 		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
 		mockRows.On("Close").Return()
 
 		products, err := repo.GetAllProducts(context.Background(), &models.ProductFilterSearch{})
 		assert.NoError(t, err)
 		assert.Len(t, products, 1)
 	})
+	t.Run("stream broken after a row", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+		defer mockRows.AssertExpectations(t)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anything(productListColumns)...).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(errors.New("connection reset"))
+		mockRows.On("Close").Return()
+
+		products, err := repo.GetAllProducts(context.Background(), &models.ProductFilterSearch{})
+		assert.ErrorContains(t, err, "failed to get all products")
+		assert.Nil(t, products)
+	})
+	t.Run("keyset after cursor", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		fs := &models.ProductFilterSearch{
+			Cursor:   &models.ProductCursor{ID: 7, Price: 300, Sort: "-price"},
+			Sort:     models.ProductSort{Field: models.SortByPrice, Desc: true},
+			Currency: "USD",
+			Limit:    11,
+		}
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "p.currency = $1") &&
+				strings.Contains(sql, "("+CurrentPrice+", p.id) < ($2, $3)") &&
+				strings.Contains(sql, "ORDER BY "+CurrentPrice+" DESC, p.id DESC") &&
+				strings.Contains(sql, "LIMIT $4")
+		}), []any{"USD", int64(300), int64(7), 11}).Return(mockRows, nil)
+
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		products, err := repo.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+		assert.Empty(t, products)
+	})
+	t.Run("keyset backward", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		fs := &models.ProductFilterSearch{
+			Cursor: &models.ProductCursor{ID: 7, Sort: "id", Backward: true},
+			Sort:   models.ProductSort{Field: models.SortByID},
+		}
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "p.id < $1") && strings.Contains(sql, "ORDER BY p.id DESC")
+		}), []any{int64(7)}).Return(mockRows, nil)
+
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		_, err := repo.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+	})
 	t.Run("query error", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
 		mockRows := new(postgres.MockRow)
@@ -145,109 +402,863 @@ func TestRepository_GetAllProducts(t *testing.T) {
 
 		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(mockRows, nil)
 		mockRows.On("Next").Return(true).Once()
-		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
-			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("scan failed")).Once()
+		mockRows.On("Scan", anything(productListColumns)...).Return(errors.New("scan failed")).Once()
 		mockRows.On("Close").Return()
 
 		products, err := repo.GetAllProducts(context.Background(), &models.ProductFilterSearch{})
 		assert.Error(t, err)
 		assert.Nil(t, products)
 	})
+	t.Run("category with subcategories", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		fs := &models.ProductFilterSearch{CategoryID: 3, IncludeDescendants: true, Status: models.StatusActive}
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "p.category_id IN (WITH RECURSIVE subtree") &&
+				strings.Contains(sql, "WHERE id = $1 AND deleted_at IS NULL") &&
+				strings.Contains(sql, "p.status = $2")
+		}), []any{int64(3), models.StatusActive}).Return(mockRows, nil)
+
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		_, err := repo.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+	})
+	t.Run("category id only", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "p.category_id = $1") && !strings.Contains(sql, "RECURSIVE")
+		}), []any{int64(3)}).Return(mockRows, nil)
+
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		_, err := repo.GetAllProducts(context.Background(), &models.ProductFilterSearch{CategoryID: 3})
+		assert.NoError(t, err)
+	})
+	t.Run("full-text search ranked by relevance", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		fs := &models.ProductFilterSearch{
+			Search: "iPhone  pro-max!",
+			Sort:   models.ProductSort{Field: models.SortByRelevance, Desc: true},
+		}
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "p.search_vector @@ to_tsquery('english', $1)") &&
+				strings.Contains(sql, "ORDER BY ts_rank(p.search_vector, to_tsquery('english', $1)) DESC, p.id DESC")
+		}), []any{"iPhone:* & pro:* & max:*"}).Return(mockRows, nil)
+
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		_, err := repo.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+	})
+	t.Run("attribute filters", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		ram := 8.0
+		fs := &models.ProductFilterSearch{
+			Status: models.StatusActive,
+			Attributes: []models.AttributeFilter{
+				{Name: "brand", Values: []string{"apple", "true"}},
+				{Name: "ram", Min: &ram},
+			},
+		}
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "p.status = $1") &&
+				strings.Contains(sql, "(p.attributes @> ANY($2::jsonb[]))") &&
+				strings.Contains(sql, "(CASE WHEN jsonb_typeof(p.attributes->$3::text) = 'number'") &&
+				strings.Contains(sql, "(p.attributes->>$3::text)::numeric END >= $4)")
+		}), []any{
+			models.StatusActive,
+			[]map[string]any{{"brand": "apple"}, {"brand": "true"}, {"brand": true}},
+			"ram", 8.0,
+		}).Return(mockRows, nil)
+
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		_, err := repo.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+	})
+}
+
+func TestRepository_ProductFacets(t *testing.T) {
+	t.Run("groups the counts by attribute", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		fs := &models.ProductFilterSearch{
+			CategoryID: 3,
+			Attributes: []models.AttributeFilter{{Name: "ram", Values: []string{"8"}}},
+		}
+
+		// the ram filter narrows every facet but its own
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "p.category_id = $1") &&
+				strings.Contains(sql, "((p.attributes @> ANY($2::jsonb[])) OR a.key = $3)") &&
+				strings.Contains(sql, "WHERE pos <= $4")
+		}), []any{int64(3), []map[string]any{{"ram": "8"}, {"ram": 8.0}}, "ram", maxFacetValues}).
+			Return(mockRows, nil)
+
+		rows := []struct {
+			value any
+			key   string
+			count int
+		}{{"apple", "brand", 5}, {"dell", "brand", 2}, {8.0, "ram", 4}}
+		for _, row := range rows {
+			mockRows.On("Next").Return(true).Once()
+			mockRows.On("Scan", anything(3)...).Run(func(args mock.Arguments) {
+				*args.Get(0).(*string) = row.key
+				*args.Get(1).(*any) = row.value
+				*args.Get(2).(*int) = row.count
+			}).Return(nil).Once()
+		}
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		facets, err := repo.ProductFacets(context.Background(), fs)
+		assert.NoError(t, err)
+		if assert.Len(t, facets, 2) {
+			assert.Equal(t, "brand", facets[0].Attribute)
+			assert.Equal(t, []*models.FacetValue{{Value: "apple", Count: 5}, {Value: "dell", Count: 2}}, facets[0].Values)
+			assert.Equal(t, "ram", facets[1].Attribute)
+			assert.Equal(t, []*models.FacetValue{{Value: 8.0, Count: 4}}, facets[1].Values)
+		}
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db error"))
+
+		facets, err := repo.ProductFacets(context.Background(), &models.ProductFilterSearch{})
+		assert.Error(t, err)
+		assert.Nil(t, facets)
+	})
 }
 
 func TestRepository_UpdateProduct(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 
 		repo := New(Params{Pool: mockPool})
 
 		ctx := context.Background()
-		tag := pgconn.NewCommandTag("UPDATE 1")
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(tag, nil)
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			version := int64(2)
+			*args.Get(0).(**int64) = &version
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
 
-		err := repo.UpdateProduct(ctx, &models.Product{ID: 1})
+		p := &models.Product{ID: 1, Version: 1}
+		err := repo.UpdateProduct(ctx, p)
 		assert.NoError(t, err)
+		assert.Equal(t, int64(2), p.Version)
+	})
+
+	t.Run("records the status change with the caller", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		ctx := models.WithPrincipal(context.Background(), models.Principal{Role: "admin", UserID: 7})
+		actor := int64(7)
+		p := &models.Product{
+			ID: 1, Title: "watch", CategoryID: 1, RegularPrice: usd(1000), Quantity: 1, Status: "active", Version: 1,
+		}
+		mockPool.On("QueryRow", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO product_status_history") &&
+				strings.Contains(sql, "WHERE old.status <> upd.status")
+		}), []any{"watch", 1, int64(1000), "", "active", int64(1), int64(1), &actor, (*int)(nil),
+			map[string]any{}, "", "", "", "", "", (*int)(nil), (*int)(nil), (*int)(nil), (*int)(nil)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			version := int64(2)
+			*args.Get(0).(**int64) = &version
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		assert.NoError(t, repo.UpdateProduct(ctx, p))
+	})
+
+	t.Run("a new regular price ends the one before", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		ctx := context.Background()
+		mockPool.On("QueryRow", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "UPDATE product_prices pp SET valid_to = NOW()") &&
+				strings.Contains(sql, "INSERT INTO product_prices") &&
+				strings.Contains(sql, "WHERE old.price <> $3")
+		}), mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			version := int64(2)
+			*args.Get(0).(**int64) = &version
+		}).Return(nil)
+
+		assert.NoError(t, repo.UpdateProduct(ctx, &models.Product{ID: 1, RegularPrice: usd(900), Version: 1}))
 	})
 
 	t.Run("not found", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 
 		repo := New(Params{Pool: mockPool})
 
 		ctx := context.Background()
-		tag := pgconn.NewCommandTag("UPDATE 0")
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(tag, nil)
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(nil)
 
 		err := repo.UpdateProduct(ctx, &models.Product{ID: 2})
+		assert.ErrorIs(t, err, ErrNotFound)
 		assert.EqualError(t, err, "product not found")
 	})
 
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		ctx := context.Background()
+		mockPool.On("QueryRow", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHERE id = $6 AND version = $7")
+		}), mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		err := repo.UpdateProduct(ctx, &models.Product{ID: 2, Version: 1})
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+
 	t.Run("exec error", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 
 		repo := New(Params{Pool: mockPool})
 
 		ctx := context.Background()
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(pgconn.CommandTag{}, errors.New("some error"))
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(errors.New("some error"))
 
 		err := repo.UpdateProduct(ctx, &models.Product{ID: 3})
 		assert.Error(t, err)
 	})
 }
 
+func TestRepository_ListStatusHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM product_status_history")
+		}), []any{int64(1)}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anything(6)...).Run(func(args mock.Arguments) {
+			*args.Get(2).(*string) = "draft"
+			*args.Get(3).(*string) = "active"
+		}).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		history, err := repo.ListStatusHistory(context.Background(), 1)
+		assert.NoError(t, err)
+		if assert.Len(t, history, 1) {
+			assert.Equal(t, "draft", history[0].FromStatus)
+			assert.Equal(t, "active", history[0].ToStatus)
+		}
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db error"))
+
+		_, err := repo.ListStatusHistory(context.Background(), 1)
+		assert.Error(t, err)
+	})
+}
+
+func TestRepository_CreatePrice(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		from := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+		to := from.AddDate(0, 0, 2)
+		pr := &models.ProductPrice{ProductID: 1, Price: usd(800), ValidFrom: from, ValidTo: &to}
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO product_prices") && strings.Contains(sql, "deleted_at IS NULL")
+		}), []any{int64(1), int64(800), "USD", from, &to, (*int64)(nil)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int64) = 5
+		}).Return(nil)
+
+		assert.NoError(t, repo.CreatePrice(context.Background(), pr))
+		assert.Equal(t, int64(5), pr.ID)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(pgx.ErrNoRows)
+
+		err := repo.CreatePrice(context.Background(), &models.ProductPrice{ProductID: 1, Price: usd(800)})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRepository_ListPrices(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM product_prices") && strings.Contains(sql, "ORDER BY valid_from, id")
+		}), []any{int64(1)}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anything(8)...).Run(func(args mock.Arguments) {
+			*args.Get(2).(*int64) = 1000
+			*args.Get(3).(*string) = "USD"
+		}).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		prices, err := repo.ListPrices(context.Background(), 1)
+		assert.NoError(t, err)
+		if assert.Len(t, prices, 1) {
+			assert.Equal(t, usd(1000), prices[0].Price)
+		}
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db error"))
+
+		_, err := repo.ListPrices(context.Background(), 1)
+		assert.Error(t, err)
+	})
+}
+
 func TestRepository_DeleteProduct(t *testing.T) {
-	t.Run("success delete", func(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
 
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(pgconn.NewCommandTag("DELETE 1"), nil)
+		mockPool.On("QueryRow", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHERE id = $1 AND version = $2 AND deleted_at IS NULL")
+		}), []any{int64(1), int64(2)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*bool) = true
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
 
-		err := repo.DeleteProduct(ctx, 1)
-		assert.NoError(t, err)
+		assert.NoError(t, repo.DeleteProduct(ctx, 1, 2))
 	})
 	t.Run("not found", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(pgconn.NewCommandTag("DELETE 0"), nil)
-		err := repo.DeleteProduct(ctx, 2)
-		assert.EqualError(t, err, "product not found")
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(nil)
+
+		err := repo.DeleteProduct(ctx, 2, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
 	})
-	t.Run("exec error", func(t *testing.T) {
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		repo := New(Params{Pool: mockPool})
+		ctx := context.Background()
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		err := repo.DeleteProduct(ctx, 2, 1)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+	t.Run("query error", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(pgconn.CommandTag{}, errors.New("some error"))
-		err := repo.DeleteProduct(ctx, 3)
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(errors.New("some error"))
+
+		err := repo.DeleteProduct(ctx, 3, 1)
 		assert.Error(t, err)
 	})
 }
 
 func Test_repository_RestoreProduct(t *testing.T) {
-	t.Run("success restore", func(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(pgconn.NewCommandTag("UPDATE 1"), nil)
-		err := repo.RestoreProduct(ctx, 1)
-		assert.NoError(t, err)
+		mockPool.On("QueryRow", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHERE id = $1 AND version = $2 AND deleted_at IS NOT NULL")
+		}), []any{int64(1), int64(2)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*bool) = true
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		assert.NoError(t, repo.RestoreProduct(ctx, 1, 2))
 	})
 	t.Run("not found", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(pgconn.NewCommandTag("UPDATE 0"), nil)
-		err := repo.RestoreProduct(ctx, 2)
-		assert.EqualError(t, err, "product not found")
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(nil)
+
+		err := repo.RestoreProduct(ctx, 2, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
 	})
-	t.Run("exec error", func(t *testing.T) {
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		repo := New(Params{Pool: mockPool})
+		ctx := context.Background()
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		err := repo.RestoreProduct(ctx, 2, 1)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+	t.Run("query error", func(t *testing.T) {
 		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
 		repo := New(Params{Pool: mockPool})
 		ctx := context.Background()
-		mockPool.On("Exec", ctx, mock.Anything, mock.Anything).Return(pgconn.CommandTag{}, errors.New("some error"))
-		err := repo.RestoreProduct(ctx, 3)
+		mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(errors.New("some error"))
+
+		err := repo.RestoreProduct(ctx, 3, 1)
+		assert.Error(t, err)
+	})
+}
+
+func TestRepository_ExistingCategories(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, []any{[]int{1, 2}}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int) = 2
+		}).Return(nil).Once()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		existing, err := repo.ExistingCategories(context.Background(), []int{1, 2})
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]bool{2: true}, existing)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db error"))
+
+		existing, err := repo.ExistingCategories(context.Background(), []int{1})
+		assert.Error(t, err)
+		assert.Nil(t, existing)
+	})
+}
+
+func TestRepository_TakenSlugs(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRows := new(postgres.MockRow)
+
+	defer mockPool.AssertExpectations(t)
+	defer mockRows.AssertExpectations(t)
+
+	repo := New(Params{Pool: mockPool})
+
+	mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "regexp_replace(slug, '-[0-9]+$', '') = ANY($1)")
+	}), []any{[]string{"watch"}}).Return(mockRows, nil)
+	for _, slug := range []string{"watch", "watch-2"} {
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = slug
+		}).Return(nil).Once()
+	}
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return()
+
+	taken, err := repo.TakenSlugs(context.Background(), []string{"watch"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"watch": true, "watch-2": true}, taken)
+}
+
+func TestRepository_TakenIdentifiers(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRows := new(postgres.MockRow)
+
+	defer mockPool.AssertExpectations(t)
+	defer mockRows.AssertExpectations(t)
+
+	repo := New(Params{Pool: mockPool})
+
+	skus, gtins := []string{"W-1", "W-2"}, []string{"04006381333931"}
+	mockPool.On("Query", mock.Anything, mock.Anything, []any{skus, gtins}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*string) = "W-2"
+	}).Return(nil).Once()
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*string) = "04006381333931"
+	}).Return(nil).Once()
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return()
+
+	takenSKUs, takenGTINs, err := repo.TakenIdentifiers(context.Background(), skus, gtins)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"W-2": true}, takenSKUs)
+	assert.Equal(t, map[string]bool{"04006381333931": true}, takenGTINs)
+}
+
+func TestRepository_ListTranslations(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRows := new(postgres.MockRow)
+
+	defer mockPool.AssertExpectations(t)
+	defer mockRows.AssertExpectations(t)
+
+	repo := New(Params{Pool: mockPool})
+
+	mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "FROM product_translations") && strings.Contains(sql, "ORDER BY locale")
+	}), []any{int64(1)}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", anything(5)...).Run(func(args mock.Arguments) {
+		*args.Get(0).(*int64) = 1
+		*args.Get(1).(*string) = "de"
+		*args.Get(2).(*string) = "Uhr"
+	}).Return(nil).Once()
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return()
+
+	trs, err := repo.ListTranslations(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.ProductTranslation{{ProductID: 1, Locale: "de", Title: "Uhr"}}, trs)
+}
+
+func TestRepository_UpsertTranslation(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		now := time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "ON CONFLICT (product_id, locale) DO UPDATE") &&
+				strings.Contains(sql, "deleted_at IS NULL")
+		}), []any{int64(1), "de", "Uhr", "Eine Uhr"}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*time.Time) = now
+		}).Return(nil)
+
+		tr := &models.ProductTranslation{ProductID: 1, Locale: "de", Title: "Uhr", Description: "Eine Uhr"}
+		assert.NoError(t, repo.UpsertTranslation(context.Background(), tr))
+		assert.Equal(t, now, tr.UpdatedAt)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Return(pgx.ErrNoRows)
+
+		err := repo.UpsertTranslation(context.Background(), &models.ProductTranslation{ProductID: 1, Locale: "de"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRepository_BestTranslations(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRows := new(postgres.MockRow)
+
+	defer mockPool.AssertExpectations(t)
+	defer mockRows.AssertExpectations(t)
+
+	repo := New(Params{Pool: mockPool})
+
+	ids, locales := []int64{1, 2}, []string{"de-CH", "de"}
+	mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "DISTINCT ON (product_id)") &&
+			strings.Contains(sql, "ORDER BY product_id, array_position($2, locale)")
+	}), []any{ids, locales}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", anything(5)...).Run(func(args mock.Arguments) {
+		*args.Get(0).(*int64) = 2
+		*args.Get(1).(*string) = "de"
+		*args.Get(2).(*string) = "Uhr"
+	}).Return(nil).Once()
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return()
+
+	best, err := repo.BestTranslations(context.Background(), ids, locales)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]*models.ProductTranslation{2: {ProductID: 2, Locale: "de", Title: "Uhr"}}, best)
+}
+
+func TestRepository_AttributeDefinitions(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRows := new(postgres.MockRow)
+
+	defer mockPool.AssertExpectations(t)
+	defer mockRows.AssertExpectations(t)
+
+	repo := New(Params{Pool: mockPool})
+
+	mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "FROM category_attributes WHERE category_id = $1")
+	}), []any{3}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", anything(4)...).Run(func(args mock.Arguments) {
+		*args.Get(2).(*string) = "brand"
+		*args.Get(3).(*string) = models.AttributeString
+	}).Return(nil).Once()
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return()
+
+	defs, err := repo.AttributeDefinitions(context.Background(), 3)
+	assert.NoError(t, err)
+	if assert.Len(t, defs, 1) {
+		assert.Equal(t, "brand", defs[0].Name)
+		assert.Equal(t, models.AttributeString, defs[0].Type)
+	}
+}
+
+func TestRepository_ImportProducts(t *testing.T) {
+	t.Run("copies in batches and commits", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx := new(postgres.MockTx)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockTx.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		ps := make([]*models.Product, importBatchSize+1)
+		for i := range ps {
+			ps[i] = &models.Product{Title: "watch", Price: usd(100), Quantity: 1, Status: "available"}
+		}
+
+		mockRow := new(postgres.MockRow)
+		mockPool.On("Begin", mock.Anything).Return(mockTx, nil)
+		mockTx.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int64) = 40
+		}).Return(nil)
+		mockTx.On("CopyFrom", mock.Anything, pgx.Identifier{"products"}, importColumns, mock.Anything).
+			Return(int64(importBatchSize), nil).Once()
+		mockTx.On("CopyFrom", mock.Anything, pgx.Identifier{"products"}, importColumns, mock.Anything).
+			Return(int64(1), nil).Once()
+		mockTx.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO stock_movements") && strings.Contains(sql, "'import'")
+		}), []any{int64(40), (*int64)(nil)}).Return(pgconn.CommandTag{}, nil)
+		mockTx.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO product_prices")
+		}), []any{int64(40), (*int64)(nil)}).Return(pgconn.CommandTag{}, nil)
+		mockTx.On("Commit", mock.Anything).Return(nil)
+		mockTx.On("Rollback", mock.Anything).Return(pgx.ErrTxClosed)
+
+		inserted, err := repo.ImportProducts(context.Background(), ps)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(importBatchSize+1), inserted)
+	})
+	t.Run("copy error rolls back", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx := new(postgres.MockTx)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockTx.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockRow := new(postgres.MockRow)
+		mockPool.On("Begin", mock.Anything).Return(mockTx, nil)
+		mockTx.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Return(nil)
+		mockTx.On("CopyFrom", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(int64(0), errors.New("copy failed"))
+		mockTx.On("Rollback", mock.Anything).Return(nil)
+
+		inserted, err := repo.ImportProducts(context.Background(), []*models.Product{{Title: "watch"}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to import products")
+		assert.Zero(t, inserted)
+	})
+	t.Run("begin error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Begin", mock.Anything).Return((*postgres.MockTx)(nil), errors.New("db down"))
+
+		_, err := repo.ImportProducts(context.Background(), []*models.Product{{Title: "watch"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestRepository_ExportProducts(t *testing.T) {
+	t.Run("streams rows with category name", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		defer mockPool.AssertExpectations(t)
+		defer mockRows.AssertExpectations(t)
+
+		repo := New(Params{Pool: mockPool})
+
+		fs := &models.ProductFilterSearch{Status: "available", Sort: models.ProductSort{Field: models.SortByPrice, Desc: true}}
+		mockPool.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "COALESCE(c.name, '')") &&
+				strings.Contains(sql, "p.status = $1") &&
+				strings.Contains(sql, "ORDER BY "+CurrentPrice+" DESC, p.id DESC") &&
+				!strings.Contains(sql, "LIMIT $")
+		}), []any{"available"}).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Twice()
+		mockRows.On("Scan", anything(productExportColumns)...).Run(func(args mock.Arguments) {
+			*args.Get(3).(*string) = "watches"
+		}).Return(nil).Twice()
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Err").Return(nil)
+		mockRows.On("Close").Return()
+
+		var got []string
+		err := repo.ExportProducts(context.Background(), fs, func(p *models.Product) error {
+			got = append(got, p.CategoryName)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"watches", "watches"}, got)
+	})
+	t.Run("callback error stops the stream", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRows := new(postgres.MockRow)
+
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).Return(mockRows, nil)
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anything(productExportColumns)...).Return(nil).Once()
+		mockRows.On("Close").Return()
+
+		writeErr := errors.New("client went away")
+		err := repo.ExportProducts(context.Background(), &models.ProductFilterSearch{}, func(*models.Product) error {
+			return writeErr
+		})
+		assert.ErrorIs(t, err, writeErr)
+		mockRows.AssertExpectations(t)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		repo := New(Params{Pool: mockPool})
+
+		mockPool.On("Query", mock.Anything, mock.Anything, mock.Anything).
+			Return((*postgres.MockRow)(nil), errors.New("db error"))
+
+		err := repo.ExportProducts(context.Background(), &models.ProductFilterSearch{}, func(*models.Product) error {
+			return nil
+		})
 		assert.Error(t, err)
 	})
 }
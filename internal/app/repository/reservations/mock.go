@@ -0,0 +1,49 @@
+package reservations
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Available(ctx context.Context, productID int64, variantID *int64) (int, error) {
+	args := m.Called(ctx, productID, variantID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepo) Create(ctx context.Context, r *models.Reservation, ttl time.Duration) error {
+	args := m.Called(ctx, r, ttl)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Get(ctx context.Context, id int64) (*models.Reservation, error) {
+	args := m.Called(ctx, id)
+	if r, ok := args.Get(0).(*models.Reservation); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) Lock(ctx context.Context, id int64) (*models.Reservation, error) {
+	args := m.Called(ctx, id)
+	if r, ok := args.Get(0).(*models.Reservation); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) SetStatus(ctx context.Context, r *models.Reservation, status string) error {
+	args := m.Called(ctx, r, status)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Expire(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,155 @@
+package reservations
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	Available(ctx context.Context, productID int64, variantID *int64) (int, error)
+	Create(ctx context.Context, r *models.Reservation, ttl time.Duration) error
+	Get(ctx context.Context, id int64) (*models.Reservation, error)
+	Lock(ctx context.Context, id int64) (*models.Reservation, error)
+	SetStatus(ctx context.Context, r *models.Reservation, status string) error
+	Expire(ctx context.Context) (int64, error)
+}
+
+var (
+	ErrNotFound        = errors.New("reservation not found")
+	ErrProductNotFound = errors.New("product not found")
+	ErrVariantNotFound = errors.New("variant not found")
+)
+
+// reserved sums what the active holds of a product or variant take from
+// its stock. Expired holds stop counting before the reaper gets to them.
+const reserved = `COALESCE((
+		SELECT SUM(quantity) FROM stock_reservations
+		WHERE product_id = $1 AND variant_id IS NOT DISTINCT FROM $2
+			AND status = 'active' AND expires_at > NOW()
+	), 0)`
+
+// reservationColumns reports a hold past its expiry as expired, whether or
+// not the reaper has marked it yet.
+const reservationColumns = `id, product_id, variant_id, quantity,
+	CASE WHEN status = 'active' AND expires_at <= NOW() THEN 'expired' ELSE status END,
+	reference, actor_id, expires_at, created_at, updated_at`
+
+type Params struct {
+	fx.In
+
+	Pool postgres.Pool `name:"app_postgres"`
+}
+
+type repository struct {
+	pool postgres.Pool `name:"app_postgres"`
+}
+
+func New(p Params) Repository {
+	return &repository{pool: p.Pool}
+}
+
+// Available locks the stock row of the product, or of the variant when
+// variantID is set, and returns its quantity minus the active holds. The
+// lock lasts until the surrounding transaction ends, so concurrent holds
+// are checked one after another.
+func (r *repository) Available(ctx context.Context, productID int64, variantID *int64) (int, error) {
+	query := `
+	SELECT s.quantity - ` + reserved + `
+	FROM (SELECT quantity FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE) s
+`
+	notFound := ErrProductNotFound
+	if variantID != nil {
+		query = `
+	SELECT s.quantity - ` + reserved + `
+	FROM (
+		SELECT v.quantity FROM product_variants v JOIN products p ON p.id = v.product_id
+		WHERE v.id = $2 AND v.product_id = $1 AND v.deleted_at IS NULL AND p.deleted_at IS NULL
+		FOR UPDATE OF v
+	) s
+`
+		notFound = ErrVariantNotFound
+	}
+
+	var available int
+	if err := postgres.Conn(ctx, r.pool).QueryRow(ctx, query, productID, variantID).Scan(&available); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, notFound
+		}
+		return 0, errors.New("failed to get available stock: " + err.Error() + "")
+	}
+	return available, nil
+}
+
+// Create stores an active hold that expires ttl from now, with the caller
+// from ctx as actor.
+func (r *repository) Create(ctx context.Context, res *models.Reservation, ttl time.Duration) error {
+	res.ActorID = models.ActorID(ctx)
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	INSERT INTO stock_reservations (product_id, variant_id, quantity, reference, actor_id, expires_at)
+	VALUES ($1, $2, $3, $4, $5, NOW() + make_interval(secs => $6))
+	RETURNING id, status, expires_at, created_at, updated_at
+`, res.ProductID, res.VariantID, res.Quantity, res.Reference, res.ActorID, ttl.Seconds()).
+		Scan(&res.ID, &res.Status, &res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt)
+	if err != nil {
+		return errors.New("failed to create reservation: " + err.Error() + "")
+	}
+	return nil
+}
+
+func (r *repository) Get(ctx context.Context, id int64) (*models.Reservation, error) {
+	return r.get(ctx, `SELECT `+reservationColumns+` FROM stock_reservations WHERE id = $1`, id)
+}
+
+// Lock is Get that also locks the reservation until the surrounding
+// transaction ends.
+func (r *repository) Lock(ctx context.Context, id int64) (*models.Reservation, error) {
+	return r.get(ctx, `SELECT `+reservationColumns+` FROM stock_reservations WHERE id = $1 FOR UPDATE`, id)
+}
+
+func (r *repository) get(ctx context.Context, query string, id int64) (*models.Reservation, error) {
+	var res models.Reservation
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, query, id).Scan(&res.ID, &res.ProductID, &res.VariantID,
+		&res.Quantity, &res.Status, &res.Reference, &res.ActorID, &res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to get reservation: " + err.Error() + "")
+	}
+	return &res, nil
+}
+
+func (r *repository) SetStatus(ctx context.Context, res *models.Reservation, status string) error {
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	UPDATE stock_reservations SET status = $2, updated_at = NOW()
+	WHERE id = $1
+	RETURNING updated_at
+`, res.ID, status).Scan(&res.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return errors.New("failed to update reservation: " + err.Error() + "")
+	}
+	res.Status = status
+	return nil
+}
+
+// Expire marks the active holds past their expiry as expired and returns
+// how many there were.
+func (r *repository) Expire(ctx context.Context) (int64, error) {
+	tag, err := postgres.Conn(ctx, r.pool).Exec(ctx, `
+	UPDATE stock_reservations SET status = 'expired', updated_at = NOW()
+	WHERE status = 'active' AND expires_at <= NOW()
+`)
+	if err != nil {
+		return 0, errors.New("failed to expire reservations: " + err.Error() + "")
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,172 @@
+package reservations
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+	"strings"
+	"testing"
+	"time"
+)
+
+const reservationColumnCount = 10
+
+func anything(n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = mock.Anything
+	}
+	return args
+}
+
+func sqlContains(part string) any {
+	return mock.MatchedBy(func(sql string) bool { return strings.Contains(sql, part) })
+}
+
+func TestRepository_Available(t *testing.T) {
+	t.Run("product", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE") &&
+				strings.Contains(sql, "variant_id IS NOT DISTINCT FROM $2")
+		}), []any{int64(1), (*int64)(nil)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int)) = 4
+		}).Return(nil)
+
+		available, err := New(Params{Pool: mockPool}).Available(context.Background(), 1, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, available)
+	})
+	t.Run("variant not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		variantID := int64(2)
+		mockPool.On("QueryRow", mock.Anything, sqlContains("FOR UPDATE OF v"), []any{int64(1), &variantID}).
+			Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Return(pgx.ErrNoRows)
+
+		_, err := New(Params{Pool: mockPool}).Available(context.Background(), 1, &variantID)
+		assert.ErrorIs(t, err, ErrVariantNotFound)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Return(pgx.ErrNoRows)
+
+		_, err := New(Params{Pool: mockPool}).Available(context.Background(), 1, nil)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+}
+
+func TestRepository_Create(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		ctx := models.WithPrincipal(context.Background(), models.Principal{Role: "admin", UserID: 7})
+		actor := int64(7)
+		mockPool.On("QueryRow", ctx, sqlContains("make_interval(secs => $6)"),
+			[]any{int64(1), (*int64)(nil), 2, "cart 9", &actor, float64(300)}).Return(mockRow)
+		mockRow.On("Scan", anything(5)...).Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 5
+			*(args.Get(1).(*string)) = models.ReservationActive
+		}).Return(nil)
+
+		res := &models.Reservation{ProductID: 1, Quantity: 2, Reference: "cart 9"}
+		err := New(Params{Pool: mockPool}).Create(ctx, res, 5*time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), res.ID)
+		assert.Equal(t, models.ReservationActive, res.Status)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(5)...).Return(errors.New("db down"))
+
+		err := New(Params{Pool: mockPool}).Create(context.Background(), &models.Reservation{}, time.Minute)
+		assert.ErrorContains(t, err, "failed to create reservation")
+	})
+}
+
+func TestRepository_Lock(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FOR UPDATE") &&
+				strings.Contains(sql, "WHEN status = 'active' AND expires_at <= NOW() THEN 'expired'")
+		}), []any{int64(5)}).Return(mockRow)
+		mockRow.On("Scan", anything(reservationColumnCount)...).Run(func(args mock.Arguments) {
+			*(args.Get(0).(*int64)) = 5
+		}).Return(nil)
+
+		res, err := New(Params{Pool: mockPool}).Lock(context.Background(), 5)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), res.ID)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(reservationColumnCount)...).Return(pgx.ErrNoRows)
+
+		_, err := New(Params{Pool: mockPool}).Get(context.Background(), 5)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRepository_SetStatus(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRow := new(postgres.MockRow)
+	defer mockPool.AssertExpectations(t)
+
+	mockPool.On("QueryRow", mock.Anything, sqlContains("UPDATE stock_reservations SET status = $2"),
+		[]any{int64(5), models.ReservationReleased}).Return(mockRow)
+	mockRow.On("Scan", mock.Anything).Return(nil)
+
+	res := &models.Reservation{ID: 5, Status: models.ReservationActive}
+	err := New(Params{Pool: mockPool}).SetStatus(context.Background(), res, models.ReservationReleased)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReservationReleased, res.Status)
+}
+
+func TestRepository_Expire(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		defer mockPool.AssertExpectations(t)
+
+		mockPool.On("Exec", mock.Anything, sqlContains("WHERE status = 'active' AND expires_at <= NOW()"), []any(nil)).
+			Return(pgconn.NewCommandTag("UPDATE 3"), nil)
+
+		n, err := New(Params{Pool: mockPool}).Expire(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), n)
+	})
+	t.Run("exec error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+
+		mockPool.On("Exec", mock.Anything, mock.Anything, mock.Anything).
+			Return(pgconn.CommandTag{}, errors.New("db down"))
+
+		_, err := New(Params{Pool: mockPool}).Expire(context.Background())
+		assert.ErrorContains(t, err, "failed to expire reservations")
+	})
+}
@@ -0,0 +1,68 @@
+package variants
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) LockProduct(ctx context.Context, productID int64) (string, error) {
+	args := m.Called(ctx, productID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepo) ListOptions(ctx context.Context, productID int64) ([]*models.ProductOption, error) {
+	args := m.Called(ctx, productID)
+	if opts, ok := args.Get(0).([]*models.ProductOption); ok {
+		return opts, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) ReplaceOptions(
+	ctx context.Context, productID, version int64, opts []*models.ProductOption,
+) (int64, error) {
+	args := m.Called(ctx, productID, version, opts)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) ListVariants(ctx context.Context, productID int64) ([]*models.ProductVariant, error) {
+	args := m.Called(ctx, productID)
+	if variants, ok := args.Get(0).([]*models.ProductVariant); ok {
+		return variants, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) GetVariant(ctx context.Context, productID, variantID int64) (*models.ProductVariant, error) {
+	args := m.Called(ctx, productID, variantID)
+	if v, ok := args.Get(0).(*models.ProductVariant); ok {
+		return v, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepo) CreateVariant(ctx context.Context, v *models.ProductVariant) error {
+	args := m.Called(ctx, v)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateVariant(ctx context.Context, v *models.ProductVariant) error {
+	args := m.Called(ctx, v)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Reserved(ctx context.Context, productID, variantID int64) (bool, error) {
+	args := m.Called(ctx, productID, variantID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) DeleteVariant(ctx context.Context, productID, variantID, version int64) error {
+	args := m.Called(ctx, productID, variantID, version)
+	return args.Error(0)
+}
@@ -0,0 +1,352 @@
+package variants
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/fx"
+)
+
+type Repository interface {
+	LockProduct(ctx context.Context, productID int64) (string, error)
+	ListOptions(ctx context.Context, productID int64) ([]*models.ProductOption, error)
+	ReplaceOptions(ctx context.Context, productID, version int64, opts []*models.ProductOption) (int64, error)
+	ListVariants(ctx context.Context, productID int64) ([]*models.ProductVariant, error)
+	GetVariant(ctx context.Context, productID, variantID int64) (*models.ProductVariant, error)
+	CreateVariant(ctx context.Context, v *models.ProductVariant) error
+	UpdateVariant(ctx context.Context, v *models.ProductVariant) error
+	Reserved(ctx context.Context, productID, variantID int64) (bool, error)
+	DeleteVariant(ctx context.Context, productID, variantID, version int64) error
+}
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrNotFound        = errors.New("variant not found")
+	ErrVersionConflict = errors.New("variant version conflict")
+	ErrProductConflict = errors.New("product version conflict")
+	ErrDuplicateSKU    = errors.New("sku is already in use")
+	ErrDuplicate       = errors.New("a variant with these options already exists")
+)
+
+const uniqueViolation = "23505"
+
+// variantColumns selects a variant joined with its product as p, the
+// price falls back to the product price and is in the product currency.
+const variantColumns = `v.id, v.product_id, v.sku, v.options, v.price, COALESCE(v.price, p.price), p.currency,
+	v.quantity, v.created_at, v.updated_at, v.version`
+
+type Params struct {
+	fx.In
+
+	Pool postgres.Pool `name:"app_postgres"`
+}
+
+type repository struct {
+	pool postgres.Pool `name:"app_postgres"`
+}
+
+func New(p Params) Repository {
+	return &repository{pool: p.Pool}
+}
+
+// LockProduct locks the product row until the transaction in ctx ends, so
+// option and variant changes of one product apply one after another. It
+// returns the currency of the product, variant prices have to be in it.
+func (r *repository) LockProduct(ctx context.Context, productID int64) (string, error) {
+	var currency string
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	SELECT currency FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+`, productID).Scan(&currency)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrProductNotFound
+		}
+		return "", errors.New("failed to lock product: " + err.Error() + "")
+	}
+	return currency, nil
+}
+
+func (r *repository) ListOptions(ctx context.Context, productID int64) ([]*models.ProductOption, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT id, product_id, name, option_values, position
+	FROM product_options
+	WHERE product_id = $1
+	ORDER BY position, id
+`, productID)
+	if err != nil {
+		return nil, errors.New("failed to get options: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	opts := []*models.ProductOption{}
+	for rows.Next() {
+		var opt models.ProductOption
+		if err = rows.Scan(&opt.ID, &opt.ProductID, &opt.Name, &opt.Values, &opt.Position); err != nil {
+			return nil, errors.New("failed to scan option: " + err.Error() + "")
+		}
+		opts = append(opts, &opt)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get options: " + err.Error() + "")
+	}
+	return opts, nil
+}
+
+// ReplaceOptions swaps the option definitions of a product for opts, which
+// are stored in the given order. The options are part of the product, so
+// they are only written while the product still is at version, which is
+// bumped. It returns the new version of the product.
+func (r *repository) ReplaceOptions(
+	ctx context.Context, productID, version int64, opts []*models.ProductOption,
+) (int64, error) {
+	tx, err := postgres.Begin(ctx, r.pool)
+	if err != nil {
+		return 0, errors.New("failed to begin transaction: " + err.Error() + "")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	err = tx.QueryRow(ctx, `
+	UPDATE products SET version = version + 1, updated_at = NOW()
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	RETURNING version
+`, productID, version).Scan(&version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrProductConflict
+		}
+		return 0, errors.New("failed to replace options: " + err.Error() + "")
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM product_options WHERE product_id = $1`, productID); err != nil {
+		return 0, errors.New("failed to replace options: " + err.Error() + "")
+	}
+	for i, opt := range opts {
+		opt.ProductID, opt.Position = productID, i
+		if err = tx.QueryRow(ctx, `
+	INSERT INTO product_options (product_id, name, option_values, position)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id
+`, productID, opt.Name, opt.Values, opt.Position).Scan(&opt.ID); err != nil {
+			return 0, errors.New("failed to replace options: " + err.Error() + "")
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, errors.New("failed to commit transaction: " + err.Error() + "")
+	}
+	return version, nil
+}
+
+func (r *repository) ListVariants(ctx context.Context, productID int64) ([]*models.ProductVariant, error) {
+	rows, err := postgres.Conn(ctx, r.pool).Query(ctx, `
+	SELECT `+variantColumns+`
+	FROM product_variants v
+	JOIN products p ON p.id = v.product_id
+	WHERE v.product_id = $1 AND v.deleted_at IS NULL
+	ORDER BY v.id
+`, productID)
+	if err != nil {
+		return nil, errors.New("failed to get variants: " + err.Error() + "")
+	}
+	defer rows.Close()
+
+	variants := []*models.ProductVariant{}
+	for rows.Next() {
+		v, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("failed to get variants: " + err.Error() + "")
+	}
+	return variants, nil
+}
+
+func (r *repository) GetVariant(ctx context.Context, productID, variantID int64) (*models.ProductVariant, error) {
+	return scanVariant(postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	SELECT `+variantColumns+`
+	FROM product_variants v
+	JOIN products p ON p.id = v.product_id
+	WHERE v.product_id = $1 AND v.id = $2 AND v.deleted_at IS NULL AND p.deleted_at IS NULL
+`, productID, variantID))
+}
+
+func (r *repository) CreateVariant(ctx context.Context, v *models.ProductVariant) error {
+	options, err := json.Marshal(v.Options)
+	if err != nil {
+		return errors.New("failed to encode variant options: " + err.Error() + "")
+	}
+	err = postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	WITH v AS (
+		INSERT INTO product_variants (product_id, sku, options, price, quantity)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *
+	), m AS (
+		INSERT INTO stock_movements (product_id, variant_id, kind, delta, balance, reason, actor_id)
+		SELECT product_id, id, 'receipt', quantity, quantity, 'initial stock', $6 FROM v WHERE quantity > 0
+	)
+	SELECT COALESCE(v.price, p.price), p.currency, v.id, v.created_at, v.updated_at, v.version
+	FROM v
+	JOIN products p ON p.id = v.product_id
+`, v.ProductID, v.SKU, string(options), amount(v.Price), v.Quantity, models.ActorID(ctx)).
+		Scan(&v.EffectivePrice.Amount, &v.EffectivePrice.Currency, &v.ID, &v.CreatedAt, &v.UpdatedAt, &v.Version)
+	if err != nil {
+		return writeError("failed to create variant", err)
+	}
+	return nil
+}
+
+// UpdateVariant writes v only if the stored version still equals
+// v.Version, on success v.Version holds the new version. The quantity is
+// left alone, only stock movements change it.
+func (r *repository) UpdateVariant(ctx context.Context, v *models.ProductVariant) error {
+	options, err := json.Marshal(v.Options)
+	if err != nil {
+		return errors.New("failed to encode variant options: " + err.Error() + "")
+	}
+	var (
+		version  *int64
+		price    *int64
+		currency *string
+		exists   bool
+	)
+	err = postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	WITH upd AS (
+		UPDATE product_variants
+		SET sku = $3, options = $4, price = $5, version = version + 1, updated_at = NOW()
+		WHERE product_id = $1 AND id = $2 AND version = $6 AND deleted_at IS NULL
+		RETURNING version, price
+	)
+	SELECT
+		(SELECT version FROM upd),
+		(SELECT COALESCE(upd.price, p.price) FROM upd, products p WHERE p.id = $1),
+		(SELECT currency FROM products WHERE id = $1),
+		EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1 AND id = $2 AND deleted_at IS NULL)
+`, v.ProductID, v.ID, v.SKU, string(options), amount(v.Price), v.Version).Scan(&version, &price, &currency, &exists)
+	if err != nil {
+		return writeError("failed to update variant", err)
+	}
+	if version == nil {
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	v.Version = *version
+	if price != nil && currency != nil {
+		v.EffectivePrice = models.Money{Currency: *currency, Amount: *price}
+	}
+	return nil
+}
+
+// Reserved locks the variant like a reservation of it does and reports
+// whether active holds take from its stock. The holds are read after the
+// lock is taken, so none committed in the meantime is missed.
+func (r *repository) Reserved(ctx context.Context, productID, variantID int64) (bool, error) {
+	conn := postgres.Conn(ctx, r.pool)
+	var id int64
+	err := conn.QueryRow(ctx, `
+	SELECT id FROM product_variants WHERE product_id = $1 AND id = $2 AND deleted_at IS NULL FOR UPDATE
+`, productID, variantID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrNotFound
+		}
+		return false, errors.New("failed to lock variant: " + err.Error() + "")
+	}
+
+	var held bool
+	err = conn.QueryRow(ctx, `
+	SELECT EXISTS (
+		SELECT 1 FROM stock_reservations
+		WHERE product_id = $1 AND variant_id = $2 AND status = 'active' AND expires_at > NOW()
+	)
+`, productID, variantID).Scan(&held)
+	if err != nil {
+		return false, errors.New("failed to check reservations: " + err.Error() + "")
+	}
+	return held, nil
+}
+
+// DeleteVariant soft-deletes a variant that still is at version, so its
+// stock movements are kept. Stock it still has is written off in the
+// ledger.
+func (r *repository) DeleteVariant(ctx context.Context, productID, variantID, version int64) error {
+	var deleted, exists bool
+	err := postgres.Conn(ctx, r.pool).QueryRow(ctx, `
+	WITH dlt AS (
+		UPDATE product_variants v
+		SET deleted_at = NOW(), quantity = 0, updated_at = NOW()
+		FROM (SELECT id, quantity FROM product_variants WHERE product_id = $1 AND id = $2) AS old
+		WHERE v.id = old.id AND v.version = $4 AND v.deleted_at IS NULL
+		RETURNING v.product_id, v.id, old.quantity
+	), m AS (
+		INSERT INTO stock_movements (product_id, variant_id, kind, delta, balance, reason, actor_id)
+		SELECT product_id, id, 'adjustment', -quantity, 0, 'variant deleted', $3 FROM dlt WHERE quantity > 0
+	)
+	SELECT EXISTS (SELECT 1 FROM dlt),
+		EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1 AND id = $2 AND deleted_at IS NULL)
+`, productID, variantID, models.ActorID(ctx), version).Scan(&deleted, &exists)
+	if err != nil {
+		return errors.New("failed to delete variant: " + err.Error() + "")
+	}
+	if !deleted {
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func scanVariant(row pgx.Row) (*models.ProductVariant, error) {
+	var (
+		v       models.ProductVariant
+		options []byte
+		price   *int64
+	)
+	err := row.Scan(&v.ID, &v.ProductID, &v.SKU, &options, &price, &v.EffectivePrice.Amount,
+		&v.EffectivePrice.Currency, &v.Quantity, &v.CreatedAt, &v.UpdatedAt, &v.Version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to scan variant: " + err.Error() + "")
+	}
+	if err = json.Unmarshal(options, &v.Options); err != nil {
+		return nil, errors.New("failed to decode variant options: " + err.Error() + "")
+	}
+	if price != nil {
+		v.Price = &models.Money{Currency: v.EffectivePrice.Currency, Amount: *price}
+	}
+	return &v, nil
+}
+
+// amount is the price a variant stores, nil inherits the product price.
+func amount(price *models.Money) *int64 {
+	if price == nil {
+		return nil
+	}
+	return &price.Amount
+}
+
+// writeError tells the unique constraints of product_variants apart from
+// other failures.
+func writeError(msg string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		switch pgErr.ConstraintName {
+		case "product_variants_sku_key":
+			return ErrDuplicateSKU
+		case "product_variants_options_key":
+			return ErrDuplicate
+		}
+	}
+	return errors.New(msg + ": " + err.Error() + "")
+}
@@ -0,0 +1,336 @@
+package variants
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/db/postgres"
+	"strings"
+	"testing"
+)
+
+const variantColumnCount = 11
+
+func anything(n int) []any {
+	args := make([]any, n)
+	for i := range args {
+		args[i] = mock.Anything
+	}
+	return args
+}
+
+func sqlContains(part string) any {
+	return mock.MatchedBy(func(sql string) bool { return strings.Contains(sql, part) })
+}
+
+func TestRepository_LockProduct(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		mockPool.On("QueryRow", mock.Anything, sqlContains("FOR UPDATE"), []any{int64(1)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*(args.Get(0).(*string)) = "USD"
+		}).Return(nil)
+
+		currency, err := New(Params{Pool: mockPool}).LockProduct(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "USD", currency)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Return(pgx.ErrNoRows)
+
+		_, err := New(Params{Pool: mockPool}).LockProduct(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+}
+
+func TestRepository_ListOptions(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRows := new(postgres.MockRow)
+	defer mockRows.AssertExpectations(t)
+
+	mockPool.On("Query", mock.Anything, sqlContains("FROM product_options"), []any{int64(1)}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", anything(5)...).
+		Run(func(args mock.Arguments) {
+			*(args.Get(2).(*string)) = "size"
+			*(args.Get(3).(*[]string)) = []string{"S", "M"}
+		}).Return(nil).Once()
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return()
+
+	opts, err := New(Params{Pool: mockPool}).ListOptions(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, opts, 1)
+	assert.Equal(t, "size", opts[0].Name)
+	assert.Equal(t, []string{"S", "M"}, opts[0].Values)
+}
+
+func TestRepository_ReplaceOptions(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx := new(postgres.MockTx)
+		mockRow := new(postgres.MockRow)
+		versionRow := new(postgres.MockRow)
+		defer mockTx.AssertExpectations(t)
+
+		mockPool.On("Begin", mock.Anything).Return(mockTx, nil)
+		mockTx.On("QueryRow", mock.Anything, sqlContains("UPDATE products SET version = version + 1"),
+			[]any{int64(1), int64(3)}).Return(versionRow)
+		versionRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int64) = 4
+		}).Return(nil)
+		mockTx.On("Exec", mock.Anything, sqlContains("DELETE FROM product_options"), []any{int64(1)}).
+			Return(pgconn.NewCommandTag("DELETE 2"), nil)
+		mockTx.On("QueryRow", mock.Anything, sqlContains("INSERT INTO product_options"),
+			[]any{int64(1), "size", []string{"S"}, 0}).Return(mockRow)
+		mockTx.On("QueryRow", mock.Anything, sqlContains("INSERT INTO product_options"),
+			[]any{int64(1), "color", []string{"red"}, 1}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Return(nil)
+		mockTx.On("Commit", mock.Anything).Return(nil)
+		mockTx.On("Rollback", mock.Anything).Return(nil)
+
+		opts := []*models.ProductOption{{Name: "size", Values: []string{"S"}}, {Name: "color", Values: []string{"red"}}}
+		version, err := New(Params{Pool: mockPool}).ReplaceOptions(context.Background(), 1, 3, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), version)
+		assert.Equal(t, 1, opts[1].Position)
+		assert.Equal(t, int64(1), opts[1].ProductID)
+	})
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockTx := new(postgres.MockTx)
+		mockRow := new(postgres.MockRow)
+		defer mockTx.AssertExpectations(t)
+
+		mockPool.On("Begin", mock.Anything).Return(mockTx, nil)
+		mockTx.On("QueryRow", mock.Anything, sqlContains("UPDATE products"), mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Return(pgx.ErrNoRows)
+		mockTx.On("Rollback", mock.Anything).Return(nil)
+
+		opts := []*models.ProductOption{{Name: "size", Values: []string{"S"}}}
+		_, err := New(Params{Pool: mockPool}).ReplaceOptions(context.Background(), 1, 3, opts)
+		assert.ErrorIs(t, err, ErrProductConflict)
+	})
+}
+
+func TestRepository_ListVariants(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRows := new(postgres.MockRow)
+	defer mockRows.AssertExpectations(t)
+
+	mockPool.On("Query", mock.Anything, sqlContains("FROM product_variants"), []any{int64(1)}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", anything(variantColumnCount)...).
+		Run(func(args mock.Arguments) {
+			*(args.Get(2).(*string)) = "TS-M-RED"
+			*(args.Get(3).(*[]byte)) = []byte(`{"size":"M","color":"red"}`)
+			*(args.Get(5).(*int64)) = 1500
+			*(args.Get(6).(*string)) = "USD"
+		}).Return(nil).Once()
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return()
+
+	variants, err := New(Params{Pool: mockPool}).ListVariants(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, variants, 1)
+	assert.Equal(t, map[string]string{"size": "M", "color": "red"}, variants[0].Options)
+	assert.Equal(t, models.Money{Currency: "USD", Amount: 1500}, variants[0].EffectivePrice)
+	assert.Nil(t, variants[0].Price)
+}
+
+func TestRepository_GetVariant(t *testing.T) {
+	mockPool := new(postgres.MockPool)
+	mockRow := new(postgres.MockRow)
+
+	mockPool.On("QueryRow", mock.Anything, mock.Anything, []any{int64(1), int64(2)}).Return(mockRow)
+	mockRow.On("Scan", anything(variantColumnCount)...).Return(pgx.ErrNoRows)
+
+	_, err := New(Params{Pool: mockPool}).GetVariant(context.Background(), 1, 2)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRepository_CreateVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+
+		v := &models.ProductVariant{ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}, Quantity: 3}
+		mockPool.On("QueryRow", mock.Anything, sqlContains("INSERT INTO stock_movements"),
+			[]any{int64(1), "TS-M", `{"size":"M"}`, (*int64)(nil), 3, (*int64)(nil)}).Return(mockRow)
+		mockRow.On("Scan", anything(6)...).
+			Run(func(args mock.Arguments) {
+				*(args.Get(0).(*int64)) = 1000
+				*(args.Get(1).(*string)) = "USD"
+				*(args.Get(2).(*int64)) = 7
+			}).Return(nil)
+
+		err := New(Params{Pool: mockPool}).CreateVariant(context.Background(), v)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), v.ID)
+		assert.Equal(t, models.Money{Currency: "USD", Amount: 1000}, v.EffectivePrice)
+	})
+	t.Run("duplicate sku", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(6)...).
+			Return(&pgconn.PgError{Code: uniqueViolation, ConstraintName: "product_variants_sku_key"})
+
+		err := New(Params{Pool: mockPool}).CreateVariant(context.Background(), &models.ProductVariant{ProductID: 1})
+		assert.ErrorIs(t, err, ErrDuplicateSKU)
+	})
+	t.Run("duplicate options", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(6)...).
+			Return(&pgconn.PgError{Code: uniqueViolation, ConstraintName: "product_variants_options_key"})
+
+		err := New(Params{Pool: mockPool}).CreateVariant(context.Background(), &models.ProductVariant{ProductID: 1})
+		assert.ErrorIs(t, err, ErrDuplicate)
+	})
+}
+
+func TestRepository_UpdateVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		price := int64(1200)
+
+		mockPool.On("QueryRow", mock.Anything, sqlContains("SET sku = $3, options = $4, price = $5, version"),
+			[]any{int64(1), int64(2), "", "null", &price, int64(2)}).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).
+			Run(func(args mock.Arguments) {
+				version, currency := int64(3), "USD"
+				*(args.Get(0).(**int64)) = &version
+				*(args.Get(1).(**int64)) = &price
+				*(args.Get(2).(**string)) = &currency
+				*(args.Get(3).(*bool)) = true
+			}).Return(nil)
+
+		v := &models.ProductVariant{ID: 2, ProductID: 1, Version: 2, Price: &models.Money{Currency: "USD", Amount: price}}
+		err := New(Params{Pool: mockPool}).UpdateVariant(context.Background(), v)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), v.Version)
+		assert.Equal(t, models.Money{Currency: "USD", Amount: 1200}, v.EffectivePrice)
+	})
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).
+			Run(func(args mock.Arguments) {
+				*(args.Get(3).(*bool)) = true
+			}).Return(nil)
+
+		err := New(Params{Pool: mockPool}).UpdateVariant(context.Background(), &models.ProductVariant{ID: 2, ProductID: 1})
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", anything(4)...).Return(nil)
+
+		err := New(Params{Pool: mockPool}).UpdateVariant(context.Background(), &models.ProductVariant{ID: 2, ProductID: 1})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRepository_Reserved(t *testing.T) {
+	t.Run("locks the variant before reading the holds", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		lockRow, heldRow := new(postgres.MockRow), new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FOR UPDATE")
+		}), []any{int64(1), int64(2)}).Return(lockRow).Once()
+		lockRow.On("Scan", mock.Anything).Return(nil)
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM stock_reservations") && strings.Contains(sql, "status = 'active'")
+		}), []any{int64(1), int64(2)}).Return(heldRow).Once()
+		heldRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*bool) = true
+		}).Return(nil)
+
+		held, err := New(Params{Pool: mockPool}).Reserved(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		assert.True(t, held)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything).Return(pgx.ErrNoRows)
+
+		_, err := New(Params{Pool: mockPool}).Reserved(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestRepository_DeleteVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		defer mockPool.AssertExpectations(t)
+		mockPool.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "SET deleted_at = NOW(), quantity = 0") &&
+				strings.Contains(sql, "'variant deleted'") && strings.Contains(sql, "v.version = $4") &&
+				!strings.Contains(sql, "DELETE FROM")
+		}), []any{int64(1), int64(2), (*int64)(nil), int64(3)}).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*bool) = true
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		err := New(Params{Pool: mockPool}).DeleteVariant(context.Background(), 1, 2, 3)
+		assert.NoError(t, err)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(nil)
+
+		err := New(Params{Pool: mockPool}).DeleteVariant(context.Background(), 1, 2, 3)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("version conflict", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(1).(*bool) = true
+		}).Return(nil)
+
+		err := New(Params{Pool: mockPool}).DeleteVariant(context.Background(), 1, 2, 3)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+	t.Run("query error", func(t *testing.T) {
+		mockPool := new(postgres.MockPool)
+		mockRow := new(postgres.MockRow)
+		mockPool.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(mockRow)
+		mockRow.On("Scan", mock.Anything, mock.Anything).Return(errors.New("db error"))
+
+		err := New(Params{Pool: mockPool}).DeleteVariant(context.Background(), 1, 2, 3)
+		assert.ErrorContains(t, err, "failed to delete variant")
+	})
+}
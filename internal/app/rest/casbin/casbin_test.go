@@ -0,0 +1,94 @@
+package casbin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	enforcer, err := New("../../../../configs/casbin/model.conf", "../../../../configs/casbin/policy.csv")
+	require.NoError(t, err)
+
+	tests := []struct {
+		sub, obj, act string
+		want          bool
+	}{
+		{"user", "/api/v1/products/", "GET", true},
+		{"user", "/api/v1/products/1", "GET", true},
+		{"user", "/api/v1/products/1", "PUT", false},
+		{"user", "/api/v1/products/by-sku/W-1", "GET", true},
+		{"user", "/api/v1/products/by-barcode/4006381333931", "GET", true},
+		{"admin", "/api/v1/products/by-barcode/4006381333931", "GET", true},
+		{"user", "/api/v1/products/by-sku/W-1", "DELETE", false},
+		{"user", "/api/v1/products/export", "GET", false},
+		{"user", "/api/v1/products/batch", "POST", true},
+		{"admin", "/api/v1/products/batch", "POST", true},
+		{"user", "/api/v1/products/batch", "DELETE", false},
+		{"admin", "/api/v1/products/export", "GET", true},
+		{"user", "/api/v1/products/import", "POST", false},
+		{"admin", "/api/v1/products/import", "POST", true},
+		{"user", "/api/v1/products/1/status/history", "GET", false},
+		{"admin", "/api/v1/products/1/status/history", "GET", true},
+		{"user", "/api/v1/products/1/prices", "GET", false},
+		{"user", "/api/v1/products/1/prices", "POST", false},
+		{"admin", "/api/v1/products/1/prices", "POST", true},
+		{"user", "/api/v1/products/1/translations", "GET", false},
+		{"user", "/api/v1/products/1/translations/de", "PUT", false},
+		{"admin", "/api/v1/products/1/translations", "GET", true},
+		{"admin", "/api/v1/products/1/translations/pt-BR", "PUT", true},
+		{"user", "/api/v1/products/1/images", "GET", true},
+		{"user", "/api/v1/products/1/images/2/file", "GET", true},
+		{"user", "/api/v1/products/1/images", "POST", false},
+		{"admin", "/api/v1/products/1/images", "POST", true},
+		{"user", "/api/v1/products/1/images/order", "PUT", false},
+		{"admin", "/api/v1/products/1/images/order", "PUT", true},
+		{"admin", "/api/v1/products/1/images/2/primary", "PUT", true},
+		{"user", "/api/v1/products/1/images/2", "DELETE", false},
+		{"admin", "/api/v1/products/1/images/2", "DELETE", true},
+		{"user", "/api/v1/products/1/options", "GET", true},
+		{"user", "/api/v1/products/1/options", "PUT", false},
+		{"admin", "/api/v1/products/1/options", "PUT", true},
+		{"user", "/api/v1/products/1/variants", "GET", true},
+		{"user", "/api/v1/products/1/variants/2", "GET", true},
+		{"user", "/api/v1/products/1/variants", "POST", false},
+		{"admin", "/api/v1/products/1/variants", "POST", true},
+		{"user", "/api/v1/products/1/variants/2", "PUT", false},
+		{"admin", "/api/v1/products/1/variants/2", "PUT", true},
+		{"admin", "/api/v1/products/1/variants/2", "DELETE", true},
+		{"user", "/api/v1/products/1/stock", "GET", true},
+		{"user", "/api/v1/products/1/stock", "POST", false},
+		{"admin", "/api/v1/products/1/stock", "POST", true},
+		{"user", "/api/v1/products/1/stock/history", "GET", false},
+		{"admin", "/api/v1/products/1/stock/history", "GET", true},
+		{"user", "/api/v1/products/low-stock", "GET", false},
+		{"admin", "/api/v1/products/low-stock", "GET", true},
+		{"user", "/api/v1/products/1/reservations", "POST", false},
+		{"admin", "/api/v1/products/1/reservations", "POST", true},
+		{"admin", "/api/v1/reservations/7", "GET", true},
+		{"admin", "/api/v1/reservations/7/confirm", "POST", true},
+		{"user", "/api/v1/reservations/7/release", "POST", false},
+		{"admin", "/api/v1/reservations/7/release", "POST", true},
+		{"user", "/api/v1/categories/", "GET", false},
+		{"user", "/api/v1/categories/tree", "GET", true},
+		{"user", "/api/v1/categories/1/tree", "GET", true},
+		{"user", "/api/v1/categories/1/path", "GET", true},
+		{"user", "/api/v1/categories/1/path", "PUT", false},
+		{"user", "/api/v1/categories/1/attributes", "GET", true},
+		{"user", "/api/v1/categories/1/attributes", "PUT", false},
+		{"admin", "/api/v1/categories/1/attributes", "PUT", true},
+		{"user", "/api/v1/categories/1/restore", "PUT", false},
+		{"admin", "/api/v1/categories/1/restore", "PUT", true},
+		{"user", "/api/v1/audit", "GET", false},
+		{"admin", "/api/v1/audit", "GET", true},
+		{"admin", "/api/v1/audit", "DELETE", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sub+" "+tt.act+" "+tt.obj, func(t *testing.T) {
+			got, err := enforcer.Enforce(tt.sub, tt.obj, tt.act)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
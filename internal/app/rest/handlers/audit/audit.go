@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/audit"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service audit.ServiceInterface
+}
+
+func New(service audit.ServiceInterface) *Handler {
+	return &Handler{service: service}
+}
+
+// ListAudit godoc
+//
+//	@Summary		Get the audit trail
+//	@Description	Get catalog mutations newest first, with the user who made them and the changed fields.
+//	@Description	Pass before_id of a page to get the next one.
+//	@Tags			audit
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			entity		query		string	false	"Entity"	Enums(product, category, variant, product_options, product_price, category_attributes, product_translation)
+//	@Param			entity_id	query		int64	false	"Entity ID"
+//	@Param			actor_id	query		int64	false	"User ID of the actor"
+//	@Param			from		query		string	false	"Start of the time range, RFC 3339"
+//	@Param			to			query		string	false	"End of the time range, RFC 3339, exclusive"
+//	@Param			limit		query		int		false	"Page size"
+//	@Param			before_id	query		int64	false	"Return entries older than this one"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	models.AuditPage
+//	@Router			/audit [get]
+func (h *Handler) ListAudit(c *gin.Context) {
+	f, err := parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := h.service.ListAudit(c.Request.Context(), f)
+	if err != nil {
+		if errors.Is(err, audit.ErrInvalidRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func parseFilter(c *gin.Context) (*models.AuditFilter, error) {
+	f := &models.AuditFilter{Entity: c.Query("entity")}
+	switch f.Entity {
+	case "", models.AuditEntityProduct, models.AuditEntityCategory, models.AuditEntityVariant,
+		models.AuditEntityOptions, models.AuditEntityPrice, models.AuditEntityAttributes,
+		models.AuditEntityTranslation:
+	default:
+		return nil, errors.New("invalid entity")
+	}
+
+	var err error
+	if f.EntityID, err = optionalID(c, "entity_id"); err != nil {
+		return nil, err
+	}
+	if f.ActorID, err = optionalID(c, "actor_id"); err != nil {
+		return nil, err
+	}
+	if id, err := optionalID(c, "before_id"); err != nil {
+		return nil, err
+	} else if id != nil {
+		f.BeforeID = *id
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			return nil, errors.New("invalid limit")
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.New("invalid from, expected RFC 3339")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.New("invalid to, expected RFC 3339")
+		}
+	}
+	return f, nil
+}
+
+func optionalID(c *gin.Context, key string) (*int64, error) {
+	v := c.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.New("invalid " + key)
+	}
+	return &id, nil
+}
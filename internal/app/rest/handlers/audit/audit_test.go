@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/audit"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandler_ListAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("success", func(t *testing.T) {
+		service := new(audit.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		service.On("ListAudit", mock.Anything, mock.MatchedBy(func(f *models.AuditFilter) bool {
+			return f.Entity == models.AuditEntityProduct && *f.EntityID == 3 && *f.ActorID == 5 &&
+				f.From.Equal(from) && f.To.IsZero() && f.Limit == 10 && f.BeforeID == 40
+		})).Return(&models.AuditPage{Items: []*models.AuditEntry{{ID: 39, Action: models.AuditActionUpdate}}}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet,
+			"/audit?entity=product&entity_id=3&actor_id=5&from=2024-05-01T00:00:00Z&limit=10&before_id=40", nil)
+
+		handler.ListAudit(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"action":"update"`)
+	})
+	t.Run("invalid query", func(t *testing.T) {
+		for _, query := range []string{"entity=order", "entity_id=abc", "actor_id=-1", "from=yesterday", "limit=x"} {
+			service := new(audit.MockService)
+			handler := New(service)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/audit?"+query, nil)
+
+			handler.ListAudit(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			service.AssertNotCalled(t, "ListAudit", mock.Anything, mock.Anything)
+		}
+	})
+	t.Run("invalid range", func(t *testing.T) {
+		service := new(audit.MockService)
+		handler := New(service)
+		service.On("ListAudit", mock.Anything, mock.Anything).Return(nil, audit.ErrInvalidRange)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/audit?from=2024-05-02T00:00:00Z&to=2024-05-01T00:00:00Z", nil)
+
+		handler.ListAudit(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("service error", func(t *testing.T) {
+		service := new(audit.MockService)
+		handler := New(service)
+		service.On("ListAudit", mock.Anything, mock.Anything).Return(nil, errors.New("failed to get audit log"))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/audit", nil)
+
+		handler.ListAudit(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -1,14 +1,25 @@
 package categories
 
 import (
+	"errors"
 	"net/http"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/rest/handlers/etag"
 	"prodigo/internal/app/usecases/categories"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+type AttributeRequest struct {
+	Name string `json:"name" example:"brand"`
+	Type string `json:"type" enums:"string,number,boolean"`
+}
+
+type AttributesRequest struct {
+	Attributes []AttributeRequest `json:"attributes"`
+}
+
 type Handler struct {
 	service categories.ServiceInterface
 }
@@ -29,6 +40,7 @@ func New(service categories.ServiceInterface) *Handler {
 //	@Produce		json
 //	@Param			request	body		models.Category	true	"Category details"
 //	@Failure		400		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
 //	@Success		201		{object}	models.Category
 //	@Router			/categories/ [post]
@@ -39,7 +51,14 @@ func (h *Handler) CreateCategory(c *gin.Context) {
 		return
 	}
 	if err := h.service.CreateCategory(c.Request.Context(), &cat); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, models.ErrParentNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, categories.ErrDuplicateName):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	c.JSON(http.StatusCreated, cat)
@@ -70,17 +89,25 @@ func (h *Handler) GetAllCategories(c *gin.Context) {
 // UpdateCategory godoc
 //
 //	@Summary		Update an existing category
-//	@Description	Update the details of an existing category by ID
+//	@Description	Update the name and the parent of an existing category by ID, an empty parent_id makes it a root category.
+//	@Description	A category can not be moved under itself or its subcategories.
 //	@Tags			categories
 //
 // @Security	ApiKeyAuth
 // @Accept			json
 //
 //	@Produce		json
-//	@Param			id	path		int64	true	"Category ID"
-//	@Failure		400	{object}	map[string]string
-//	@Failure		500	{object}	map[string]string
-//	@Success		200	{object}	models.Category
+//	@Param			id			path		int64			true	"Category ID"
+//	@Param			If-Match	header		string			true	"ETag of the category being updated"
+//	@Param			request		body		models.Category	true	"Category details"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		409			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	models.Category
+//	@Header			200			{string}	ETag	"Category version"
 //	@Router			/categories/{id} [put]
 func (h *Handler) UpdateCategory(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -89,34 +116,147 @@ func (h *Handler) UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
+
 	var cat models.Category
 	if err := c.ShouldBindJSON(&cat); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	cat.ID = id
+	cat.Version = version
 
 	if err := h.service.UpdateCategory(c.Request.Context(), &cat); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, categories.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, categories.ErrVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrParentNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrCategoryCycle), errors.Is(err, categories.ErrDuplicateName):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
+	c.Header("ETag", etag.Format(cat.Version))
 	c.JSON(http.StatusOK, cat)
 }
 
-// DeleteCategory godoc
+// GetCategoryTree godoc
 //
-//	@Summary		Delete a category
-//	@Description	Delete an existing category by ID
+//	@Summary		Get the category tree
+//	@Description	Get every category nested under its parent, siblings sorted by name
+//	@Tags			categories
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.CategoryNode
+//	@Router			/categories/tree [get]
+func (h *Handler) GetCategoryTree(c *gin.Context) {
+	tree, err := h.service.GetTree(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetCategorySubtree godoc
+//
+//	@Summary		Get a category subtree
+//	@Description	Get a category with all its subcategories nested under it
+//	@Tags			categories
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Category ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{object}	models.CategoryNode
+//	@Router			/categories/{id}/tree [get]
+func (h *Handler) GetCategorySubtree(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	node, err := h.service.GetSubtree(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, categories.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, node)
+}
+
+// GetCategoryPath godoc
+//
+//	@Summary		Get a category breadcrumb
+//	@Description	Get the ancestors of a category from the root down, ending with the category itself
 //	@Tags			categories
 //
 // @Security	ApiKeyAuth
 //
-//	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int64	true	"Category ID"
 //	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
 //	@Failure		500	{object}	map[string]string
-//	@Success		204	{object}	map[string]string
+//	@Success		200	{array}		models.Category
+//	@Router			/categories/{id}/path [get]
+func (h *Handler) GetCategoryPath(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	path, err := h.service.GetPath(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, categories.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, path)
+}
+
+// DeleteCategory godoc
+//
+//	@Summary		Delete a category
+//	@Description	Delete an existing category by ID. The policy decides what happens to its products and subcategories:
+//	@Description	block refuses while there are live ones, reassign moves them to target_id, cascade deletes the subcategories and archives the products.
+//	@Tags			categories
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int64	true	"Category ID"
+//	@Param			If-Match	header		string	true	"ETag of the category being deleted"
+//	@Param			policy		query		string	false	"Delete policy"	Enums(block, reassign, cascade)	default(block)
+//	@Param			target_id	query		int64	false	"Category receiving the contents with the reassign policy"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		409			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		204			{object}	map[string]string
 //	@Router			/categories/{id} [delete]
 func (h *Handler) DeleteCategory(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -124,31 +264,207 @@ func (h *Handler) DeleteCategory(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	if err := h.service.DeleteCategory(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
+	opts := models.CategoryDelete{Policy: c.Query("policy"), Version: version}
+	if v := c.Query("target_id"); v != "" {
+		if opts.TargetID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_id"})
+			return
+		}
+	}
+	if err := h.service.DeleteCategory(c.Request.Context(), id, opts); err != nil {
+		switch {
+		case errors.Is(err, categories.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, categories.ErrVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case errors.Is(err, categories.ErrInvalidPolicy), errors.Is(err, categories.ErrInvalidTarget):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, categories.ErrNotEmpty):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	c.JSON(http.StatusNoContent, gin.H{"message": "category deleted"})
 }
 
+// RestoreCategory godoc
+//
+//	@Summary		Restore a deleted category
+//	@Description	Restore a soft-deleted category together with the subcategories deleted with it,
+//	@Description	restore_products also moves the products the cascade policy archived back to draft,
+//	@Description	like any archived product.
+//	@Description	If-Match carries the ETag the category had when it was deleted.
+//	@Tags			categories
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id					path		int64	true	"Category ID"
+//	@Param			If-Match			header		string	true	"ETag of the deleted category"
+//	@Param			restore_products	query		bool	false	"Move the products archived with the category back to draft"
+//	@Failure		400					{object}	map[string]string
+//	@Failure		404					{object}	map[string]string
+//	@Failure		409					{object}	map[string]string
+//	@Failure		412					{object}	map[string]string
+//	@Failure		428					{object}	map[string]string
+//	@Failure		500					{object}	map[string]string
+//	@Success		200					{object}	map[string]any
+//	@Router			/categories/{id}/restore [put]
+func (h *Handler) RestoreCategory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
+	var withProducts bool
+	if v := c.Query("restore_products"); v != "" {
+		if withProducts, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid restore_products"})
+			return
+		}
+	}
+	restored, err := h.service.RestoreCategory(c.Request.Context(), id, version, withProducts)
+	if err != nil {
+		switch {
+		case errors.Is(err, categories.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, categories.ErrVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case errors.Is(err, categories.ErrNotDeleted), errors.Is(err, categories.ErrParentIsDeleted):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "category restored", "restored_products": restored})
+}
+
 // CategoryStatistics godoc
 //
 //	@Summary		Get category statistics
 //	@Description	Get statistics for categories (product count, total quantity and value)
+//	@Description	With rollup every category also counts the products of its subcategories.
+//	@Description	Products with variants count the quantity and the price of every variant.
 //	@Tags			categories
 //
 // @Security	ApiKeyAuth
 //
 //	@Accept			json
 //	@Produce		json
-//	@Failure		500	{object}	map[string]string
-//	@Success		204	{object}	[]models.CategoryStats
+//	@Param			rollup	query		bool	false	"Include subcategories"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Success		204		{object}	[]models.CategoryStats
 //	@Router			/categories/stats [get]
 func (h *Handler) CategoryStatistics(c *gin.Context) {
-	stats, err := h.service.CategoryStatistics(c.Request.Context())
+	var rollup bool
+	if v := c.Query("rollup"); v != "" {
+		var err error
+		if rollup, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rollup"})
+			return
+		}
+	}
+	stats, err := h.service.CategoryStatistics(c.Request.Context(), rollup)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, stats)
 }
+
+// ListAttributes godoc
+//
+//	@Summary		List category attributes
+//	@Description	Get the attributes the products of a category can have, like brand or ram, sorted by name
+//	@Tags			categories
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Category ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.AttributeDefinition
+//	@Router			/categories/{id}/attributes [get]
+func (h *Handler) ListAttributes(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	defs, err := h.service.ListAttributes(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, categories.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, defs)
+}
+
+// ReplaceAttributes godoc
+//
+//	@Summary		Replace category attributes
+//	@Description	Replace the attribute definitions of a category. Names are lowercase letters, digits and underscores
+//	@Description	and can not end in _gte or _lte. Every product of the category has to keep fitting the definitions.
+//	@Tags			categories
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int64				true	"Category ID"
+//	@Param			request	body		AttributesRequest	true	"Attributes"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Success		200		{array}		models.AttributeDefinition
+//	@Router			/categories/{id}/attributes [put]
+func (h *Handler) ReplaceAttributes(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req AttributesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defs := make([]*models.AttributeDefinition, len(req.Attributes))
+	for i, a := range req.Attributes {
+		defs[i] = &models.AttributeDefinition{Name: a.Name, Type: a.Type}
+	}
+
+	if err := h.service.ReplaceAttributes(c.Request.Context(), id, defs); err != nil {
+		switch {
+		case errors.Is(err, categories.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidAttributes):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, categories.ErrAttributesInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, defs)
+}
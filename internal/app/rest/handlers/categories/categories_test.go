@@ -2,6 +2,7 @@ package categories
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -71,6 +72,21 @@ func TestHandler_CreateCategory(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), `"error"`)
 	})
+	t.Run("name taken", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		service.On("CreateCategory", mock.Anything, mock.Anything).Return(categories.ErrDuplicateName)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/categories", strings.NewReader(`{"name":"Phones"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.CreateCategory(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
 }
 
 func TestHandler_GetAllCategories(t *testing.T) {
@@ -119,19 +135,87 @@ func TestHandler_UpdateCategory(t *testing.T) {
 		handler := New(service)
 		defer service.AssertExpectations(t)
 
-		category := models.Category{ID: 1, Name: "Updated Name"}
-		service.On("UpdateCategory", mock.Anything, &category).Return(nil)
+		category := models.Category{ID: 1, Name: "Updated Name", Version: 3}
+		service.On("UpdateCategory", mock.Anything, &category).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Category).Version = 4
+		}).Return(nil)
 
 		body := `{"name": "Updated Name"}`
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 		c.Params = gin.Params{{Key: "id", Value: "1"}}
 		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1", strings.NewReader(body))
+		c.Request.Header.Set("If-Match", `"3"`)
 
 		handler.UpdateCategory(c)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Updated Name")
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	})
+	t.Run("missing if-match", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1", strings.NewReader(`{"name": "x"}`))
+
+		handler.UpdateCategory(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+	t.Run("stale version", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("UpdateCategory", mock.Anything, mock.Anything).Return(categories.ErrVersionConflict)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1", strings.NewReader(`{"name": "x"}`))
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.UpdateCategory(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+	t.Run("move under a subcategory", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("UpdateCategory", mock.Anything, mock.MatchedBy(func(c *models.Category) bool {
+			return c.ParentID != nil && *c.ParentID == 5
+		})).Return(models.ErrCategoryCycle)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1", strings.NewReader(`{"name": "x", "parent_id": 5}`))
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.UpdateCategory(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("missing parent", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		service.On("UpdateCategory", mock.Anything, mock.Anything).Return(models.ErrParentNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1", strings.NewReader(`{"name": "x", "parent_id": 9}`))
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.UpdateCategory(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 	t.Run("invalid id", func(t *testing.T) {
 		service := new(categories.MockService)
@@ -152,7 +236,7 @@ func TestHandler_UpdateCategory(t *testing.T) {
 		handler := New(service)
 		defer service.AssertExpectations(t)
 
-		category := models.Category{ID: 1, Name: "New Name"}
+		category := models.Category{ID: 1, Name: "New Name", Version: 1}
 		service.On("UpdateCategory", mock.Anything, &category).Return(errors.New("update failed"))
 
 		body := `{"name": "New Name"}`
@@ -161,6 +245,7 @@ func TestHandler_UpdateCategory(t *testing.T) {
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1", strings.NewReader(body))
 		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", `"1"`)
 
 		handler.UpdateCategory(c)
 
@@ -176,6 +261,7 @@ func TestHandler_UpdateCategory(t *testing.T) {
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1", strings.NewReader("{bad json"))
 		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", `"1"`)
 
 		handler.UpdateCategory(c)
 
@@ -190,12 +276,13 @@ func TestHandler_DeleteCategory(t *testing.T) {
 		handler := New(service)
 		defer service.AssertExpectations(t)
 
-		service.On("DeleteCategory", mock.Anything, int64(1)).Return(nil)
+		service.On("DeleteCategory", mock.Anything, int64(1), models.CategoryDelete{Version: 1}).Return(nil)
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 		c.Params = gin.Params{{Key: "id", Value: "1"}}
 		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
 
 		handler.DeleteCategory(c)
 
@@ -222,18 +309,267 @@ func TestHandler_DeleteCategory(t *testing.T) {
 		handler := New(service)
 		defer service.AssertExpectations(t)
 
-		service.On("DeleteCategory", mock.Anything, int64(1)).Return(errors.New("delete failed"))
+		service.On("DeleteCategory", mock.Anything, int64(1), models.CategoryDelete{Version: 1}).Return(errors.New("delete failed"))
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
 
 		handler.DeleteCategory(c)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), "delete failed")
 	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("DeleteCategory", mock.Anything, int64(1), models.CategoryDelete{Version: 1}).Return(categories.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.DeleteCategory(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("reassign policy", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		opts := models.CategoryDelete{Policy: models.DeletePolicyReassign, TargetID: 2, Version: 1}
+		service.On("DeleteCategory", mock.Anything, int64(1), opts).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1?policy=reassign&target_id=2", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.DeleteCategory(c)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+	t.Run("invalid target_id", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1?policy=reassign&target_id=abc", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.DeleteCategory(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid target_id")
+	})
+	t.Run("invalid policy", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		opts := models.CategoryDelete{Policy: "drop", Version: 1}
+		service.On("DeleteCategory", mock.Anything, int64(1), opts).Return(categories.ErrInvalidPolicy)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1?policy=drop", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.DeleteCategory(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("not empty", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("DeleteCategory", mock.Anything, int64(1), models.CategoryDelete{Version: 1}).
+			Return(fmt.Errorf("%w: 3 products, 0 subcategories", categories.ErrNotEmpty))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.DeleteCategory(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "3 products")
+	})
+	t.Run("missing if-match", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1", nil)
+
+		handler.DeleteCategory(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		service.AssertNotCalled(t, "DeleteCategory")
+	})
+	t.Run("wildcard if-match", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1", nil)
+		c.Request.Header.Set("If-Match", "*")
+
+		handler.DeleteCategory(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		service.AssertNotCalled(t, "DeleteCategory")
+	})
+	t.Run("stale version", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("DeleteCategory", mock.Anything, int64(1), models.CategoryDelete{Version: 1}).Return(categories.ErrVersionConflict)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/categories/1", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.DeleteCategory(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
+
+func TestHandler_RestoreCategory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("RestoreCategory", mock.Anything, int64(1), int64(1), true).Return(int64(4), nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1/restore?restore_products=true", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.RestoreCategory(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"restored_products":4`)
+	})
+	t.Run("invalid restore_products", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1/restore?restore_products=maybe", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.RestoreCategory(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("not deleted", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("RestoreCategory", mock.Anything, int64(1), int64(1), false).Return(int64(0), categories.ErrNotDeleted)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1/restore", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.RestoreCategory(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("RestoreCategory", mock.Anything, int64(1), int64(1), false).Return(int64(0), categories.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1/restore", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.RestoreCategory(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("missing if-match", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1/restore", nil)
+
+		handler.RestoreCategory(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		service.AssertNotCalled(t, "RestoreCategory")
+	})
+	t.Run("wildcard if-match", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1/restore", nil)
+		c.Request.Header.Set("If-Match", "*")
+
+		handler.RestoreCategory(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		service.AssertNotCalled(t, "RestoreCategory")
+	})
+	t.Run("stale version", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("RestoreCategory", mock.Anything, int64(1), int64(1), false).Return(int64(0), categories.ErrVersionConflict)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/1/restore", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.RestoreCategory(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
 }
 
 func TestHandler_CategoryStatistics(t *testing.T) {
@@ -242,7 +578,7 @@ func TestHandler_CategoryStatistics(t *testing.T) {
 		handler := New(service)
 		defer service.AssertExpectations(t)
 
-		service.On("CategoryStatistics", mock.Anything).Return([]*models.CategoryStats{
+		service.On("CategoryStatistics", mock.Anything, false).Return([]*models.CategoryStats{
 			{CategoryID: 1, CategoryName: "SUV", ProductCount: 5},
 		}, nil)
 
@@ -260,7 +596,7 @@ func TestHandler_CategoryStatistics(t *testing.T) {
 		handler := New(service)
 		defer service.AssertExpectations(t)
 
-		service.On("CategoryStatistics", mock.Anything).Return([]*models.CategoryStats{}, errors.New("db error"))
+		service.On("CategoryStatistics", mock.Anything, false).Return([]*models.CategoryStats{}, errors.New("db error"))
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
@@ -271,4 +607,216 @@ func TestHandler_CategoryStatistics(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), "db error")
 	})
+	t.Run("rollup", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("CategoryStatistics", mock.Anything, true).Return([]*models.CategoryStats{}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/stats?rollup=true", nil)
+
+		handler.CategoryStatistics(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("invalid rollup", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/stats?rollup=maybe", nil)
+
+		handler.CategoryStatistics(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_GetCategoryTree(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("GetTree", mock.Anything).Return([]*models.CategoryNode{{
+			Category: models.Category{ID: 1, Name: "Electronics"},
+			Children: []*models.CategoryNode{{Category: models.Category{ID: 2, Name: "Phones"}, Children: []*models.CategoryNode{}}},
+		}}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/tree", nil)
+
+		handler.GetCategoryTree(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"children":[{"children":[]`)
+	})
+	t.Run("internal error", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		service.On("GetTree", mock.Anything).Return(nil, errors.New("failed to get all categories"))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/tree", nil)
+
+		handler.GetCategoryTree(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestHandler_GetCategorySubtree(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("GetSubtree", mock.Anything, int64(2)).
+			Return(&models.CategoryNode{Category: models.Category{ID: 2, Name: "Phones"}, Children: []*models.CategoryNode{}}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/2/tree", nil)
+
+		handler.GetCategorySubtree(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"Phones"`)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		service.On("GetSubtree", mock.Anything, int64(2)).Return(nil, categories.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/2/tree", nil)
+
+		handler.GetCategorySubtree(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("invalid id", func(t *testing.T) {
+		handler := New(new(categories.MockService))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "abc"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/abc/tree", nil)
+
+		handler.GetCategorySubtree(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_GetCategoryPath(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("GetPath", mock.Anything, int64(2)).
+			Return([]*models.Category{{ID: 1, Name: "Electronics"}, {ID: 2, Name: "Phones"}}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/2/path", nil)
+
+		handler.GetCategoryPath(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Regexp(t, `"Electronics".*"Phones"`, w.Body.String())
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		service.On("GetPath", mock.Anything, int64(2)).Return(nil, categories.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/categories/2/path", nil)
+
+		handler.GetCategoryPath(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_ListAttributes(t *testing.T) {
+	service := new(categories.MockService)
+	handler := New(service)
+	defer service.AssertExpectations(t)
+
+	service.On("ListAttributes", mock.Anything, int64(2)).
+		Return([]*models.AttributeDefinition{{ID: 1, CategoryID: 2, Name: "brand", Type: "string"}}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "2"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/categories/2/attributes", nil)
+
+	handler.ListAttributes(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"brand","type":"string"`)
+}
+
+func TestHandler_ReplaceAttributes(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(categories.MockService)
+		handler := New(service)
+		defer service.AssertExpectations(t)
+
+		service.On("ReplaceAttributes", mock.Anything, int64(2), mock.MatchedBy(func(defs []*models.AttributeDefinition) bool {
+			return len(defs) == 2 && defs[1].Name == "ram" && defs[1].Type == models.AttributeNumber
+		})).Return(nil)
+
+		body := `{"attributes":[{"name":"brand","type":"string"},{"name":"ram","type":"number"}]}`
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/categories/2/attributes", strings.NewReader(body))
+
+		handler.ReplaceAttributes(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"ram"`)
+	})
+	for _, tt := range []struct {
+		err  error
+		name string
+		want int
+	}{
+		{fmt.Errorf("%w: duplicate attribute", models.ErrInvalidAttributes), "invalid definitions", http.StatusBadRequest},
+		{categories.ErrNotFound, "category not found", http.StatusNotFound},
+		{fmt.Errorf("%w: 3 products", categories.ErrAttributesInUse), "values in use", http.StatusConflict},
+		{errors.New("db error"), "service error", http.StatusInternalServerError},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(categories.MockService)
+			handler := New(service)
+			service.On("ReplaceAttributes", mock.Anything, int64(2), mock.Anything).Return(tt.err)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = gin.Params{{Key: "id", Value: "2"}}
+			c.Request = httptest.NewRequest(http.MethodPut, "/categories/2/attributes",
+				strings.NewReader(`{"attributes":[]}`))
+
+			handler.ReplaceAttributes(c)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
 }
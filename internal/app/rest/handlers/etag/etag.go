@@ -0,0 +1,53 @@
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrMissing = errors.New("missing If-Match header")
+	ErrInvalid = errors.New("invalid If-Match header")
+)
+
+func Format(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Parse reads the version from an If-Match value. The wildcard "*" is
+// rejected, a write has to name the version it was made against.
+func Parse(header string) (int64, error) {
+	if header == "" {
+		return 0, ErrMissing
+	}
+
+	value, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		return 0, ErrInvalid
+	}
+
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrInvalid
+	}
+	return version, nil
+}
+
+// Require parses the If-Match header of the request and writes the
+// error response itself when the header is missing or malformed.
+func Require(c *gin.Context) (int64, bool) {
+	version, err := Parse(c.GetHeader("If-Match"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrMissing) {
+			status = http.StatusPreconditionRequired
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	return version, true
+}
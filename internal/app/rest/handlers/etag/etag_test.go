@@ -0,0 +1,78 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, `"3"`, Format(3))
+}
+
+func TestParse(t *testing.T) {
+	t.Run("strong", func(t *testing.T) {
+		version, err := Parse(`"7"`)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), version)
+	})
+	t.Run("weak", func(t *testing.T) {
+		version, err := Parse(`W/"7"`)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), version)
+	})
+	t.Run("wildcard", func(t *testing.T) {
+		_, err := Parse("*")
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+	t.Run("missing", func(t *testing.T) {
+		_, err := Parse("")
+		assert.ErrorIs(t, err, ErrMissing)
+	})
+	t.Run("not quoted", func(t *testing.T) {
+		_, err := Parse("7")
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+	t.Run("not a number", func(t *testing.T) {
+		_, err := Parse(`"abc"`)
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+}
+
+func TestRequire(t *testing.T) {
+	t.Run("missing header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+
+		_, ok := Require(c)
+
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+	t.Run("invalid header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+		c.Request.Header.Set("If-Match", "abc")
+
+		_, ok := Require(c)
+
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("valid header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+		c.Request.Header.Set("If-Match", `"2"`)
+
+		version, ok := Require(c)
+
+		assert.True(t, ok)
+		assert.Equal(t, int64(2), version)
+	})
+}
@@ -2,13 +2,23 @@ package handlers
 
 import (
 	"go.uber.org/fx"
+	"prodigo/internal/app/rest/handlers/audit"
 	"prodigo/internal/app/rest/handlers/categories"
+	"prodigo/internal/app/rest/handlers/images"
+	"prodigo/internal/app/rest/handlers/inventory"
 	"prodigo/internal/app/rest/handlers/products"
+	"prodigo/internal/app/rest/handlers/reservations"
+	"prodigo/internal/app/rest/handlers/variants"
 )
 
 var Module = fx.Module("handlers",
 	fx.Provide(
+		audit.New,
 		categories.New,
+		images.New,
+		inventory.New,
 		products.New,
+		reservations.New,
+		variants.New,
 	),
 )
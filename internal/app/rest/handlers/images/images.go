@@ -0,0 +1,474 @@
+package images
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/images"
+	"prodigo/pkg/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultURLTTL is how long presigned image URLs stay valid unless configured.
+const defaultURLTTL = 15 * time.Minute
+
+const maxImageSize = 10 * 1024 * 1024
+
+const (
+	// immutableCache is sent for gallery files, their keys never get reused.
+	immutableCache = "private, max-age=31536000, immutable"
+	// revalidateCache is sent for the primary image, which can change.
+	revalidateCache = "private, no-cache"
+)
+
+type Handler struct {
+	service  images.ServiceInterface
+	urlTTL   time.Duration
+	redirect bool
+}
+
+func New(service images.ServiceInterface, conf *config.Config) *Handler {
+	ttl := conf.AppStorageURLTTL
+	if ttl <= 0 {
+		ttl = defaultURLTTL
+	}
+	return &Handler{service: service, urlTTL: ttl, redirect: conf.AppStorageRedirect}
+}
+
+type ImageOrder struct {
+	ImageIDs []int64 `json:"image_ids"`
+}
+
+// ListImages godoc
+//
+//	@Summary		List product images
+//	@Description	Get the gallery of a product in display order
+//	@Tags			images
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Product ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.ProductImage
+//	@Router			/products/{id}/images [get]
+func (h *Handler) ListImages(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	imgs, err := h.service.ListImages(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, imgs)
+}
+
+// AddImage godoc
+//
+//	@Summary		Add product image
+//	@Description	Upload a JPEG or PNG image to the end of the product gallery.
+//	@Description	The image is re-encoded without metadata and scaled into the configured renditions.
+//	@Description	The first image of a product becomes its primary image.
+//	@Tags			images
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			id			path		int64	true	"Product ID"
+//	@Param			image		formData	file	true	"Image file"
+//	@Param			alt_text	formData	string	false	"Alternative text"
+//	@Param			primary		formData	bool	false	"Make it the primary image"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		201			{object}	models.ProductImage
+//	@Router			/products/{id}/images [post]
+func (h *Handler) AddImage(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+
+	img := &models.ProductImage{ProductID: productID, AltText: c.PostForm("alt_text")}
+	if v := c.PostForm("primary"); v != "" {
+		primary, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid primary"})
+			return
+		}
+		img.Primary = primary
+	}
+
+	if h.upload(c, img) {
+		c.JSON(http.StatusCreated, img)
+	}
+}
+
+// ReorderImages godoc
+//
+//	@Summary		Reorder product images
+//	@Description	Set the gallery order, the list has to contain every image of the product once
+//	@Tags			images
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int64		true	"Product ID"
+//	@Param			request	body		ImageOrder	true	"Image IDs in the new order"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Success		200		{array}		models.ProductImage
+//	@Router			/products/{id}/images/order [put]
+func (h *Handler) ReorderImages(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var order ImageOrder
+	if err := c.ShouldBindJSON(&order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ReorderImages(c.Request.Context(), productID, order.ImageIDs); err != nil {
+		writeError(c, err)
+		return
+	}
+	h.ListImages(c)
+}
+
+// SetPrimaryImage godoc
+//
+//	@Summary		Set primary image
+//	@Description	Make an image the primary image of its product
+//	@Tags			images
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id			path		int64	true	"Product ID"
+//	@Param			image_id	path		int64	true	"Image ID"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	map[string]string
+//	@Router			/products/{id}/images/{image_id}/primary [put]
+func (h *Handler) SetPrimaryImage(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	imageID, ok := pathID(c, "image_id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.SetPrimary(c.Request.Context(), productID, imageID); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "primary image updated"})
+}
+
+// DeleteImage godoc
+//
+//	@Summary		Delete product image
+//	@Description	Remove an image from the gallery, the next image becomes primary if needed
+//	@Tags			images
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id			path	int64	true	"Product ID"
+//	@Param			image_id	path	int64	true	"Image ID"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		204
+//	@Router			/products/{id}/images/{image_id} [delete]
+func (h *Handler) DeleteImage(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	imageID, ok := pathID(c, "image_id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteImage(c.Request.Context(), productID, imageID); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetImageFile godoc
+//
+//	@Summary		Get image file
+//	@Description	Get the binary of a gallery image. With presigned redirects enabled
+//	@Description	the response redirects to a temporary URL of the object storage.
+//	@Tags			images
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		image/jpeg
+//	@Produce		image/png
+//	@Param			id			path		int64	true	"Product ID"
+//	@Param			image_id	path		int64	true	"Image ID"
+//	@Param			size		query		string	false	"Rendition: original (default), thumb, medium, large"
+//	@Param			If-None-Match	header	string	false	"ETag of a cached copy"
+//	@Success		200			{string}	binary	"Image binary data"
+//	@Header			200			{string}	ETag	"Rendition version"
+//	@Success		302			{string}	string	"Redirect to a presigned URL"
+//	@Success		304			{string}	string	"Cached copy is current"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Router			/products/{id}/images/{image_id}/file [get]
+func (h *Handler) GetImageFile(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	imageID, ok := pathID(c, "image_id")
+	if !ok {
+		return
+	}
+
+	img, err := h.service.GetImage(c.Request.Context(), productID, imageID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	h.serve(c, img, immutableCache)
+}
+
+// UploadProductImage godoc
+//
+//	@Summary		Upload product image
+//	@Description	Upload an image for a product by ID, it becomes the primary image of the gallery
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			id		path		int64	true	"Product ID"
+//	@Param			image	formData	file	true	"Product image file"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Success		200		{object}	map[string]string
+//	@Router			/products/{id}/image [post]
+func (h *Handler) UploadProductImage(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	img := &models.ProductImage{ProductID: productID, Primary: true}
+	if h.upload(c, img) {
+		c.JSON(http.StatusOK, gin.H{"message": "image uploaded", "image_id": img.ID})
+	}
+}
+
+// GetProductImage godoc
+//
+//	@Summary		Get product image
+//	@Description	Get the primary image of a product by ID. With presigned redirects enabled
+//	@Description	the response redirects to a temporary URL of the object storage.
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		image/jpeg
+//	@Produce		image/png
+//	@Param			id				path		int64	true	"Product ID"
+//	@Param			size			query		string	false	"Rendition: original (default), thumb, medium, large"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy"
+//	@Success		200				{string}	binary	"Image binary data"
+//	@Header			200				{string}	ETag	"Rendition version"
+//	@Success		302				{string}	string	"Redirect to a presigned URL"
+//	@Success		304				{string}	string	"Cached copy is current"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Router			/products/{id}/image [get]
+func (h *Handler) GetProductImage(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	img, err := h.service.GetPrimaryImage(c.Request.Context(), productID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	h.serve(c, img, revalidateCache)
+}
+
+// upload reads the "image" form file into img, it reports whether the
+// image was stored and writes the error response otherwise.
+func (h *Handler) upload(c *gin.Context, img *models.ProductImage) bool {
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return false
+	}
+
+	defer func() {
+		if err = file.Close(); err != nil {
+			log.Printf("failed to close uploaded image: %v", err)
+		}
+	}()
+
+	if header.Size > maxImageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file size exceeds 10MB"})
+		return false
+	}
+
+	if img.ContentType, err = sniff(file); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file header"})
+		return false
+	}
+
+	if err = h.service.AddImage(c.Request.Context(), img, file); err != nil {
+		switch {
+		case errors.Is(err, images.ErrUnsupportedType):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported file type: " + img.ContentType})
+		case errors.Is(err, images.ErrInvalidImage), errors.Is(err, images.ErrImageTooLarge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			writeError(c, err)
+		}
+		return false
+	}
+	return true
+}
+
+// serve writes the rendition picked by the size query parameter. The
+// ETag is derived from the object key, which changes with every upload,
+// so a matching If-None-Match is answered without touching the storage.
+func (h *Handler) serve(c *gin.Context, img *models.ProductImage, cacheControl string) {
+	size := c.Query("size")
+	key, err := h.service.RenditionKey(img, size)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	etag := etagOf(key)
+	c.Header("ETag", etag)
+	if etagMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Header("Cache-Control", cacheControl)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if h.redirect {
+		url, err := h.service.PresignImage(c.Request.Context(), img, size, h.urlTTL)
+		if err == nil {
+			// the redirect must not outlive the signature
+			c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(h.urlTTL.Seconds())))
+			c.Redirect(http.StatusFound, url)
+			return
+		}
+		if !errors.Is(err, images.ErrPresignUnsupported) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	obj, err := h.service.OpenImage(c.Request.Context(), img, size)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer func() {
+		if err = obj.Body.Close(); err != nil {
+			log.Printf("failed to close image %d: %v", img.ID, err)
+		}
+	}()
+
+	name := fmt.Sprintf("product-%d-%d", img.ProductID, img.ID)
+	if size != "" && size != images.SizeOriginal {
+		name += "-" + size
+	}
+	c.Header("Cache-Control", cacheControl)
+	c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, obj.Body, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`inline; filename="%s%s"`, name, path.Ext(key)),
+	})
+}
+
+func etagOf(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatch implements the weak comparison If-None-Match asks for.
+func etagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// sniff detects the content type from the first bytes and rewinds the file.
+func sniff(file multipart.File) (string, error) {
+	buffer := make([]byte, 512)
+	n, err := file.Read(buffer)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+	return http.DetectContentType(buffer[:n]), nil
+}
+
+func pathID(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, images.ErrProductNotFound), errors.Is(err, images.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, images.ErrInvalidOrder), errors.Is(err, images.ErrUnknownSize):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
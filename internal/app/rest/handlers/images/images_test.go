@@ -0,0 +1,468 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/images"
+	"prodigo/pkg/config"
+	"prodigo/pkg/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+var png = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 16)...)
+
+func uploadRequest(t *testing.T, target string, fields map[string]string, content []byte) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		assert.NoError(t, mw.WriteField(k, v))
+	}
+	part, err := mw.CreateFormFile("image", "photo.png")
+	assert.NoError(t, err)
+	_, err = part.Write(content)
+	assert.NoError(t, err)
+	assert.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestNew(t *testing.T) {
+	service := new(images.MockService)
+	handler := New(service, &config.Config{AppStorageRedirect: true})
+	assert.NotNil(t, handler)
+	assert.Equal(t, service, handler.service)
+	assert.True(t, handler.redirect)
+	assert.Equal(t, defaultURLTTL, handler.urlTTL)
+}
+
+func TestHandler_ListImages(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("ListImages", mock.Anything, int64(1)).Return([]*models.ProductImage{
+			{ID: 2, ProductID: 1, Primary: true},
+			{ID: 3, ProductID: 1, Position: 1},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1/images", nil)
+
+		handler.ListImages(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"is_primary":true`)
+		assert.NotContains(t, w.Body.String(), `"key"`)
+	})
+	t.Run("invalid id", func(t *testing.T) {
+		handler := &Handler{}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "abc"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/abc/images", nil)
+
+		handler.ListImages(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_AddImage(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("AddImage", mock.Anything, mock.MatchedBy(func(img *models.ProductImage) bool {
+			return img.ProductID == 1 && img.AltText == "front" && img.Primary && img.ContentType == "image/png"
+		}), mock.Anything).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = uploadRequest(t, "/products/1/images", map[string]string{"alt_text": "front", "primary": "true"}, png)
+
+		handler.AddImage(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+	t.Run("invalid primary", func(t *testing.T) {
+		handler := &Handler{service: new(images.MockService)}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = uploadRequest(t, "/products/1/images", map[string]string{"primary": "maybe"}, png)
+
+		handler.AddImage(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("unsupported type", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("AddImage", mock.Anything, mock.Anything, mock.Anything).
+			Return(images.ErrUnsupportedType)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = uploadRequest(t, "/products/1/images", nil, []byte("plain text"))
+
+		handler.AddImage(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unsupported file type")
+	})
+	t.Run("invalid image", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("AddImage", mock.Anything, mock.Anything, mock.Anything).Return(images.ErrInvalidImage)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = uploadRequest(t, "/products/1/images", nil, png)
+
+		handler.AddImage(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid image")
+	})
+	t.Run("product not found", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("AddImage", mock.Anything, mock.Anything, mock.Anything).
+			Return(images.ErrProductNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "9"}}
+		c.Request = uploadRequest(t, "/products/9/images", nil, png)
+
+		handler.AddImage(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_ReorderImages(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("ReorderImages", mock.Anything, int64(1), []int64{3, 2}).Return(nil)
+		service.On("ListImages", mock.Anything, int64(1)).Return([]*models.ProductImage{{ID: 3}, {ID: 2}}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/images/order",
+			strings.NewReader(`{"image_ids":[3,2]}`))
+
+		handler.ReorderImages(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("invalid order", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("ReorderImages", mock.Anything, int64(1), []int64{3}).Return(images.ErrInvalidOrder)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/images/order",
+			strings.NewReader(`{"image_ids":[3]}`))
+
+		handler.ReorderImages(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		service.AssertNotCalled(t, "ListImages", mock.Anything, mock.Anything)
+	})
+}
+
+func TestHandler_SetPrimaryImage(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("SetPrimary", mock.Anything, int64(1), int64(2)).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}, {Key: "image_id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/images/2/primary", nil)
+
+		handler.SetPrimaryImage(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("invalid image id", func(t *testing.T) {
+		handler := &Handler{}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}, {Key: "image_id", Value: "x"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/images/x/primary", nil)
+
+		handler.SetPrimaryImage(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid image_id")
+	})
+}
+
+func TestHandler_DeleteImage(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("DeleteImage", mock.Anything, int64(1), int64(2)).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}, {Key: "image_id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/products/1/images/2", nil)
+
+		handler.DeleteImage(c)
+		c.Writer.WriteHeaderNow()
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("DeleteImage", mock.Anything, int64(1), int64(2)).Return(images.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}, {Key: "image_id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/products/1/images/2", nil)
+
+		handler.DeleteImage(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_GetImageFile(t *testing.T) {
+	service := new(images.MockService)
+	handler := &Handler{service: service}
+
+	img := &models.ProductImage{ID: 2, ProductID: 1, Key: "products/1/images/a.jpg", Renditions: []string{"thumb"}}
+	service.On("GetImage", mock.Anything, int64(1), int64(2)).Return(img, nil)
+	service.On("RenditionKey", img, "thumb").Return("products/1/images/a_thumb.jpg", nil)
+	service.On("RenditionKey", img, "huge").Return("", images.ErrUnknownSize)
+	service.On("OpenImage", mock.Anything, img, "thumb").Return(&storage.Object{
+		Body:        io.NopCloser(strings.NewReader("jpg")),
+		ContentType: "image/jpeg",
+		Size:        3,
+	}, nil)
+
+	request := func(target, etag string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}, {Key: "image_id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		if etag != "" {
+			c.Request.Header.Set("If-None-Match", etag)
+		}
+
+		handler.GetImageFile(c)
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	t.Run("serves the rendition", func(t *testing.T) {
+		w := request("/products/1/images/2/file?size=thumb", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "jpg", w.Body.String())
+		assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+		assert.Equal(t, immutableCache, w.Header().Get("Cache-Control"))
+		assert.Equal(t, `inline; filename="product-1-2-thumb.jpg"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, etagOf("products/1/images/a_thumb.jpg"), w.Header().Get("ETag"))
+	})
+	t.Run("not modified", func(t *testing.T) {
+		w := request("/products/1/images/2/file?size=thumb", `"other", W/`+etagOf("products/1/images/a_thumb.jpg"))
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		service.AssertNumberOfCalls(t, "OpenImage", 1)
+	})
+	t.Run("unknown size", func(t *testing.T) {
+		w := request("/products/1/images/2/file?size=huge", "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_UploadProductImage(t *testing.T) {
+	t.Run("invalid id", func(t *testing.T) {
+		handler := &Handler{service: new(images.MockService)}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "abc"}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/products/abc/image", nil)
+
+		handler.UploadProductImage(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"invalid product id"`)
+	})
+	t.Run("stores image as primary", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("AddImage", mock.Anything, mock.MatchedBy(func(img *models.ProductImage) bool {
+			return img.ProductID == 1 && img.Primary && img.ContentType == "image/png"
+		}), mock.Anything).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = uploadRequest(t, "/products/1/image", nil, png)
+
+		handler.UploadProductImage(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestHandler_GetProductImage(t *testing.T) {
+	t.Run("invalid id", func(t *testing.T) {
+		handler := &Handler{}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "abc"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/abc/image", nil)
+
+		handler.GetProductImage(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid product id")
+	})
+	t.Run("product without image", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		service.On("GetPrimaryImage", mock.Anything, int64(9999)).Return((*models.ProductImage)(nil), images.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "9999"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/9999/image", nil)
+
+		handler.GetProductImage(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "image not found")
+	})
+	t.Run("object missing in storage", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		img := &models.ProductImage{ProductID: 1, Key: "products/1/image.jpg"}
+		service.On("GetPrimaryImage", mock.Anything, int64(1)).Return(img, nil)
+		service.On("RenditionKey", img, "").Return(img.Key, nil)
+		service.On("OpenImage", mock.Anything, img, "").Return((*storage.Object)(nil), images.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1/image", nil)
+
+		handler.GetProductImage(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("primary image is revalidated", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service}
+
+		img := &models.ProductImage{ID: 4, ProductID: 1, Key: "products/1/images/b.png", ContentType: "image/png"}
+		service.On("GetPrimaryImage", mock.Anything, int64(1)).Return(img, nil)
+		service.On("RenditionKey", img, "").Return(img.Key, nil)
+		service.On("OpenImage", mock.Anything, img, "").Return(&storage.Object{
+			Body:        io.NopCloser(strings.NewReader("png")),
+			ContentType: "image/png",
+			Size:        3,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1/image", nil)
+
+		handler.GetProductImage(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "png", w.Body.String())
+		assert.Equal(t, revalidateCache, w.Header().Get("Cache-Control"))
+		assert.Equal(t, `inline; filename="product-1-4.png"`, w.Header().Get("Content-Disposition"))
+	})
+	t.Run("redirects to presigned url", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service, redirect: true, urlTTL: time.Minute}
+
+		img := &models.ProductImage{ProductID: 1, Key: "products/1/image.jpg"}
+		service.On("GetPrimaryImage", mock.Anything, int64(1)).Return(img, nil)
+		service.On("RenditionKey", img, "medium").Return("products/1/image_medium.jpg", nil)
+		service.On("PresignImage", mock.Anything, img, "medium", time.Minute).
+			Return("http://minio:9000/products/1/image_medium.jpg?X-Amz-Signature=abc", nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1/image?size=medium", nil)
+
+		handler.GetProductImage(c)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "http://minio:9000/products/1/image_medium.jpg?X-Amz-Signature=abc", w.Header().Get("Location"))
+		assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+	})
+	t.Run("presign error", func(t *testing.T) {
+		service := new(images.MockService)
+		handler := &Handler{service: service, redirect: true, urlTTL: time.Minute}
+
+		img := &models.ProductImage{ProductID: 1, Key: "products/1/image.jpg"}
+		service.On("GetPrimaryImage", mock.Anything, int64(1)).Return(img, nil)
+		service.On("RenditionKey", img, "").Return(img.Key, nil)
+		service.On("PresignImage", mock.Anything, img, "", time.Minute).Return("", errors.New("failed to sign image url"))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1/image", nil)
+
+		handler.GetProductImage(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,208 @@
+package inventory
+
+import (
+	"errors"
+	"net/http"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/inventory"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service inventory.ServiceInterface
+}
+
+func New(service inventory.ServiceInterface) *Handler {
+	return &Handler{service: service}
+}
+
+// StockRequest is one stock movement. Receipts, sales and returns take a
+// positive quantity, adjustments a signed one.
+type StockRequest struct {
+	VariantID *int64 `json:"variant_id"`
+	Kind      string `json:"kind" enums:"receipt,sale,adjustment,return"`
+	Reason    string `json:"reason"`
+	Quantity  int    `json:"quantity"`
+}
+
+// AdjustStock godoc
+//
+//	@Summary		Adjust product stock
+//	@Description	Record a receipt, sale, adjustment or return and change the stock atomically.
+//	@Description	Products with variants keep stock per variant and need variant_id.
+//	@Description	A movement that would take the stock below zero is rejected.
+//	@Tags			inventory
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int64			true	"Product ID"
+//	@Param			request	body		StockRequest	true	"Stock movement"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Success		201		{object}	models.StockMovement
+//	@Router			/products/{id}/stock [post]
+func (h *Handler) AdjustStock(c *gin.Context) {
+	productID, ok := pathID(c)
+	if !ok {
+		return
+	}
+	var req StockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	m := &models.StockMovement{ProductID: productID, VariantID: req.VariantID, Kind: req.Kind, Reason: req.Reason}
+	if err := h.service.AdjustStock(c.Request.Context(), m, req.Quantity); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, m)
+}
+
+// GetStockLevel godoc
+//
+//	@Summary		Get product stock
+//	@Description	Get the stock on hand of a product and of each of its variants
+//	@Tags			inventory
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Product ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{object}	models.StockLevel
+//	@Router			/products/{id}/stock [get]
+func (h *Handler) GetStockLevel(c *gin.Context) {
+	productID, ok := pathID(c)
+	if !ok {
+		return
+	}
+	level, err := h.service.GetStockLevel(c.Request.Context(), productID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, level)
+}
+
+// ListMovements godoc
+//
+//	@Summary		Get stock history
+//	@Description	Get the stock movements of a product newest first, with the stock after each one.
+//	@Description	Pass before_id of a page to get the next one.
+//	@Tags			inventory
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id			path		int64	true	"Product ID"
+//	@Param			variant_id	query		int64	false	"Only movements of this variant"
+//	@Param			limit		query		int		false	"Page size"
+//	@Param			before_id	query		int64	false	"Return movements older than this one"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	models.StockPage
+//	@Router			/products/{id}/stock/history [get]
+func (h *Handler) ListMovements(c *gin.Context) {
+	productID, ok := pathID(c)
+	if !ok {
+		return
+	}
+	f, err := parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	f.ProductID = productID
+
+	page, err := h.service.ListMovements(c.Request.Context(), f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// ListLowStock godoc
+//
+//	@Summary		Get low stock products
+//	@Description	Get the products whose stock on hand is below their reorder threshold, the emptiest first.
+//	@Description	since is when the product dropped below the threshold.
+//	@Tags			inventory
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.LowStock
+//	@Router			/products/low-stock [get]
+func (h *Handler) ListLowStock(c *gin.Context) {
+	products, err := h.service.ListLowStock(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, products)
+}
+
+func parseFilter(c *gin.Context) (*models.StockFilter, error) {
+	f := &models.StockFilter{}
+	var err error
+	if f.VariantID, err = optionalID(c, "variant_id"); err != nil {
+		return nil, err
+	}
+	if id, err := optionalID(c, "before_id"); err != nil {
+		return nil, err
+	} else if id != nil {
+		f.BeforeID = *id
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			return nil, errors.New("invalid limit")
+		}
+	}
+	return f, nil
+}
+
+func optionalID(c *gin.Context, key string) (*int64, error) {
+	v := c.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.New("invalid " + key)
+	}
+	return &id, nil
+}
+
+func pathID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, inventory.ErrProductNotFound), errors.Is(err, inventory.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidMovement):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,175 @@
+package inventory
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/inventory"
+	"strings"
+	"testing"
+)
+
+func newContext(method, target, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c, w
+}
+
+func TestHandler_AdjustStock(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(inventory.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("AdjustStock", mock.Anything, mock.MatchedBy(func(m *models.StockMovement) bool {
+			return m.ProductID == 1 && *m.VariantID == 2 && m.Kind == models.MovementSale && m.Reason == "order 42"
+		}), 3).Run(func(args mock.Arguments) {
+			m := args.Get(1).(*models.StockMovement)
+			m.ID, m.Delta, m.Balance = 10, -3, 4
+		}).Return(nil)
+
+		body := `{"kind":"sale","quantity":3,"variant_id":2,"reason":"order 42"}`
+		c, w := newContext(http.MethodPost, "/products/1/stock", body)
+		handler.AdjustStock(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"balance":4`)
+	})
+	t.Run("insufficient stock", func(t *testing.T) {
+		service := new(inventory.MockService)
+		handler := New(service)
+
+		service.On("AdjustStock", mock.Anything, mock.Anything, 9).Return(models.ErrInsufficientStock)
+
+		c, w := newContext(http.MethodPost, "/products/1/stock", `{"kind":"sale","quantity":9}`)
+		handler.AdjustStock(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("invalid movement", func(t *testing.T) {
+		service := new(inventory.MockService)
+		handler := New(service)
+
+		service.On("AdjustStock", mock.Anything, mock.Anything, 0).Return(models.ErrInvalidMovement)
+
+		c, w := newContext(http.MethodPost, "/products/1/stock", `{"kind":"receipt"}`)
+		handler.AdjustStock(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("variant not found", func(t *testing.T) {
+		service := new(inventory.MockService)
+		handler := New(service)
+
+		service.On("AdjustStock", mock.Anything, mock.Anything, 1).Return(inventory.ErrVariantNotFound)
+
+		c, w := newContext(http.MethodPost, "/products/1/stock", `{"kind":"receipt","quantity":1,"variant_id":5}`)
+		handler.AdjustStock(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("invalid body", func(t *testing.T) {
+		handler := New(new(inventory.MockService))
+
+		c, w := newContext(http.MethodPost, "/products/1/stock", `{`)
+		handler.AdjustStock(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_GetStockLevel(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(inventory.MockService)
+		handler := New(service)
+
+		service.On("GetStockLevel", mock.Anything, int64(1)).Return(&models.StockLevel{ProductID: 1, OnHand: 0}, nil)
+
+		c, w := newContext(http.MethodGet, "/products/1/stock", "")
+		handler.GetStockLevel(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"on_hand":0`)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(inventory.MockService)
+		handler := New(service)
+
+		service.On("GetStockLevel", mock.Anything, int64(1)).Return(nil, inventory.ErrProductNotFound)
+
+		c, w := newContext(http.MethodGet, "/products/1/stock", "")
+		handler.GetStockLevel(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_ListMovements(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(inventory.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("ListMovements", mock.Anything, mock.MatchedBy(func(f *models.StockFilter) bool {
+			return f.ProductID == 1 && *f.VariantID == 2 && f.BeforeID == 30 && f.Limit == 10
+		})).Return(&models.StockPage{Items: []*models.StockMovement{}}, nil)
+
+		c, w := newContext(http.MethodGet, "/products/1/stock/history?variant_id=2&before_id=30&limit=10", "")
+		handler.ListMovements(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("invalid limit", func(t *testing.T) {
+		handler := New(new(inventory.MockService))
+
+		c, w := newContext(http.MethodGet, "/products/1/stock/history?limit=-1", "")
+		handler.ListMovements(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("service error", func(t *testing.T) {
+		service := new(inventory.MockService)
+		handler := New(service)
+
+		service.On("ListMovements", mock.Anything, mock.Anything).Return(nil, errors.New("failed to get stock history"))
+
+		c, w := newContext(http.MethodGet, "/products/1/stock/history", "")
+		handler.ListMovements(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestHandler_ListLowStock(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(inventory.MockService)
+		handler := New(service)
+
+		service.On("ListLowStock", mock.Anything).
+			Return([]*models.LowStock{{ProductID: 1, Title: "watch", OnHand: 1, Threshold: 5}}, nil)
+
+		c, w := newContext(http.MethodGet, "/products/low-stock", "")
+		handler.ListLowStock(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"reorder_threshold":5`)
+	})
+	t.Run("service error", func(t *testing.T) {
+		service := new(inventory.MockService)
+		handler := New(service)
+
+		service.On("ListLowStock", mock.Anything).Return(nil, errors.New("failed to get low stock products"))
+
+		c, w := newContext(http.MethodGet, "/products/low-stock", "")
+		handler.ListLowStock(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -1,25 +1,34 @@
 package products
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
+	"maps"
+	"math"
 	"net/http"
-	"os"
+	"net/url"
 	"path/filepath"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/rest/casbin"
+	"prodigo/internal/app/rest/handlers/etag"
 	"prodigo/internal/app/usecases/products"
+	"prodigo/pkg/xlsx"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
-	service products.ServiceInterface
+	service  products.ServiceInterface
+	enforcer casbin.Enforcer
 }
 
-func New(service products.ServiceInterface) *Handler {
-	return &Handler{service: service}
+func New(service products.ServiceInterface, enforcer casbin.Enforcer) *Handler {
+	return &Handler{service: service, enforcer: enforcer}
 }
 
 // CreateProduct godoc
@@ -34,6 +43,7 @@ func New(service products.ServiceInterface) *Handler {
 //	@Produce		json
 //	@Param			request	body		models.Product	true	"Product details"
 //	@Failure		400		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
 //	@Success		201		{object}	map[string]string
 //	@Router			/products/ [post]
@@ -44,12 +54,24 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 		return
 	}
 	if err := h.service.CreateProduct(c.Request.Context(), &p); err != nil {
+		if errors.Is(err, models.ErrInvalidStatus) || errors.Is(err, models.ErrInvalidMovement) ||
+			errors.Is(err, models.ErrInvalidThreshold) || errors.Is(err, models.ErrInvalidPrice) ||
+			errors.Is(err, models.ErrInvalidAttribute) || errors.Is(err, models.ErrInvalidDetails) ||
+			invalidIdentifier(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if duplicateIdentifier(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{
 		"message":  "product created",
 		"title":    p.Title,
+		"slug":     p.Slug,
 		"price":    p.Price,
 		"quantity": p.Quantity,
 		"status":   p.Status,
@@ -60,6 +82,10 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 //
 //	@Summary		Get all products
 //	@Description	Get a list of all products with optional filters
+//	@Description	attr.<name>=value filters by an attribute of the category, repeat it to match any of several values.
+//	@Description	attr.<name>_gte and attr.<name>_lte bound a number attribute.
+//	@Description	The first page carries facets, the product counts per attribute value.
+//	@Description	Titles and descriptions are translated into the best locale of Accept-Language that has a translation.
 //	@Tags			products
 //
 // @Security	ApiKeyAuth
@@ -67,57 +93,188 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			category	query		string	false	"Filter by category name"
+//	@Param			category_id	query		int64	false	"Filter by category ID"
+//	@Param			include_descendants	query		bool	false	"Let the category filters match subcategories too"
 //	@Param			status	query		string	false	"Filter by product status"
-//	@Param			price_min	query		int	false	"Minimum price filter"
-//	@Param			price_max	query		int	false	"Maximum price filter"
-//	@Param			search	query		string	false	"Search term for product title"
+//	@Param			currency	query		string	false	"Filter by ISO 4217 currency, required with price_min, price_max and the price sort"
+//	@Param			price_min	query		string	false	"Minimum price in the currency, 19.99"
+//	@Param			price_max	query		string	false	"Maximum price in the currency, 19.99"
+//	@Param			search	query		string	false	"Full-text search over product title, brand and description, words match by prefix"
+//	@Param			sort	query		string	false	"Sort order: id, price, created_at, title or relevance, prefix with - for descending"
+//	@Param			limit	query		int	false	"Page size"
+//	@Param			cursor	query		string	false	"Cursor from next_cursor or prev_cursor of a previous page"
+//	@Param			Accept-Language	header		string	false	"Preferred locales, de-CH, de;q=0.8"
+//	@Failure		400		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
-//	@Success		200		{object}	[]models.Product
+//	@Success		200		{object}	models.ProductPage
 //	@Router			/products/ [get]
 func (h *Handler) GetAllProducts(c *gin.Context) {
+	fs, err := filterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		fs.Limit = n
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		if fs.Cursor, err = models.DecodeProductCursor(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	page, err := h.service.GetAllProducts(localized(c), &fs)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) || errors.Is(err, models.ErrInvalidSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// filterFromQuery reads the filters and sort order shared by listing and export.
+func filterFromQuery(c *gin.Context) (models.ProductFilterSearch, error) {
 	var fs models.ProductFilterSearch
 	if v := c.Query("category"); v != "" {
 		fs.CategoryName = v
 	}
+	if v := c.Query("category_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return fs, errors.New("invalid category_id")
+		}
+		fs.CategoryID = id
+	}
+	if v := c.Query("include_descendants"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return fs, errors.New("invalid include_descendants")
+		}
+		fs.IncludeDescendants = include
+	}
 	if v := c.Query("status"); v != "" {
 		fs.Status = v
 	}
-	if v := c.Query("price_min"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			fs.PriceMin = n
+	if v := c.Query("currency"); v != "" {
+		if _, ok := models.MinorDigits(v); !ok {
+			return fs, errors.New("invalid currency")
 		}
+		fs.Currency = v
 	}
-	if v := c.Query("price_max"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			fs.PriceMax = n
+	// the price bounds are decimals in the currency, "19.99"
+	for _, bound := range []struct {
+		dst  *int64
+		name string
+	}{{&fs.PriceMin, "price_min"}, {&fs.PriceMax, "price_max"}} {
+		v := c.Query(bound.name)
+		if v == "" {
+			continue
+		}
+		if fs.Currency == "" {
+			return fs, errors.New(bound.name + " needs a currency")
+		}
+		m, err := models.ParseMoney(v, fs.Currency)
+		if err != nil {
+			return fs, errors.New("invalid " + bound.name)
 		}
+		*bound.dst = m.Amount
 	}
 	if v := c.Query("search"); v != "" {
 		fs.Search = v
 	}
-	prods, err := h.service.GetAllProducts(c.Request.Context(), &fs)
+	attrs, err := attributeFilters(c.Request.URL.Query())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return fs, err
+	}
+	fs.Attributes = attrs
+
+	sort, err := models.ParseProductSort(c.Query("sort"))
+	if err != nil {
+		return fs, err
 	}
-	c.JSON(http.StatusOK, prods)
+	fs.Sort = sort
+	return fs, nil
+}
+
+// attributeFilters reads the attr.<name> parameters into one filter per
+// attribute, sorted by name. Repeating attr.<name> matches any of the
+// values, attr.<name>_gte and attr.<name>_lte bound a number.
+func attributeFilters(query url.Values) ([]models.AttributeFilter, error) {
+	filters := map[string]*models.AttributeFilter{}
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "attr.")
+		if !ok {
+			continue
+		}
+		var bound **float64
+		base, isMin := strings.CutSuffix(name, "_gte")
+		base, isMax := strings.CutSuffix(base, "_lte")
+		if !models.ValidAttributeName(base) || (isMin && isMax) {
+			return nil, errors.New("invalid attribute filter " + key)
+		}
+
+		f, ok := filters[base]
+		if !ok {
+			f = &models.AttributeFilter{Name: base}
+			filters[base] = f
+		}
+		switch {
+		case isMin:
+			bound = &f.Min
+		case isMax:
+			bound = &f.Max
+		default:
+			f.Values = append(f.Values, values...)
+			continue
+		}
+		n, err := strconv.ParseFloat(values[0], 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, errors.New("invalid " + key)
+		}
+		*bound = &n
+	}
+
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	names := slices.Sorted(maps.Keys(filters))
+	attrs := make([]models.AttributeFilter, len(names))
+	for i, name := range names {
+		attrs[i] = *filters[name]
+	}
+	return attrs, nil
 }
 
 // GetProductByID godoc
 //
 //	@Summary		Get a product by ID
-//	@Description	Get the details of a product by its ID
+//	@Description	Get the details of a product by its ID.
+//	@Description	The title and description are translated into the best locale of Accept-Language that has a
+//	@Description	translation, Content-Language names it. Without one the original text is returned.
 //	@Tags			products
 //
 // @Security	ApiKeyAuth
 //
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int64	true	"Product ID"
-//	@Failure		400	{object}	map[string]string
-//	@Failure		404	{object}	map[string]string
-//	@Failure		500	{object}	map[string]string
-//	@Success		200	{object}	models.Product
+//	@Param			id				path		int64	true	"Product ID"
+//	@Param			Accept-Language	header		string	false	"Preferred locales, de-CH, de;q=0.8"
+//	@Failure		400				{object}	map[string]string
+//	@Failure		404				{object}	map[string]string
+//	@Failure		500				{object}	map[string]string
+//	@Success		200				{object}	models.Product
+//	@Header			200				{string}	ETag				"Product version"
+//	@Header			200				{string}	Content-Language	"Locale of the translation"
 //	@Router			/products/{id} [get]
 func (h *Handler) GetProductByID(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -125,33 +282,119 @@ func (h *Handler) GetProductByID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	product, err := h.service.GetProduct(c.Request.Context(), id)
+	product, err := h.service.GetProduct(localized(c), id)
+	h.writeProduct(c, product, err)
+}
+
+// GetProductBySKU godoc
+//
+//	@Summary		Get a product by SKU
+//	@Description	Get the details of a product by its stock keeping unit, translated like GET /products/{id}
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			sku				path		string	true	"Product SKU"
+//	@Param			Accept-Language	header		string	false	"Preferred locales, de-CH, de;q=0.8"
+//	@Failure		400				{object}	map[string]string
+//	@Failure		404				{object}	map[string]string
+//	@Failure		500				{object}	map[string]string
+//	@Success		200				{object}	models.Product
+//	@Header			200				{string}	ETag				"Product version"
+//	@Header			200				{string}	Content-Language	"Locale of the translation"
+//	@Router			/products/by-sku/{sku} [get]
+func (h *Handler) GetProductBySKU(c *gin.Context) {
+	product, err := h.service.GetProductBySKU(localized(c), c.Param("sku"))
+	h.writeProduct(c, product, err)
+}
+
+// GetProductByBarcode godoc
+//
+//	@Summary		Get a product by barcode
+//	@Description	Get the details of a product by an EAN-8, UPC-A, EAN-13 or GTIN-14 barcode.
+//	@Description	The check digit is verified, the UPC-A and EAN-13 forms of a code find the same product.
+//	@Description	The product is translated like GET /products/{id}.
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			code			path		string	true	"Barcode digits"
+//	@Param			Accept-Language	header		string	false	"Preferred locales, de-CH, de;q=0.8"
+//	@Failure		400				{object}	map[string]string
+//	@Failure		404				{object}	map[string]string
+//	@Failure		500				{object}	map[string]string
+//	@Success		200				{object}	models.Product
+//	@Header			200				{string}	ETag				"Product version"
+//	@Header			200				{string}	Content-Language	"Locale of the translation"
+//	@Router			/products/by-barcode/{code} [get]
+func (h *Handler) GetProductByBarcode(c *gin.Context) {
+	product, err := h.service.GetProductByBarcode(localized(c), c.Param("code"))
+	h.writeProduct(c, product, err)
+}
+
+// writeProduct answers a lookup with the product, its ETag and the locale
+// it was translated into.
+func (h *Handler) writeProduct(c *gin.Context, product *models.Product, err error) {
 	if err != nil {
-		if errors.Is(err, products.ErrNotFound) {
+		switch {
+		case invalidIdentifier(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, products.ErrNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.Header("ETag", etag.Format(product.Version))
+	if product.Locale != "" {
+		c.Header("Content-Language", product.Locale)
+	}
 	c.JSON(http.StatusOK, product)
 }
 
+// localized stores the locales the Accept-Language header asks for in the
+// request context, so the response varies by that header.
+func localized(c *gin.Context) context.Context {
+	c.Header("Vary", "Accept-Language")
+	return models.WithLocales(c.Request.Context(), models.LocaleChain(c.GetHeader("Accept-Language")))
+}
+
+func invalidIdentifier(err error) bool {
+	return errors.Is(err, models.ErrInvalidSKU) || errors.Is(err, models.ErrInvalidGTIN) ||
+		errors.Is(err, models.ErrInvalidSlug)
+}
+
+func duplicateIdentifier(err error) bool {
+	return errors.Is(err, products.ErrDuplicateSKU) || errors.Is(err, products.ErrDuplicateGTIN) ||
+		errors.Is(err, products.ErrDuplicateSlug)
+}
+
 // UpdateProduct godoc
 //
 //	@Summary		Update an existing product
-//	@Description	Update the details of an existing product by ID
+//	@Description	Update the details of an existing product by ID.
+//	@Description	The quantity is changed through POST /products/{id}/stock only.
+//	@Description	The slug stays when the title changes, set slug to change it.
 //	@Tags			products
 //
 // @Security	ApiKeyAuth
 //
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int64			true	"Product ID"
-//	@Param			request	body		models.Product	true	"Product details"
-//	@Failure		400		{object}	map[string]string
-//	@Failure		404		{object}	map[string]string
-//	@Failure		500		{object}	map[string]string
-//	@Success		200		{object}	models.Product
+//	@Param			id			path		int64			true	"Product ID"
+//	@Param			If-Match	header		string			true	"ETag of the product being updated"
+//	@Param			request		body		models.Product	true	"Product details"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		409			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	models.Product
+//	@Header			200			{string}	ETag	"Product version"
 //	@Router			/products/{id} [put]
 func (h *Handler) UpdateProduct(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -160,15 +403,35 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
+
 	var p models.Product
 	if err = c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	p.ID = id
+	p.Version = version
 
 	if err = h.service.UpdateProduct(c.Request.Context(), &p); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, products.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, products.ErrVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidStatus), errors.Is(err, models.ErrStockManaged),
+			errors.Is(err, models.ErrInvalidThreshold), errors.Is(err, models.ErrInvalidPrice),
+			errors.Is(err, models.ErrCurrencyMismatch), errors.Is(err, models.ErrInvalidAttribute),
+			errors.Is(err, models.ErrInvalidDetails), invalidIdentifier(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidTransition), duplicateIdentifier(err):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
@@ -177,6 +440,7 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
 		return
 	}
+	c.Header("ETag", etag.Format(updatedProduct.Version))
 	c.JSON(http.StatusOK, updatedProduct)
 
 }
@@ -191,10 +455,14 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 //
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int64	true	"Product ID"
-//	@Failure		400	{object}	map[string]string
-//	@Failure		500	{object}	map[string]string
-//	@Success		204	{object}	map[string]string
+//	@Param			id			path		int64	true	"Product ID"
+//	@Param			If-Match	header		string	true	"ETag of the product being deleted"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		204			{object}	map[string]string
 //	@Router			/products/{id} [delete]
 func (h *Handler) DeleteProduct(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -202,8 +470,19 @@ func (h *Handler) DeleteProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	if err := h.service.DeleteProduct(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteProduct(c.Request.Context(), id, version); err != nil {
+		switch {
+		case errors.Is(err, products.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, products.ErrVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	c.JSON(http.StatusNoContent, gin.H{"message": "product deleted"})
@@ -216,19 +495,25 @@ type UpdateStatus struct {
 // UpdateProductStatus godoc
 //
 //	@Summary		Update product status
-//	@Description	Update the status of a product by ID
+//	@Description	Move a product to another status of its lifecycle:
+//	@Description	draft -> active, archived; active -> out_of_stock, discontinued, archived;
+//	@Description	out_of_stock -> active, discontinued, archived; discontinued -> archived; archived -> draft
 //	@Tags			products
 //
 // @Security	ApiKeyAuth
 //
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int64			true	"Product ID"
-//	@Param			request	body		UpdateStatus	true	"Product status"
-//	@Failure		400		{object}	map[string]string
-//	@Failure		404		{object}	map[string]string
-//	@Failure		500		{object}	map[string]string
-//	@Success		200		{object}	map[string]string
+//	@Param			id			path		int64			true	"Product ID"
+//	@Param			If-Match	header		string			true	"ETag of the product being updated"
+//	@Param			request		body		UpdateStatus	true	"Product status"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		409			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	map[string]string
 //	@Router			/products/{id}/status [put]
 func (h *Handler) UpdateProductStatus(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -236,12 +521,56 @@ func (h *Handler) UpdateProductStatus(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
 	var payload UpdateStatus
 	if err = c.ShouldBindJSON(&payload); err != nil || payload.Status == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
 		return
 	}
-	err = h.service.UpdateProductStatus(c.Request.Context(), id, payload.Status)
+	err = h.service.UpdateProductStatus(c.Request.Context(), id, payload.Status, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, products.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, products.ErrVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidStatus):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "status updated"})
+}
+
+// GetStatusHistory godoc
+//
+//	@Summary		Get product status history
+//	@Description	Get every status change of a product with the user who made it, oldest first
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Product ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.StatusChange
+//	@Router			/products/{id}/status/history [get]
+func (h *Handler) GetStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	history, err := h.service.GetStatusHistory(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, products.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -250,169 +579,498 @@ func (h *Handler) UpdateProductStatus(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "status updated"})
+	c.JSON(http.StatusOK, history)
 }
 
-const uploadDir = "./uploads/products"
+// PriceRequest schedules a price, without valid_to it becomes the regular
+// price once it takes effect.
+type PriceRequest struct {
+	ValidFrom time.Time    `json:"valid_from" example:"2025-01-01T00:00:00Z"`
+	ValidTo   *time.Time   `json:"valid_to"`
+	Price     models.Money `json:"price"`
+}
 
-// UploadProductImage godoc
+// SchedulePrice godoc
 //
-//	@Summary		Upload product image
-//	@Description	Upload an image for a product by ID
+//	@Summary		Schedule a product price
+//	@Description	Add a price that takes effect at valid_from. With valid_to it lasts until then and wins over
+//	@Description	the regular price, like a sale. Without valid_to it replaces the regular price.
 //	@Tags			products
 //
 // @Security	ApiKeyAuth
 //
 //	@Accept			json
-//	@Accept			multipart/form-data
 //	@Produce		json
-//	@Param			id		path		int64	true	"Product ID"
-//	@Param			image	formData	file	true	"Product image file"
+//	@Param			id		path		int64			true	"Product ID"
+//	@Param			request	body		PriceRequest	true	"Price and when it applies"
 //	@Failure		400		{object}	map[string]string
 //	@Failure		404		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
 //	@Failure		500		{object}	map[string]string
-//	@Success		200		{object}	map[string]string
-//	@Router			/products/{id}/image [post]
-func (h *Handler) UploadProductImage(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+//	@Success		201		{object}	models.ProductPrice
+//	@Router			/products/{id}/prices [post]
+func (h *Handler) SchedulePrice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req PriceRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	pr := &models.ProductPrice{ProductID: id, Price: req.Price, ValidFrom: req.ValidFrom, ValidTo: req.ValidTo}
+	if err = h.service.SchedulePrice(c.Request.Context(), pr); err != nil {
+		switch {
+		case errors.Is(err, products.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidPrice), errors.Is(err, models.ErrCurrencyMismatch),
+			errors.Is(err, models.ErrInvalidSchedule):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, products.ErrVersionConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, pr)
+}
 
-	file, header, err := c.Request.FormFile("image")
+// GetPriceHistory godoc
+//
+//	@Summary		Get product price history
+//	@Description	Get the past, current and scheduled prices of a product in the order they take effect
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Product ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.ProductPrice
+//	@Router			/products/{id}/prices [get]
+func (h *Handler) GetPriceHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-
-	defer func() {
-		if err = file.Close(); err != nil {
-			fmt.Println("Error closing file:", err)
+	prices, err := h.service.GetPriceHistory(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
 		}
-	}()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, prices)
+}
 
-	buffer := make([]byte, 512)
-	_, err = file.Read(buffer)
+// TranslationRequest is the text of a product in a locale, an empty
+// description falls back to the original one.
+type TranslationRequest struct {
+	Title       string `json:"title" example:"Handy"`
+	Description string `json:"description"`
+}
+
+// ListTranslations godoc
+//
+//	@Summary		List product translations
+//	@Description	Get the translations of a product by locale
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Product ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.ProductTranslation
+//	@Router			/products/{id}/translations [get]
+func (h *Handler) ListTranslations(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file header"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
+	trs, err := h.service.ListTranslations(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, trs)
+}
 
-	contentType := http.DetectContentType(buffer)
-	if contentType != "image/jpeg" && contentType != "image/png" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported file type: " + contentType})
+// UpsertTranslation godoc
+//
+//	@Summary		Create or replace a product translation
+//	@Description	Set the title and description of a product in a locale, a BCP 47 tag like de or pt-BR.
+//	@Description	The locale is stored in its canonical case, pt_br becomes pt-BR.
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int64				true	"Product ID"
+//	@Param			locale	path		string				true	"Locale"
+//	@Param			request	body		TranslationRequest	true	"Translated text"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Success		200		{object}	models.ProductTranslation
+//	@Router			/products/{id}/translations/{locale} [put]
+func (h *Handler) UpsertTranslation(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	if header.Size > 10*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "file size exceeds 10MB"})
+	var req TranslationRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tr := &models.ProductTranslation{
+		ProductID: id, Locale: c.Param("locale"), Title: req.Title, Description: req.Description,
+	}
+	if err = h.service.UpsertTranslation(c.Request.Context(), tr); err != nil {
+		switch {
+		case errors.Is(err, products.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrInvalidLocale), errors.Is(err, models.ErrInvalidTranslation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
+	c.JSON(http.StatusOK, tr)
+}
 
-	_, err = file.Seek(0, io.SeekStart)
+// RestoreProduct godoc
+//
+//	@Summary		Restore a deleted product
+//	@Description	Restore a soft-deleted product by ID, If-Match carries the ETag it had when it was deleted
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int64	true	"Product ID"
+//	@Param			If-Match	header		string	true	"ETag of the deleted product"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	map[string]string
+//	@Router			/products/{id}/restore [put]
+func (h *Handler) RestoreProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rewind file"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-
-	productDir := filepath.Join(uploadDir, idStr)
-	const perm = 0o750
-	if err = os.MkdirAll(productDir, perm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create directory"})
+	version, ok := etag.Require(c)
+	if !ok {
 		return
 	}
-
-	filePath := filepath.Join(productDir, "image.jpg")
-	// #nosec G304
-	dst, errf := os.Create(filePath)
-	if errf != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create file"})
+	if err := h.service.RestoreProduct(c.Request.Context(), id, version); err != nil {
+		switch {
+		case errors.Is(err, products.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, products.ErrVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
+	c.Status(http.StatusAccepted)
+	c.JSON(http.StatusOK, gin.H{"message": "product restored"})
+}
 
-	defer func() {
-		if err = dst.Close(); err != nil {
-			fmt.Println("Error closing file dst:", err)
-		}
-	}()
+// batchRoutes are the single product routes, under the products group,
+// whose policy decides who may run each batch action.
+var batchRoutes = map[string]struct{ path, method string }{
+	models.BatchActionUpdate:  {"/%d", http.MethodPut},
+	models.BatchActionStatus:  {"/%d/status", http.MethodPut},
+	models.BatchActionDelete:  {"/%d", http.MethodDelete},
+	models.BatchActionRestore: {"/%d/restore", http.MethodPut},
+}
 
-	if _, err = io.Copy(dst, file); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+// BatchProducts godoc
+//
+//	@Summary		Run a batch of product operations
+//	@Description	Update fields, change the status, delete or restore many products in one request.
+//	@Description	Every action needs the permission of its single product endpoint.
+//	@Description	With all_or_nothing the batch is one transaction the first failure rolls back, answered with 422.
+//	@Description	Otherwise every item commits on its own. Each result carries the status code of the single endpoint.
+//	@Tags			products
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.Batch	true	"Operations"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		403		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
+//	@Failure		422		{object}	models.BatchReport
+//	@Failure		500		{object}	map[string]string
+//	@Success		200		{object}	models.BatchReport
+//	@Router			/products/batch [post]
+func (h *Handler) BatchProducts(c *gin.Context) {
+	var b models.Batch
+	if err := c.ShouldBindJSON(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.CheckBatch(&b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorizeBatch(c, &b) {
 		return
 	}
 
-	product, err := h.service.GetProduct(c.Request.Context(), id)
+	report, err := h.service.BatchProducts(c.Request.Context(), &b)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		if errors.Is(err, products.ErrVersionConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	for i := range report.Results {
+		report.Results[i].Code = batchCode(report.Results[i].Err)
+	}
 
-	product.Image = filePath
-	if err := h.service.UpdateProduct(c.Request.Context(), product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update image in DB"})
+	if report.RolledBack {
+		c.JSON(http.StatusUnprocessableEntity, report)
 		return
 	}
+	c.JSON(http.StatusOK, report)
+}
+
+// authorizeBatch checks every action of b against the policy of its
+// single product route and answers 403 when one is not allowed.
+func (h *Handler) authorizeBatch(c *gin.Context, b *models.Batch) bool {
+	principal, ok := models.PrincipalFrom(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return false
+	}
+	group := strings.TrimSuffix(c.Request.URL.Path, "/batch")
+
+	checked := make(map[string]bool)
+	for _, op := range b.Operations {
+		if checked[op.Action] {
+			continue
+		}
+		checked[op.Action] = true
+
+		route := batchRoutes[op.Action]
+		obj := group + fmt.Sprintf(route.path, op.IDs[0])
+		allowed, err := h.enforcer.Enforce(principal.Role, obj, route.method)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("forbidden: %s", op.Action)})
+			return false
+		}
+	}
+	return true
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "image uploaded", "filename": header.Filename})
+// batchCode is the status the single product endpoint answers err with.
+// Batches carry no If-Match, so a concurrent write is a conflict.
+func batchCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, models.ErrBatchRolledBack):
+		return http.StatusFailedDependency
+	case errors.Is(err, products.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrInvalidStatus), errors.Is(err, models.ErrStockManaged),
+		errors.Is(err, models.ErrInvalidThreshold), errors.Is(err, models.ErrInvalidPrice),
+		errors.Is(err, models.ErrCurrencyMismatch), errors.Is(err, models.ErrInvalidAttribute),
+		errors.Is(err, models.ErrInvalidDetails), invalidIdentifier(err):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, products.ErrVersionConflict),
+		duplicateIdentifier(err):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
-// GetProductImage godoc
+const maxImportSize = 20 * 1024 * 1024
+
+// ImportProducts godoc
 //
-//	@Summary		Get product image
-//	@Description	Get the image of a product by ID
+//	@Summary		Import products
+//	@Description	Bulk create products from a CSV file with a header line or an NDJSON file.
+//	@Description	Every row is validated and reported by its line number, valid rows are inserted in one transaction.
 //	@Tags			products
 //
 // @Security	ApiKeyAuth
 //
-//	@Accept			json
-//	@Produce		image/jpeg
-//	@Param			id	path		int64	true	"Product ID"
-//	@Success		200	{string}	binary	"Image binary data"
-//	@Failure		400	{object}	map[string]string
-//	@Failure		404	{object}	map[string]string
-//	@Router			/products/{id}/image [get]
-func (h *Handler) GetProductImage(c *gin.Context) {
-	id := c.Param("id")
-	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file			formData	file	true	"CSV or NDJSON file"
+//	@Param			format			query		string	false	"csv or ndjson, detected from the file extension when omitted"
+//	@Param			dry_run			query		bool	false	"Only validate, nothing is inserted"
+//	@Param			all_or_nothing	query		bool	false	"Reject the whole file if any row is invalid"
+//	@Failure		400				{object}	map[string]string
+//	@Failure		413				{object}	map[string]string
+//	@Failure		422				{object}	models.ImportReport
+//	@Failure		500				{object}	map[string]string
+//	@Success		200				{object}	models.ImportReport
+//	@Router			/products/import [post]
+func (h *Handler) ImportProducts(c *gin.Context) {
+	var (
+		opts models.ImportOptions
+		err  error
+	)
+	if v := c.Query("dry_run"); v != "" {
+		if opts.DryRun, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dry_run"})
+			return
+		}
+	}
+	if v := c.Query("all_or_nothing"); v != "" {
+		if opts.AllOrNothing, err = strconv.ParseBool(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid all_or_nothing"})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file size exceeds 20MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer func() {
+		if err = file.Close(); err != nil {
+			fmt.Println("Error closing file:", err)
+		}
+	}()
+
+	opts.Format = strings.ToLower(c.Query("format"))
+	if opts.Format == "" {
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".csv":
+			opts.Format = models.ImportFormatCSV
+		case ".ndjson", ".jsonl":
+			opts.Format = models.ImportFormatNDJSON
+		}
+	}
+
+	report, err := h.service.ImportProducts(c.Request.Context(), file, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, products.ErrUnsupportedFormat),
+			errors.Is(err, products.ErrInvalidImport),
+			errors.Is(err, products.ErrImportTooLarge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	filePath := filepath.Join(uploadDir, id, "image.jpg")
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+	if opts.AllOrNothing && len(report.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
 		return
 	}
+	c.JSON(http.StatusOK, report)
+}
 
-	c.File(filePath)
+var exportContentTypes = map[string]string{
+	models.ExportFormatCSV:    "text/csv; charset=utf-8",
+	models.ExportFormatNDJSON: "application/x-ndjson",
+	models.ExportFormatXLSX:   xlsx.ContentType,
 }
 
-// RestoreProduct godoc
+// ExportProducts godoc
 //
-//	@Summary		Restore a deleted product
-//	@Description	Restore a soft-deleted product by ID
+//	@Summary		Export products
+//	@Description	Stream every product matching the list filters as a file download, attr.<name> filters included.
+//	@Description	Attribute values are not exported.
 //	@Tags			products
 //
 // @Security	ApiKeyAuth
 //
-//	@Accept			json
-//	@Produce		json
-//	@Param			id	path		int64	true	"Product ID"
-//	@Failure		400	{object}	map[string]string
-//	@Failure		500	{object}	map[string]string
-//	@Success		200	{object}	map[string]string
-//	@Router			/products/{id}/restore [put]
-func (h *Handler) RestoreProduct(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param			format		query		string	false	"csv (default), ndjson or xlsx"
+//	@Param			category	query		string	false	"Filter by category name"
+//	@Param			category_id	query		int64	false	"Filter by category ID"
+//	@Param			include_descendants	query		bool	false	"Let the category filters match subcategories too"
+//	@Param			status		query		string	false	"Filter by product status"
+//	@Param			currency	query		string	false	"Filter by ISO 4217 currency, required with price_min, price_max and the price sort"
+//	@Param			price_min	query		string	false	"Minimum price in the currency, 19.99"
+//	@Param			price_max	query		string	false	"Maximum price in the currency, 19.99"
+//	@Param			search		query		string	false	"Full-text search over product title, words match by prefix"
+//	@Param			sort		query		string	false	"Sort order: id, price, created_at, title or relevance, prefix with - for descending"
+//	@Success		200			{file}		file	"Exported products"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Router			/products/export [get]
+func (h *Handler) ExportProducts(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", models.ExportFormatCSV))
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": products.ErrUnsupportedFormat.Error()})
+		return
+	}
+
+	fs, err := filterFromQuery(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.service.RestoreProduct(c.Request.Context(), id); err != nil {
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+
+	if err = h.service.ExportProducts(c.Request.Context(), &fs, format, c.Writer); err != nil {
+		if c.Writer.Written() {
+			// the download has started, all that is left is to cut it short
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Header("Content-Type", "")
+		c.Header("Content-Disposition", "")
+		if errors.Is(err, models.ErrInvalidSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
 	}
-	c.Status(http.StatusAccepted)
-	c.JSON(http.StatusOK, gin.H{"message": "product restored"})
 }
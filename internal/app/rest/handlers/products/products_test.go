@@ -1,21 +1,29 @@
 package products
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/rest/casbin"
 	"prodigo/internal/app/usecases/products"
+	"slices"
 	"strings"
 	"testing"
 )
 
 func TestNew(t *testing.T) {
 	service := new(products.MockService)
-	handler := New(service)
+	handler := New(service, nil)
 	assert.NotNil(t, handler)
 	assert.Equal(t, service, handler.service)
 }
@@ -31,7 +39,7 @@ func TestHandler_CreateProduct(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 
-		body := `{"title":"Test Product","price":100,"quantity":10,"status":"available"}`
+		body := `{"title":"Test Product","price":{"amount":"1.00","currency":"USD"},"quantity":10,"status":"available"}`
 		c.Request = httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
 		c.Request.Header.Set("Content-Type", "application/json")
 
@@ -51,7 +59,7 @@ func TestHandler_CreateProduct(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 
-		body := `{"title":"Test Product","price":100,"quantity":10,"status":"available"}`
+		body := `{"title":"Test Product","price":{"amount":"1.00","currency":"USD"},"quantity":10,"status":"available"}`
 		c.Request = httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
 		c.Request.Header.Set("Content-Type", "application/json")
 
@@ -60,6 +68,26 @@ func TestHandler_CreateProduct(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), `"error"`)
 	})
+	t.Run("invalid attribute", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("CreateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.Attributes["ram"] == "16GB"
+		})).Return(fmt.Errorf("%w: \"ram\" has to be a number", models.ErrInvalidAttribute))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		body := `{"title":"Laptop","price":{"amount":"1.00","currency":"USD"},"attributes":{"ram":"16GB"}}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.CreateProduct(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 	t.Run("invalid body", func(t *testing.T) {
 		service := new(products.MockService)
 		handler := &Handler{service: service}
@@ -74,6 +102,24 @@ func TestHandler_CreateProduct(t *testing.T) {
 	})
 }
 
+func TestHandler_CreateProduct_DuplicateSKU(t *testing.T) {
+	service := new(products.MockService)
+	handler := &Handler{service: service}
+
+	service.On("CreateProduct", mock.Anything, mock.Anything).Return(products.ErrDuplicateSKU)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := `{"title":"Laptop","sku":"LT-1","price":{"amount":"1.00","currency":"USD"},"quantity":1}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.CreateProduct(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
 func TestHandler_UpdateProduct(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		service := new(products.MockService)
@@ -83,26 +129,104 @@ func TestHandler_UpdateProduct(t *testing.T) {
 		updated := &models.Product{
 			ID:       1,
 			Title:    "Updated",
-			Price:    100,
+			Price:    models.Money{Currency: "USD", Amount: 100},
 			Quantity: 5,
 			Status:   "available",
+			Version:  3,
 		}
 
-		service.On("UpdateProduct", mock.Anything, mock.Anything).Return(nil)
+		service.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.ID == 1 && p.Version == 2
+		})).Return(nil)
 		service.On("GetProduct", mock.Anything, int64(1)).Return(updated, nil)
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 
-		body := `{"title":"Updated","price":100,"quantity":5,"status":"available"}`
+		body := `{"title":"Updated","price":{"amount":"1.00","currency":"USD"},"quantity":5,"status":"available"}`
 		c.Request = httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(body))
 		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", `"2"`)
 		c.Params = gin.Params{{Key: "id", Value: "1"}}
 
 		handler.UpdateProduct(c)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), `"title":"Updated"`)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	})
+	t.Run("missing if-match", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"title":"Updated"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+
+		handler.UpdateProduct(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+	t.Run("stale version", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("UpdateProduct", mock.Anything, mock.Anything).Return(products.ErrVersionConflict)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"title":"Updated"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", `"1"`)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+
+		handler.UpdateProduct(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+	t.Run("quantity change", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("UpdateProduct", mock.Anything, mock.Anything).Return(models.ErrStockManaged)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"quantity":5}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", `"1"`)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+
+		handler.UpdateProduct(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("negative reorder threshold", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.ReorderThreshold != nil && *p.ReorderThreshold == -1
+		})).Return(models.ErrInvalidThreshold)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"reorder_threshold":-1}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", `"1"`)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+
+		handler.UpdateProduct(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 	t.Run("invalid id", func(t *testing.T) {
 		service := new(products.MockService)
@@ -130,9 +254,10 @@ func TestHandler_UpdateProduct(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 
-		body := `{"title":"Updated","price":100,"quantity":5,"status":"available"}`
+		body := `{"title":"Updated","price":{"amount":"1.00","currency":"USD"},"quantity":5,"status":"available"}`
 		c.Request = httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(body))
 		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", `"2"`)
 		c.Params = gin.Params{{Key: "id", Value: "1"}}
 
 		handler.UpdateProduct(c)
@@ -147,8 +272,10 @@ func TestHandler_GetAllProducts(t *testing.T) {
 		handler := &Handler{service: service}
 		defer service.AssertExpectations(t)
 
-		expected := []*models.Product{
-			{ID: 1, Title: "Phone", Price: 1000, Quantity: 10, Status: "available"},
+		expected := &models.ProductPage{
+			Items:      []*models.Product{{ID: 1, Title: "Phone", Price: models.Money{Currency: "USD", Amount: 1000}, Quantity: 10, Status: "available"}},
+			NextCursor: "next",
+			HasMore:    true,
 		}
 
 		service.On("GetAllProducts", mock.Anything, mock.Anything).Return(expected, nil)
@@ -161,13 +288,154 @@ func TestHandler_GetAllProducts(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), `"title":"Phone"`)
+		assert.Contains(t, w.Body.String(), `"next_cursor":"next"`)
+		assert.Contains(t, w.Body.String(), `"has_more":true`)
+	})
+	t.Run("category with subcategories", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("GetAllProducts", mock.Anything, mock.MatchedBy(func(fs *models.ProductFilterSearch) bool {
+			return fs.CategoryID == 3 && fs.IncludeDescendants
+		})).Return(&models.ProductPage{Items: []*models.Product{}}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/products?category_id=3&include_descendants=true", nil)
+
+		handler.GetAllProducts(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("invalid category filter", func(t *testing.T) {
+		for _, query := range []string{"category_id=abc", "category_id=0", "include_descendants=maybe"} {
+			handler := &Handler{service: new(products.MockService)}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/products?"+query, nil)
+
+			handler.GetAllProducts(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+	t.Run("price range in a currency", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("GetAllProducts", mock.Anything, mock.MatchedBy(func(fs *models.ProductFilterSearch) bool {
+			return fs.Currency == "USD" && fs.PriceMin == 1000 && fs.PriceMax == 1999
+		})).Return(&models.ProductPage{Items: []*models.Product{}}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/products?currency=USD&price_min=10&price_max=19.99", nil)
+
+		handler.GetAllProducts(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("invalid price filter", func(t *testing.T) {
+		for _, query := range []string{"price_min=10", "currency=usd", "currency=USD&price_max=1.999"} {
+			handler := &Handler{service: new(products.MockService)}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/products?"+query, nil)
+
+			handler.GetAllProducts(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+	t.Run("attribute filters and facets", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		ram, maxRAM := 8.0, 32.0
+		want := []models.AttributeFilter{
+			{Name: "brand", Values: []string{"apple", "dell"}},
+			{Name: "ram", Min: &ram, Max: &maxRAM},
+		}
+		page := &models.ProductPage{
+			Items:  []*models.Product{},
+			Facets: []*models.Facet{{Attribute: "brand", Values: []*models.FacetValue{{Value: "apple", Count: 2}}}},
+		}
+		service.On("GetAllProducts", mock.Anything, mock.MatchedBy(func(fs *models.ProductFilterSearch) bool {
+			return assert.ObjectsAreEqual(want, fs.Attributes)
+		})).Return(page, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet,
+			"/products?attr.ram_lte=32&attr.brand=apple&attr.ram_gte=8&attr.brand=dell", nil)
+
+		handler.GetAllProducts(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"facets":[{"attribute":"brand","values":[{"value":"apple","count":2}]}]`)
+	})
+	t.Run("invalid attribute filter", func(t *testing.T) {
+		for _, query := range []string{"attr.Brand=apple", "attr.=x", "attr.ram_gte=lots", "attr.ram_lte=NaN"} {
+			handler := &Handler{service: new(products.MockService)}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/products?"+query, nil)
+
+			handler.GetAllProducts(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+	t.Run("invalid sort", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/products?sort=quantity", nil)
+
+		handler.GetAllProducts(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid sort"`)
+	})
+	t.Run("invalid cursor", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/products?cursor=%25%25", nil)
+
+		handler.GetAllProducts(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid cursor"`)
+	})
+	t.Run("invalid limit", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/products?limit=-1", nil)
+
+		handler.GetAllProducts(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 	t.Run("service error", func(t *testing.T) {
 		service := new(products.MockService)
 		handler := &Handler{service: service}
 		defer service.AssertExpectations(t)
 
-		service.On("GetAllProducts", mock.Anything, mock.Anything).Return([]*models.Product{}, errors.New("fail"))
+		service.On("GetAllProducts", mock.Anything, mock.Anything).Return((*models.ProductPage)(nil), errors.New("fail"))
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
@@ -189,7 +457,7 @@ func TestHandler_GetProductByID(t *testing.T) {
 		expected := &models.Product{
 			ID:       1,
 			Title:    "Laptop",
-			Price:    1500,
+			Price:    models.Money{Currency: "USD", Amount: 1500},
 			Quantity: 5,
 			Status:   "available",
 		}
@@ -206,6 +474,28 @@ func TestHandler_GetProductByID(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), `"title":"Laptop"`)
 	})
+	t.Run("negotiates the locale", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("GetProduct", mock.MatchedBy(func(ctx context.Context) bool {
+			return slices.Equal(models.LocalesFrom(ctx), []string{"de-CH", "de", "en"})
+		}), int64(1)).Return(&models.Product{ID: 1, Title: "Uhr", Locale: "de"}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1", nil)
+		c.Request.Header.Set("Accept-Language", "de-CH, en;q=0.5")
+
+		handler.GetProductByID(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "de", w.Header().Get("Content-Language"))
+		assert.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+		assert.Contains(t, w.Body.String(), `"locale":"de"`)
+	})
 	t.Run("invalid id", func(t *testing.T) {
 		service := new(products.MockService)
 		handler := &Handler{service: service}
@@ -239,6 +529,80 @@ func TestHandler_GetProductByID(t *testing.T) {
 	})
 }
 
+func TestHandler_GetProductBySKU(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("GetProductBySKU", mock.Anything, "LT-1").
+			Return(&models.Product{ID: 1, SKU: "LT-1", Version: 3}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "sku", Value: "LT-1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/by-sku/LT-1", nil)
+
+		handler.GetProductBySKU(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		assert.Contains(t, w.Body.String(), `"sku":"LT-1"`)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("GetProductBySKU", mock.Anything, "LT-1").Return(nil, products.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "sku", Value: "LT-1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/by-sku/LT-1", nil)
+
+		handler.GetProductBySKU(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_GetProductByBarcode(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("GetProductByBarcode", mock.Anything, "4006381333931").
+			Return(&models.Product{ID: 1, GTIN: "04006381333931"}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "code", Value: "4006381333931"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/by-barcode/4006381333931", nil)
+
+		handler.GetProductByBarcode(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"gtin":"04006381333931"`)
+	})
+	t.Run("invalid barcode", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("GetProductByBarcode", mock.Anything, "123").
+			Return(nil, fmt.Errorf("%w \"123\"", models.ErrInvalidGTIN))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "code", Value: "123"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/by-barcode/123", nil)
+
+		handler.GetProductByBarcode(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
 func TestHandler_DeleteProduct(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		service := new(products.MockService)
@@ -248,9 +612,10 @@ func TestHandler_DeleteProduct(t *testing.T) {
 
 		req := httptest.NewRequest(http.MethodDelete, "/products/1", nil)
 		c.Request = req
+		c.Request.Header.Set("If-Match", `"1"`)
 
 		c.Params = gin.Params{{Key: "id", Value: "1"}}
-		service.On("DeleteProduct", req.Context(), int64(1)).Return(nil)
+		service.On("DeleteProduct", req.Context(), int64(1), int64(1)).Return(nil)
 
 		handler.DeleteProduct(c)
 		assert.Equal(t, http.StatusNoContent, w.Code)
@@ -275,68 +640,134 @@ func TestHandler_DeleteProduct(t *testing.T) {
 		handler := &Handler{service: service}
 		defer service.AssertExpectations(t)
 
-		service.On("DeleteProduct", mock.Anything, int64(2)).Return(errors.New("delete error"))
+		service.On("DeleteProduct", mock.Anything, int64(2), int64(1)).Return(errors.New("delete error"))
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 		c.Params = gin.Params{{Key: "id", Value: "2"}}
 		c.Request = httptest.NewRequest(http.MethodDelete, "/products/2", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
 
 		handler.DeleteProduct(c)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), `"error":"delete error"`)
 	})
-}
-
-func TestHandler_UpdateProductStatus(t *testing.T) {
-	t.Run("success", func(t *testing.T) {
+	t.Run("not found", func(t *testing.T) {
 		service := new(products.MockService)
 		handler := &Handler{service: service}
 		defer service.AssertExpectations(t)
 
-		service.On("UpdateProductStatus", mock.Anything, int64(1), "available").Return(nil)
+		service.On("DeleteProduct", mock.Anything, int64(2), int64(1)).Return(products.ErrNotFound)
 
-		body := `{"status": "available"}`
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
-		c.Params = []gin.Param{{Key: "id", Value: "1"}}
-		c.Request = httptest.NewRequest(http.MethodPatch, "/products/1/status", strings.NewReader(body))
+		c.Params = gin.Params{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/products/2", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
 
-		handler.UpdateProductStatus(c)
+		handler.DeleteProduct(c)
 
-		assert.Equal(t, http.StatusOK, w.Code)
-		assert.JSONEq(t, `{"message":"status updated"}`, w.Body.String())
+		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
-	t.Run("invalid id", func(t *testing.T) {
+	t.Run("missing if-match", func(t *testing.T) {
 		service := new(products.MockService)
 		handler := &Handler{service: service}
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
-		c.Params = []gin.Param{{Key: "id", Value: "abc"}}
-		c.Request = httptest.NewRequest(http.MethodPatch, "/products/abc/status", nil)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/products/1", nil)
 
-		handler.UpdateProductStatus(c)
+		handler.DeleteProduct(c)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), `"error":"invalid id"`)
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		service.AssertNotCalled(t, "DeleteProduct")
 	})
-	t.Run("not found", func(t *testing.T) {
+	t.Run("wildcard if-match", func(t *testing.T) {
 		service := new(products.MockService)
 		handler := &Handler{service: service}
-		defer service.AssertExpectations(t)
-
-		service.On("UpdateProductStatus", mock.Anything, int64(2), "archived").Return(products.ErrNotFound)
 
-		body := `{"status": "archived"}`
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
-		c.Params = []gin.Param{{Key: "id", Value: "2"}}
-		c.Request = httptest.NewRequest(http.MethodPatch, "/products/2/status", strings.NewReader(body))
-		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/products/1", nil)
+		c.Request.Header.Set("If-Match", "*")
 
-		handler.UpdateProductStatus(c)
+		handler.DeleteProduct(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		service.AssertNotCalled(t, "DeleteProduct")
+	})
+	t.Run("stale version", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("DeleteProduct", mock.Anything, int64(1), int64(1)).Return(products.ErrVersionConflict)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodDelete, "/products/1", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.DeleteProduct(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
+
+func TestHandler_UpdateProductStatus(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("UpdateProductStatus", mock.Anything, int64(1), "active", int64(1)).Return(nil)
+
+		body := `{"status": "active"}`
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPatch, "/products/1/status", strings.NewReader(body))
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.UpdateProductStatus(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"message":"status updated"}`, w.Body.String())
+	})
+	t.Run("invalid id", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "abc"}}
+		c.Request = httptest.NewRequest(http.MethodPatch, "/products/abc/status", nil)
+
+		handler.UpdateProductStatus(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid id"`)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("UpdateProductStatus", mock.Anything, int64(2), "archived", int64(1)).Return(products.ErrNotFound)
+
+		body := `{"status": "archived"}`
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodPatch, "/products/2/status", strings.NewReader(body))
+		c.Request.Header.Set("If-Match", `"1"`)
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.UpdateProductStatus(c)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
 		assert.Contains(t, w.Body.String(), `"error":"`+products.ErrNotFound.Error()+`"`)
@@ -350,6 +781,7 @@ func TestHandler_UpdateProductStatus(t *testing.T) {
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 		body := `{"status": ""}`
 		c.Request = httptest.NewRequest(http.MethodPatch, "/products/1/status", strings.NewReader(body))
+		c.Request.Header.Set("If-Match", `"1"`)
 		c.Request.Header.Set("Content-Type", "application/json")
 
 		handler.UpdateProductStatus(c)
@@ -357,18 +789,55 @@ func TestHandler_UpdateProductStatus(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 		assert.Contains(t, w.Body.String(), `"error":"invalid status"`)
 	})
+	t.Run("illegal transition", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("UpdateProductStatus", mock.Anything, int64(1), "discontinued", int64(1)).
+			Return(fmt.Errorf("%w: draft can not change to discontinued, allowed: active, archived",
+				models.ErrInvalidTransition))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/status", strings.NewReader(`{"status": "discontinued"}`))
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.UpdateProductStatus(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "allowed: active, archived")
+	})
+	t.Run("unknown status", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("UpdateProductStatus", mock.Anything, int64(1), "availble", int64(1)).
+			Return(fmt.Errorf("%w %q", models.ErrInvalidStatus, "availble"))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/status", strings.NewReader(`{"status": "availble"}`))
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.UpdateProductStatus(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 	t.Run("internal error", func(t *testing.T) {
 		service := new(products.MockService)
 		handler := &Handler{service: service}
 		defer service.AssertExpectations(t)
 
-		service.On("UpdateProductStatus", mock.Anything, int64(3), "sold").Return(errors.New("update error"))
+		service.On("UpdateProductStatus", mock.Anything, int64(3), "archived", int64(1)).Return(errors.New("update error"))
 
-		body := `{"status": "sold"}`
+		body := `{"status": "archived"}`
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 		c.Params = []gin.Param{{Key: "id", Value: "3"}}
 		c.Request = httptest.NewRequest(http.MethodPatch, "/products/3/status", strings.NewReader(body))
+		c.Request.Header.Set("If-Match", `"1"`)
 		c.Request.Header.Set("Content-Type", "application/json")
 
 		handler.UpdateProductStatus(c)
@@ -376,6 +845,296 @@ func TestHandler_UpdateProductStatus(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), `"error":"update error"`)
 	})
+	t.Run("missing if-match", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/status", strings.NewReader(`{"status": "archived"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.UpdateProductStatus(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		service.AssertNotCalled(t, "UpdateProductStatus")
+	})
+	t.Run("wildcard if-match", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/status", strings.NewReader(`{"status": "archived"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", "*")
+
+		handler.UpdateProductStatus(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		service.AssertNotCalled(t, "UpdateProductStatus")
+	})
+	t.Run("stale version", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("UpdateProductStatus", mock.Anything, int64(1), "archived", int64(1)).Return(products.ErrVersionConflict)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/status", strings.NewReader(`{"status": "archived"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.UpdateProductStatus(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
+
+func TestHandler_GetStatusHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		actor := int64(7)
+		service.On("GetStatusHistory", mock.Anything, int64(1)).Return([]*models.StatusChange{
+			{ID: 1, ProductID: 1, FromStatus: "draft", ToStatus: "active", ActorID: &actor},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1/status/history", nil)
+
+		handler.GetStatusHistory(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"actor_id":7`)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("GetStatusHistory", mock.Anything, int64(2)).
+			Return(([]*models.StatusChange)(nil), products.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/2/status/history", nil)
+
+		handler.GetStatusHistory(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_SchedulePrice(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("SchedulePrice", mock.Anything, mock.MatchedBy(func(pr *models.ProductPrice) bool {
+			return pr.ProductID == 1 && pr.Price == models.Money{Currency: "USD", Amount: 799} &&
+				pr.ValidFrom.Day() == 3 && pr.ValidTo != nil && pr.ValidTo.Day() == 6
+		})).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		body := `{"price":{"amount":"7.99","currency":"USD"},` +
+			`"valid_from":"2025-01-03T00:00:00Z","valid_to":"2025-01-06T00:00:00Z"}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/products/1/prices", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.SchedulePrice(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"price":{"amount":"7.99","currency":"USD"}`)
+	})
+	t.Run("invalid schedule", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("SchedulePrice", mock.Anything, mock.Anything).
+			Return(fmt.Errorf("%w: valid_from has to be in the future", models.ErrInvalidSchedule))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		body := `{"price":{"amount":"7.99","currency":"USD"},"valid_from":"2020-01-03T00:00:00Z"}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/products/1/prices", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.SchedulePrice(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("concurrent write", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("SchedulePrice", mock.Anything, mock.Anything).Return(products.ErrVersionConflict)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		body := `{"price":{"amount":"7.99","currency":"USD"},"valid_from":"2030-01-03T00:00:00Z"}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/products/1/prices", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.SchedulePrice(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("invalid body", func(t *testing.T) {
+		handler := &Handler{service: new(products.MockService)}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/products/1/prices",
+			strings.NewReader(`{"price":{"amount":"7.999","currency":"USD"}}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		handler.SchedulePrice(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_GetPriceHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("GetPriceHistory", mock.Anything, int64(1)).Return([]*models.ProductPrice{
+			{ID: 1, ProductID: 1, Price: models.Money{Currency: "USD", Amount: 1000}},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1/prices", nil)
+
+		handler.GetPriceHistory(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"amount":"10.00"`)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("GetPriceHistory", mock.Anything, int64(2)).Return(nil, products.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/2/prices", nil)
+
+		handler.GetPriceHistory(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_ListTranslations(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("ListTranslations", mock.Anything, int64(1)).
+			Return([]*models.ProductTranslation{{ProductID: 1, Locale: "de", Title: "Uhr"}}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/1/translations", nil)
+
+		handler.ListTranslations(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"locale":"de"`)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("ListTranslations", mock.Anything, int64(2)).Return(nil, products.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "2"}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/2/translations", nil)
+
+		handler.ListTranslations(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_UpsertTranslation(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("UpsertTranslation", mock.Anything, &models.ProductTranslation{
+			ProductID: 1, Locale: "de", Title: "Uhr", Description: "Eine Uhr",
+		}).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}, {Key: "locale", Value: "de"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/translations/de",
+			strings.NewReader(`{"title": "Uhr", "description": "Eine Uhr"}`))
+
+		handler.UpsertTranslation(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"title":"Uhr"`)
+	})
+	t.Run("invalid locale", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("UpsertTranslation", mock.Anything, mock.Anything).Return(models.ErrInvalidLocale)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "1"}, {Key: "locale", Value: "deutsch"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/translations/deutsch",
+			strings.NewReader(`{"title": "Uhr"}`))
+
+		handler.UpsertTranslation(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("UpsertTranslation", mock.Anything, mock.Anything).Return(products.ErrNotFound)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "id", Value: "2"}, {Key: "locale", Value: "de"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/2/translations/de",
+			strings.NewReader(`{"title": "Uhr"}`))
+
+		handler.UpsertTranslation(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
 }
 
 func TestHandler_RestoreProduct(t *testing.T) {
@@ -384,12 +1143,13 @@ func TestHandler_RestoreProduct(t *testing.T) {
 		handler := &Handler{service: service}
 		defer service.AssertExpectations(t)
 
-		service.On("RestoreProduct", mock.Anything, int64(1)).Return(nil)
+		service.On("RestoreProduct", mock.Anything, int64(1), int64(1)).Return(nil)
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 		c.Params = []gin.Param{{Key: "id", Value: "1"}}
 		c.Request = httptest.NewRequest(http.MethodPatch, "/products/1/restore", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
 
 		handler.RestoreProduct(c)
 
@@ -415,65 +1175,296 @@ func TestHandler_RestoreProduct(t *testing.T) {
 		handler := &Handler{service: service}
 		defer service.AssertExpectations(t)
 
-		service.On("RestoreProduct", mock.Anything, int64(2)).Return(errors.New("restore failed"))
+		service.On("RestoreProduct", mock.Anything, int64(2), int64(1)).Return(errors.New("restore failed"))
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
 		c.Params = []gin.Param{{Key: "id", Value: "2"}}
 		c.Request = httptest.NewRequest(http.MethodPatch, "/products/2/restore", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
 
 		handler.RestoreProduct(c)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), `"error":"restore failed"`)
 	})
-}
+	t.Run("missing if-match", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
 
-func TestHandler_UploadProductImage(t *testing.T) {
-	t.Run("invalid id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/restore", nil)
+
+		handler.RestoreProduct(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		service.AssertNotCalled(t, "RestoreProduct")
+	})
+	t.Run("wildcard if-match", func(t *testing.T) {
 		service := new(products.MockService)
 		handler := &Handler{service: service}
 
-		req := httptest.NewRequest(http.MethodPost, "/products/abc/upload", nil)
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
-		c.Params = gin.Params{{Key: "id", Value: "abc"}}
-		c.Request = req
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/restore", nil)
+		c.Request.Header.Set("If-Match", "*")
 
-		handler.UploadProductImage(c)
+		handler.RestoreProduct(c)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), `"invalid product id"`)
+		service.AssertNotCalled(t, "RestoreProduct")
+	})
+	t.Run("stale version", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("RestoreProduct", mock.Anything, int64(1), int64(1)).Return(products.ErrVersionConflict)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Request = httptest.NewRequest(http.MethodPut, "/products/1/restore", nil)
+		c.Request.Header.Set("If-Match", `"1"`)
+
+		handler.RestoreProduct(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
 	})
 }
 
-func TestHandler_GetProductImage(t *testing.T) {
-	t.Run("invalid id", func(t *testing.T) {
+func batchRequest(role, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/products/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	ctx := models.WithPrincipal(req.Context(), models.Principal{Role: role, UserID: 1})
+	return req.WithContext(ctx)
+}
+
+func TestHandler_BatchProducts(t *testing.T) {
+	enforcer, err := casbin.New("../../../../../configs/casbin/model.conf", "../../../../../configs/casbin/policy.csv")
+	require.NoError(t, err)
+
+	t.Run("reports every item", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service, enforcer: enforcer}
+		defer service.AssertExpectations(t)
+
+		service.On("BatchProducts", mock.Anything, &models.Batch{Operations: []models.BatchOperation{
+			{Action: models.BatchActionStatus, Status: models.StatusActive, IDs: []int64{1, 2}},
+		}}).Return(&models.BatchReport{
+			Results: []models.BatchResult{
+				{Action: models.BatchActionStatus, ID: 1},
+				{Action: models.BatchActionStatus, ID: 2, Err: products.ErrNotFound, Error: "product not found"},
+			},
+			Succeeded: 1,
+			Failed:    1,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = batchRequest("admin", `{"operations":[{"action":"status","status":"active","ids":[1,2]}]}`)
+
+		handler.BatchProducts(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `{"action":"status","id":1,"code":200}`)
+		assert.Contains(t, w.Body.String(), `{"action":"status","error":"product not found","id":2,"code":404}`)
+	})
+	t.Run("rolled back", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service, enforcer: enforcer}
+
+		service.On("BatchProducts", mock.Anything, mock.Anything).Return(&models.BatchReport{
+			Results: []models.BatchResult{
+				{Action: models.BatchActionDelete, ID: 1, Err: models.ErrBatchRolledBack},
+				{Action: models.BatchActionUpdate, ID: 2, Err: models.ErrInvalidTransition},
+			},
+			Failed:     2,
+			RolledBack: true,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = batchRequest("admin", `{"all_or_nothing":true,"operations":[`+
+			`{"action":"delete","ids":[1]},{"action":"update","ids":[2],"fields":{"status":"archived"}}]}`)
+
+		handler.BatchProducts(c)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":1,"code":424`)
+		assert.Contains(t, w.Body.String(), `"id":2,"code":409`)
+	})
+	t.Run("action the role may not run", func(t *testing.T) {
+		handler := &Handler{service: new(products.MockService), enforcer: enforcer}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = batchRequest("user", `{"operations":[{"action":"delete","ids":[1]}]}`)
+
+		handler.BatchProducts(c)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "delete")
+	})
+	t.Run("invalid batch", func(t *testing.T) {
+		handler := &Handler{service: new(products.MockService), enforcer: enforcer}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = batchRequest("admin", `{"operations":[{"action":"update","ids":[1],"fields":{"sku":"W-1"}}]}`)
+
+		handler.BatchProducts(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func importRequest(t *testing.T, target, filename, content string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	assert.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestHandler_ImportProducts(t *testing.T) {
+	t.Run("format detected from extension", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
+
+		service.On("ImportProducts", mock.Anything, mock.Anything, models.ImportOptions{
+			Format: models.ImportFormatNDJSON,
+			DryRun: true,
+		}).Return(&models.ImportReport{Errors: []models.ImportRowError{}, Total: 1, Valid: 1, DryRun: true}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = importRequest(t, "/products/import?dry_run=true", "products.jsonl", `{"title":"watch"}`)
+
+		handler.ImportProducts(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"dry_run":true`)
+	})
+	t.Run("all or nothing with bad rows", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("ImportProducts", mock.Anything, mock.Anything, mock.Anything).Return(&models.ImportReport{
+			Errors: []models.ImportRowError{{Line: 2, Error: "title is required"}},
+			Total:  1,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = importRequest(t, "/products/import?all_or_nothing=1", "products.csv", "title\n")
+
+		handler.ImportProducts(c)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `"title is required"`)
+	})
+	t.Run("unsupported format", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("ImportProducts", mock.Anything, mock.Anything, models.ImportOptions{}).
+			Return((*models.ImportReport)(nil), products.ErrUnsupportedFormat)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = importRequest(t, "/products/import", "products.txt", "title\n")
+
+		handler.ImportProducts(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("missing file", func(t *testing.T) {
 		handler := &Handler{}
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
-		c.Params = []gin.Param{{Key: "id", Value: "abc"}}
-		c.Request = httptest.NewRequest(http.MethodGet, "/products/abc/image", nil)
+		c.Request = httptest.NewRequest(http.MethodPost, "/products/import", nil)
+
+		handler.ImportProducts(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"failed to read file"`)
+	})
+	t.Run("invalid dry_run", func(t *testing.T) {
+		handler := &Handler{}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/products/import?dry_run=maybe", nil)
 
-		handler.GetProductImage(c)
+		handler.ImportProducts(c)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "invalid product id")
 	})
+}
+
+func TestHandler_ExportProducts(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+		defer service.AssertExpectations(t)
 
-	t.Run("image not found", func(t *testing.T) {
+		service.On("ExportProducts", mock.Anything, mock.MatchedBy(func(fs *models.ProductFilterSearch) bool {
+			return fs.Status == "available" && fs.Currency == "USD" && fs.PriceMin == 10
+		}), models.ExportFormatNDJSON, mock.Anything).Run(func(args mock.Arguments) {
+			_, _ = args.Get(3).(io.Writer).Write([]byte("{}\n"))
+		}).Return(nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/export?format=ndjson&status=available&currency=USD&price_min=0.10", nil)
+
+		handler.ExportProducts(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="products.ndjson"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "{}\n", w.Body.String())
+	})
+	t.Run("unsupported format", func(t *testing.T) {
 		handler := &Handler{}
 
-		id := "9999"
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
-		c.Params = []gin.Param{{Key: "id", Value: id}}
-		c.Request = httptest.NewRequest(http.MethodGet, "/products/9999/image", nil)
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/export?format=pdf", nil)
 
-		handler.GetProductImage(c)
+		handler.ExportProducts(c)
 
-		assert.Equal(t, http.StatusNotFound, w.Code)
-		assert.Contains(t, w.Body.String(), "image not found")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("error before streaming", func(t *testing.T) {
+		service := new(products.MockService)
+		handler := &Handler{service: service}
+
+		service.On("ExportProducts", mock.Anything, mock.Anything, models.ExportFormatCSV, mock.Anything).
+			Return(errors.New("failed to export products"))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/products/export", nil)
+
+		handler.ExportProducts(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
 	})
 }
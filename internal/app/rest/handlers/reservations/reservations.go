@@ -0,0 +1,183 @@
+package reservations
+
+import (
+	"errors"
+	"net/http"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/reservations"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service reservations.ServiceInterface
+}
+
+func New(service reservations.ServiceInterface) *Handler {
+	return &Handler{service: service}
+}
+
+// ReservationRequest holds Quantity units, of the variant VariantID for a
+// product with variants, for TTLSeconds or the configured default.
+type ReservationRequest struct {
+	VariantID  *int64 `json:"variant_id"`
+	Reference  string `json:"reference"`
+	Quantity   int    `json:"quantity"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// Reserve godoc
+//
+//	@Summary		Reserve stock
+//	@Description	Hold units of a product for a checkout. The hold lowers the available quantity
+//	@Description	until it is confirmed, released or expires.
+//	@Tags			reservations
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int64				true	"Product ID"
+//	@Param			request	body		ReservationRequest	true	"Reservation"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Success		201		{object}	models.Reservation
+//	@Router			/products/{id}/reservations [post]
+func (h *Handler) Reserve(c *gin.Context) {
+	productID, ok := pathID(c)
+	if !ok {
+		return
+	}
+	var req ReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	r := &models.Reservation{
+		ProductID: productID,
+		VariantID: req.VariantID,
+		Quantity:  req.Quantity,
+		Reference: req.Reference,
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if err := h.service.Reserve(c.Request.Context(), r, ttl); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+// GetReservation godoc
+//
+//	@Summary		Get a reservation
+//	@Description	Get a stock reservation by its ID, a hold past its expiry is reported as expired
+//	@Tags			reservations
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Reservation ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{object}	models.Reservation
+//	@Router			/reservations/{id} [get]
+func (h *Handler) GetReservation(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	r, err := h.service.GetReservation(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// ConfirmReservation godoc
+//
+//	@Summary		Confirm a reservation
+//	@Description	Turn an active reservation into a sale of its units
+//	@Tags			reservations
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Reservation ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		409	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{object}	models.Reservation
+//	@Router			/reservations/{id}/confirm [post]
+func (h *Handler) ConfirmReservation(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	r, err := h.service.Confirm(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// ReleaseReservation godoc
+//
+//	@Summary		Release a reservation
+//	@Description	Give the units of an active reservation back
+//	@Tags			reservations
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Reservation ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		404	{object}	map[string]string
+//	@Failure		409	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{object}	models.Reservation
+//	@Router			/reservations/{id}/release [post]
+func (h *Handler) ReleaseReservation(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	r, err := h.service.Release(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func pathID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, reservations.ErrNotFound), errors.Is(err, reservations.ErrProductNotFound),
+		errors.Is(err, reservations.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInsufficientStock), errors.Is(err, models.ErrReservationClosed),
+		errors.Is(err, models.ErrReservationExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidReservation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,155 @@
+package reservations
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/reservations"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newContext(method, target, body, id string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c, w
+}
+
+func TestHandler_Reserve(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(reservations.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("Reserve", mock.Anything, mock.MatchedBy(func(r *models.Reservation) bool {
+			return r.ProductID == 1 && *r.VariantID == 2 && r.Quantity == 3 && r.Reference == "cart 9"
+		}), 5*time.Minute).Run(func(args mock.Arguments) {
+			r := args.Get(1).(*models.Reservation)
+			r.ID, r.Status = 7, models.ReservationActive
+		}).Return(nil)
+
+		body := `{"quantity":3,"variant_id":2,"reference":"cart 9","ttl_seconds":300}`
+		c, w := newContext(http.MethodPost, "/products/1/reservations", body, "1")
+		handler.Reserve(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"active"`)
+	})
+	t.Run("not enough stock", func(t *testing.T) {
+		service := new(reservations.MockService)
+		handler := New(service)
+
+		service.On("Reserve", mock.Anything, mock.Anything, time.Duration(0)).Return(models.ErrInsufficientStock)
+
+		c, w := newContext(http.MethodPost, "/products/1/reservations", `{"quantity":3}`, "1")
+		handler.Reserve(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("invalid reservation", func(t *testing.T) {
+		service := new(reservations.MockService)
+		handler := New(service)
+
+		service.On("Reserve", mock.Anything, mock.Anything, mock.Anything).Return(models.ErrInvalidReservation)
+
+		c, w := newContext(http.MethodPost, "/products/1/reservations", `{}`, "1")
+		handler.Reserve(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("invalid id", func(t *testing.T) {
+		handler := New(new(reservations.MockService))
+
+		c, w := newContext(http.MethodPost, "/products/x/reservations", `{}`, "x")
+		handler.Reserve(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_GetReservation(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(reservations.MockService)
+		handler := New(service)
+
+		service.On("GetReservation", mock.Anything, int64(7)).
+			Return(&models.Reservation{ID: 7, Status: models.ReservationExpired}, nil)
+
+		c, w := newContext(http.MethodGet, "/reservations/7", "", "7")
+		handler.GetReservation(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"expired"`)
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(reservations.MockService)
+		handler := New(service)
+
+		service.On("GetReservation", mock.Anything, int64(7)).Return(nil, reservations.ErrNotFound)
+
+		c, w := newContext(http.MethodGet, "/reservations/7", "", "7")
+		handler.GetReservation(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_ConfirmReservation(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(reservations.MockService)
+		handler := New(service)
+
+		service.On("Confirm", mock.Anything, int64(7)).
+			Return(&models.Reservation{ID: 7, Status: models.ReservationConfirmed}, nil)
+
+		c, w := newContext(http.MethodPost, "/reservations/7/confirm", "", "7")
+		handler.ConfirmReservation(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("expired", func(t *testing.T) {
+		service := new(reservations.MockService)
+		handler := New(service)
+
+		service.On("Confirm", mock.Anything, int64(7)).Return(nil, models.ErrReservationExpired)
+
+		c, w := newContext(http.MethodPost, "/reservations/7/confirm", "", "7")
+		handler.ConfirmReservation(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestHandler_ReleaseReservation(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(reservations.MockService)
+		handler := New(service)
+
+		service.On("Release", mock.Anything, int64(7)).
+			Return(&models.Reservation{ID: 7, Status: models.ReservationReleased}, nil)
+
+		c, w := newContext(http.MethodPost, "/reservations/7/release", "", "7")
+		handler.ReleaseReservation(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+	t.Run("service error", func(t *testing.T) {
+		service := new(reservations.MockService)
+		handler := New(service)
+
+		service.On("Release", mock.Anything, int64(7)).Return(nil, errors.New("failed to update reservation"))
+
+		c, w := newContext(http.MethodPost, "/reservations/7/release", "", "7")
+		handler.ReleaseReservation(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,346 @@
+package variants
+
+import (
+	"errors"
+	"net/http"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/rest/handlers/etag"
+	"prodigo/internal/app/usecases/variants"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service variants.ServiceInterface
+}
+
+func New(service variants.ServiceInterface) *Handler {
+	return &Handler{service: service}
+}
+
+type OptionRequest struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type OptionsRequest struct {
+	Options []OptionRequest `json:"options"`
+}
+
+// VariantRequest is the full state of a variant, a missing price makes the
+// variant inherit the product price. A price of its own has to be in the
+// currency of the product.
+type VariantRequest struct {
+	Options  map[string]string `json:"options"`
+	Price    *models.Money     `json:"price"`
+	SKU      string            `json:"sku"`
+	Quantity int               `json:"quantity"`
+}
+
+// ListOptions godoc
+//
+//	@Summary		List product options
+//	@Description	Get the options the variants of a product differ in, like size or color
+//	@Tags			variants
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Product ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.ProductOption
+//	@Router			/products/{id}/options [get]
+func (h *Handler) ListOptions(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	opts, err := h.service.ListOptions(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, opts)
+}
+
+// ReplaceOptions godoc
+//
+//	@Summary		Replace product options
+//	@Description	Replace the option definitions of a product, kept in the given order.
+//	@Description	Every existing variant has to keep a valid value for each option.
+//	@Description	The options are part of the product, this requires If-Match with the ETag of the product.
+//	@Tags			variants
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int64			true	"Product ID"
+//	@Param			If-Match	header		string			true	"ETag of the product"
+//	@Param			request		body		OptionsRequest	true	"Options"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		409			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{array}		models.ProductOption
+//	@Header			200			{string}	ETag	"Product version"
+//	@Router			/products/{id}/options [put]
+func (h *Handler) ReplaceOptions(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
+
+	var req OptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts := make([]*models.ProductOption, len(req.Options))
+	for i, opt := range req.Options {
+		opts[i] = &models.ProductOption{Name: opt.Name, Values: opt.Values}
+	}
+
+	version, err := h.service.ReplaceOptions(c.Request.Context(), productID, version, opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("ETag", etag.Format(version))
+	c.JSON(http.StatusOK, opts)
+}
+
+// ListVariants godoc
+//
+//	@Summary		List product variants
+//	@Description	Get the variants of a product with their own SKU, price and stock
+//	@Tags			variants
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id	path		int64	true	"Product ID"
+//	@Failure		400	{object}	map[string]string
+//	@Failure		500	{object}	map[string]string
+//	@Success		200	{array}		models.ProductVariant
+//	@Router			/products/{id}/variants [get]
+func (h *Handler) ListVariants(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	vs, err := h.service.ListVariants(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, vs)
+}
+
+// GetVariant godoc
+//
+//	@Summary		Get a product variant
+//	@Description	Get a variant of a product by its ID
+//	@Tags			variants
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id			path		int64	true	"Product ID"
+//	@Param			variant_id	path		int64	true	"Variant ID"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	models.ProductVariant
+//	@Header			200			{string}	ETag	"Variant version"
+//	@Router			/products/{id}/variants/{variant_id} [get]
+func (h *Handler) GetVariant(c *gin.Context) {
+	productID, variantID, ok := pathIDs(c)
+	if !ok {
+		return
+	}
+	v, err := h.service.GetVariant(c.Request.Context(), productID, variantID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("ETag", etag.Format(v.Version))
+	c.JSON(http.StatusOK, v)
+}
+
+// CreateVariant godoc
+//
+//	@Summary		Create a product variant
+//	@Description	Add a variant with one value for every option of the product
+//	@Tags			variants
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int64			true	"Product ID"
+//	@Param			request	body		VariantRequest	true	"Variant"
+//	@Failure		400		{object}	map[string]string
+//	@Failure		404		{object}	map[string]string
+//	@Failure		409		{object}	map[string]string
+//	@Failure		500		{object}	map[string]string
+//	@Success		201		{object}	models.ProductVariant
+//	@Header			201		{string}	ETag	"Variant version"
+//	@Router			/products/{id}/variants [post]
+func (h *Handler) CreateVariant(c *gin.Context) {
+	productID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	v, ok := bindVariant(c)
+	if !ok {
+		return
+	}
+	v.ProductID = productID
+
+	if err := h.service.CreateVariant(c.Request.Context(), v); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("ETag", etag.Format(v.Version))
+	c.JSON(http.StatusCreated, v)
+}
+
+// UpdateVariant godoc
+//
+//	@Summary		Update a product variant
+//	@Description	Replace the SKU, options and price of a variant, the quantity
+//	@Description	is changed through POST /products/{id}/stock only.
+//	@Description	Requires If-Match with the ETag from the last read.
+//	@Tags			variants
+//
+// @Security	ApiKeyAuth
+//
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int64			true	"Product ID"
+//	@Param			variant_id	path		int64			true	"Variant ID"
+//	@Param			If-Match	header		string			true	"ETag of the variant"
+//	@Param			request		body		VariantRequest	true	"Variant"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		409			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		200			{object}	models.ProductVariant
+//	@Header			200			{string}	ETag	"Variant version"
+//	@Router			/products/{id}/variants/{variant_id} [put]
+func (h *Handler) UpdateVariant(c *gin.Context) {
+	productID, variantID, ok := pathIDs(c)
+	if !ok {
+		return
+	}
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
+	v, ok := bindVariant(c)
+	if !ok {
+		return
+	}
+	v.ID, v.ProductID, v.Version = variantID, productID, version
+
+	if err := h.service.UpdateVariant(c.Request.Context(), v); err != nil {
+		writeError(c, err)
+		return
+	}
+	h.GetVariant(c)
+}
+
+// DeleteVariant godoc
+//
+//	@Summary		Delete a product variant
+//	@Description	Remove a variant of a product, its stock movements are kept. A variant with active reservations
+//	@Description	is refused with 409 until they are confirmed or released.
+//	@Description	Requires If-Match with the ETag from the last read.
+//	@Tags			variants
+//
+// @Security	ApiKeyAuth
+//
+//	@Produce		json
+//	@Param			id			path		int64	true	"Product ID"
+//	@Param			variant_id	path		int64	true	"Variant ID"
+//	@Param			If-Match	header		string	true	"ETag of the variant"
+//	@Failure		400			{object}	map[string]string
+//	@Failure		404			{object}	map[string]string
+//	@Failure		409			{object}	map[string]string
+//	@Failure		412			{object}	map[string]string
+//	@Failure		428			{object}	map[string]string
+//	@Failure		500			{object}	map[string]string
+//	@Success		204
+//	@Router			/products/{id}/variants/{variant_id} [delete]
+func (h *Handler) DeleteVariant(c *gin.Context) {
+	productID, variantID, ok := pathIDs(c)
+	if !ok {
+		return
+	}
+	version, ok := etag.Require(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteVariant(c.Request.Context(), productID, variantID, version); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func bindVariant(c *gin.Context) (*models.ProductVariant, bool) {
+	var req VariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &models.ProductVariant{SKU: req.SKU, Options: req.Options, Price: req.Price, Quantity: req.Quantity}, true
+}
+
+func pathID(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
+
+func pathIDs(c *gin.Context) (productID, variantID int64, ok bool) {
+	if productID, ok = pathID(c, "id"); !ok {
+		return 0, 0, false
+	}
+	if variantID, ok = pathID(c, "variant_id"); !ok {
+		return 0, 0, false
+	}
+	return productID, variantID, true
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, variants.ErrProductNotFound), errors.Is(err, variants.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	// ErrOptionsInUse wraps the ErrInvalidVariant of the variant in the way
+	case errors.Is(err, variants.ErrOptionsInUse), errors.Is(err, variants.ErrDuplicateSKU),
+		errors.Is(err, variants.ErrDuplicate), errors.Is(err, variants.ErrReserved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidOptions), errors.Is(err, models.ErrInvalidVariant),
+		errors.Is(err, models.ErrStockManaged), errors.Is(err, models.ErrCurrencyMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, variants.ErrVersionConflict), errors.Is(err, variants.ErrProductConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,330 @@
+package variants
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/usecases/variants"
+	"strings"
+	"testing"
+)
+
+func newContext(method, target, body string, params ...gin.Param) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = params
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c, w
+}
+
+var (
+	productParam = gin.Param{Key: "id", Value: "1"}
+	variantParam = gin.Param{Key: "variant_id", Value: "2"}
+)
+
+func TestHandler_ListOptions(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(variants.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("ListOptions", mock.Anything, int64(1)).
+			Return([]*models.ProductOption{{Name: "size", Values: []string{"S", "M"}}}, nil)
+
+		c, w := newContext(http.MethodGet, "/products/1/options", "", productParam)
+		handler.ListOptions(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"values":["S","M"]`)
+	})
+	t.Run("invalid id", func(t *testing.T) {
+		handler := New(new(variants.MockService))
+
+		c, w := newContext(http.MethodGet, "/products/abc/options", "", gin.Param{Key: "id", Value: "abc"})
+		handler.ListOptions(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_ReplaceOptions(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(variants.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("ReplaceOptions", mock.Anything, int64(1), int64(3), []*models.ProductOption{
+			{Name: "size", Values: []string{"S", "M"}},
+			{Name: "color", Values: []string{"red"}},
+		}).Return(int64(4), nil)
+
+		body := `{"options":[{"name":"size","values":["S","M"]},{"name":"color","values":["red"]}]}`
+		c, w := newContext(http.MethodPut, "/products/1/options", body, productParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.ReplaceOptions(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	})
+	t.Run("missing if-match", func(t *testing.T) {
+		handler := New(new(variants.MockService))
+
+		c, w := newContext(http.MethodPut, "/products/1/options", `{"options":[]}`, productParam)
+		handler.ReplaceOptions(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+	t.Run("product changed", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("ReplaceOptions", mock.Anything, int64(1), int64(3), mock.Anything).
+			Return(int64(0), variants.ErrProductConflict)
+
+		c, w := newContext(http.MethodPut, "/products/1/options", `{"options":[]}`, productParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.ReplaceOptions(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("ReplaceOptions", mock.Anything, int64(1), int64(3), mock.Anything).
+			Return(int64(0), fmt.Errorf("%w: option without a name", models.ErrInvalidOptions))
+
+		c, w := newContext(http.MethodPut, "/products/1/options", `{"options":[{"values":["S"]}]}`, productParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.ReplaceOptions(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("options in use", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("ReplaceOptions", mock.Anything, int64(1), int64(3), mock.Anything).
+			Return(int64(0), fmt.Errorf("%w: variant %q: %w", variants.ErrOptionsInUse, "TS-L", models.ErrInvalidVariant))
+
+		c, w := newContext(http.MethodPut, "/products/1/options", `{"options":[]}`, productParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.ReplaceOptions(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("invalid body", func(t *testing.T) {
+		handler := New(new(variants.MockService))
+
+		c, w := newContext(http.MethodPut, "/products/1/options", `{`, productParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.ReplaceOptions(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_GetVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(variants.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("GetVariant", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Version: 3}, nil)
+
+		c, w := newContext(http.MethodGet, "/products/1/variants/2", "", productParam, variantParam)
+		handler.GetVariant(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	})
+	t.Run("not found", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("GetVariant", mock.Anything, int64(1), int64(2)).Return(nil, variants.ErrNotFound)
+
+		c, w := newContext(http.MethodGet, "/products/1/variants/2", "", productParam, variantParam)
+		handler.GetVariant(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("invalid variant id", func(t *testing.T) {
+		handler := New(new(variants.MockService))
+
+		c, w := newContext(http.MethodGet, "/products/1/variants/x", "", productParam,
+			gin.Param{Key: "variant_id", Value: "x"})
+		handler.GetVariant(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid variant_id")
+	})
+}
+
+func TestHandler_CreateVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(variants.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("CreateVariant", mock.Anything, mock.MatchedBy(func(v *models.ProductVariant) bool {
+			return v.ProductID == 1 && v.SKU == "TS-M" && v.Options["size"] == "M" && *v.Price == models.Money{Currency: "USD", Amount: 1500} &&
+				v.Quantity == 4
+		})).Run(func(args mock.Arguments) {
+			v := args.Get(1).(*models.ProductVariant)
+			v.ID, v.Version = 2, 1
+		}).Return(nil)
+
+		body := `{"sku":"TS-M","options":{"size":"M"},"price":{"amount":"15.00","currency":"USD"},"quantity":4}`
+		c, w := newContext(http.MethodPost, "/products/1/variants", body, productParam)
+		handler.CreateVariant(c)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+		assert.Contains(t, w.Body.String(), `"id":2`)
+	})
+	t.Run("duplicate sku", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("CreateVariant", mock.Anything, mock.Anything).Return(variants.ErrDuplicateSKU)
+
+		c, w := newContext(http.MethodPost, "/products/1/variants", `{"sku":"TS-M"}`, productParam)
+		handler.CreateVariant(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("invalid variant", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("CreateVariant", mock.Anything, mock.Anything).
+			Return(fmt.Errorf("%w: sku is required", models.ErrInvalidVariant))
+
+		c, w := newContext(http.MethodPost, "/products/1/variants", `{}`, productParam)
+		handler.CreateVariant(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("CreateVariant", mock.Anything, mock.Anything).Return(variants.ErrProductNotFound)
+
+		c, w := newContext(http.MethodPost, "/products/1/variants", `{"sku":"TS-M"}`, productParam)
+		handler.CreateVariant(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_UpdateVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(variants.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("UpdateVariant", mock.Anything, mock.MatchedBy(func(v *models.ProductVariant) bool {
+			return v.ID == 2 && v.ProductID == 1 && v.Version == 3 && v.Price == nil
+		})).Return(nil)
+		service.On("GetVariant", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Version: 4}, nil)
+
+		c, w := newContext(http.MethodPut, "/products/1/variants/2", `{"sku":"TS-M","options":{"size":"M"}}`,
+			productParam, variantParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.UpdateVariant(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	})
+	t.Run("missing if-match", func(t *testing.T) {
+		handler := New(new(variants.MockService))
+
+		c, w := newContext(http.MethodPut, "/products/1/variants/2", `{}`, productParam, variantParam)
+		handler.UpdateVariant(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+	t.Run("version conflict", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("UpdateVariant", mock.Anything, mock.Anything).Return(variants.ErrVersionConflict)
+
+		c, w := newContext(http.MethodPut, "/products/1/variants/2", `{"sku":"TS-M"}`, productParam, variantParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.UpdateVariant(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
+
+func TestHandler_DeleteVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		service := new(variants.MockService)
+		defer service.AssertExpectations(t)
+		handler := New(service)
+
+		service.On("DeleteVariant", mock.Anything, int64(1), int64(2), int64(3)).Return(nil)
+
+		c, w := newContext(http.MethodDelete, "/products/1/variants/2", "", productParam, variantParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.DeleteVariant(c)
+
+		assert.Equal(t, http.StatusNoContent, c.Writer.Status())
+		assert.Empty(t, w.Body.String())
+	})
+	t.Run("service error", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("DeleteVariant", mock.Anything, int64(1), int64(2), int64(3)).Return(errors.New("failed to delete variant"))
+
+		c, w := newContext(http.MethodDelete, "/products/1/variants/2", "", productParam, variantParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.DeleteVariant(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+	t.Run("active reservations", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("DeleteVariant", mock.Anything, int64(1), int64(2), int64(3)).Return(variants.ErrReserved)
+
+		c, w := newContext(http.MethodDelete, "/products/1/variants/2", "", productParam, variantParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.DeleteVariant(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+	t.Run("missing if-match", func(t *testing.T) {
+		handler := New(new(variants.MockService))
+
+		c, w := newContext(http.MethodDelete, "/products/1/variants/2", "", productParam, variantParam)
+		handler.DeleteVariant(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+	t.Run("version conflict", func(t *testing.T) {
+		service := new(variants.MockService)
+		handler := New(service)
+
+		service.On("DeleteVariant", mock.Anything, int64(1), int64(2), int64(3)).Return(variants.ErrVersionConflict)
+
+		c, w := newContext(http.MethodDelete, "/products/1/variants/2", "", productParam, variantParam)
+		c.Request.Header.Set("If-Match", `"3"`)
+		handler.DeleteVariant(c)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+}
@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"net/http"
+	"prodigo/internal/app/models"
 	"prodigo/internal/app/rest/casbin"
+	"prodigo/pkg/db/postgres"
 	"prodigo/pkg/jwt"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -53,6 +56,12 @@ func (m *Middleware) Auth() gin.HandlerFunc {
 			return
 		}
 
+		userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token subject"})
+			return
+		}
+
 		sub := claims.Audience[0]
 		obj := c.Request.URL.Path
 		act := c.Request.Method
@@ -67,6 +76,23 @@ func (m *Middleware) Auth() gin.HandlerFunc {
 			return
 		}
 
+		c.Request = c.Request.WithContext(models.WithPrincipal(c.Request.Context(), models.Principal{
+			Role:   sub,
+			UserID: userID,
+		}))
+		c.Next()
+	}
+}
+
+// ReadYourWrites keeps the reads of a request on the primary once it wrote,
+// clients send X-Read-Primary to read their previous request's writes.
+func (m *Middleware) ReadYourWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := postgres.ReadYourWrites(c.Request.Context())
+		if primary, _ := strconv.ParseBool(c.GetHeader("X-Read-Primary")); primary {
+			ctx = postgres.WithPrimary(ctx)
+		}
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
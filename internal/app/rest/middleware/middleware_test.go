@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/rest/casbin"
+	"prodigo/pkg/jwt"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware_Auth(t *testing.T) {
+	maker, err := jwt.New("0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+	enforcer, err := casbin.New("../../../../configs/casbin/model.conf", "../../../../configs/casbin/policy.csv")
+	require.NoError(t, err)
+
+	var principal models.Principal
+	router := gin.New()
+	router.Use(New(maker, enforcer).Auth())
+	router.GET("/api/v1/products/:id", func(c *gin.Context) {
+		principal, _ = models.PrincipalFrom(c.Request.Context())
+	})
+
+	request := func(header string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("stores the principal", func(t *testing.T) {
+		token, err := maker.CreateToken(42, "user", time.Minute)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, request("Bearer "+token))
+		assert.Equal(t, models.Principal{Role: "user", UserID: 42}, principal)
+	})
+	t.Run("missing header", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request(""))
+	})
+	t.Run("unknown role", func(t *testing.T) {
+		token, err := maker.CreateToken(42, "guest", time.Minute)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusForbidden, request("Bearer "+token))
+	})
+}
@@ -6,8 +6,13 @@ import (
 	"net"
 	"net/http"
 	_ "prodigo/api/app"
+	"prodigo/internal/app/rest/handlers/audit"
 	"prodigo/internal/app/rest/handlers/categories"
+	"prodigo/internal/app/rest/handlers/images"
+	"prodigo/internal/app/rest/handlers/inventory"
 	"prodigo/internal/app/rest/handlers/products"
+	"prodigo/internal/app/rest/handlers/reservations"
+	"prodigo/internal/app/rest/handlers/variants"
 	"prodigo/internal/app/rest/middleware"
 	"time"
 
@@ -23,18 +28,33 @@ type Server struct {
 	srv             *http.Server
 	categoryHandler *categories.Handler
 	productHandler  *products.Handler
+	imageHandler    *images.Handler
+	auditHandler    *audit.Handler
+	variantHandler  *variants.Handler
+	stockHandler    *inventory.Handler
+	holdHandler     *reservations.Handler
 }
 
 func New(
 	mw *middleware.Middleware,
 	productHandler *products.Handler,
 	categoryHandler *categories.Handler,
+	imageHandler *images.Handler,
+	auditHandler *audit.Handler,
+	variantHandler *variants.Handler,
+	stockHandler *inventory.Handler,
+	holdHandler *reservations.Handler,
 ) *Server {
 	return &Server{
 		mux:             gin.New(),
 		mw:              mw,
 		productHandler:  productHandler,
 		categoryHandler: categoryHandler,
+		imageHandler:    imageHandler,
+		auditHandler:    auditHandler,
+		variantHandler:  variantHandler,
+		stockHandler:    stockHandler,
+		holdHandler:     holdHandler,
 	}
 }
 
@@ -55,18 +75,54 @@ func (s *Server) Start(host, port string) error {
 	v1 := s.mux.Group("/api/v1")
 	{
 		v1.Use(s.mw.Auth())
+		v1.Use(s.mw.ReadYourWrites())
 
 		prods := v1.Group("/products")
 		{
 			prods.POST("/", s.productHandler.CreateProduct)
 			prods.GET("/", s.productHandler.GetAllProducts)
+			prods.POST("/import", s.productHandler.ImportProducts)
+			prods.GET("/export", s.productHandler.ExportProducts)
+			prods.POST("/batch", s.productHandler.BatchProducts)
+			prods.GET("/low-stock", s.stockHandler.ListLowStock)
+			prods.GET("/by-sku/:sku", s.productHandler.GetProductBySKU)
+			prods.GET("/by-barcode/:code", s.productHandler.GetProductByBarcode)
 			prods.GET("/:id", s.productHandler.GetProductByID)
 			prods.PUT("/:id", s.productHandler.UpdateProduct)
 			prods.DELETE("/:id", s.productHandler.DeleteProduct)
 			prods.PUT("/:id/restore", s.productHandler.RestoreProduct)
 			prods.PUT("/:id/status", s.productHandler.UpdateProductStatus)
-			prods.POST("/:id/image", s.productHandler.UploadProductImage)
-			prods.GET("/:id/image", s.productHandler.GetProductImage)
+			prods.GET("/:id/status/history", s.productHandler.GetStatusHistory)
+			prods.GET("/:id/prices", s.productHandler.GetPriceHistory)
+			prods.POST("/:id/prices", s.productHandler.SchedulePrice)
+			prods.GET("/:id/translations", s.productHandler.ListTranslations)
+			prods.PUT("/:id/translations/:locale", s.productHandler.UpsertTranslation)
+			prods.POST("/:id/image", s.imageHandler.UploadProductImage)
+			prods.GET("/:id/image", s.imageHandler.GetProductImage)
+			prods.GET("/:id/images", s.imageHandler.ListImages)
+			prods.POST("/:id/images", s.imageHandler.AddImage)
+			prods.PUT("/:id/images/order", s.imageHandler.ReorderImages)
+			prods.PUT("/:id/images/:image_id/primary", s.imageHandler.SetPrimaryImage)
+			prods.DELETE("/:id/images/:image_id", s.imageHandler.DeleteImage)
+			prods.GET("/:id/images/:image_id/file", s.imageHandler.GetImageFile)
+			prods.GET("/:id/options", s.variantHandler.ListOptions)
+			prods.PUT("/:id/options", s.variantHandler.ReplaceOptions)
+			prods.GET("/:id/variants", s.variantHandler.ListVariants)
+			prods.POST("/:id/variants", s.variantHandler.CreateVariant)
+			prods.GET("/:id/variants/:variant_id", s.variantHandler.GetVariant)
+			prods.PUT("/:id/variants/:variant_id", s.variantHandler.UpdateVariant)
+			prods.DELETE("/:id/variants/:variant_id", s.variantHandler.DeleteVariant)
+			prods.GET("/:id/stock", s.stockHandler.GetStockLevel)
+			prods.POST("/:id/stock", s.stockHandler.AdjustStock)
+			prods.GET("/:id/stock/history", s.stockHandler.ListMovements)
+			prods.POST("/:id/reservations", s.holdHandler.Reserve)
+		}
+
+		holds := v1.Group("/reservations")
+		{
+			holds.GET("/:id", s.holdHandler.GetReservation)
+			holds.POST("/:id/confirm", s.holdHandler.ConfirmReservation)
+			holds.POST("/:id/release", s.holdHandler.ReleaseReservation)
 		}
 
 		cats := v1.Group("/categories")
@@ -75,8 +131,16 @@ func (s *Server) Start(host, port string) error {
 			cats.GET("/", s.categoryHandler.GetAllCategories)
 			cats.PUT("/:id", s.categoryHandler.UpdateCategory)
 			cats.DELETE("/:id", s.categoryHandler.DeleteCategory)
+			cats.PUT("/:id/restore", s.categoryHandler.RestoreCategory)
 			cats.GET("/stats", s.categoryHandler.CategoryStatistics)
+			cats.GET("/tree", s.categoryHandler.GetCategoryTree)
+			cats.GET("/:id/tree", s.categoryHandler.GetCategorySubtree)
+			cats.GET("/:id/path", s.categoryHandler.GetCategoryPath)
+			cats.GET("/:id/attributes", s.categoryHandler.ListAttributes)
+			cats.PUT("/:id/attributes", s.categoryHandler.ReplaceAttributes)
 		}
+
+		v1.GET("/audit", s.auditHandler.ListAudit)
 	}
 
 	s.mux.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -0,0 +1,58 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"log"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/inventory"
+	"prodigo/pkg/notifier"
+	"time"
+)
+
+// ServiceInterface raises low stock alerts. Check runs in the transaction
+// that changed the stock, Notify after it committed, so a rolled back
+// change never alerts.
+type ServiceInterface interface {
+	Check(ctx context.Context, productID int64) (*models.LowStock, error)
+	Notify(ctx context.Context, ls *models.LowStock)
+}
+
+type Service struct {
+	repository inventory.Repository
+	notifier   notifier.Notifier
+}
+
+func New(repository inventory.Repository, n notifier.Notifier) ServiceInterface {
+	return &Service{repository: repository, notifier: n}
+}
+
+// Check returns the product when its stock has just dropped below its
+// reorder threshold. A product that stays low is returned once, it alerts
+// again only after it was restocked.
+func (s *Service) Check(ctx context.Context, productID int64) (*models.LowStock, error) {
+	ls, err := s.repository.SyncLowStock(ctx, productID)
+	if err != nil {
+		return nil, errors.New("failed to check low stock")
+	}
+	return ls, nil
+}
+
+// Notify sends ls in the background, failures are only logged. A nil ls
+// is ignored.
+func (s *Service) Notify(ctx context.Context, ls *models.LowStock) {
+	if ls == nil {
+		return
+	}
+	go s.send(context.WithoutCancel(ctx), ls)
+}
+
+func (s *Service) send(ctx context.Context, ls *models.LowStock) {
+	e := notifier.Event{Type: models.EventLowStock, Data: ls, OccurredAt: time.Now()}
+	if ls.Since != nil {
+		e.OccurredAt = *ls.Since
+	}
+	if err := s.notifier.Notify(ctx, e); err != nil {
+		log.Printf("low stock alert for product %d: %v", ls.ProductID, err)
+	}
+}
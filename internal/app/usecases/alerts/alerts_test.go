@@ -0,0 +1,64 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/inventory"
+	"prodigo/pkg/notifier"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestService_Check(t *testing.T) {
+	t.Run("dropped below", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		ls := &models.LowStock{ProductID: 1, OnHand: 2, Threshold: 5}
+		mockRepo.On("SyncLowStock", mock.Anything, int64(1)).Return(ls, nil)
+
+		got, err := New(mockRepo, new(notifier.MockNotifier)).Check(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, ls, got)
+	})
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		mockRepo.On("SyncLowStock", mock.Anything, int64(1)).Return(nil, errors.New("db down"))
+
+		_, err := New(mockRepo, new(notifier.MockNotifier)).Check(context.Background(), 1)
+		assert.EqualError(t, err, "failed to check low stock")
+	})
+}
+
+func TestService_Notify(t *testing.T) {
+	t.Run("sends the alert", func(t *testing.T) {
+		mockNotifier := new(notifier.MockNotifier)
+		since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		ls := &models.LowStock{Since: &since, ProductID: 1, OnHand: 2, Threshold: 5}
+
+		sent := make(chan notifier.Event, 1)
+		mockNotifier.On("Notify", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent <- args.Get(1).(notifier.Event)
+		}).Return(errors.New("webhook down"))
+
+		// the request context may end before the alert is out
+		ctx, cancel := context.WithCancel(context.Background())
+		New(new(inventory.MockRepo), mockNotifier).Notify(ctx, ls)
+		cancel()
+
+		select {
+		case e := <-sent:
+			assert.Equal(t, notifier.Event{Type: models.EventLowStock, Data: ls, OccurredAt: since}, e)
+		case <-time.After(time.Second):
+			t.Fatal("alert not sent")
+		}
+	})
+	t.Run("nothing to send", func(t *testing.T) {
+		mockNotifier := new(notifier.MockNotifier)
+
+		New(new(inventory.MockRepo), mockNotifier).Notify(context.Background(), nil)
+		mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	})
+}
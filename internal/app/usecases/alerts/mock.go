@@ -0,0 +1,24 @@
+package alerts
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) Check(ctx context.Context, productID int64) (*models.LowStock, error) {
+	args := m.Called(ctx, productID)
+	if ls, ok := args.Get(0).(*models.LowStock); ok {
+		return ls, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) Notify(ctx context.Context, ls *models.LowStock) {
+	m.Called(ctx, ls)
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
+)
+
+type ServiceInterface interface {
+	ListAudit(ctx context.Context, f *models.AuditFilter) (*models.AuditPage, error)
+}
+
+var ErrInvalidRange = errors.New("from must be before to")
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+type Service struct {
+	repository audit.Repository
+}
+
+func New(repository audit.Repository) ServiceInterface {
+	return &Service{repository: repository}
+}
+
+// ListAudit returns a page of the audit trail, newest first. BeforeID of
+// the page continues with older entries.
+func (s *Service) ListAudit(ctx context.Context, f *models.AuditFilter) (*models.AuditPage, error) {
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return nil, ErrInvalidRange
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	// one extra row tells whether another page exists
+	query := *f
+	query.Limit = limit + 1
+
+	entries, err := s.repository.List(ctx, &query)
+	if err != nil {
+		return nil, errors.New("failed to get audit log")
+	}
+
+	page := &models.AuditPage{Items: entries, HasMore: len(entries) > limit}
+	if page.HasMore {
+		page.Items = entries[:limit]
+		page.BeforeID = page.Items[limit-1].ID
+	}
+	if page.Items == nil {
+		page.Items = []*models.AuditEntry{}
+	}
+	return page, nil
+}
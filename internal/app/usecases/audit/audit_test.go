@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestService_ListAudit(t *testing.T) {
+	t.Run("has more", func(t *testing.T) {
+		mockRepo := new(audit.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("List", mock.Anything, mock.MatchedBy(func(f *models.AuditFilter) bool {
+			return f.Limit == 3 && f.Entity == models.AuditEntityProduct
+		})).Return([]*models.AuditEntry{{ID: 9}, {ID: 8}, {ID: 7}}, nil)
+
+		service := New(mockRepo)
+		page, err := service.ListAudit(context.Background(), &models.AuditFilter{Entity: models.AuditEntityProduct, Limit: 2})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Items, 2)
+		assert.True(t, page.HasMore)
+		assert.Equal(t, int64(8), page.BeforeID)
+	})
+	t.Run("default limit", func(t *testing.T) {
+		mockRepo := new(audit.MockRepo)
+		mockRepo.On("List", mock.Anything, mock.MatchedBy(func(f *models.AuditFilter) bool {
+			return f.Limit == DefaultPageSize+1
+		})).Return(nil, nil)
+
+		service := New(mockRepo)
+		page, err := service.ListAudit(context.Background(), &models.AuditFilter{})
+
+		assert.NoError(t, err)
+		assert.Empty(t, page.Items)
+		assert.NotNil(t, page.Items)
+		assert.False(t, page.HasMore)
+	})
+	t.Run("invalid range", func(t *testing.T) {
+		now := time.Now()
+		service := New(new(audit.MockRepo))
+
+		_, err := service.ListAudit(context.Background(), &models.AuditFilter{From: now, To: now.Add(-time.Hour)})
+
+		assert.ErrorIs(t, err, ErrInvalidRange)
+	})
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(audit.MockRepo)
+		mockRepo.On("List", mock.Anything, mock.Anything).Return(nil, errors.New("db error"))
+
+		service := New(mockRepo)
+		_, err := service.ListAudit(context.Background(), &models.AuditFilter{})
+
+		assert.EqualError(t, err, "failed to get audit log")
+	})
+}
@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) ListAudit(ctx context.Context, f *models.AuditFilter) (*models.AuditPage, error) {
+	args := m.Called(ctx, f)
+	if page, ok := args.Get(0).(*models.AuditPage); ok {
+		return page, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
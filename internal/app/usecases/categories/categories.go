@@ -3,38 +3,101 @@ package categories
 import (
 	"context"
 	"errors"
+	"fmt"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
 	"prodigo/internal/app/repository/categories"
+	"prodigo/pkg/db/postgres"
+	"strings"
+)
+
+var (
+	ErrNotFound        = errors.New("category not found")
+	ErrVersionConflict = errors.New("category was modified by another request")
+	ErrNotEmpty        = errors.New("category still has products or subcategories")
+	ErrInvalidPolicy   = errors.New("invalid delete policy")
+	ErrInvalidTarget   = errors.New("invalid target category")
+	ErrNotDeleted      = errors.New("category is not deleted")
+	ErrParentIsDeleted = errors.New("parent category is deleted, restore it first")
+	ErrAttributesInUse = errors.New("attributes do not fit the values of existing products")
+	ErrDuplicateName   = errors.New("a category with this name already exists under the parent")
 )
 
 type Service struct {
 	repository categories.Repository
+	audit      audit.Repository
+	tx         postgres.Transactor
 }
 
 type ServiceInterface interface {
 	CreateCategory(ctx context.Context, c *models.Category) error
 	UpdateCategory(ctx context.Context, c *models.Category) error
 	GetAllCategories(ctx context.Context) ([]*models.Category, error)
-	DeleteCategory(ctx context.Context, id int64) error
-	CategoryStatistics(ctx context.Context) ([]*models.CategoryStats, error)
+	GetTree(ctx context.Context) ([]*models.CategoryNode, error)
+	GetSubtree(ctx context.Context, id int64) (*models.CategoryNode, error)
+	GetPath(ctx context.Context, id int64) ([]*models.Category, error)
+	DeleteCategory(ctx context.Context, id int64, opts models.CategoryDelete) error
+	RestoreCategory(ctx context.Context, id, version int64, withProducts bool) (int64, error)
+	CategoryStatistics(ctx context.Context, rollup bool) ([]*models.CategoryStats, error)
+	ListAttributes(ctx context.Context, id int64) ([]*models.AttributeDefinition, error)
+	ReplaceAttributes(ctx context.Context, id int64, defs []*models.AttributeDefinition) error
 }
 
-func New(repository categories.Repository) ServiceInterface {
-	return &Service{repository: repository}
+func New(repository categories.Repository, auditRepo audit.Repository, tx postgres.Transactor) ServiceInterface {
+	return &Service{repository: repository, audit: auditRepo, tx: tx}
 }
 
 func (s *Service) CreateCategory(ctx context.Context, c *models.Category) error {
-	if err := s.repository.CreateCategory(ctx, c); err != nil {
-		return errors.New("failed to create category")
-	}
-	return nil
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.checkParent(ctx, c); err != nil {
+			return err
+		}
+		if err := s.repository.CreateCategory(ctx, c); err != nil {
+			if errors.Is(err, categories.ErrDuplicateName) {
+				return ErrDuplicateName
+			}
+			return errors.New("failed to create category")
+		}
+		return s.record(ctx, models.AuditActionCreate, c.ID, nil, c)
+	})
 }
 
+// UpdateCategory replaces the name and the parent of c, an empty ParentID
+// makes it a root. Moving a category under its own subtree is rejected
+// with models.ErrCategoryCycle. Two concurrent moves could each pass that
+// check and form a cycle together, so the move runs serializable and a
+// conflict that outlasts the retries is reported as ErrVersionConflict.
 func (s *Service) UpdateCategory(ctx context.Context, c *models.Category) error {
-	if err := s.repository.UpdateCategory(ctx, c); err != nil {
-		return errors.New("failed to update category")
+	version := c.Version
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// a retry starts over from the version the caller sent
+		c.Version = version
+		before, err := s.getCategory(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		if err = s.checkParent(ctx, c); err != nil {
+			return err
+		}
+		if err = s.repository.UpdateCategory(ctx, c); err != nil {
+			switch {
+			case errors.Is(err, categories.ErrVersionConflict):
+				return ErrVersionConflict
+			case errors.Is(err, categories.ErrNotFound):
+				return ErrNotFound
+			case errors.Is(err, categories.ErrDuplicateName):
+				return ErrDuplicateName
+			}
+			return errors.New("failed to update category")
+		}
+		after := *before
+		after.Name, after.ParentID, after.Version = c.Name, c.ParentID, c.Version
+		return s.record(ctx, models.AuditActionUpdate, c.ID, before, &after)
+	}, postgres.WithIsolation(postgres.Serializable))
+	if errors.Is(err, postgres.ErrSerialization) {
+		return ErrVersionConflict
 	}
-	return nil
+	return err
 }
 
 func (s *Service) GetAllCategories(ctx context.Context) ([]*models.Category, error) {
@@ -45,17 +108,326 @@ func (s *Service) GetAllCategories(ctx context.Context) ([]*models.Category, err
 	return cats, nil
 }
 
-func (s *Service) DeleteCategory(ctx context.Context, id int64) error {
-	if err := s.repository.DeleteCategory(ctx, id); err != nil {
-		return errors.New("failed to delete category")
+// GetTree returns every category arranged as a forest of root categories.
+func (s *Service) GetTree(ctx context.Context) ([]*models.CategoryNode, error) {
+	cats, err := s.repository.GetAllCategories(ctx)
+	if err != nil {
+		return nil, errors.New("failed to get all categories")
 	}
-	return nil
+	return models.BuildCategoryTree(cats), nil
+}
+
+func (s *Service) GetSubtree(ctx context.Context, id int64) (*models.CategoryNode, error) {
+	cats, err := s.repository.GetSubtree(ctx, id)
+	if err != nil {
+		if errors.Is(err, categories.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to get category subtree")
+	}
+	for _, root := range models.BuildCategoryTree(cats) {
+		if root.ID == id {
+			return root, nil
+		}
+	}
+	return nil, ErrNotFound
 }
 
-func (s *Service) CategoryStatistics(ctx context.Context) ([]*models.CategoryStats, error) {
-	stats, err := s.repository.CategoryStatistics(ctx)
+// GetPath returns the breadcrumb of a category, from its root down to
+// the category itself.
+func (s *Service) GetPath(ctx context.Context, id int64) ([]*models.Category, error) {
+	path, err := s.repository.GetPath(ctx, id)
+	if err != nil {
+		if errors.Is(err, categories.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to get category path")
+	}
+	return path, nil
+}
+
+// DeleteCategory soft-deletes a category still at opts.Version, opts.Policy
+// decides what happens to its products and subcategories and defaults to
+// models.DeletePolicyBlock.
+func (s *Service) DeleteCategory(ctx context.Context, id int64, opts models.CategoryDelete) error {
+	if opts.Policy == "" {
+		opts.Policy = models.DeletePolicyBlock
+	}
+	switch opts.Policy {
+	case models.DeletePolicyBlock, models.DeletePolicyCascade:
+	case models.DeletePolicyReassign:
+		if opts.TargetID <= 0 || opts.TargetID == id {
+			return ErrInvalidTarget
+		}
+	default:
+		return fmt.Errorf("%w %q", ErrInvalidPolicy, opts.Policy)
+	}
+
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		before, err := s.getCategory(ctx, id)
+		if err != nil {
+			return err
+		}
+		// a stale version would release the contents for nothing
+		if before.Version != opts.Version {
+			return ErrVersionConflict
+		}
+		if err = s.releaseContents(ctx, id, opts); err != nil {
+			return err
+		}
+		if err = s.repository.DeleteCategory(ctx, id, opts.Version); err != nil {
+			switch {
+			case errors.Is(err, categories.ErrVersionConflict):
+				return ErrVersionConflict
+			case errors.Is(err, categories.ErrNotFound):
+				return ErrNotFound
+			}
+			return errors.New("failed to delete category")
+		}
+		return s.record(ctx, models.AuditActionDelete, id, before, nil)
+	})
+}
+
+// releaseContents applies the delete policy to what category id holds.
+func (s *Service) releaseContents(ctx context.Context, id int64, opts models.CategoryDelete) error {
+	switch opts.Policy {
+	case models.DeletePolicyReassign:
+		path, err := s.repository.GetPath(ctx, opts.TargetID)
+		if err != nil {
+			if errors.Is(err, categories.ErrNotFound) {
+				return ErrInvalidTarget
+			}
+			return errors.New("failed to get target category")
+		}
+		// the target must survive the delete, so it can not sit below id
+		for _, ancestor := range path {
+			if ancestor.ID == id {
+				return ErrInvalidTarget
+			}
+		}
+		moved, err := s.repository.ReassignContents(ctx, id, opts.TargetID)
+		if err != nil {
+			return errors.New("failed to reassign category contents")
+		}
+		return s.recordProducts(ctx, models.AuditActionUpdate, map[string]any{
+			"from_category_id": id, "category_id": opts.TargetID, "count": moved,
+		})
+	case models.DeletePolicyCascade:
+		archived, err := s.repository.ArchiveContents(ctx, id)
+		if err != nil {
+			return errors.New("failed to archive category contents")
+		}
+		return s.recordProducts(ctx, models.AuditActionUpdate, map[string]any{
+			"status": models.StatusArchived, "archived_with_category": id, "count": archived,
+		})
+	default:
+		products, children, err := s.repository.CategoryUsage(ctx, id)
+		if err != nil {
+			return errors.New("failed to count category contents")
+		}
+		if products > 0 || children > 0 {
+			return fmt.Errorf("%w: %d products, %d subcategories", ErrNotEmpty, products, children)
+		}
+		return nil
+	}
+}
+
+// RestoreCategory undeletes a category still at version and the
+// subcategories deleted with it, withProducts also moves the products
+// archived with it back to draft, as the product lifecycle has it. It
+// returns the number of restored products.
+func (s *Service) RestoreCategory(ctx context.Context, id, version int64, withProducts bool) (int64, error) {
+	var restored int64
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		deleted, err := s.repository.GetDeletedCategory(ctx, id)
+		if err != nil {
+			if errors.Is(err, categories.ErrNotFound) {
+				return s.notDeleted(ctx, id)
+			}
+			return errors.New("failed to get category")
+		}
+		if deleted.Version != version {
+			return ErrVersionConflict
+		}
+		if deleted.ParentID != nil {
+			if _, err = s.getCategory(ctx, *deleted.ParentID); err != nil {
+				if errors.Is(err, ErrNotFound) {
+					return ErrParentIsDeleted
+				}
+				return err
+			}
+		}
+
+		if restored, err = s.repository.RestoreCategory(ctx, id, version, withProducts); err != nil {
+			switch {
+			case errors.Is(err, categories.ErrVersionConflict):
+				return ErrVersionConflict
+			case errors.Is(err, categories.ErrNotFound):
+				return ErrNotFound
+			}
+			return errors.New("failed to restore category")
+		}
+		after, err := s.getCategory(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err = s.record(ctx, models.AuditActionRestore, id, nil, after); err != nil {
+			return err
+		}
+		if restored == 0 {
+			return nil
+		}
+		return s.recordProducts(ctx, models.AuditActionRestore, map[string]any{
+			"restored_with_category": id, "count": restored,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return restored, nil
+}
+
+// notDeleted tells a live category apart from a missing one.
+func (s *Service) notDeleted(ctx context.Context, id int64) error {
+	if _, err := s.getCategory(ctx, id); err != nil {
+		return err
+	}
+	return ErrNotDeleted
+}
+
+func (s *Service) CategoryStatistics(ctx context.Context, rollup bool) ([]*models.CategoryStats, error) {
+	stats, err := s.repository.CategoryStatistics(ctx, rollup)
 	if err != nil {
 		return nil, errors.New("failed to get category statistics")
 	}
 	return stats, nil
 }
+
+func (s *Service) ListAttributes(ctx context.Context, id int64) ([]*models.AttributeDefinition, error) {
+	if _, err := s.getCategory(ctx, id); err != nil {
+		return nil, err
+	}
+	defs, err := s.repository.ListAttributes(ctx, id)
+	if err != nil {
+		return nil, errors.New("failed to get attributes")
+	}
+	return defs, nil
+}
+
+// ReplaceAttributes swaps the attribute definitions of a category for
+// defs. Every product of the category has to keep fitting them, values
+// of a dropped attribute or of another type have to be changed first.
+func (s *Service) ReplaceAttributes(ctx context.Context, id int64, defs []*models.AttributeDefinition) error {
+	for _, d := range defs {
+		d.Name = strings.TrimSpace(d.Name)
+	}
+	if err := models.CheckDefinitions(defs); err != nil {
+		return err
+	}
+
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.getCategory(ctx, id); err != nil {
+			return err
+		}
+		before, err := s.repository.ListAttributes(ctx, id)
+		if err != nil {
+			return errors.New("failed to get attributes")
+		}
+		types := make(map[string]string, len(defs))
+		for _, d := range defs {
+			types[d.Name] = d.Type
+		}
+		misfits, err := s.repository.AttributeMismatches(ctx, id, types)
+		if err != nil {
+			return errors.New("failed to check attributes")
+		}
+		if misfits > 0 {
+			return fmt.Errorf("%w: %d products", ErrAttributesInUse, misfits)
+		}
+
+		if err = s.repository.ReplaceAttributes(ctx, id, defs); err != nil {
+			return errors.New("failed to replace attributes")
+		}
+		return s.recordAttributes(ctx, id, before, defs)
+	})
+}
+
+// checkParent makes sure the parent of c exists and is not c itself or
+// one of its subcategories.
+func (s *Service) checkParent(ctx context.Context, c *models.Category) error {
+	if c.ParentID == nil {
+		return nil
+	}
+	if *c.ParentID == c.ID {
+		return models.ErrCategoryCycle
+	}
+	path, err := s.repository.GetPath(ctx, *c.ParentID)
+	if err != nil {
+		if errors.Is(err, categories.ErrNotFound) {
+			return models.ErrParentNotFound
+		}
+		return errors.New("failed to get parent category")
+	}
+	for _, ancestor := range path {
+		if ancestor.ID == c.ID {
+			return models.ErrCategoryCycle
+		}
+	}
+	return nil
+}
+
+func (s *Service) getCategory(ctx context.Context, id int64) (*models.Category, error) {
+	c, err := s.repository.GetCategoryByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, categories.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to get category")
+	}
+	return c, nil
+}
+
+// recordProducts writes a single audit entry summing up a change to the
+// products of a category.
+func (s *Service) recordProducts(ctx context.Context, action string, summary map[string]any) error {
+	e, err := models.NewAuditEntry(ctx, action, models.AuditEntityProduct, nil, nil, summary)
+	if err != nil {
+		return errors.New("failed to build audit entry")
+	}
+	if err = s.audit.Record(ctx, e); err != nil {
+		return errors.New("failed to record audit entry")
+	}
+	return nil
+}
+
+func (s *Service) recordAttributes(ctx context.Context, id int64, before, after []*models.AttributeDefinition) error {
+	e, err := models.NewAuditEntry(ctx, models.AuditActionUpdate, models.AuditEntityAttributes, &id,
+		map[string]any{"attributes": before}, map[string]any{"attributes": after})
+	if err != nil {
+		return errors.New("failed to build audit entry")
+	}
+	if err = s.audit.Record(ctx, e); err != nil {
+		return errors.New("failed to record audit entry")
+	}
+	return nil
+}
+
+// record writes an audit entry for a mutation of category id, it has to
+// run in the transaction of the mutation.
+func (s *Service) record(ctx context.Context, action string, id int64, before, after *models.Category) error {
+	var from, to any
+	if before != nil {
+		from = before
+	}
+	if after != nil {
+		to = after
+	}
+	e, err := models.NewAuditEntry(ctx, action, models.AuditEntityCategory, &id, from, to)
+	if err != nil {
+		return errors.New("failed to build audit entry")
+	}
+	if err = s.audit.Record(ctx, e); err != nil {
+		return errors.New("failed to record audit entry")
+	}
+	return nil
+}
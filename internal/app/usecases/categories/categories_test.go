@@ -3,93 +3,363 @@ package categories
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
 	"prodigo/internal/app/repository/categories"
+	"prodigo/pkg/db/postgres"
 	"testing"
 )
 
+// newService records audit entries into a mock that accepts anything,
+// tests about the entries themselves use their own audit mock.
+func newService(repo categories.Repository) *Service {
+	auditRepo := new(audit.MockRepo)
+	auditRepo.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return &Service{repository: repo, audit: auditRepo, tx: postgres.MockTransactor{}}
+}
+
+// retryingTx runs fn twice like a transaction retried after a
+// serialization failure, and then gives up.
+type retryingTx struct {
+	postgres.MockTransactor
+}
+
+func (retryingTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error, _ ...postgres.TxOption) error {
+	for range 2 {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: failed to commit transaction", postgres.ErrSerialization)
+}
+
 func TestService_CreateCategory(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 
 		category := &models.Category{
 			Name: "test",
 		}
 		mockRepo.On("CreateCategory", mock.Anything, category).Return(nil)
 
-		err := service.CreateCategory(nil, category)
+		err := service.CreateCategory(context.Background(), category)
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
 
 	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		category := &models.Category{
 			Name: "test",
 		}
 		mockRepo.On("CreateCategory", mock.Anything, category).Return(errors.New("db error"))
-		err := service.CreateCategory(nil, category)
+		err := service.CreateCategory(context.Background(), category)
 		assert.Error(t, err)
 		assert.EqualError(t, err, "failed to create category")
 		mockRepo.AssertExpectations(t)
 	})
+	t.Run("name taken under the parent", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		category := &models.Category{Name: "test"}
+		mockRepo.On("CreateCategory", mock.Anything, category).Return(categories.ErrDuplicateName)
+
+		err := service.CreateCategory(context.Background(), category)
+		assert.ErrorIs(t, err, ErrDuplicateName)
+	})
 }
 
 func TestService_UpdateCategory(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		category := &models.Category{
 			Name: "test",
 		}
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(0)).Return(&models.Category{Name: "old"}, nil)
 		mockRepo.On("UpdateCategory", mock.Anything, category).Return(nil)
-		err := service.UpdateCategory(nil, category)
+		err := service.UpdateCategory(context.Background(), category)
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
 	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		category := &models.Category{
 			Name: "test",
 		}
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(0)).Return(&models.Category{Name: "old"}, nil)
 		mockRepo.On("UpdateCategory", mock.Anything, category).Return(errors.New("db error"))
-		err := service.UpdateCategory(nil, category)
+		err := service.UpdateCategory(context.Background(), category)
 		assert.Error(t, err)
 		assert.EqualError(t, err, "failed to update category")
 		mockRepo.AssertExpectations(t)
 	})
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		category := &models.Category{ID: 1, Name: "test", Version: 2}
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Name: "old", Version: 3}, nil)
+		mockRepo.On("UpdateCategory", mock.Anything, category).Return(categories.ErrVersionConflict)
+		err := service.UpdateCategory(context.Background(), category)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("serialization conflict", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		service.tx = retryingTx{}
+		category := &models.Category{ID: 1, Name: "test", Version: 2}
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 2}, nil)
+		mockRepo.On("UpdateCategory", mock.Anything, mock.MatchedBy(func(c *models.Category) bool {
+			return c.Version == 2
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Category).Version = 3
+		}).Return(nil).Twice()
+		err := service.UpdateCategory(context.Background(), category)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		category := &models.Category{ID: 1, Name: "test", Version: 2}
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(nil, categories.ErrNotFound)
+		err := service.UpdateCategory(context.Background(), category)
+		assert.ErrorIs(t, err, ErrNotFound)
+		mockRepo.AssertNotCalled(t, "UpdateCategory", mock.Anything, mock.Anything)
+	})
+	t.Run("records audit entry", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		auditRepo := new(audit.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		defer auditRepo.AssertExpectations(t)
+
+		service := New(mockRepo, auditRepo, postgres.MockTransactor{})
+		ctx := models.WithPrincipal(context.Background(), models.Principal{Role: "admin", UserID: 5})
+		category := &models.Category{ID: 1, Name: "Phones", Version: 1}
+
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).
+			Return(&models.Category{ID: 1, Name: "Mobiles", Version: 1}, nil)
+		mockRepo.On("UpdateCategory", mock.Anything, category).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Category).Version = 2
+		}).Return(nil)
+		auditRepo.On("Record", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+			return *e.ActorID == 5 && *e.EntityID == 1 &&
+				e.Action == models.AuditActionUpdate && e.Entity == models.AuditEntityCategory &&
+				string(e.Changes) == `{"name":{"from":"Mobiles","to":"Phones"},"version":{"from":1,"to":2}}`
+		})).Return(nil)
+
+		err := service.UpdateCategory(ctx, category)
+		assert.NoError(t, err)
+	})
+	t.Run("audit failure fails the update", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		auditRepo := new(audit.MockRepo)
+
+		service := New(mockRepo, auditRepo, postgres.MockTransactor{})
+		category := &models.Category{ID: 1, Name: "Phones", Version: 1}
+
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Name: "Mobiles"}, nil)
+		mockRepo.On("UpdateCategory", mock.Anything, category).Return(nil)
+		auditRepo.On("Record", mock.Anything, mock.Anything).Return(errors.New("db error"))
+
+		err := service.UpdateCategory(context.Background(), category)
+		assert.EqualError(t, err, "failed to record audit entry")
+	})
 }
 
 func TestService_DeleteCategory(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		id := int64(1)
-		mockRepo.On("DeleteCategory", mock.Anything, id).Return(nil)
-		err := service.DeleteCategory(nil, id)
+		mockRepo.On("GetCategoryByID", mock.Anything, id).Return(&models.Category{ID: id, Version: 1}, nil)
+		mockRepo.On("CategoryUsage", mock.Anything, id).Return(int64(0), int64(0), nil)
+		mockRepo.On("DeleteCategory", mock.Anything, id, int64(1)).Return(nil)
+		err := service.DeleteCategory(context.Background(), id, models.CategoryDelete{Version: 1})
 		assert.NoError(t, err)
 	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		id := int64(1)
-		mockRepo.On("DeleteCategory", mock.Anything, id).Return(errors.New("db error"))
-		err := service.DeleteCategory(nil, id)
+		mockRepo.On("GetCategoryByID", mock.Anything, id).Return(&models.Category{ID: id, Version: 1}, nil)
+		mockRepo.On("CategoryUsage", mock.Anything, id).Return(int64(0), int64(0), nil)
+		mockRepo.On("DeleteCategory", mock.Anything, id, int64(1)).Return(errors.New("db error"))
+		err := service.DeleteCategory(context.Background(), id, models.CategoryDelete{Version: 1})
 		assert.Error(t, err)
 		assert.EqualError(t, err, "failed to delete category")
 		mockRepo.AssertExpectations(t)
 	})
+	t.Run("stale version releases nothing", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 2}, nil)
+
+		opts := models.CategoryDelete{Policy: models.DeletePolicyCascade, Version: 1}
+		err := service.DeleteCategory(context.Background(), 1, opts)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		mockRepo.AssertNotCalled(t, "ArchiveContents", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "DeleteCategory", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("concurrent change", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 1}, nil)
+		mockRepo.On("CategoryUsage", mock.Anything, int64(1)).Return(int64(0), int64(0), nil)
+		mockRepo.On("DeleteCategory", mock.Anything, int64(1), int64(1)).Return(categories.ErrVersionConflict)
+
+		err := service.DeleteCategory(context.Background(), 1, models.CategoryDelete{Version: 1})
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+}
+
+func TestService_DeleteCategoryPolicies(t *testing.T) {
+	t.Run("block with contents", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 1}, nil)
+		mockRepo.On("CategoryUsage", mock.Anything, int64(1)).Return(int64(3), int64(1), nil)
+
+		err := service.DeleteCategory(context.Background(), 1, models.CategoryDelete{Version: 1})
+		assert.ErrorIs(t, err, ErrNotEmpty)
+		assert.Contains(t, err.Error(), "3 products, 1 subcategories")
+		mockRepo.AssertNotCalled(t, "DeleteCategory", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("invalid policy", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+
+		err := service.DeleteCategory(context.Background(), 1, models.CategoryDelete{Policy: "drop", Version: 1})
+		assert.ErrorIs(t, err, ErrInvalidPolicy)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("reassign without target", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+
+		err := service.DeleteCategory(context.Background(), 1, models.CategoryDelete{Policy: models.DeletePolicyReassign, Version: 1})
+		assert.ErrorIs(t, err, ErrInvalidTarget)
+	})
+	t.Run("reassign to own subcategory", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 1}, nil)
+		mockRepo.On("GetPath", mock.Anything, int64(5)).Return([]*models.Category{{ID: 1}, {ID: 5}}, nil)
+
+		opts := models.CategoryDelete{Policy: models.DeletePolicyReassign, TargetID: 5, Version: 1}
+		err := service.DeleteCategory(context.Background(), 1, opts)
+		assert.ErrorIs(t, err, ErrInvalidTarget)
+		mockRepo.AssertNotCalled(t, "ReassignContents", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("reassign to missing target", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 1}, nil)
+		mockRepo.On("GetPath", mock.Anything, int64(5)).Return(nil, categories.ErrNotFound)
+
+		opts := models.CategoryDelete{Policy: models.DeletePolicyReassign, TargetID: 5, Version: 1}
+		err := service.DeleteCategory(context.Background(), 1, opts)
+		assert.ErrorIs(t, err, ErrInvalidTarget)
+	})
+	t.Run("reassign", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 1}, nil)
+		mockRepo.On("GetPath", mock.Anything, int64(2)).Return([]*models.Category{{ID: 2}}, nil)
+		mockRepo.On("ReassignContents", mock.Anything, int64(1), int64(2)).Return(int64(4), nil)
+		mockRepo.On("DeleteCategory", mock.Anything, int64(1), int64(1)).Return(nil)
+
+		opts := models.CategoryDelete{Policy: models.DeletePolicyReassign, TargetID: 2, Version: 1}
+		err := service.DeleteCategory(context.Background(), 1, opts)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("cascade", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 1}, nil)
+		mockRepo.On("ArchiveContents", mock.Anything, int64(1)).Return(int64(7), nil)
+		mockRepo.On("DeleteCategory", mock.Anything, int64(1), int64(1)).Return(nil)
+
+		err := service.DeleteCategory(context.Background(), 1, models.CategoryDelete{Policy: models.DeletePolicyCascade, Version: 1})
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(nil, categories.ErrNotFound)
+
+		err := service.DeleteCategory(context.Background(), 1, models.CategoryDelete{Version: 1})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestService_RestoreCategory(t *testing.T) {
+	parent := int64(2)
+	t.Run("with products", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedCategory", mock.Anything, int64(1)).Return(&models.Category{ID: 1, ParentID: &parent, Version: 1}, nil)
+		mockRepo.On("GetCategoryByID", mock.Anything, parent).Return(&models.Category{ID: parent}, nil)
+		mockRepo.On("RestoreCategory", mock.Anything, int64(1), int64(1), true).Return(int64(3), nil)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, ParentID: &parent, Version: 1}, nil)
+
+		restored, err := service.RestoreCategory(context.Background(), 1, 1, true)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), restored)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("not deleted", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedCategory", mock.Anything, int64(1)).Return(nil, categories.ErrNotFound)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 1}, nil)
+
+		_, err := service.RestoreCategory(context.Background(), 1, 1, false)
+		assert.ErrorIs(t, err, ErrNotDeleted)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedCategory", mock.Anything, int64(1)).Return(nil, categories.ErrNotFound)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(nil, categories.ErrNotFound)
+
+		_, err := service.RestoreCategory(context.Background(), 1, 1, false)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedCategory", mock.Anything, int64(1)).Return(&models.Category{ID: 1, Version: 2}, nil)
+
+		_, err := service.RestoreCategory(context.Background(), 1, 1, false)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		mockRepo.AssertNotCalled(t, "RestoreCategory", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("parent deleted", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedCategory", mock.Anything, int64(1)).Return(&models.Category{ID: 1, ParentID: &parent, Version: 1}, nil)
+		mockRepo.On("GetCategoryByID", mock.Anything, parent).Return(nil, categories.ErrNotFound)
+
+		_, err := service.RestoreCategory(context.Background(), 1, 1, false)
+		assert.ErrorIs(t, err, ErrParentIsDeleted)
+		mockRepo.AssertNotCalled(t, "RestoreCategory", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
 }
 
 func TestService_GetAllCategories(t *testing.T) {
 	t.Run("test", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		mockRepo.On("GetAllCategories", mock.Anything).Return([]*models.Category{}, nil)
 		categs, err := service.GetAllCategories(nil)
 		assert.NoError(t, err)
@@ -97,7 +367,7 @@ func TestService_GetAllCategories(t *testing.T) {
 	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		mockRepo.On("GetAllCategories", mock.Anything).Return([]*models.Category{}, errors.New("db error"))
 		categs, err := service.GetAllCategories(nil)
 		assert.Error(t, err)
@@ -110,7 +380,7 @@ func TestService_GetAllCategories(t *testing.T) {
 func TestService_CategoryStatistics(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := New(mockRepo)
+		service := newService(mockRepo)
 
 		expectedStats := []*models.CategoryStats{
 			{
@@ -118,21 +388,21 @@ func TestService_CategoryStatistics(t *testing.T) {
 				CategoryName:  "Test Category",
 				ProductCount:  1,
 				TotalQuantity: 10,
-				TotalValue:    1000,
+				TotalValue:    []models.Money{{Currency: "USD", Amount: 1000}},
 			},
 		}
 
-		mockRepo.On("CategoryStatistics", mock.Anything).Return(expectedStats, nil).Once()
-		stats, err := service.CategoryStatistics(context.Background())
+		mockRepo.On("CategoryStatistics", mock.Anything, true).Return(expectedStats, nil).Once()
+		stats, err := service.CategoryStatistics(context.Background(), true)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedStats, stats)
 		mockRepo.AssertExpectations(t)
 	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(categories.MockRepo)
-		service := New(mockRepo)
-		mockRepo.On("CategoryStatistics", mock.Anything).Return([]*models.CategoryStats{}, errors.New("db error")).Once()
-		stats, err := service.CategoryStatistics(context.Background())
+		service := newService(mockRepo)
+		mockRepo.On("CategoryStatistics", mock.Anything, true).Return([]*models.CategoryStats{}, errors.New("db error")).Once()
+		stats, err := service.CategoryStatistics(context.Background(), true)
 		assert.Error(t, err)
 		assert.EqualError(t, err, "failed to get category statistics")
 		assert.Nil(t, stats)
@@ -140,3 +410,182 @@ func TestService_CategoryStatistics(t *testing.T) {
 	})
 
 }
+
+func TestService_CategoryParent(t *testing.T) {
+	parent := int64(2)
+
+	t.Run("create under missing parent", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetPath", mock.Anything, parent).Return(nil, categories.ErrNotFound)
+
+		err := service.CreateCategory(context.Background(), &models.Category{Name: "Phones", ParentID: &parent})
+
+		assert.ErrorIs(t, err, models.ErrParentNotFound)
+		mockRepo.AssertNotCalled(t, "CreateCategory", mock.Anything, mock.Anything)
+	})
+	t.Run("create under parent", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+		category := &models.Category{Name: "Phones", ParentID: &parent}
+		mockRepo.On("GetPath", mock.Anything, parent).Return([]*models.Category{{ID: 1}, {ID: 2}}, nil)
+		mockRepo.On("CreateCategory", mock.Anything, category).Return(nil)
+
+		assert.NoError(t, service.CreateCategory(context.Background(), category))
+	})
+	t.Run("move under itself", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(2)).Return(&models.Category{ID: 2}, nil)
+
+		err := service.UpdateCategory(context.Background(), &models.Category{ID: 2, Name: "Phones", ParentID: &parent})
+
+		assert.ErrorIs(t, err, models.ErrCategoryCycle)
+	})
+	t.Run("move under a subcategory", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		child := int64(5)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(2)).Return(&models.Category{ID: 2}, nil)
+		mockRepo.On("GetPath", mock.Anything, child).Return([]*models.Category{{ID: 1}, {ID: 2}, {ID: 5}}, nil)
+
+		err := service.UpdateCategory(context.Background(), &models.Category{ID: 2, Name: "Phones", ParentID: &child})
+
+		assert.ErrorIs(t, err, models.ErrCategoryCycle)
+		mockRepo.AssertNotCalled(t, "UpdateCategory", mock.Anything, mock.Anything)
+	})
+}
+
+func TestService_GetTree(t *testing.T) {
+	root, phones := int64(1), int64(2)
+	cats := []*models.Category{
+		{ID: 3, Name: "Cases", ParentID: &phones},
+		{ID: 2, Name: "Phones", ParentID: &root},
+		{ID: 4, Name: "Laptops", ParentID: &root},
+		{ID: 1, Name: "Electronics"},
+		{ID: 5, Name: "Books"},
+	}
+
+	t.Run("forest", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetAllCategories", mock.Anything).Return(cats, nil)
+
+		tree, err := service.GetTree(context.Background())
+
+		assert.NoError(t, err)
+		if assert.Len(t, tree, 2) {
+			assert.Equal(t, "Books", tree[0].Name)
+			assert.Empty(t, tree[0].Children)
+			electronics := tree[1]
+			if assert.Len(t, electronics.Children, 2) {
+				assert.Equal(t, "Laptops", electronics.Children[0].Name)
+				assert.Equal(t, "Phones", electronics.Children[1].Name)
+				assert.Equal(t, "Cases", electronics.Children[1].Children[0].Name)
+			}
+		}
+	})
+	t.Run("subtree", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetSubtree", mock.Anything, phones).Return(cats[:2], nil)
+
+		node, err := service.GetSubtree(context.Background(), phones)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Phones", node.Name)
+		assert.Len(t, node.Children, 1)
+	})
+	t.Run("subtree not found", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetSubtree", mock.Anything, int64(9)).Return(nil, categories.ErrNotFound)
+
+		_, err := service.GetSubtree(context.Background(), 9)
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("path", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetPath", mock.Anything, int64(3)).Return([]*models.Category{cats[3], cats[1], cats[0]}, nil)
+
+		path, err := service.GetPath(context.Background(), 3)
+
+		assert.NoError(t, err)
+		assert.Len(t, path, 3)
+	})
+	t.Run("path error", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetPath", mock.Anything, int64(3)).Return(nil, errors.New("db error"))
+
+		_, err := service.GetPath(context.Background(), 3)
+
+		assert.EqualError(t, err, "failed to get category path")
+	})
+}
+
+func TestService_ReplaceAttributes(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		auditRepo := new(audit.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		defer auditRepo.AssertExpectations(t)
+		service := &Service{repository: mockRepo, audit: auditRepo, tx: postgres.MockTransactor{}}
+
+		defs := []*models.AttributeDefinition{
+			{Name: " brand ", Type: models.AttributeString}, {Name: "ram", Type: models.AttributeNumber},
+		}
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1}, nil)
+		mockRepo.On("ListAttributes", mock.Anything, int64(1)).Return([]*models.AttributeDefinition{}, nil)
+		mockRepo.On("AttributeMismatches", mock.Anything, int64(1),
+			map[string]string{"brand": models.AttributeString, "ram": models.AttributeNumber}).Return(int64(0), nil)
+		mockRepo.On("ReplaceAttributes", mock.Anything, int64(1), defs).Return(nil)
+		auditRepo.On("Record", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+			return e.Entity == models.AuditEntityAttributes && *e.EntityID == 1
+		})).Return(nil)
+
+		assert.NoError(t, service.ReplaceAttributes(context.Background(), 1, defs))
+		assert.Equal(t, "brand", defs[0].Name)
+	})
+	t.Run("invalid definitions", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+
+		for _, defs := range [][]*models.AttributeDefinition{
+			{{Name: "Brand", Type: models.AttributeString}},
+			{{Name: "ram_gte", Type: models.AttributeNumber}},
+			{{Name: "ram", Type: "integer"}},
+			{{Name: "ram", Type: models.AttributeNumber}, {Name: "ram", Type: models.AttributeString}},
+		} {
+			err := service.ReplaceAttributes(context.Background(), 1, defs)
+			assert.ErrorIs(t, err, models.ErrInvalidAttributes)
+		}
+		mockRepo.AssertNotCalled(t, "ReplaceAttributes", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("products hold values that would not fit", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(1)).Return(&models.Category{ID: 1}, nil)
+		mockRepo.On("ListAttributes", mock.Anything, int64(1)).Return([]*models.AttributeDefinition{}, nil)
+		mockRepo.On("AttributeMismatches", mock.Anything, int64(1), map[string]string{}).Return(int64(3), nil)
+
+		err := service.ReplaceAttributes(context.Background(), 1, []*models.AttributeDefinition{})
+		assert.ErrorIs(t, err, ErrAttributesInUse)
+		mockRepo.AssertNotCalled(t, "ReplaceAttributes", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("category not found", func(t *testing.T) {
+		mockRepo := new(categories.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetCategoryByID", mock.Anything, int64(9)).Return(nil, categories.ErrNotFound)
+
+		_, err := service.ListAttributes(context.Background(), 9)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = service.ReplaceAttributes(context.Background(), 9, []*models.AttributeDefinition{})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -25,12 +25,54 @@ func (m *MockService) UpdateCategory(ctx context.Context, c *models.Category) er
 	return args.Error(0)
 }
 
-func (m *MockService) DeleteCategory(ctx context.Context, id int64) error {
-	args := m.Called(ctx, id)
+func (m *MockService) DeleteCategory(ctx context.Context, id int64, opts models.CategoryDelete) error {
+	args := m.Called(ctx, id, opts)
 	return args.Error(0)
 }
 
-func (m *MockService) CategoryStatistics(ctx context.Context) ([]*models.CategoryStats, error) {
+func (m *MockService) RestoreCategory(ctx context.Context, id, version int64, withProducts bool) (int64, error) {
+	args := m.Called(ctx, id, version, withProducts)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockService) GetTree(ctx context.Context) ([]*models.CategoryNode, error) {
 	args := m.Called(ctx)
+	if tree, ok := args.Get(0).([]*models.CategoryNode); ok {
+		return tree, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) GetSubtree(ctx context.Context, id int64) (*models.CategoryNode, error) {
+	args := m.Called(ctx, id)
+	if node, ok := args.Get(0).(*models.CategoryNode); ok {
+		return node, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) GetPath(ctx context.Context, id int64) ([]*models.Category, error) {
+	args := m.Called(ctx, id)
+	if path, ok := args.Get(0).([]*models.Category); ok {
+		return path, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) CategoryStatistics(ctx context.Context, rollup bool) ([]*models.CategoryStats, error) {
+	args := m.Called(ctx, rollup)
 	return args.Get(0).([]*models.CategoryStats), args.Error(1)
 }
+
+func (m *MockService) ListAttributes(ctx context.Context, id int64) ([]*models.AttributeDefinition, error) {
+	args := m.Called(ctx, id)
+	if defs, ok := args.Get(0).([]*models.AttributeDefinition); ok {
+		return defs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) ReplaceAttributes(ctx context.Context, id int64, defs []*models.AttributeDefinition) error {
+	args := m.Called(ctx, id, defs)
+	return args.Error(0)
+}
@@ -2,13 +2,29 @@ package usecases
 
 import (
 	"go.uber.org/fx"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/internal/app/usecases/audit"
 	"prodigo/internal/app/usecases/categories"
+	"prodigo/internal/app/usecases/images"
+	"prodigo/internal/app/usecases/inventory"
 	"prodigo/internal/app/usecases/products"
+	"prodigo/internal/app/usecases/reservations"
+	"prodigo/internal/app/usecases/variants"
 )
 
 var Module = fx.Module("usecases",
 	fx.Provide(
+		alerts.New,
+		audit.New,
 		categories.New,
+		images.New,
+		inventory.New,
 		products.New,
+		reservations.New,
+		reservations.NewReaper,
+		variants.New,
 	),
+	fx.Invoke(func(lc fx.Lifecycle, r *reservations.Reaper) {
+		lc.Append(fx.Hook{OnStart: r.Start, OnStop: r.Stop})
+	}),
 )
@@ -0,0 +1,269 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/images"
+	"prodigo/pkg/imageproc"
+	"prodigo/pkg/storage"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ServiceInterface interface {
+	ListImages(ctx context.Context, productID int64) ([]*models.ProductImage, error)
+	GetImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error)
+	GetPrimaryImage(ctx context.Context, productID int64) (*models.ProductImage, error)
+	AddImage(ctx context.Context, img *models.ProductImage, r io.Reader) error
+	ReorderImages(ctx context.Context, productID int64, imageIDs []int64) error
+	SetPrimary(ctx context.Context, productID, imageID int64) error
+	DeleteImage(ctx context.Context, productID, imageID int64) error
+	RenditionKey(img *models.ProductImage, size string) (string, error)
+	OpenImage(ctx context.Context, img *models.ProductImage, size string) (*storage.Object, error)
+	PresignImage(ctx context.Context, img *models.ProductImage, size string, expiry time.Duration) (string, error)
+}
+
+var (
+	ErrProductNotFound    = errors.New("product not found")
+	ErrNotFound           = errors.New("image not found")
+	ErrInvalidOrder       = errors.New("image order must list every image of the product once")
+	ErrUnsupportedType    = errors.New("unsupported image type")
+	ErrInvalidImage       = errors.New("invalid image")
+	ErrImageTooLarge      = errors.New("image dimensions are too large")
+	ErrUnknownSize        = errors.New("unknown image size")
+	ErrPresignUnsupported = errors.New("image urls can not be signed by this storage")
+)
+
+// SizeOriginal names the normalized upload itself.
+const SizeOriginal = "original"
+
+// an object that can't be deleted is retried this often, waiting a little
+// longer each time
+const (
+	deleteAttempts = 3
+	deleteBackoff  = 50 * time.Millisecond
+)
+
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+type Service struct {
+	repository images.Repository
+	storage    storage.Storage
+	processor  *imageproc.Processor
+}
+
+func New(repository images.Repository, store storage.Storage, processor *imageproc.Processor) ServiceInterface {
+	return &Service{repository: repository, storage: store, processor: processor}
+}
+
+func (s *Service) ListImages(ctx context.Context, productID int64) ([]*models.ProductImage, error) {
+	imgs, err := s.repository.ListImages(ctx, productID)
+	if err != nil {
+		return nil, errors.New("failed to get images")
+	}
+	return imgs, nil
+}
+
+func (s *Service) GetImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error) {
+	img, err := s.repository.GetImage(ctx, productID, imageID)
+	if err != nil {
+		return nil, mapError(err, "failed to get image")
+	}
+	return img, nil
+}
+
+func (s *Service) GetPrimaryImage(ctx context.Context, productID int64) (*models.ProductImage, error) {
+	img, err := s.repository.GetPrimaryImage(ctx, productID)
+	if err != nil {
+		return nil, mapError(err, "failed to get image")
+	}
+	return img, nil
+}
+
+// AddImage normalizes r, stores it with its renditions under a fresh key,
+// so uploads never overwrite each other, and records it in the gallery of
+// img.ProductID.
+func (s *Service) AddImage(ctx context.Context, img *models.ProductImage, r io.Reader) error {
+	if !supportedTypes[img.ContentType] {
+		return ErrUnsupportedType
+	}
+
+	res, err := s.processor.Process(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, imageproc.ErrInvalidImage):
+			return ErrInvalidImage
+		case errors.Is(err, imageproc.ErrTooLarge):
+			return ErrImageTooLarge
+		}
+		return errors.New("failed to process image")
+	}
+
+	img.Key = fmt.Sprintf("products/%d/images/%s%s", img.ProductID, uuid.NewString(), res.Original.Ext)
+	img.ContentType = res.Original.ContentType
+	img.Width, img.Height = res.Original.Width, res.Original.Height
+	img.Renditions = []string{}
+
+	stored := []string{}
+	put := func(key string, file *imageproc.Image) error {
+		if err := s.storage.Put(ctx, key, bytes.NewReader(file.Data), int64(len(file.Data)), file.ContentType); err != nil {
+			s.deleteObjects(ctx, stored)
+			return errors.New("failed to store image")
+		}
+		stored = append(stored, key)
+		return nil
+	}
+
+	if err = put(img.Key, res.Original); err != nil {
+		return err
+	}
+	for _, rend := range s.processor.Renditions() {
+		file, ok := res.Renditions[rend.Name]
+		if !ok {
+			continue
+		}
+		if err = put(renditionKey(img.Key, rend.Name), file); err != nil {
+			return err
+		}
+		img.Renditions = append(img.Renditions, rend.Name)
+	}
+
+	if err = s.repository.AddImage(ctx, img); err != nil {
+		s.deleteObjects(ctx, stored)
+		return mapError(err, "failed to add image")
+	}
+	return nil
+}
+
+func (s *Service) ReorderImages(ctx context.Context, productID int64, imageIDs []int64) error {
+	if err := s.repository.ReorderImages(ctx, productID, imageIDs); err != nil {
+		return mapError(err, "failed to reorder images")
+	}
+	return nil
+}
+
+func (s *Service) SetPrimary(ctx context.Context, productID, imageID int64) error {
+	if err := s.repository.SetPrimary(ctx, productID, imageID); err != nil {
+		return mapError(err, "failed to set primary image")
+	}
+	return nil
+}
+
+// DeleteImage removes the gallery entry first, the stored object is only
+// dropped once nothing points at it anymore.
+func (s *Service) DeleteImage(ctx context.Context, productID, imageID int64) error {
+	img, err := s.repository.DeleteImage(ctx, productID, imageID)
+	if err != nil {
+		return mapError(err, "failed to delete image")
+	}
+	keys := []string{img.Key}
+	for _, name := range img.Renditions {
+		keys = append(keys, renditionKey(img.Key, name))
+	}
+	s.deleteObjects(ctx, keys)
+	return nil
+}
+
+// RenditionKey names the object serving size of img. Configured sizes the
+// image was already smaller than, and images stored before renditions
+// existed, are served by the original.
+func (s *Service) RenditionKey(img *models.ProductImage, size string) (string, error) {
+	if size == "" || size == SizeOriginal {
+		return img.Key, nil
+	}
+	if !s.processor.Has(size) {
+		return "", ErrUnknownSize
+	}
+	if slices.Contains(img.Renditions, size) {
+		return renditionKey(img.Key, size), nil
+	}
+	return img.Key, nil
+}
+
+func (s *Service) OpenImage(ctx context.Context, img *models.ProductImage, size string) (*storage.Object, error) {
+	key, err := s.RenditionKey(img, size)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := s.storage.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to read image")
+	}
+	if obj.ContentType == "" || obj.ContentType == "application/octet-stream" {
+		obj.ContentType = img.ContentType
+	}
+	return obj, nil
+}
+
+func (s *Service) PresignImage(
+	ctx context.Context, img *models.ProductImage, size string, expiry time.Duration,
+) (string, error) {
+	key, err := s.RenditionKey(img, size)
+	if err != nil {
+		return "", err
+	}
+	url, err := s.storage.PresignGet(ctx, key, expiry)
+	if err != nil {
+		if errors.Is(err, storage.ErrPresignUnsupported) {
+			return "", ErrPresignUnsupported
+		}
+		return "", errors.New("failed to sign image url")
+	}
+	return url, nil
+}
+
+// deleteObjects removes objects no image points to anymore. A delete that
+// keeps failing leaves an orphaned object, its key is logged so it can be
+// removed from the bucket by hand.
+func (s *Service) deleteObjects(ctx context.Context, keys []string) {
+	// the image row is gone already, a cancelled request must not keep its objects
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		var err error
+		for attempt := 1; attempt <= deleteAttempts; attempt++ {
+			if err = s.storage.Delete(ctx, key); err == nil {
+				break
+			}
+			if attempt < deleteAttempts {
+				time.Sleep(time.Duration(attempt) * deleteBackoff)
+			}
+		}
+		if err != nil {
+			log.Printf("orphaned image object %s: %v", key, err)
+		}
+	}
+}
+
+// renditionKey places a rendition next to its original,
+// products/1/images/<uuid>.jpg becomes products/1/images/<uuid>_thumb.jpg.
+func renditionKey(key, size string) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + size + ext
+}
+
+func mapError(err error, msg string) error {
+	switch {
+	case errors.Is(err, images.ErrProductNotFound):
+		return ErrProductNotFound
+	case errors.Is(err, images.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, images.ErrInvalidOrder):
+		return ErrInvalidOrder
+	}
+	return errors.New(msg)
+}
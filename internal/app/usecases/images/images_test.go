@@ -0,0 +1,243 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"image"
+	"image/jpeg"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/images"
+	"prodigo/pkg/imageproc"
+	"prodigo/pkg/storage"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	imageKey       = regexp.MustCompile(`^products/1/images/[0-9a-f-]{36}\.jpg$`)
+	thumbKey       = regexp.MustCompile(`^products/1/images/[0-9a-f-]{36}_thumb\.jpg$`)
+	testRenditions = []imageproc.Rendition{{Name: "thumb", Size: 100}, {Name: "large", Size: 1000}}
+)
+
+func newService(repo images.Repository, store storage.Storage) ServiceInterface {
+	return New(repo, store, imageproc.New(testRenditions, 0))
+}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil))
+	return buf.Bytes()
+}
+
+func TestService_AddImage(t *testing.T) {
+	t.Run("stores original and renditions", func(t *testing.T) {
+		mockRepo := new(images.MockRepo)
+		mockStorage := new(storage.MockStorage)
+		service := newService(mockRepo, mockStorage)
+		defer mockRepo.AssertExpectations(t)
+		defer mockStorage.AssertExpectations(t)
+
+		mockStorage.On("Put", mock.Anything, mock.MatchedBy(imageKey.MatchString), mock.Anything, mock.Anything, "image/jpeg").
+			Return(nil).Once()
+		mockStorage.On("Put", mock.Anything, mock.MatchedBy(thumbKey.MatchString), mock.Anything, mock.Anything, "image/jpeg").
+			Return(nil).Once()
+		mockRepo.On("AddImage", mock.Anything, mock.AnythingOfType("*models.ProductImage")).Return(nil).Once()
+
+		img := &models.ProductImage{ProductID: 1, ContentType: "image/png"}
+		err := service.AddImage(context.Background(), img, bytes.NewReader(testJPEG(t, 400, 300)))
+		assert.NoError(t, err)
+		assert.Regexp(t, imageKey, img.Key)
+		assert.Equal(t, "image/jpeg", img.ContentType)
+		assert.Equal(t, 400, img.Width)
+		assert.Equal(t, 300, img.Height)
+		assert.Equal(t, []string{"thumb"}, img.Renditions)
+	})
+	t.Run("unsupported type", func(t *testing.T) {
+		mockStorage := new(storage.MockStorage)
+		service := newService(new(images.MockRepo), mockStorage)
+
+		err := service.AddImage(context.Background(),
+			&models.ProductImage{ProductID: 1, ContentType: "image/gif"}, strings.NewReader("gif"))
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+		mockStorage.AssertNotCalled(t, "Put")
+	})
+	t.Run("undecodable image", func(t *testing.T) {
+		mockStorage := new(storage.MockStorage)
+		service := newService(new(images.MockRepo), mockStorage)
+
+		err := service.AddImage(context.Background(),
+			&models.ProductImage{ProductID: 1, ContentType: "image/png"}, strings.NewReader("\x89PNG\r\n\x1a\n"))
+		assert.ErrorIs(t, err, ErrInvalidImage)
+		mockStorage.AssertNotCalled(t, "Put")
+	})
+	t.Run("orphaned objects are removed", func(t *testing.T) {
+		mockRepo := new(images.MockRepo)
+		mockStorage := new(storage.MockStorage)
+		service := newService(mockRepo, mockStorage)
+		defer mockStorage.AssertExpectations(t)
+
+		mockStorage.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(nil).Twice()
+		mockRepo.On("AddImage", mock.Anything, mock.Anything).Return(images.ErrProductNotFound).Once()
+		mockStorage.On("Delete", mock.Anything, mock.MatchedBy(imageKey.MatchString)).Return(nil).Once()
+		mockStorage.On("Delete", mock.Anything, mock.MatchedBy(thumbKey.MatchString)).Return(nil).Once()
+
+		err := service.AddImage(context.Background(),
+			&models.ProductImage{ProductID: 1, ContentType: "image/jpeg"}, bytes.NewReader(testJPEG(t, 400, 300)))
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+	t.Run("storage error", func(t *testing.T) {
+		mockRepo := new(images.MockRepo)
+		mockStorage := new(storage.MockStorage)
+		service := newService(mockRepo, mockStorage)
+		defer mockStorage.AssertExpectations(t)
+
+		mockStorage.On("Put", mock.Anything, mock.MatchedBy(imageKey.MatchString), mock.Anything, mock.Anything, mock.Anything).
+			Return(nil).Once()
+		mockStorage.On("Put", mock.Anything, mock.MatchedBy(thumbKey.MatchString), mock.Anything, mock.Anything, mock.Anything).
+			Return(errors.New("disk full")).Once()
+		mockStorage.On("Delete", mock.Anything, mock.MatchedBy(imageKey.MatchString)).Return(nil).Once()
+
+		err := service.AddImage(context.Background(),
+			&models.ProductImage{ProductID: 1, ContentType: "image/jpeg"}, bytes.NewReader(testJPEG(t, 400, 300)))
+		assert.EqualError(t, err, "failed to store image")
+		mockRepo.AssertNotCalled(t, "AddImage")
+	})
+}
+
+func TestService_ReorderImages(t *testing.T) {
+	mockRepo := new(images.MockRepo)
+	service := newService(mockRepo, new(storage.MockStorage))
+
+	mockRepo.On("ReorderImages", mock.Anything, int64(1), []int64{2, 1}).Return(images.ErrInvalidOrder).Once()
+
+	err := service.ReorderImages(context.Background(), 1, []int64{2, 1})
+	assert.ErrorIs(t, err, ErrInvalidOrder)
+}
+
+func TestService_SetPrimary(t *testing.T) {
+	mockRepo := new(images.MockRepo)
+	service := newService(mockRepo, new(storage.MockStorage))
+
+	mockRepo.On("SetPrimary", mock.Anything, int64(1), int64(2)).Return(errors.New("db error")).Once()
+
+	err := service.SetPrimary(context.Background(), 1, 2)
+	assert.EqualError(t, err, "failed to set primary image")
+}
+
+func TestService_DeleteImage(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(images.MockRepo)
+		mockStorage := new(storage.MockStorage)
+		service := newService(mockRepo, mockStorage)
+		defer mockStorage.AssertExpectations(t)
+
+		mockRepo.On("DeleteImage", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductImage{Key: "products/1/images/a.png", Renditions: []string{"thumb"}}, nil).Once()
+		mockStorage.On("Delete", mock.Anything, "products/1/images/a.png").Return(nil).Once()
+		mockStorage.On("Delete", mock.Anything, "products/1/images/a_thumb.png").Return(nil).Once()
+
+		assert.NoError(t, service.DeleteImage(context.Background(), 1, 2))
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(images.MockRepo)
+		mockStorage := new(storage.MockStorage)
+		service := newService(mockRepo, mockStorage)
+
+		mockRepo.On("DeleteImage", mock.Anything, int64(1), int64(2)).
+			Return((*models.ProductImage)(nil), images.ErrNotFound).Once()
+
+		err := service.DeleteImage(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, ErrNotFound)
+		mockStorage.AssertNotCalled(t, "Delete")
+	})
+	t.Run("retries a failed object delete", func(t *testing.T) {
+		mockRepo := new(images.MockRepo)
+		mockStorage := new(storage.MockStorage)
+		service := newService(mockRepo, mockStorage)
+		defer mockStorage.AssertExpectations(t)
+
+		mockRepo.On("DeleteImage", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductImage{Key: "products/1/images/a.png"}, nil).Once()
+		mockStorage.On("Delete", mock.Anything, "products/1/images/a.png").Return(errors.New("timeout")).Once()
+		mockStorage.On("Delete", mock.Anything, "products/1/images/a.png").Return(nil).Once()
+
+		assert.NoError(t, service.DeleteImage(context.Background(), 1, 2))
+	})
+	t.Run("gives up on an object that can't be deleted", func(t *testing.T) {
+		mockRepo := new(images.MockRepo)
+		mockStorage := new(storage.MockStorage)
+		service := newService(mockRepo, mockStorage)
+		defer mockStorage.AssertExpectations(t)
+
+		mockRepo.On("DeleteImage", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductImage{Key: "products/1/images/a.png"}, nil).Once()
+		mockStorage.On("Delete", mock.Anything, "products/1/images/a.png").
+			Return(errors.New("access denied")).Times(deleteAttempts)
+
+		assert.NoError(t, service.DeleteImage(context.Background(), 1, 2))
+	})
+}
+
+func TestService_OpenImage(t *testing.T) {
+	t.Run("falls back to the recorded content type", func(t *testing.T) {
+		mockStorage := new(storage.MockStorage)
+		service := newService(new(images.MockRepo), mockStorage)
+
+		mockStorage.On("Get", mock.Anything, "products/1/images/a.png").
+			Return(&storage.Object{ContentType: "application/octet-stream"}, nil).Once()
+
+		obj, err := service.OpenImage(context.Background(),
+			&models.ProductImage{Key: "products/1/images/a.png", ContentType: "image/png"}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", obj.ContentType)
+	})
+	t.Run("missing object", func(t *testing.T) {
+		mockStorage := new(storage.MockStorage)
+		service := newService(new(images.MockRepo), mockStorage)
+
+		mockStorage.On("Get", mock.Anything, mock.Anything).Return((*storage.Object)(nil), storage.ErrNotFound).Once()
+
+		_, err := service.OpenImage(context.Background(), &models.ProductImage{Key: "products/1/images/a.png"}, "")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestService_PresignImage(t *testing.T) {
+	mockStorage := new(storage.MockStorage)
+	service := newService(new(images.MockRepo), mockStorage)
+
+	mockStorage.On("PresignGet", mock.Anything, mock.Anything, time.Minute).
+		Return("", storage.ErrPresignUnsupported).Once()
+
+	_, err := service.PresignImage(context.Background(), &models.ProductImage{Key: "k"}, "", time.Minute)
+	assert.ErrorIs(t, err, ErrPresignUnsupported)
+}
+
+func TestService_RenditionKey(t *testing.T) {
+	service := newService(new(images.MockRepo), new(storage.MockStorage))
+	img := &models.ProductImage{Key: "products/1/images/a.jpg", Renditions: []string{"thumb"}}
+
+	tests := []struct {
+		size, want string
+		err        error
+	}{
+		{"", "products/1/images/a.jpg", nil},
+		{"original", "products/1/images/a.jpg", nil},
+		{"thumb", "products/1/images/a_thumb.jpg", nil},
+		{"large", "products/1/images/a.jpg", nil},
+		{"huge", "", ErrUnknownSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			key, err := service.RenditionKey(img, tt.size)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.want, key)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package images
+
+import (
+	"context"
+	"io"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/storage"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) ListImages(ctx context.Context, productID int64) ([]*models.ProductImage, error) {
+	args := m.Called(ctx, productID)
+	return args.Get(0).([]*models.ProductImage), args.Error(1)
+}
+
+func (m *MockService) GetImage(ctx context.Context, productID, imageID int64) (*models.ProductImage, error) {
+	args := m.Called(ctx, productID, imageID)
+	return args.Get(0).(*models.ProductImage), args.Error(1)
+}
+
+func (m *MockService) GetPrimaryImage(ctx context.Context, productID int64) (*models.ProductImage, error) {
+	args := m.Called(ctx, productID)
+	return args.Get(0).(*models.ProductImage), args.Error(1)
+}
+
+func (m *MockService) AddImage(ctx context.Context, img *models.ProductImage, r io.Reader) error {
+	args := m.Called(ctx, img, r)
+	return args.Error(0)
+}
+
+func (m *MockService) ReorderImages(ctx context.Context, productID int64, imageIDs []int64) error {
+	args := m.Called(ctx, productID, imageIDs)
+	return args.Error(0)
+}
+
+func (m *MockService) SetPrimary(ctx context.Context, productID, imageID int64) error {
+	args := m.Called(ctx, productID, imageID)
+	return args.Error(0)
+}
+
+func (m *MockService) DeleteImage(ctx context.Context, productID, imageID int64) error {
+	args := m.Called(ctx, productID, imageID)
+	return args.Error(0)
+}
+
+func (m *MockService) RenditionKey(img *models.ProductImage, size string) (string, error) {
+	args := m.Called(img, size)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockService) OpenImage(ctx context.Context, img *models.ProductImage, size string) (*storage.Object, error) {
+	args := m.Called(ctx, img, size)
+	return args.Get(0).(*storage.Object), args.Error(1)
+}
+
+func (m *MockService) PresignImage(
+	ctx context.Context, img *models.ProductImage, size string, expiry time.Duration,
+) (string, error) {
+	args := m.Called(ctx, img, size, expiry)
+	return args.String(0), args.Error(1)
+}
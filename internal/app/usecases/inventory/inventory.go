@@ -0,0 +1,136 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/inventory"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/db/postgres"
+	"strings"
+)
+
+type ServiceInterface interface {
+	AdjustStock(ctx context.Context, m *models.StockMovement, quantity int) error
+	GetStockLevel(ctx context.Context, productID int64) (*models.StockLevel, error)
+	ListMovements(ctx context.Context, f *models.StockFilter) (*models.StockPage, error)
+	ListLowStock(ctx context.Context) ([]*models.LowStock, error)
+}
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrVariantNotFound = errors.New("variant not found")
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+type Service struct {
+	repository inventory.Repository
+	tx         postgres.Transactor
+	alerts     alerts.ServiceInterface
+}
+
+func New(repository inventory.Repository, tx postgres.Transactor, alertService alerts.ServiceInterface) ServiceInterface {
+	return &Service{repository: repository, tx: tx, alerts: alertService}
+}
+
+// AdjustStock records a movement of m.Kind over quantity units. A product
+// with variants keeps its stock per variant, so m.VariantID is required
+// for it. Stock never drops below zero, such a movement fails with
+// models.ErrInsufficientStock. A movement that takes the product below
+// its reorder threshold raises a low stock alert.
+func (s *Service) AdjustStock(ctx context.Context, m *models.StockMovement, quantity int) error {
+	delta, err := models.MovementDelta(m.Kind, quantity)
+	if err != nil {
+		return err
+	}
+	m.Delta = delta
+	m.Reason = strings.TrimSpace(m.Reason)
+
+	var lowStock *models.LowStock
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		if m.VariantID == nil {
+			hasVariants, err := s.repository.HasVariants(ctx, m.ProductID)
+			if err != nil {
+				return errors.New("failed to check variants")
+			}
+			if hasVariants {
+				return fmt.Errorf("%w: variant_id is required for a product with variants", models.ErrInvalidMovement)
+			}
+		}
+		if err := s.repository.ApplyMovement(ctx, m); err != nil {
+			return mapError(err, "failed to adjust stock")
+		}
+		var err error
+		lowStock, err = s.alerts.Check(ctx, m.ProductID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.alerts.Notify(ctx, lowStock)
+	return nil
+}
+
+func (s *Service) GetStockLevel(ctx context.Context, productID int64) (*models.StockLevel, error) {
+	level, err := s.repository.StockLevel(ctx, productID)
+	if err != nil {
+		return nil, mapError(err, "failed to get stock level")
+	}
+	return level, nil
+}
+
+// ListMovements returns a page of the stock history of a product, newest
+// first. BeforeID of the page continues with older movements.
+func (s *Service) ListMovements(ctx context.Context, f *models.StockFilter) (*models.StockPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	// one extra row tells whether another page exists
+	query := *f
+	query.Limit = limit + 1
+
+	movements, err := s.repository.ListMovements(ctx, &query)
+	if err != nil {
+		return nil, errors.New("failed to get stock history")
+	}
+
+	page := &models.StockPage{Items: movements, HasMore: len(movements) > limit}
+	if page.HasMore {
+		page.Items = movements[:limit]
+		page.BeforeID = page.Items[limit-1].ID
+	}
+	if page.Items == nil {
+		page.Items = []*models.StockMovement{}
+	}
+	return page, nil
+}
+
+// ListLowStock returns the products below their reorder threshold, the
+// emptiest first.
+func (s *Service) ListLowStock(ctx context.Context) ([]*models.LowStock, error) {
+	products, err := s.repository.ListLowStock(ctx)
+	if err != nil {
+		return nil, errors.New("failed to get low stock products")
+	}
+	return products, nil
+}
+
+func mapError(err error, msg string) error {
+	switch {
+	case errors.Is(err, inventory.ErrProductNotFound):
+		return ErrProductNotFound
+	case errors.Is(err, inventory.ErrVariantNotFound):
+		return ErrVariantNotFound
+	case errors.Is(err, inventory.ErrInsufficientStock):
+		return models.ErrInsufficientStock
+	}
+	return errors.New(msg)
+}
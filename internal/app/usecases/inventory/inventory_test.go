@@ -0,0 +1,211 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/inventory"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/db/postgres"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newService(repo inventory.Repository) *Service {
+	mockAlerts := new(alerts.MockService)
+	mockAlerts.On("Check", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockAlerts.On("Notify", mock.Anything, mock.Anything).Maybe()
+	return &Service{repository: repo, tx: postgres.MockTransactor{}, alerts: mockAlerts}
+}
+
+func TestService_AdjustStock(t *testing.T) {
+	t.Run("sale takes stock away", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo)
+
+		mockRepo.On("HasVariants", mock.Anything, int64(1)).Return(false, nil)
+		mockRepo.On("ApplyMovement", mock.Anything, mock.MatchedBy(func(m *models.StockMovement) bool {
+			return m.Delta == -3 && m.Reason == "order 42"
+		})).Return(nil)
+
+		m := &models.StockMovement{ProductID: 1, Kind: models.MovementSale, Reason: " order 42 "}
+		assert.NoError(t, service.AdjustStock(context.Background(), m, 3))
+	})
+	t.Run("variant movement skips the variants check", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo)
+
+		variantID := int64(2)
+		m := &models.StockMovement{ProductID: 1, VariantID: &variantID, Kind: models.MovementReceipt}
+		mockRepo.On("ApplyMovement", mock.Anything, m).Return(nil)
+
+		assert.NoError(t, service.AdjustStock(context.Background(), m, 5))
+		assert.Equal(t, 5, m.Delta)
+		mockRepo.AssertNotCalled(t, "HasVariants", mock.Anything, mock.Anything)
+	})
+	t.Run("invalid quantity", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		err := service.AdjustStock(context.Background(), &models.StockMovement{ProductID: 1, Kind: models.MovementReturn}, -1)
+		assert.ErrorIs(t, err, models.ErrInvalidMovement)
+		mockRepo.AssertNotCalled(t, "ApplyMovement", mock.Anything, mock.Anything)
+	})
+	t.Run("variant required", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("HasVariants", mock.Anything, int64(1)).Return(true, nil)
+
+		err := service.AdjustStock(context.Background(), &models.StockMovement{ProductID: 1, Kind: models.MovementReceipt}, 1)
+		assert.ErrorIs(t, err, models.ErrInvalidMovement)
+		mockRepo.AssertNotCalled(t, "ApplyMovement", mock.Anything, mock.Anything)
+	})
+	t.Run("insufficient stock", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("HasVariants", mock.Anything, int64(1)).Return(false, nil)
+		mockRepo.On("ApplyMovement", mock.Anything, mock.Anything).Return(inventory.ErrInsufficientStock)
+
+		err := service.AdjustStock(context.Background(), &models.StockMovement{ProductID: 1, Kind: models.MovementSale}, 9)
+		assert.ErrorIs(t, err, models.ErrInsufficientStock)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("HasVariants", mock.Anything, int64(1)).Return(false, nil)
+		mockRepo.On("ApplyMovement", mock.Anything, mock.Anything).Return(inventory.ErrProductNotFound)
+
+		err := service.AdjustStock(context.Background(), &models.StockMovement{ProductID: 1, Kind: models.MovementAdjustment}, -1)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+}
+
+func TestService_AdjustStock_LowStock(t *testing.T) {
+	t.Run("alerts after commit", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		mockAlerts := new(alerts.MockService)
+		defer mockAlerts.AssertExpectations(t)
+		service := &Service{repository: mockRepo, tx: postgres.MockTransactor{}, alerts: mockAlerts}
+
+		ls := &models.LowStock{ProductID: 1, OnHand: 1, Threshold: 5}
+		mockRepo.On("HasVariants", mock.Anything, int64(1)).Return(false, nil)
+		mockRepo.On("ApplyMovement", mock.Anything, mock.Anything).Return(nil)
+		mockAlerts.On("Check", mock.Anything, int64(1)).Return(ls, nil)
+		mockAlerts.On("Notify", mock.Anything, ls).Return()
+
+		m := &models.StockMovement{ProductID: 1, Kind: models.MovementSale}
+		assert.NoError(t, service.AdjustStock(context.Background(), m, 4))
+	})
+	t.Run("check fails the movement", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		mockAlerts := new(alerts.MockService)
+		service := &Service{repository: mockRepo, tx: postgres.MockTransactor{}, alerts: mockAlerts}
+
+		mockRepo.On("HasVariants", mock.Anything, int64(1)).Return(false, nil)
+		mockRepo.On("ApplyMovement", mock.Anything, mock.Anything).Return(nil)
+		mockAlerts.On("Check", mock.Anything, int64(1)).Return(nil, errors.New("failed to check low stock"))
+
+		m := &models.StockMovement{ProductID: 1, Kind: models.MovementSale}
+		assert.EqualError(t, service.AdjustStock(context.Background(), m, 4), "failed to check low stock")
+		mockAlerts.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	})
+}
+
+func TestService_GetStockLevel(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("StockLevel", mock.Anything, int64(1)).Return(&models.StockLevel{ProductID: 1, OnHand: 4}, nil)
+
+		level, err := service.GetStockLevel(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, level.OnHand)
+	})
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("StockLevel", mock.Anything, int64(1)).Return(nil, errors.New("db error"))
+
+		_, err := service.GetStockLevel(context.Background(), 1)
+		assert.EqualError(t, err, "failed to get stock level")
+	})
+}
+
+func TestService_ListMovements(t *testing.T) {
+	t.Run("has more", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo)
+
+		mockRepo.On("ListMovements", mock.Anything, mock.MatchedBy(func(f *models.StockFilter) bool {
+			return f.Limit == 3
+		})).Return([]*models.StockMovement{{ID: 9}, {ID: 8}, {ID: 7}}, nil)
+
+		page, err := service.ListMovements(context.Background(), &models.StockFilter{ProductID: 1, Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, page.Items, 2)
+		assert.True(t, page.HasMore)
+		assert.Equal(t, int64(8), page.BeforeID)
+	})
+	t.Run("default and max limit", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo)
+
+		mockRepo.On("ListMovements", mock.Anything, mock.MatchedBy(func(f *models.StockFilter) bool {
+			return f.Limit == DefaultPageSize+1
+		})).Return(nil, nil).Once()
+		mockRepo.On("ListMovements", mock.Anything, mock.MatchedBy(func(f *models.StockFilter) bool {
+			return f.Limit == MaxPageSize+1
+		})).Return(nil, nil).Once()
+
+		page, err := service.ListMovements(context.Background(), &models.StockFilter{ProductID: 1})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Items)
+		assert.NotNil(t, page.Items)
+
+		_, err = service.ListMovements(context.Background(), &models.StockFilter{ProductID: 1, Limit: 1000})
+		assert.NoError(t, err)
+	})
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("ListMovements", mock.Anything, mock.Anything).Return(nil, errors.New("db error"))
+
+		_, err := service.ListMovements(context.Background(), &models.StockFilter{ProductID: 1})
+		assert.EqualError(t, err, "failed to get stock history")
+	})
+}
+
+func TestService_ListLowStock(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		products := []*models.LowStock{{ProductID: 1}}
+		mockRepo.On("ListLowStock", mock.Anything).Return(products, nil)
+
+		got, err := service.ListLowStock(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, products, got)
+	})
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(inventory.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("ListLowStock", mock.Anything).Return(nil, errors.New("db down"))
+
+		_, err := service.ListLowStock(context.Background())
+		assert.EqualError(t, err, "failed to get low stock products")
+	})
+}
@@ -0,0 +1,41 @@
+package inventory
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) AdjustStock(ctx context.Context, sm *models.StockMovement, quantity int) error {
+	args := m.Called(ctx, sm, quantity)
+	return args.Error(0)
+}
+
+func (m *MockService) GetStockLevel(ctx context.Context, productID int64) (*models.StockLevel, error) {
+	args := m.Called(ctx, productID)
+	if level, ok := args.Get(0).(*models.StockLevel); ok {
+		return level, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) ListMovements(ctx context.Context, f *models.StockFilter) (*models.StockPage, error) {
+	args := m.Called(ctx, f)
+	if page, ok := args.Get(0).(*models.StockPage); ok {
+		return page, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) ListLowStock(ctx context.Context) ([]*models.LowStock, error) {
+	args := m.Called(ctx)
+	if products, ok := args.Get(0).([]*models.LowStock); ok {
+		return products, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
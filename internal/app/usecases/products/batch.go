@@ -0,0 +1,117 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+)
+
+// BatchProducts runs the operations of b in order and reports every item.
+// With b.AllOrNothing the first failing item rolls the whole batch back
+// and the other items are reported as models.ErrBatchRolledBack. Otherwise
+// every item commits on its own and a failure does not stop the rest.
+// Low stock alerts go out once their items are committed.
+func (s *Service) BatchProducts(ctx context.Context, b *models.Batch) (*models.BatchReport, error) {
+	if err := models.CheckBatch(b); err != nil {
+		return nil, err
+	}
+
+	report := &models.BatchReport{Results: []models.BatchResult{}}
+	for _, op := range b.Operations {
+		for _, id := range op.IDs {
+			report.Results = append(report.Results, models.BatchResult{Action: op.Action, ID: id})
+		}
+	}
+
+	var (
+		errs     []error
+		lowStock []*models.LowStock
+		failed   int
+	)
+	// a retried transaction runs run again, so it starts over every time
+	// and the report is only filled in once the batch is done
+	run := func(ctx context.Context) error {
+		errs, lowStock, failed = make([]error, len(report.Results)), nil, -1
+		i := 0
+		for _, op := range b.Operations {
+			for _, id := range op.IDs {
+				alert, err := s.batchItem(ctx, &op, id)
+				if err != nil {
+					errs[i] = err
+					if b.AllOrNothing {
+						failed = i
+						return err
+					}
+				} else if alert != nil {
+					lowStock = append(lowStock, alert)
+				}
+				i++
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if b.AllOrNothing {
+		err = s.inTx(ctx, run)
+		if errors.Is(err, ErrVersionConflict) && failed >= 0 {
+			errs[failed] = err
+		}
+	} else {
+		err = run(ctx)
+	}
+	if err != nil {
+		if failed < 0 {
+			return nil, err
+		}
+		report.RolledBack = true
+		lowStock = nil
+		for i := range errs {
+			if i != failed {
+				errs[i] = models.ErrBatchRolledBack
+			}
+		}
+	}
+
+	for i, err := range errs {
+		report.Results[i].Err = err
+	}
+	for i := range report.Results {
+		if r := &report.Results[i]; r.Err != nil {
+			r.Error = r.Err.Error()
+			report.Failed++
+		} else {
+			report.Succeeded++
+		}
+	}
+	for _, alert := range lowStock {
+		s.alerts.Notify(ctx, alert)
+	}
+	return report, nil
+}
+
+// batchItem applies op to product id in a transaction of its own, or in
+// the one of the batch when ctx carries it.
+func (s *Service) batchItem(ctx context.Context, op *models.BatchOperation, id int64) (*models.LowStock, error) {
+	var lowStock *models.LowStock
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		switch op.Action {
+		case models.BatchActionUpdate:
+			p := *op.Fields
+			p.ID = id
+			if err := s.updateProduct(ctx, &p); err != nil {
+				return err
+			}
+			var err error
+			lowStock, err = s.checkLowStock(ctx, &p)
+			return err
+		case models.BatchActionStatus:
+			return s.updateProductStatus(ctx, id, op.Status, 0)
+		case models.BatchActionDelete:
+			return s.deleteProduct(ctx, id, 0)
+		default:
+			return s.restoreProduct(ctx, id, 0)
+		}
+	})
+	return lowStock, err
+}
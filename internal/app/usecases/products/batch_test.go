@@ -0,0 +1,161 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
+	"prodigo/internal/app/repository/products"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/db/postgres"
+	"testing"
+)
+
+type inTxKey struct{}
+
+// retryOnceTx runs a failed transaction a second time like WithinTx after
+// a serialization failure, calls nested in it join it.
+type retryOnceTx struct {
+	postgres.MockTransactor
+}
+
+func (t retryOnceTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return t.WithinTx(ctx, fn)
+}
+
+func (retryOnceTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error, _ ...postgres.TxOption) error {
+	if ctx.Value(inTxKey{}) != nil {
+		return fn(ctx)
+	}
+	ctx = context.WithValue(ctx, inTxKey{}, true)
+	if err := fn(ctx); err == nil {
+		return nil
+	}
+	return fn(ctx)
+}
+
+func TestService_BatchProducts(t *testing.T) {
+	t.Run("best effort goes on after a failure", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Status: models.StatusDraft}, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.ID == 1 && p.Status == models.StatusActive
+		})).Return(nil).Once()
+		mockRepo.On("GetProductByID", mock.Anything, int64(2)).
+			Return((*models.Product)(nil), products.ErrNotFound).Once()
+		mockRepo.On("GetProductByID", mock.Anything, int64(3)).Return(&models.Product{ID: 3, Version: 4}, nil).Once()
+		mockRepo.On("DeleteProduct", mock.Anything, int64(3), int64(4)).Return(nil).Once()
+
+		report, err := service.BatchProducts(context.Background(), &models.Batch{Operations: []models.BatchOperation{
+			{Action: models.BatchActionStatus, Status: models.StatusActive, IDs: []int64{1, 2}},
+			{Action: models.BatchActionDelete, IDs: []int64{3}},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Succeeded)
+		assert.Equal(t, 1, report.Failed)
+		assert.False(t, report.RolledBack)
+		assert.NoError(t, report.Results[0].Err)
+		assert.ErrorIs(t, report.Results[1].Err, ErrNotFound)
+		assert.Equal(t, "product not found", report.Results[1].Error)
+		assert.Equal(t, models.BatchResult{Action: models.BatchActionDelete, ID: 3}, report.Results[2])
+	})
+	t.Run("all or nothing stops at the first failure", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Brand: "Old", Version: 2}, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.ID == 1 && p.Brand == "Acme" && p.Version == 2
+		})).Return(nil).Once()
+		mockRepo.On("GetProductByID", mock.Anything, int64(2)).
+			Return((*models.Product)(nil), products.ErrNotFound).Once()
+
+		report, err := service.BatchProducts(context.Background(), &models.Batch{
+			AllOrNothing: true,
+			Operations: []models.BatchOperation{
+				{Action: models.BatchActionUpdate, Fields: &models.Product{Brand: "Acme"}, IDs: []int64{1, 2}},
+				{Action: models.BatchActionRestore, IDs: []int64{3}},
+			},
+		})
+		require.NoError(t, err)
+		assert.True(t, report.RolledBack)
+		assert.Equal(t, 0, report.Succeeded)
+		assert.Equal(t, 3, report.Failed)
+		assert.ErrorIs(t, report.Results[0].Err, models.ErrBatchRolledBack)
+		assert.ErrorIs(t, report.Results[1].Err, ErrNotFound)
+		assert.ErrorIs(t, report.Results[2].Err, models.ErrBatchRolledBack)
+		mockRepo.AssertNotCalled(t, "RestoreProduct", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("retried batch reports the attempt that committed", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		mockAlerts := new(alerts.MockService)
+		auditRepo := new(audit.MockRepo)
+		service := &Service{repository: mockRepo, audit: auditRepo, tx: retryOnceTx{}, alerts: mockAlerts}
+		defer mockRepo.AssertExpectations(t)
+		defer mockAlerts.AssertExpectations(t)
+		threshold := 5
+		alert := &models.LowStock{ProductID: 1, OnHand: 2, Threshold: threshold}
+
+		auditRepo.On("Record", mock.Anything, mock.Anything).Return(nil)
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1}, nil).Twice()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.Anything).Return(nil).Times(3)
+		mockAlerts.On("Check", mock.Anything, int64(1)).Return(alert, nil).Twice()
+		mockRepo.On("GetProductByID", mock.Anything, int64(2)).
+			Return((*models.Product)(nil), errors.New("deadlock detected")).Once()
+		mockRepo.On("GetProductByID", mock.Anything, int64(2)).Return(&models.Product{ID: 2}, nil).Once()
+		mockAlerts.On("Notify", mock.Anything, alert).Once()
+
+		report, err := service.BatchProducts(context.Background(), &models.Batch{
+			AllOrNothing: true,
+			Operations: []models.BatchOperation{
+				{Action: models.BatchActionUpdate, Fields: &models.Product{ReorderThreshold: &threshold}, IDs: []int64{1}},
+				{Action: models.BatchActionUpdate, Fields: &models.Product{Brand: "Acme"}, IDs: []int64{2}},
+			},
+		})
+		require.NoError(t, err)
+		assert.False(t, report.RolledBack)
+		assert.Equal(t, 2, report.Succeeded)
+		assert.Equal(t, 0, report.Failed)
+		for _, r := range report.Results {
+			assert.NoError(t, r.Err)
+			assert.Empty(t, r.Error)
+		}
+	})
+	t.Run("invalid batch", func(t *testing.T) {
+		service := newService(new(products.MockRepo))
+		many := make([]int64, models.MaxBatchItems+1)
+		for i := range many {
+			many[i] = int64(i + 1)
+		}
+
+		tests := []*models.Batch{
+			{},
+			{Operations: []models.BatchOperation{{Action: "publish", IDs: []int64{1}}}},
+			{Operations: []models.BatchOperation{{Action: models.BatchActionDelete}}},
+			{Operations: []models.BatchOperation{{Action: models.BatchActionUpdate, IDs: []int64{1}}}},
+			{Operations: []models.BatchOperation{
+				{Action: models.BatchActionUpdate, Fields: &models.Product{Slug: "watch"}, IDs: []int64{1, 2}},
+			}},
+			{Operations: []models.BatchOperation{{Action: models.BatchActionDelete, Status: "active", IDs: []int64{1}}}},
+			{Operations: []models.BatchOperation{{Action: models.BatchActionRestore, IDs: many}}},
+		}
+		for _, b := range tests {
+			_, err := service.BatchProducts(context.Background(), b)
+			assert.ErrorIs(t, err, models.ErrInvalidBatch)
+		}
+
+		_, err := service.BatchProducts(context.Background(), &models.Batch{Operations: []models.BatchOperation{
+			{Action: models.BatchActionStatus, Status: "sold", IDs: []int64{1}},
+		}})
+		assert.ErrorIs(t, err, models.ErrInvalidStatus)
+	})
+}
@@ -0,0 +1,136 @@
+package products
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"prodigo/internal/app/models"
+	"prodigo/pkg/xlsx"
+	"strconv"
+	"time"
+)
+
+const exportBufferSize = 32 * 1024
+
+var exportColumns = []string{
+	"id", "title", "category_id", "category_name", "price", "currency", "quantity",
+	"image", "status", "created_at", "updated_at", "version", "sku", "gtin", "slug",
+}
+
+// exporter encodes products one at a time, close writes whatever the
+// format needs after the last row.
+type exporter interface {
+	write(p *models.Product) error
+	close() error
+}
+
+// ExportProducts writes every product matching fs to w in the given format.
+// Rows are encoded as they are read. Output is buffered, so a query that
+// fails straight away can still be answered with an error status.
+func (s *Service) ExportProducts(ctx context.Context, fs *models.ProductFilterSearch, format string, w io.Writer) error {
+	if err := fs.CheckSort(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriterSize(w, exportBufferSize)
+	var (
+		exp exporter
+		err error
+	)
+	switch format {
+	case models.ExportFormatCSV:
+		exp, err = newCSVExporter(bw)
+	case models.ExportFormatNDJSON:
+		exp = &ndjsonExporter{enc: json.NewEncoder(bw)}
+	case models.ExportFormatXLSX:
+		exp, err = newXLSXExporter(bw)
+	default:
+		return ErrUnsupportedFormat
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start export: %w", err)
+	}
+
+	if err = s.repository.ExportProducts(ctx, fs, exp.write); err != nil {
+		return errors.New("failed to export products")
+	}
+	if err = exp.close(); err != nil {
+		return fmt.Errorf("failed to finish export: %w", err)
+	}
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("failed to finish export: %w", err)
+	}
+	return nil
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) (*csvExporter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return &csvExporter{w: cw}, nil
+}
+
+func (e *csvExporter) write(p *models.Product) error {
+	return e.w.Write([]string{
+		strconv.FormatInt(p.ID, 10), p.Title, strconv.Itoa(p.CategoryID), p.CategoryName,
+		p.Price.Decimal(), p.Price.Currency, strconv.Itoa(p.Quantity), p.Image, p.Status,
+		p.CreatedAt.Format(time.RFC3339), p.UpdatedAt.Format(time.RFC3339), strconv.FormatInt(p.Version, 10),
+		p.SKU, p.GTIN, p.Slug,
+	})
+}
+
+func (e *csvExporter) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) write(p *models.Product) error {
+	return e.enc.Encode(p)
+}
+
+func (e *ndjsonExporter) close() error {
+	return nil
+}
+
+type xlsxExporter struct {
+	w *xlsx.Writer
+}
+
+func newXLSXExporter(w io.Writer) (*xlsxExporter, error) {
+	xw, err := xlsx.NewWriter(w, "Products")
+	if err != nil {
+		return nil, err
+	}
+	header := make([]any, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column
+	}
+	if err = xw.WriteRow(header...); err != nil {
+		return nil, err
+	}
+	return &xlsxExporter{w: xw}, nil
+}
+
+func (e *xlsxExporter) write(p *models.Product) error {
+	return e.w.WriteRow(
+		p.ID, p.Title, p.CategoryID, p.CategoryName, p.Price.Decimal(), p.Price.Currency, p.Quantity,
+		p.Image, p.Status, p.CreatedAt, p.UpdatedAt, p.Version, p.SKU, p.GTIN, p.Slug,
+	)
+}
+
+func (e *xlsxExporter) close() error {
+	return e.w.Close()
+}
@@ -0,0 +1,99 @@
+package products
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/products"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportRows(rows ...*models.Product) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		fn := args.Get(2).(func(*models.Product) error)
+		for _, p := range rows {
+			if err := fn(p); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestService_ExportProducts(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	row := &models.Product{
+		ID: 1, Title: "watch, gold", CategoryID: 2, CategoryName: "watches",
+		Price: models.Money{Currency: "USD", Amount: 1500}, Quantity: 3,
+		Status: "available", CreatedAt: created, UpdatedAt: created, Version: 4, SKU: "W-1", Slug: "watch-gold",
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("ExportProducts", mock.Anything, mock.Anything, mock.Anything).
+			Run(exportRows(row)).Return(nil).Once()
+
+		var buf bytes.Buffer
+		err := service.ExportProducts(context.Background(), &models.ProductFilterSearch{}, models.ExportFormatCSV, &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, "id,title,category_id,category_name,price,currency,quantity,image,status,created_at,updated_at,version,sku,gtin,slug\n"+
+			`1,"watch, gold",2,watches,15.00,USD,3,,available,2024-05-01T10:00:00Z,2024-05-01T10:00:00Z,4,W-1,,watch-gold`+"\n", buf.String())
+	})
+	t.Run("ndjson", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("ExportProducts", mock.Anything, mock.Anything, mock.Anything).
+			Run(exportRows(row, row)).Return(nil).Once()
+
+		var buf bytes.Buffer
+		err := service.ExportProducts(context.Background(), &models.ProductFilterSearch{}, models.ExportFormatNDJSON, &buf)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"category_name":"watches"`)
+	})
+	t.Run("xlsx", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("ExportProducts", mock.Anything, mock.Anything, mock.Anything).
+			Run(exportRows(row)).Return(nil).Once()
+
+		var buf bytes.Buffer
+		err := service.ExportProducts(context.Background(), &models.ProductFilterSearch{}, models.ExportFormatXLSX, &buf)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("PK")))
+	})
+	t.Run("nothing written when the query fails", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("ExportProducts", mock.Anything, mock.Anything, mock.Anything).
+			Return(errors.New("db error")).Once()
+
+		var buf bytes.Buffer
+		err := service.ExportProducts(context.Background(), &models.ProductFilterSearch{}, models.ExportFormatXLSX, &buf)
+		assert.EqualError(t, err, "failed to export products")
+		assert.Zero(t, buf.Len())
+	})
+	t.Run("unsupported format", func(t *testing.T) {
+		service := newService(new(products.MockRepo))
+
+		err := service.ExportProducts(context.Background(), &models.ProductFilterSearch{}, "pdf", &bytes.Buffer{})
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+	t.Run("relevance without search", func(t *testing.T) {
+		service := newService(new(products.MockRepo))
+
+		fs := &models.ProductFilterSearch{Sort: models.ProductSort{Field: models.SortByRelevance, Desc: true}}
+		err := service.ExportProducts(context.Background(), fs, models.ExportFormatCSV, &bytes.Buffer{})
+		assert.ErrorIs(t, err, models.ErrInvalidSort)
+	})
+}
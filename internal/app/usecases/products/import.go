@@ -0,0 +1,367 @@
+package products
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"prodigo/internal/app/models"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported import format")
+	ErrInvalidImport     = errors.New("invalid import file")
+	ErrImportTooLarge    = errors.New("import file has too many rows")
+)
+
+// MaxImportRows bounds a single upload, bigger catalogues are split by the caller.
+const MaxImportRows = 10000
+
+var requiredColumns = []string{"title", "category_id", "price", "currency", "quantity", "status"}
+
+// importRecord is one line of an upload, NDJSON lines decode into it
+// directly so unknown or misspelled keys are reported per row. CSV files
+// carry the price as a decimal amount and a separate currency column.
+type importRecord struct {
+	Title      string       `json:"title"`
+	Image      string       `json:"image"`
+	Status     string       `json:"status"`
+	SKU        string       `json:"sku"`
+	GTIN       string       `json:"gtin"`
+	Slug       string       `json:"slug"`
+	Price      models.Money `json:"price"`
+	CategoryID int          `json:"category_id"`
+	Quantity   int          `json:"quantity"`
+}
+
+type importRow struct {
+	err     error
+	product *models.Product
+	line    int
+}
+
+// ImportProducts validates every row of r and stores the valid ones.
+// Rows are never partially written: with AllOrNothing a single bad row
+// rejects the whole file, and DryRun only reports what would happen. Rows
+// without a slug get one from their title, like CreateProduct does.
+func (s *Service) ImportProducts(
+	ctx context.Context, r io.Reader, opts models.ImportOptions,
+) (*models.ImportReport, error) {
+	var (
+		rows []importRow
+		err  error
+	)
+	switch opts.Format {
+	case models.ImportFormatCSV:
+		rows, err = parseCSV(r)
+	case models.ImportFormatNDJSON:
+		rows, err = parseNDJSON(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	seen := make(map[int]bool)
+	for _, row := range rows {
+		if row.err == nil && row.product.CategoryID > 0 && !seen[row.product.CategoryID] {
+			seen[row.product.CategoryID] = true
+			ids = append(ids, row.product.CategoryID)
+		}
+	}
+	existing := map[int]bool{}
+	if len(ids) > 0 {
+		if existing, err = s.repository.ExistingCategories(ctx, ids); err != nil {
+			return nil, errors.New("failed to check categories")
+		}
+	}
+
+	report := &models.ImportReport{
+		Errors: []models.ImportRowError{},
+		Total:  len(rows),
+		DryRun: opts.DryRun,
+	}
+	var checked []importRow
+	for _, row := range rows {
+		err := row.err
+		if err == nil {
+			err = validateProduct(row.product)
+		}
+		if err == nil && !existing[row.product.CategoryID] {
+			err = fmt.Errorf("category %d does not exist", row.product.CategoryID)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Error: err.Error(), Line: row.line})
+			continue
+		}
+		checked = append(checked, row)
+	}
+
+	valid, rowErrs, err := s.assignIdentifiers(ctx, checked)
+	if err != nil {
+		return nil, err
+	}
+	if len(rowErrs) > 0 {
+		report.Errors = append(report.Errors, rowErrs...)
+		slices.SortFunc(report.Errors, func(a, b models.ImportRowError) int { return a.Line - b.Line })
+	}
+	report.Valid = len(valid)
+
+	if len(valid) == 0 || opts.DryRun || (opts.AllOrNothing && len(report.Errors) > 0) {
+		return report, nil
+	}
+
+	err = s.tx.InTx(ctx, func(ctx context.Context) error {
+		inserted, err := s.repository.ImportProducts(ctx, valid)
+		if err != nil {
+			return errors.New("failed to import products")
+		}
+		report.Inserted = int(inserted)
+
+		// a single entry sums up the import, the rows themselves are in the file
+		summary := map[string]any{"format": opts.Format, "total": report.Total, "inserted": report.Inserted}
+		e, err := models.NewAuditEntry(ctx, models.AuditActionImport, models.AuditEntityProduct, nil, nil, summary)
+		if err != nil {
+			return errors.New("failed to build audit entry")
+		}
+		if err = s.audit.Record(ctx, e); err != nil {
+			return errors.New("failed to record audit entry")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// assignIdentifiers rejects the rows whose SKU, GTIN or slug another
+// product or an earlier row has, and gives the remaining rows without a
+// slug a free one.
+func (s *Service) assignIdentifiers(
+	ctx context.Context, rows []importRow,
+) ([]*models.Product, []models.ImportRowError, error) {
+	if len(rows) == 0 {
+		return nil, nil, nil
+	}
+
+	var skus, gtins, slugs []string
+	for _, row := range rows {
+		p := row.product
+		if p.SKU != "" {
+			skus = append(skus, p.SKU)
+		}
+		if p.GTIN != "" {
+			gtins = append(gtins, p.GTIN)
+		}
+		if p.Slug != "" {
+			slugs = append(slugs, p.Slug)
+		} else {
+			slugs = append(slugs, models.Slugify(p.Title))
+		}
+	}
+
+	takenSKUs, takenGTINs := map[string]bool{}, map[string]bool{}
+	if len(skus) > 0 || len(gtins) > 0 {
+		var err error
+		if takenSKUs, takenGTINs, err = s.repository.TakenIdentifiers(ctx, skus, gtins); err != nil {
+			return nil, nil, errors.New("failed to check identifiers")
+		}
+	}
+	takenSlugs, err := s.repository.TakenSlugs(ctx, slugs)
+	if err != nil {
+		return nil, nil, errors.New("failed to check slugs")
+	}
+
+	var (
+		valid []*models.Product
+		errs  []models.ImportRowError
+	)
+	for _, row := range rows {
+		p := row.product
+		var err error
+		switch {
+		case p.SKU != "" && takenSKUs[p.SKU]:
+			err = fmt.Errorf("sku %q is already in use", p.SKU)
+		case p.GTIN != "" && takenGTINs[p.GTIN]:
+			err = fmt.Errorf("gtin %q is already in use", p.GTIN)
+		case p.Slug != "" && takenSlugs[p.Slug]:
+			err = fmt.Errorf("slug %q is already in use", p.Slug)
+		}
+		if err != nil {
+			errs = append(errs, models.ImportRowError{Error: err.Error(), Line: row.line})
+			continue
+		}
+		takenSKUs[p.SKU] = p.SKU != ""
+		takenGTINs[p.GTIN] = p.GTIN != ""
+		takenSlugs[p.Slug] = p.Slug != ""
+		valid = append(valid, p)
+	}
+	// generated slugs go last so they never take one a later row asks for
+	for _, p := range valid {
+		if p.Slug == "" {
+			p.Slug = models.NextSlug(models.Slugify(p.Title), takenSlugs)
+		}
+	}
+	return valid, errs, nil
+}
+
+// validateProduct checks the rules the products table enforces,
+// so bad rows are reported instead of aborting the whole COPY.
+// The GTIN is normalized to GTIN-14.
+func validateProduct(p *models.Product) error {
+	switch {
+	case strings.TrimSpace(p.Title) == "":
+		return errors.New("title is required")
+	case p.Price.Amount <= 0:
+		return errors.New("price must be positive")
+	case p.Quantity < 0:
+		return errors.New("quantity can not be negative")
+	case strings.TrimSpace(p.Status) == "":
+		return errors.New("status is required")
+	case !models.ValidStatus(p.Status):
+		return fmt.Errorf("invalid status %q", p.Status)
+	case p.CategoryID <= 0:
+		return errors.New("category_id is required")
+	}
+	return checkIdentifiers(p)
+}
+
+func (rec *importRecord) product() *models.Product {
+	return &models.Product{
+		Title:      strings.TrimSpace(rec.Title),
+		Image:      rec.Image,
+		Status:     strings.TrimSpace(rec.Status),
+		CategoryID: rec.CategoryID,
+		Price:      rec.Price,
+		Quantity:   rec.Quantity,
+		SKU:        strings.TrimSpace(rec.SKU),
+		GTIN:       strings.TrimSpace(rec.GTIN),
+		Slug:       strings.TrimSpace(rec.Slug),
+	}
+}
+
+// parseCSV expects a header line naming the columns, their order is free.
+func parseCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read header", ErrInvalidImport)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "title", "category_id", "price", "currency", "quantity", "image", "status", "sku", "gtin", "slug":
+		default:
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, name)
+		}
+		columns[name] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidImport, name)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrImportTooLarge
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, importRow{err: parseErr.Err, line: parseErr.StartLine})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		rec, err := csvRecord(record, columns)
+		rows = append(rows, importRow{err: err, product: rec.product(), line: line})
+	}
+	return rows, nil
+}
+
+func csvRecord(record []string, columns map[string]int) (*importRecord, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	number := func(name string) (int, error) {
+		v := field(name)
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", name, v)
+		}
+		return n, nil
+	}
+
+	rec := &importRecord{
+		Title: field("title"), Image: field("image"), Status: field("status"),
+		SKU: field("sku"), GTIN: field("gtin"), Slug: field("slug"),
+	}
+	var err error
+	if rec.CategoryID, err = number("category_id"); err != nil {
+		return rec, err
+	}
+	if rec.Price, err = models.ParseMoney(field("price"), field("currency")); err != nil {
+		return rec, fmt.Errorf("invalid price: %w", err)
+	}
+	if rec.Quantity, err = number("quantity"); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// parseNDJSON reads one product object per line, blank lines are skipped.
+func parseNDJSON(r io.Reader) ([]importRow, error) {
+	reader := bufio.NewReader(r)
+
+	var rows []importRow
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if len(rows) == MaxImportRows {
+				return nil, ErrImportTooLarge
+			}
+			var rec importRecord
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			decErr := dec.Decode(&rec)
+			if decErr == nil && dec.More() {
+				decErr = errors.New("unexpected data after object")
+			}
+			rows = append(rows, importRow{err: decErr, product: rec.product(), line: line})
+		}
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+	}
+}
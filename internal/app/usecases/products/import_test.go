@@ -0,0 +1,174 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
+	"prodigo/internal/app/repository/products"
+	"prodigo/pkg/db/postgres"
+	"strings"
+	"testing"
+)
+
+const importCSV = `title,category_id,price,currency,quantity,status
+watch,1,15.00,USD,3,active
+,1,1.00,USD,1,active
+phone,7,250.00,USD,10,active
+laptop,1,abc,USD,1,active
+`
+
+func TestService_ImportProducts(t *testing.T) {
+	t.Run("csv reports bad rows and inserts valid ones", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("ExistingCategories", mock.Anything, []int{1, 7}).Return(map[int]bool{1: true}, nil).Once()
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+		mockRepo.On("ImportProducts", mock.Anything, mock.MatchedBy(func(ps []*models.Product) bool {
+			return len(ps) == 1 && ps[0].Title == "watch" && ps[0].Price == models.Money{Currency: "USD", Amount: 1500}
+		})).Return(int64(1), nil).Once()
+
+		report, err := service.ImportProducts(context.Background(), strings.NewReader(importCSV),
+			models.ImportOptions{Format: models.ImportFormatCSV})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, report.Total)
+		assert.Equal(t, 1, report.Valid)
+		assert.Equal(t, 1, report.Inserted)
+		assert.Equal(t, []models.ImportRowError{
+			{Line: 3, Error: "title is required"},
+			{Line: 4, Error: "category 7 does not exist"},
+			{Line: 5, Error: `invalid price: invalid amount "abc" for USD`},
+		}, report.Errors)
+	})
+	t.Run("records one audit entry for the import", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		auditRepo := new(audit.MockRepo)
+		defer auditRepo.AssertExpectations(t)
+
+		service := New(mockRepo, auditRepo, postgres.MockTransactor{}, nil)
+		mockRepo.On("ExistingCategories", mock.Anything, []int{1, 7}).Return(map[int]bool{1: true}, nil).Once()
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+		mockRepo.On("ImportProducts", mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+		auditRepo.On("Record", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+			return e.Action == models.AuditActionImport && e.EntityID == nil &&
+				string(e.After) == `{"format":"csv","inserted":1,"total":4}`
+		})).Return(nil).Once()
+
+		_, err := service.ImportProducts(context.Background(), strings.NewReader(importCSV),
+			models.ImportOptions{Format: models.ImportFormatCSV})
+		assert.NoError(t, err)
+	})
+	t.Run("all or nothing inserts nothing on bad rows", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("ExistingCategories", mock.Anything, mock.Anything).Return(map[int]bool{1: true}, nil).Once()
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+
+		report, err := service.ImportProducts(context.Background(), strings.NewReader(importCSV),
+			models.ImportOptions{Format: models.ImportFormatCSV, AllOrNothing: true})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Valid)
+		assert.Zero(t, report.Inserted)
+		assert.Len(t, report.Errors, 3)
+	})
+	t.Run("dry run only validates", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("ExistingCategories", mock.Anything, []int{1}).Return(map[int]bool{1: true}, nil).Once()
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+
+		body := `{"title":"watch","category_id":1,"price":{"amount":"15.00","currency":"USD"},"quantity":3,"status":"active"}
+
+{"title":"phone","category_id":1,"price":{"amount":"-0.01","currency":"USD"},"quantity":3,"status":"active"}
+{"title":"tv","category_id":1,"price":{"amount":"0.01","currency":"USD"},"quantity":3,"status":"active","colour":"red"}
+{"title":
+`
+		report, err := service.ImportProducts(context.Background(), strings.NewReader(body),
+			models.ImportOptions{Format: models.ImportFormatNDJSON, DryRun: true})
+		assert.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 4, report.Total)
+		assert.Equal(t, 1, report.Valid)
+		assert.Zero(t, report.Inserted)
+		if assert.Len(t, report.Errors, 3) {
+			assert.Equal(t, models.ImportRowError{Line: 3, Error: "price must be positive"}, report.Errors[0])
+			assert.Equal(t, 4, report.Errors[1].Line)
+			assert.Equal(t, 5, report.Errors[2].Line)
+		}
+	})
+	t.Run("identifiers are unique", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		body := `title,category_id,price,currency,quantity,status,sku,gtin,slug
+watch,1,15.00,USD,3,active,W-1,,
+watch,1,15.00,USD,3,active,W-1,,
+watch,1,15.00,USD,3,active,W-2,4006381333931,
+phone,1,15.00,USD,3,active,,,watch
+clock,1,15.00,USD,3,active,,40063812,
+`
+		mockRepo.On("ExistingCategories", mock.Anything, []int{1}).Return(map[int]bool{1: true}, nil).Once()
+		mockRepo.On("TakenIdentifiers", mock.Anything, []string{"W-1", "W-1", "W-2"},
+			[]string{"04006381333931", "00000040063812"}).
+			Return(map[string]bool{}, map[string]bool{"04006381333931": true}, nil).Once()
+		mockRepo.On("TakenSlugs", mock.Anything, []string{"watch", "watch", "watch", "watch", "clock"}).
+			Return(map[string]bool{"clock": true}, nil).Once()
+		mockRepo.On("ImportProducts", mock.Anything, mock.MatchedBy(func(ps []*models.Product) bool {
+			return len(ps) == 3 && ps[0].Slug == "watch-2" && ps[1].Slug == "watch" && ps[2].Slug == "clock-2"
+		})).Return(int64(3), nil).Once()
+
+		report, err := service.ImportProducts(context.Background(), strings.NewReader(body),
+			models.ImportOptions{Format: models.ImportFormatCSV})
+		assert.NoError(t, err)
+		assert.Equal(t, []models.ImportRowError{
+			{Line: 3, Error: `sku "W-1" is already in use`},
+			{Line: 4, Error: `gtin "04006381333931" is already in use`},
+		}, report.Errors)
+	})
+	t.Run("category is required", func(t *testing.T) {
+		service := newService(new(products.MockRepo))
+
+		report, err := service.ImportProducts(context.Background(),
+			strings.NewReader(`{"title":"watch","price":{"amount":"0.01","currency":"USD"},"quantity":1,"status":"active"}`),
+			models.ImportOptions{Format: models.ImportFormatNDJSON})
+		assert.NoError(t, err)
+		assert.Equal(t, []models.ImportRowError{{Line: 1, Error: "category_id is required"}}, report.Errors)
+	})
+	t.Run("unsupported format", func(t *testing.T) {
+		service := newService(new(products.MockRepo))
+
+		report, err := service.ImportProducts(context.Background(), strings.NewReader(""),
+			models.ImportOptions{Format: "xml"})
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+		assert.Nil(t, report)
+	})
+	t.Run("missing required column", func(t *testing.T) {
+		service := newService(new(products.MockRepo))
+
+		_, err := service.ImportProducts(context.Background(), strings.NewReader("title,price,status\nwatch,1,ok\n"),
+			models.ImportOptions{Format: models.ImportFormatCSV})
+		assert.ErrorIs(t, err, ErrInvalidImport)
+	})
+	t.Run("error from repository", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("ExistingCategories", mock.Anything, []int{1}).Return(map[int]bool{1: true}, nil).Once()
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+		mockRepo.On("ImportProducts", mock.Anything, mock.Anything).Return(int64(0), errors.New("db error")).Once()
+
+		_, err := service.ImportProducts(context.Background(),
+			strings.NewReader("title,category_id,price,currency,quantity,status\nwatch,1,1,USD,1,active\n"),
+			models.ImportOptions{Format: models.ImportFormatCSV})
+		assert.EqualError(t, err, "failed to import products")
+	})
+}
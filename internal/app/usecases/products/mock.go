@@ -3,6 +3,7 @@ package products
 import (
 	"context"
 	"github.com/stretchr/testify/mock"
+	"io"
 	"prodigo/internal/app/models"
 )
 
@@ -15,9 +16,9 @@ func (m *MockService) CreateProduct(ctx context.Context, p *models.Product) erro
 	return args.Error(0)
 }
 
-func (m *MockService) GetAllProducts(ctx context.Context, fs *models.ProductFilterSearch) ([]*models.Product, error) {
+func (m *MockService) GetAllProducts(ctx context.Context, fs *models.ProductFilterSearch) (*models.ProductPage, error) {
 	args := m.Called(ctx, fs)
-	return args.Get(0).([]*models.Product), args.Error(1)
+	return args.Get(0).(*models.ProductPage), args.Error(1)
 }
 
 func (m *MockService) GetProduct(ctx context.Context, id int64) (*models.Product, error) {
@@ -25,22 +26,88 @@ func (m *MockService) GetProduct(ctx context.Context, id int64) (*models.Product
 	return args.Get(0).(*models.Product), args.Error(1)
 }
 
+func (m *MockService) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	args := m.Called(ctx, sku)
+	if p, ok := args.Get(0).(*models.Product); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) GetProductByBarcode(ctx context.Context, code string) (*models.Product, error) {
+	args := m.Called(ctx, code)
+	if p, ok := args.Get(0).(*models.Product); ok {
+		return p, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockService) UpdateProduct(ctx context.Context, p *models.Product) error {
 	args := m.Called(ctx, p)
 	return args.Error(0)
 }
 
-func (m *MockService) DeleteProduct(ctx context.Context, id int64) error {
-	args := m.Called(ctx, id)
+func (m *MockService) DeleteProduct(ctx context.Context, id, version int64) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
-func (m *MockService) UpdateProductStatus(ctx context.Context, id int64, status string) error {
-	args := m.Called(ctx, id, status)
+func (m *MockService) UpdateProductStatus(ctx context.Context, id int64, status string, version int64) error {
+	args := m.Called(ctx, id, status, version)
 	return args.Error(0)
 }
 
-func (m *MockService) RestoreProduct(ctx context.Context, id int64) error {
+func (m *MockService) GetStatusHistory(ctx context.Context, id int64) ([]*models.StatusChange, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]*models.StatusChange), args.Error(1)
+}
+
+func (m *MockService) SchedulePrice(ctx context.Context, pr *models.ProductPrice) error {
+	args := m.Called(ctx, pr)
+	return args.Error(0)
+}
+
+func (m *MockService) GetPriceHistory(ctx context.Context, id int64) ([]*models.ProductPrice, error) {
+	args := m.Called(ctx, id)
+	if prices, ok := args.Get(0).([]*models.ProductPrice); ok {
+		return prices, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) ListTranslations(ctx context.Context, id int64) ([]*models.ProductTranslation, error) {
 	args := m.Called(ctx, id)
+	if trs, ok := args.Get(0).([]*models.ProductTranslation); ok {
+		return trs, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) UpsertTranslation(ctx context.Context, tr *models.ProductTranslation) error {
+	args := m.Called(ctx, tr)
 	return args.Error(0)
 }
+
+func (m *MockService) RestoreProduct(ctx context.Context, id, version int64) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockService) ImportProducts(
+	ctx context.Context, r io.Reader, opts models.ImportOptions,
+) (*models.ImportReport, error) {
+	args := m.Called(ctx, r, opts)
+	return args.Get(0).(*models.ImportReport), args.Error(1)
+}
+
+func (m *MockService) ExportProducts(
+	ctx context.Context, fs *models.ProductFilterSearch, format string, w io.Writer,
+) error {
+	args := m.Called(ctx, fs, format, w)
+	return args.Error(0)
+}
+
+func (m *MockService) BatchProducts(ctx context.Context, b *models.Batch) (*models.BatchReport, error) {
+	args := m.Called(ctx, b)
+	return args.Get(0).(*models.BatchReport), args.Error(1)
+}
@@ -4,45 +4,279 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
 	"prodigo/internal/app/repository/products"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/db/postgres"
+	"slices"
+	"time"
 )
 
 type ServiceInterface interface {
 	CreateProduct(ctx context.Context, p *models.Product) error
 	GetProduct(ctx context.Context, id int64) (*models.Product, error)
-	GetAllProducts(ctx context.Context, fs *models.ProductFilterSearch) ([]*models.Product, error)
+	GetProductBySKU(ctx context.Context, sku string) (*models.Product, error)
+	GetProductByBarcode(ctx context.Context, code string) (*models.Product, error)
+	GetAllProducts(ctx context.Context, fs *models.ProductFilterSearch) (*models.ProductPage, error)
 	UpdateProduct(ctx context.Context, p *models.Product) error
-	DeleteProduct(ctx context.Context, id int64) error
-	RestoreProduct(ctx context.Context, id int64) error
-	UpdateProductStatus(ctx context.Context, id int64, status string) error
+	DeleteProduct(ctx context.Context, id, version int64) error
+	RestoreProduct(ctx context.Context, id, version int64) error
+	UpdateProductStatus(ctx context.Context, id int64, status string, version int64) error
+	GetStatusHistory(ctx context.Context, id int64) ([]*models.StatusChange, error)
+	SchedulePrice(ctx context.Context, pr *models.ProductPrice) error
+	GetPriceHistory(ctx context.Context, id int64) ([]*models.ProductPrice, error)
+	ListTranslations(ctx context.Context, id int64) ([]*models.ProductTranslation, error)
+	UpsertTranslation(ctx context.Context, tr *models.ProductTranslation) error
+	ImportProducts(ctx context.Context, r io.Reader, opts models.ImportOptions) (*models.ImportReport, error)
+	ExportProducts(ctx context.Context, fs *models.ProductFilterSearch, format string, w io.Writer) error
+	BatchProducts(ctx context.Context, b *models.Batch) (*models.BatchReport, error)
 }
 
-var ErrNotFound = errors.New("product not found")
+var (
+	ErrNotFound        = errors.New("product not found")
+	ErrVersionConflict = errors.New("product was modified by another request")
+	ErrDuplicateSKU    = errors.New("sku is already in use")
+	ErrDuplicateGTIN   = errors.New("gtin is already in use")
+	ErrDuplicateSlug   = errors.New("slug is already in use")
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
 
 type Service struct {
 	repository products.Repository
+	audit      audit.Repository
+	tx         postgres.Transactor
+	alerts     alerts.ServiceInterface
 }
 
-func New(repository products.Repository) ServiceInterface {
-	return &Service{repository: repository}
+func New(
+	repository products.Repository, auditRepo audit.Repository, tx postgres.Transactor,
+	alertService alerts.ServiceInterface,
+) ServiceInterface {
+	return &Service{repository: repository, audit: auditRepo, tx: tx, alerts: alertService}
 }
 
+// slugAttempts bounds how often CreateProduct moves a slug it made on to
+// the next number when products created at the same time take it first.
+const slugAttempts = 5
+
+// CreateProduct starts products without a status as drafts. The quantity
+// is the opening stock, zero is allowed. Opening stock below the reorder
+// threshold raises a low stock alert right away. Without a slug one is
+// made from the title, numbered when another product has it.
 func (s *Service) CreateProduct(ctx context.Context, p *models.Product) error {
-	if err := s.repository.CreateProduct(ctx, p); err != nil {
+	if err := checkIdentifiers(p); err != nil {
+		return err
+	}
+	if err := models.CheckDetails(p); err != nil {
+		return err
+	}
+	if p.Quantity < 0 {
+		return fmt.Errorf("%w: quantity can not be negative", models.ErrInvalidMovement)
+	}
+	if err := models.CheckPrice(p.Price); err != nil {
+		return err
+	}
+	if p.ReorderThreshold != nil && *p.ReorderThreshold < 0 {
+		return models.ErrInvalidThreshold
+	}
+	if p.Status == "" {
+		p.Status = models.StatusDraft
+	}
+	if !models.ValidStatus(p.Status) {
+		return fmt.Errorf("%w %q", models.ErrInvalidStatus, p.Status)
+	}
+	var lowStock *models.LowStock
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.checkAttributes(ctx, p); err != nil {
+			return err
+		}
+		if err := s.createProduct(ctx, p); err != nil {
+			return err
+		}
+		if err := s.record(ctx, models.AuditActionCreate, p.ID, nil, p); err != nil {
+			return err
+		}
+		var err error
+		lowStock, err = s.checkLowStock(ctx, p)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.alerts.Notify(ctx, lowStock)
+	return nil
+}
+
+// createProduct stores p. A slug the client gave that is in use is
+// ErrDuplicateSlug, a slug made here moves on to the next free number.
+func (s *Service) createProduct(ctx context.Context, p *models.Product) error {
+	generated := p.Slug == ""
+	var (
+		base  string
+		taken map[string]bool
+	)
+	if generated {
+		base = models.Slugify(p.Title)
+		var err error
+		if taken, err = s.repository.TakenSlugs(ctx, []string{base}); err != nil {
+			return errors.New("failed to generate slug")
+		}
+		p.Slug = models.NextSlug(base, taken)
+	}
+	for attempt := 1; ; attempt++ {
+		err := s.repository.CreateProduct(ctx, p)
+		if err == nil {
+			return nil
+		}
+		if generated && errors.Is(err, products.ErrDuplicateSlug) {
+			if attempt == slugAttempts {
+				return errors.New("failed to generate slug")
+			}
+			// NextSlug marked the slug just tried as taken
+			p.Slug = models.NextSlug(base, taken)
+			continue
+		}
+		if dup := duplicateError(err); dup != nil {
+			return dup
+		}
 		return errors.New("failed to create product")
 	}
+}
+
+// checkIdentifiers validates the identifiers p has, its GTIN is
+// normalized to GTIN-14.
+func checkIdentifiers(p *models.Product) error {
+	if p.SKU != "" {
+		if err := models.CheckSKU(p.SKU); err != nil {
+			return err
+		}
+	}
+	if p.GTIN != "" {
+		gtin, err := models.NormalizeGTIN(p.GTIN)
+		if err != nil {
+			return err
+		}
+		p.GTIN = gtin
+	}
+	if p.Slug != "" {
+		return models.CheckSlug(p.Slug)
+	}
 	return nil
 }
 
-func (s *Service) GetAllProducts(ctx context.Context, fs *models.ProductFilterSearch) ([]*models.Product, error) {
-	prods, err := s.repository.GetAllProducts(ctx, fs)
+// duplicateError maps a clash on a unique identifier, nil for any other
+// error.
+func duplicateError(err error) error {
+	switch {
+	case errors.Is(err, products.ErrDuplicateSKU):
+		return ErrDuplicateSKU
+	case errors.Is(err, products.ErrDuplicateGTIN):
+		return ErrDuplicateGTIN
+	case errors.Is(err, products.ErrDuplicateSlug):
+		return ErrDuplicateSlug
+	}
+	return nil
+}
+
+// checkAttributes validates the attribute values of p against the
+// attributes defined for its category. Only the category of the product
+// itself counts, a parent category does not pass its attributes down.
+func (s *Service) checkAttributes(ctx context.Context, p *models.Product) error {
+	if len(p.Attributes) == 0 {
+		return nil
+	}
+	defs, err := s.repository.AttributeDefinitions(ctx, p.CategoryID)
+	if err != nil {
+		return errors.New("failed to get attributes")
+	}
+	return models.CheckAttributes(defs, p.Attributes)
+}
+
+// checkLowStock syncs the low stock mark of p when its reorder threshold
+// was set, the only field here that can move a product in or out of low
+// stock.
+func (s *Service) checkLowStock(ctx context.Context, p *models.Product) (*models.LowStock, error) {
+	if p.ReorderThreshold == nil {
+		return nil, nil
+	}
+	return s.alerts.Check(ctx, p.ID)
+}
+
+// GetAllProducts returns a page of products, their text is translated
+// into the locales of ctx where a translation exists. Sorting by title and
+// search use the original titles.
+func (s *Service) GetAllProducts(ctx context.Context, fs *models.ProductFilterSearch) (*models.ProductPage, error) {
+	if fs.Cursor != nil && fs.Cursor.Sort != fs.Sort.String() {
+		return nil, models.ErrInvalidCursor
+	}
+	if err := fs.CheckSort(); err != nil {
+		return nil, err
+	}
+
+	limit := fs.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	// one extra row tells whether another page exists
+	query := *fs
+	query.Limit = limit + 1
+
+	prods, err := s.repository.GetAllProducts(ctx, &query)
 	if err != nil {
 		return nil, errors.New("failed to get all products")
 	}
-	return prods, nil
+
+	hasMore := len(prods) > limit
+	if hasMore {
+		prods = prods[:limit]
+	}
+
+	backward := fs.Cursor != nil && fs.Cursor.Backward
+	if backward {
+		slices.Reverse(prods)
+	}
+
+	page := &models.ProductPage{Items: prods, HasMore: hasMore}
+	if page.Items == nil {
+		page.Items = []*models.Product{}
+	}
+	// the facets describe the whole listing, later pages would repeat them
+	if fs.Cursor == nil {
+		if page.Facets, err = s.repository.ProductFacets(ctx, fs); err != nil {
+			return nil, errors.New("failed to get facets")
+		}
+	}
+	if len(prods) == 0 {
+		return page, nil
+	}
+
+	if hasMore || backward {
+		if page.NextCursor, err = models.NewProductCursor(prods[len(prods)-1], fs.Sort, false).Encode(); err != nil {
+			return nil, fmt.Errorf("failed to encode next cursor: %w", err)
+		}
+	}
+	if (hasMore && backward) || (fs.Cursor != nil && !backward) {
+		if page.PrevCursor, err = models.NewProductCursor(prods[0], fs.Sort, true).Encode(); err != nil {
+			return nil, fmt.Errorf("failed to encode prev cursor: %w", err)
+		}
+	}
+	// the cursors hold the original titles, translate only after them
+	if err = s.localize(ctx, prods...); err != nil {
+		return nil, err
+	}
+	return page, nil
 }
 
+// GetProduct returns a product translated into the locales of ctx, the
+// same goes for GetProductBySKU and GetProductByBarcode.
 func (s *Service) GetProduct(ctx context.Context, id int64) (*models.Product, error) {
 	product, err := s.repository.GetProductByID(ctx, id)
 	if err != nil {
@@ -51,16 +285,97 @@ func (s *Service) GetProduct(ctx context.Context, id int64) (*models.Product, er
 		}
 		return nil, errors.New("failed to get product")
 	}
+	if err = s.localize(ctx, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+
+}
+
+func (s *Service) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	if err := models.CheckSKU(sku); err != nil {
+		return nil, err
+	}
+	product, err := s.repository.GetProductBySKU(ctx, sku)
+	if err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to get product")
+	}
+	if err = s.localize(ctx, product); err != nil {
+		return nil, err
+	}
 	return product, nil
+}
 
+// GetProductByBarcode finds a product by an EAN-8, UPC-A, EAN-13 or
+// GTIN-14 code, all forms of the same number match.
+func (s *Service) GetProductByBarcode(ctx context.Context, code string) (*models.Product, error) {
+	gtin, err := models.NormalizeGTIN(code)
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.repository.GetProductByGTIN(ctx, gtin)
+	if err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("failed to get product")
+	}
+	if err = s.localize(ctx, product); err != nil {
+		return nil, err
+	}
+	return product, nil
 }
 
+// UpdateProduct merges the non-zero fields of p into the stored product.
+// p.Price becomes the regular price, it has to be in the currency of the
+// product, which can not be changed. p.Attributes replaces all attribute
+// values when set. p.Version is the version the caller last saw, a stale one makes
+// the write fail with ErrVersionConflict. Raising the reorder threshold
+// above the stock on hand raises a low stock alert. A new title keeps the
+// slug, the slug only changes when p.Slug is set.
 func (s *Service) UpdateProduct(ctx context.Context, p *models.Product) error {
+	if err := checkIdentifiers(p); err != nil {
+		return err
+	}
+	if err := models.CheckDetails(p); err != nil {
+		return err
+	}
+	if p.ReorderThreshold != nil && *p.ReorderThreshold < 0 {
+		return models.ErrInvalidThreshold
+	}
+	var lowStock *models.LowStock
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.updateProduct(ctx, p); err != nil {
+			return err
+		}
+		var err error
+		lowStock, err = s.checkLowStock(ctx, p)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.alerts.Notify(ctx, lowStock)
+	return nil
+}
+
+func (s *Service) updateProduct(ctx context.Context, p *models.Product) error {
 	update, err := s.repository.GetProductByID(ctx, p.ID)
 
 	if err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			return ErrNotFound
+		}
 		return fmt.Errorf("product not found: %w", err)
 	}
+	before := *update
+
+	if p.Version != 0 {
+		update.Version = p.Version
+	}
 
 	if p.Title != "" {
 		update.Title = p.Title
@@ -68,48 +383,361 @@ func (s *Service) UpdateProduct(ctx context.Context, p *models.Product) error {
 	if p.CategoryID != 0 {
 		update.CategoryID = p.CategoryID
 	}
-	if p.Price != 0 {
-		update.Price = p.Price
+	// sending back the price in effect is fine while a scheduled one lasts
+	if !p.Price.IsZero() && p.Price != update.Price && p.Price != update.RegularPrice {
+		if p.Price.Currency != update.Price.Currency {
+			return fmt.Errorf("%w: the price has to be in %s", models.ErrCurrencyMismatch, update.Price.Currency)
+		}
+		if err = models.CheckPrice(p.Price); err != nil {
+			return err
+		}
+		update.RegularPrice = p.Price
 	}
-	if p.Quantity != 0 {
-		update.Quantity = p.Quantity
+	// the stock only moves through the ledger, sending it back unchanged is fine
+	if p.Quantity != 0 && p.Quantity != update.Quantity {
+		return models.ErrStockManaged
 	}
 	if p.Image != "" {
 		update.Image = p.Image
 	}
+	if p.SKU != "" {
+		update.SKU = p.SKU
+	}
+	if p.GTIN != "" {
+		update.GTIN = p.GTIN
+	}
+	if p.Slug != "" {
+		update.Slug = p.Slug
+	}
+	if p.Description != "" {
+		update.Description = p.Description
+	}
+	if p.Brand != "" {
+		update.Brand = p.Brand
+	}
+	if p.WeightGrams != nil {
+		update.WeightGrams = p.WeightGrams
+	}
+	if p.Dimensions != nil {
+		update.Dimensions = p.Dimensions
+	}
+	if p.ReorderThreshold != nil {
+		update.ReorderThreshold = p.ReorderThreshold
+	}
+	// the values have to fit the category, also when only it changes
+	if p.Attributes != nil {
+		update.Attributes = p.Attributes
+	}
+	if p.Attributes != nil || update.CategoryID != before.CategoryID {
+		if err = s.checkAttributes(ctx, update); err != nil {
+			return err
+		}
+	}
 
-	if p.Status != "" {
+	if p.Status != "" && p.Status != update.Status {
+		if err = models.CheckTransition(update.Status, p.Status); err != nil {
+			return err
+		}
 		update.Status = p.Status
 	}
 
 	if err = s.repository.UpdateProduct(ctx, update); err != nil {
+		switch {
+		case errors.Is(err, products.ErrVersionConflict):
+			return ErrVersionConflict
+		case errors.Is(err, products.ErrNotFound):
+			return ErrNotFound
+		}
+		if dup := duplicateError(err); dup != nil {
+			return dup
+		}
 		return errors.New("failed to update product")
 	}
-	return nil
+	p.Version = update.Version
+	return s.record(ctx, models.AuditActionUpdate, p.ID, &before, update)
 }
 
-func (s *Service) DeleteProduct(ctx context.Context, id int64) error {
-	if err := s.repository.DeleteProduct(ctx, id); err != nil {
+// DeleteProduct soft-deletes a product that still is at version.
+func (s *Service) DeleteProduct(ctx context.Context, id, version int64) error {
+	return s.inTx(ctx, func(ctx context.Context) error {
+		return s.deleteProduct(ctx, id, version)
+	})
+}
+
+// deleteProduct takes version 0 for the version it reads, batches do not
+// know the versions of their items.
+func (s *Service) deleteProduct(ctx context.Context, id, version int64) error {
+	before, err := s.repository.GetProductByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			return ErrNotFound
+		}
+		return errors.New("failed to get product")
+	}
+	if version == 0 {
+		version = before.Version
+	}
+	if err = s.repository.DeleteProduct(ctx, id, version); err != nil {
+		switch {
+		case errors.Is(err, products.ErrVersionConflict):
+			return ErrVersionConflict
+		case errors.Is(err, products.ErrNotFound):
+			return ErrNotFound
+		}
 		return errors.New("failed to delete product")
 	}
-	return nil
+	return s.record(ctx, models.AuditActionDelete, id, before, nil)
+}
+
+// UpdateProductStatus moves a product that still is at version along the
+// lifecycle in models, setting the status it already has is a no-op.
+func (s *Service) UpdateProductStatus(ctx context.Context, id int64, status string, version int64) error {
+	if !models.ValidStatus(status) {
+		return fmt.Errorf("%w %q", models.ErrInvalidStatus, status)
+	}
+	return s.inTx(ctx, func(ctx context.Context) error {
+		return s.updateProductStatus(ctx, id, status, version)
+	})
 }
 
-func (s *Service) UpdateProductStatus(ctx context.Context, id int64, status string) error {
+// updateProductStatus takes version 0 for the version it reads.
+func (s *Service) updateProductStatus(ctx context.Context, id int64, status string, version int64) error {
 	p, err := s.repository.GetProductByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("product not found: %w", err)
+		if errors.Is(err, products.ErrNotFound) {
+			return ErrNotFound
+		}
+		return errors.New("failed to get product")
+	}
+	if version != 0 && version != p.Version {
+		return ErrVersionConflict
+	}
+	if p.Status == status {
+		return nil
 	}
+	if err = models.CheckTransition(p.Status, status); err != nil {
+		return err
+	}
+
+	before := *p
 	p.Status = status
-	if err := s.repository.UpdateProduct(ctx, p); err != nil {
+	if err = s.repository.UpdateProduct(ctx, p); err != nil {
+		switch {
+		case errors.Is(err, products.ErrVersionConflict):
+			return ErrVersionConflict
+		case errors.Is(err, products.ErrNotFound):
+			return ErrNotFound
+		}
 		return errors.New("failed to update product status")
 	}
+	return s.record(ctx, models.AuditActionUpdate, id, &before, p)
+}
+
+func (s *Service) GetStatusHistory(ctx context.Context, id int64) ([]*models.StatusChange, error) {
+	if _, err := s.GetProduct(ctx, id); err != nil {
+		return nil, err
+	}
+	history, err := s.repository.ListStatusHistory(ctx, id)
+	if err != nil {
+		return nil, errors.New("failed to get status history")
+	}
+	return history, nil
+}
+
+// SchedulePrice adds a price that takes effect at pr.ValidFrom. With a
+// ValidTo it lasts until then, without one it becomes the regular price.
+func (s *Service) SchedulePrice(ctx context.Context, pr *models.ProductPrice) error {
+	pr.ValidFrom = pr.ValidFrom.UTC()
+	if pr.ValidTo != nil {
+		validTo := pr.ValidTo.UTC()
+		pr.ValidTo = &validTo
+	}
+	if err := models.CheckSchedule(pr, time.Now()); err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(ctx context.Context) error {
+		p, err := s.GetProduct(ctx, pr.ProductID)
+		if err != nil {
+			return err
+		}
+		if pr.Price.Currency != p.Price.Currency {
+			return fmt.Errorf("%w: the price has to be in %s", models.ErrCurrencyMismatch, p.Price.Currency)
+		}
+		if err = s.repository.CreatePrice(ctx, pr); err != nil {
+			if errors.Is(err, products.ErrNotFound) {
+				return ErrNotFound
+			}
+			return errors.New("failed to schedule price")
+		}
+		return s.recordPrice(ctx, pr)
+	})
+}
+
+func (s *Service) GetPriceHistory(ctx context.Context, id int64) ([]*models.ProductPrice, error) {
+	if _, err := s.GetProduct(ctx, id); err != nil {
+		return nil, err
+	}
+	prices, err := s.repository.ListPrices(ctx, id)
+	if err != nil {
+		return nil, errors.New("failed to get price history")
+	}
+	return prices, nil
+}
+
+func (s *Service) ListTranslations(ctx context.Context, id int64) ([]*models.ProductTranslation, error) {
+	if _, err := s.GetProduct(ctx, id); err != nil {
+		return nil, err
+	}
+	trs, err := s.repository.ListTranslations(ctx, id)
+	if err != nil {
+		return nil, errors.New("failed to get translations")
+	}
+	return trs, nil
+}
+
+// UpsertTranslation creates or replaces the translation of a product in
+// tr.Locale, which is stored in its canonical case, "pt_br" as "pt-BR".
+func (s *Service) UpsertTranslation(ctx context.Context, tr *models.ProductTranslation) error {
+	if err := models.CheckTranslation(tr); err != nil {
+		return err
+	}
+
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		trs, err := s.ListTranslations(ctx, tr.ProductID)
+		if err != nil {
+			return err
+		}
+		action := models.AuditActionCreate
+		var before *models.ProductTranslation
+		if i := slices.IndexFunc(trs, func(t *models.ProductTranslation) bool { return t.Locale == tr.Locale }); i >= 0 {
+			action, before = models.AuditActionUpdate, trs[i]
+		}
+		if err = s.repository.UpsertTranslation(ctx, tr); err != nil {
+			if errors.Is(err, products.ErrNotFound) {
+				return ErrNotFound
+			}
+			return errors.New("failed to upsert translation")
+		}
+		return s.recordTranslation(ctx, action, before, tr)
+	})
+}
+
+// localize puts the best translation for the locales of ctx into ps,
+// products without one keep their own text.
+func (s *Service) localize(ctx context.Context, ps ...*models.Product) error {
+	locales := models.LocalesFrom(ctx)
+	if len(locales) == 0 || len(ps) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(ps))
+	for i, p := range ps {
+		ids[i] = p.ID
+	}
+	best, err := s.repository.BestTranslations(ctx, ids, locales)
+	if err != nil {
+		return errors.New("failed to get translations")
+	}
+	for _, p := range ps {
+		if tr, ok := best[p.ID]; ok {
+			p.Localize(tr)
+		}
+	}
 	return nil
 }
 
-func (s *Service) RestoreProduct(ctx context.Context, id int64) error {
-	if err := s.repository.RestoreProduct(ctx, id); err != nil {
+// RestoreProduct undeletes a product that still is at the version it was
+// deleted with.
+func (s *Service) RestoreProduct(ctx context.Context, id, version int64) error {
+	return s.inTx(ctx, func(ctx context.Context) error {
+		return s.restoreProduct(ctx, id, version)
+	})
+}
+
+// restoreProduct takes version 0 for the version it reads.
+func (s *Service) restoreProduct(ctx context.Context, id, version int64) error {
+	deleted, err := s.repository.GetDeletedProduct(ctx, id)
+	if err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			return ErrNotFound
+		}
+		return errors.New("failed to get product")
+	}
+	if version == 0 {
+		version = deleted.Version
+	}
+	if err = s.repository.RestoreProduct(ctx, id, version); err != nil {
+		switch {
+		case errors.Is(err, products.ErrVersionConflict):
+			return ErrVersionConflict
+		case errors.Is(err, products.ErrNotFound):
+			return ErrNotFound
+		}
 		return errors.New("failed to restore product")
 	}
+	after, err := s.repository.GetProductByID(ctx, id)
+	if err != nil {
+		return errors.New("failed to get product")
+	}
+	return s.record(ctx, models.AuditActionRestore, id, nil, after)
+}
+
+// inTx runs fn in a transaction. One that still conflicts with concurrent
+// writes after its retries is answered like a stale version.
+func (s *Service) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := s.tx.InTx(ctx, fn)
+	if errors.Is(err, postgres.ErrSerialization) {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+// record writes an audit entry for a mutation of product id, it has to run
+// in the transaction of the mutation.
+func (s *Service) record(ctx context.Context, action string, id int64, before, after *models.Product) error {
+	var from, to any
+	if before != nil {
+		from = before
+	}
+	if after != nil {
+		to = after
+	}
+	e, err := models.NewAuditEntry(ctx, action, models.AuditEntityProduct, &id, from, to)
+	if err != nil {
+		return errors.New("failed to build audit entry")
+	}
+	if err = s.audit.Record(ctx, e); err != nil {
+		return errors.New("failed to record audit entry")
+	}
+	return nil
+}
+
+// recordPrice writes the audit entry of a scheduled price, keyed by the
+// ID of the price.
+func (s *Service) recordPrice(ctx context.Context, pr *models.ProductPrice) error {
+	e, err := models.NewAuditEntry(ctx, models.AuditActionCreate, models.AuditEntityPrice, &pr.ID, nil, pr)
+	if err != nil {
+		return errors.New("failed to build audit entry")
+	}
+	if err = s.audit.Record(ctx, e); err != nil {
+		return errors.New("failed to record audit entry")
+	}
+	return nil
+}
+
+// recordTranslation writes the audit entry of a translation, keyed by the
+// ID of the product.
+func (s *Service) recordTranslation(ctx context.Context, action string, before, after *models.ProductTranslation) error {
+	var from any
+	if before != nil {
+		from = before
+	}
+	e, err := models.NewAuditEntry(ctx, action, models.AuditEntityTranslation, &after.ProductID, from, after)
+	if err != nil {
+		return errors.New("failed to build audit entry")
+	}
+	if err = s.audit.Record(ctx, e); err != nil {
+		return errors.New("failed to record audit entry")
+	}
 	return nil
 }
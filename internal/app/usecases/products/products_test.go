@@ -3,22 +3,46 @@ package products
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
 	"prodigo/internal/app/repository/products"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/db/postgres"
+	"strings"
 	"testing"
+	"time"
 )
 
+// newService records audit entries into a mock that accepts anything,
+// tests about the entries themselves use their own audit mock. Low stock
+// checks find nothing.
+func newService(repo products.Repository) *Service {
+	auditRepo := new(audit.MockRepo)
+	auditRepo.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockAlerts := new(alerts.MockService)
+	mockAlerts.On("Check", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockAlerts.On("Notify", mock.Anything, mock.Anything).Maybe()
+	return &Service{repository: repo, audit: auditRepo, tx: postgres.MockTransactor{}, alerts: mockAlerts}
+}
+
+func usd(amount int64) models.Money {
+	return models.Money{Currency: "USD", Amount: amount}
+}
+
 func TestService_CreateProduct(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 
 		product := &models.Product{
 			Title: "Test Product",
+			Price: usd(1000),
 		}
 
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
 		mockRepo.On("CreateProduct", mock.Anything, product).Return(nil).Once()
 
 		err := service.CreateProduct(context.Background(), product)
@@ -26,14 +50,114 @@ func TestService_CreateProduct(t *testing.T) {
 
 		mockRepo.AssertExpectations(t)
 	})
+	t.Run("defaults to draft", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		product := &models.Product{Title: "Test Product", Price: usd(1000)}
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+		mockRepo.On("CreateProduct", mock.Anything, product).Return(nil).Once()
+
+		assert.NoError(t, service.CreateProduct(context.Background(), product))
+		assert.Equal(t, models.StatusDraft, product.Status)
+	})
+	t.Run("invalid status", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		err := service.CreateProduct(context.Background(), &models.Product{Title: "Test Product", Price: usd(1000), Status: "availble"})
+		assert.ErrorIs(t, err, models.ErrInvalidStatus)
+		mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("negative quantity", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		err := service.CreateProduct(context.Background(), &models.Product{Title: "Test Product", Quantity: -1})
+		assert.ErrorIs(t, err, models.ErrInvalidMovement)
+		mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("invalid price", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		for _, price := range []models.Money{{}, usd(0), {Currency: "XXX", Amount: 100}} {
+			err := service.CreateProduct(context.Background(), &models.Product{Title: "Test Product", Price: price})
+			assert.ErrorIs(t, err, models.ErrInvalidPrice)
+		}
+		mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("negative reorder threshold", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		threshold := -1
+		err := service.CreateProduct(context.Background(),
+			&models.Product{Title: "Test Product", Price: usd(1000), ReorderThreshold: &threshold})
+		assert.ErrorIs(t, err, models.ErrInvalidThreshold)
+		mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("opening stock below the threshold alerts", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		mockAlerts := new(alerts.MockService)
+		defer mockAlerts.AssertExpectations(t)
+		service := newService(mockRepo)
+		service.alerts = mockAlerts
+
+		threshold := 5
+		product := &models.Product{
+			ID: 1, Title: "Test Product", Price: usd(1000), Quantity: 2, ReorderThreshold: &threshold,
+		}
+		ls := &models.LowStock{ProductID: 1, OnHand: 2, Threshold: 5}
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+		mockRepo.On("CreateProduct", mock.Anything, product).Return(nil).Once()
+		mockAlerts.On("Check", mock.Anything, int64(1)).Return(ls, nil)
+		mockAlerts.On("Notify", mock.Anything, ls).Return()
+
+		assert.NoError(t, service.CreateProduct(context.Background(), product))
+	})
+	t.Run("attributes are checked against the category", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		defs := []*models.AttributeDefinition{
+			{Name: "brand", Type: models.AttributeString}, {Name: "ram", Type: models.AttributeNumber},
+		}
+		product := &models.Product{
+			Title: "Laptop", CategoryID: 3, Price: usd(1000),
+			Attributes: map[string]any{"brand": "apple", "ram": 16.0},
+		}
+		mockRepo.On("AttributeDefinitions", mock.Anything, 3).Return(defs, nil).Once()
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
+		mockRepo.On("CreateProduct", mock.Anything, product).Return(nil).Once()
+
+		assert.NoError(t, service.CreateProduct(context.Background(), product))
+	})
+	t.Run("invalid attribute", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		defs := []*models.AttributeDefinition{{Name: "ram", Type: models.AttributeNumber}}
+		mockRepo.On("AttributeDefinitions", mock.Anything, 3).Return(defs, nil)
+
+		for _, attrs := range []map[string]any{{"ram": "16GB"}, {"color": "red"}} {
+			err := service.CreateProduct(context.Background(),
+				&models.Product{Title: "Laptop", CategoryID: 3, Price: usd(1000), Attributes: attrs})
+			assert.ErrorIs(t, err, models.ErrInvalidAttribute)
+		}
+		mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 
 		product := &models.Product{
 			Title: "Test Product",
+			Price: usd(1000),
 		}
 
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil)
 		mockRepo.On("CreateProduct", mock.Anything, product).Return(errors.New("db error")).Once()
 
 		err := service.CreateProduct(context.Background(), product)
@@ -44,42 +168,311 @@ func TestService_CreateProduct(t *testing.T) {
 	})
 }
 
+func TestService_CreateProduct_Identifiers(t *testing.T) {
+	t.Run("slug from the title gets a free suffix", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		product := &models.Product{Title: "Smart Phone", Price: usd(1000), GTIN: "4006381333931", SKU: "SP-1"}
+		mockRepo.On("TakenSlugs", mock.Anything, []string{"smart-phone"}).
+			Return(map[string]bool{"smart-phone": true, "smart-phone-2": true}, nil).Once()
+		mockRepo.On("CreateProduct", mock.Anything, product).Return(nil).Once()
+
+		assert.NoError(t, service.CreateProduct(context.Background(), product))
+		assert.Equal(t, "smart-phone-3", product.Slug)
+		assert.Equal(t, "04006381333931", product.GTIN)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("generated slug taken meanwhile moves to the next suffix", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		product := &models.Product{Title: "Smart Phone", Price: usd(1000)}
+		mockRepo.On("TakenSlugs", mock.Anything, []string{"smart-phone"}).
+			Return(map[string]bool{"smart-phone": true}, nil).Once()
+		mockRepo.On("CreateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.Slug == "smart-phone-2"
+		})).Return(products.ErrDuplicateSlug).Once()
+		mockRepo.On("CreateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.Slug == "smart-phone-3"
+		})).Return(nil).Once()
+
+		assert.NoError(t, service.CreateProduct(context.Background(), product))
+		assert.Equal(t, "smart-phone-3", product.Slug)
+	})
+	t.Run("generated slug gives up after a few attempts", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("TakenSlugs", mock.Anything, mock.Anything).Return(map[string]bool{}, nil).Once()
+		mockRepo.On("CreateProduct", mock.Anything, mock.Anything).Return(products.ErrDuplicateSlug).Times(slugAttempts)
+
+		err := service.CreateProduct(context.Background(), &models.Product{Title: "phone", Price: usd(1000)})
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrDuplicateSlug)
+	})
+	t.Run("given slug in use", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		product := &models.Product{Title: "phone", Price: usd(1000), Slug: "phone"}
+		mockRepo.On("CreateProduct", mock.Anything, product).Return(products.ErrDuplicateSlug).Once()
+
+		assert.ErrorIs(t, service.CreateProduct(context.Background(), product), ErrDuplicateSlug)
+		assert.Equal(t, "phone", product.Slug)
+	})
+	t.Run("invalid identifiers", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		for _, tt := range []struct {
+			product *models.Product
+			err     error
+		}{
+			{&models.Product{Title: "phone", Price: usd(1000), GTIN: "4006381333932"}, models.ErrInvalidGTIN},
+			{&models.Product{Title: "phone", Price: usd(1000), SKU: "SP 1"}, models.ErrInvalidSKU},
+			{&models.Product{Title: "phone", Price: usd(1000), Slug: "Phone"}, models.ErrInvalidSlug},
+		} {
+			assert.ErrorIs(t, service.CreateProduct(context.Background(), tt.product), tt.err)
+		}
+		mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("duplicate sku", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		product := &models.Product{Title: "phone", Price: usd(1000), SKU: "SP-1", Slug: "phone"}
+		mockRepo.On("CreateProduct", mock.Anything, product).Return(products.ErrDuplicateSKU).Once()
+
+		assert.ErrorIs(t, service.CreateProduct(context.Background(), product), ErrDuplicateSKU)
+		mockRepo.AssertNotCalled(t, "TakenSlugs", mock.Anything, mock.Anything)
+	})
+}
+
 func TestService_GetAllProducts(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		fs := &models.ProductFilterSearch{}
 
-		mockRepo.On("GetAllProducts", mock.Anything, fs).Return([]*models.Product{}, nil).Once()
-		prods, err := service.GetAllProducts(context.Background(), fs)
+		mockRepo.On("GetAllProducts", mock.Anything, mock.Anything).Return([]*models.Product{}, nil).Once()
+		mockRepo.On("ProductFacets", mock.Anything, fs).Return([]*models.Facet{}, nil).Once()
+		page, err := service.GetAllProducts(context.Background(), fs)
 		assert.NoError(t, err)
-		assert.Len(t, prods, 0)
+		assert.Len(t, page.Items, 0)
+		assert.False(t, page.HasMore)
+		assert.Empty(t, page.NextCursor)
 	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		fs := &models.ProductFilterSearch{}
-		mockRepo.On("GetAllProducts", mock.Anything, fs).Return([]*models.Product{}, errors.New("db error")).Once()
-		prods, err := service.GetAllProducts(context.Background(), fs)
+		mockRepo.On("GetAllProducts", mock.Anything, mock.Anything).Return([]*models.Product{}, errors.New("db error")).Once()
+		page, err := service.GetAllProducts(context.Background(), fs)
 		assert.Error(t, err)
 		assert.EqualError(t, err, "failed to get all products")
-		assert.Nil(t, prods)
+		assert.Nil(t, page)
+	})
+	t.Run("limit is capped and next cursor set", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		fs := &models.ProductFilterSearch{Limit: 2, Currency: "USD", Sort: models.ProductSort{Field: models.SortByPrice}}
+
+		rows := []*models.Product{
+			{ID: 1, Price: models.Money{Currency: "USD", Amount: 10}},
+			{ID: 2, Price: models.Money{Currency: "USD", Amount: 20}},
+			{ID: 3, Price: models.Money{Currency: "USD", Amount: 30}},
+		}
+		mockRepo.On("GetAllProducts", mock.Anything, mock.MatchedBy(func(q *models.ProductFilterSearch) bool {
+			return q.Limit == 3
+		})).Return(rows, nil).Once()
+		mockRepo.On("ProductFacets", mock.Anything, fs).Return([]*models.Facet{}, nil).Once()
+
+		page, err := service.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+		assert.Len(t, page.Items, 2)
+		assert.True(t, page.HasMore)
+		assert.Empty(t, page.PrevCursor)
+
+		cur, err := models.DecodeProductCursor(page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), cur.ID)
+		assert.Equal(t, int64(20), cur.Price)
+		assert.Equal(t, "price", cur.Sort)
+		assert.False(t, cur.Backward)
+	})
+	t.Run("max page size", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		fs := &models.ProductFilterSearch{Limit: 1000}
+
+		mockRepo.On("GetAllProducts", mock.Anything, mock.MatchedBy(func(q *models.ProductFilterSearch) bool {
+			return q.Limit == MaxPageSize+1
+		})).Return([]*models.Product{}, nil).Once()
+		mockRepo.On("ProductFacets", mock.Anything, fs).Return([]*models.Facet{}, nil).Once()
+
+		_, err := service.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("backward page is reversed", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		fs := &models.ProductFilterSearch{
+			Limit:  2,
+			Cursor: &models.ProductCursor{ID: 5, Sort: "id", Backward: true},
+			Sort:   models.ProductSort{Field: models.SortByID},
+		}
+
+		rows := []*models.Product{{ID: 4}, {ID: 3}}
+		mockRepo.On("GetAllProducts", mock.Anything, mock.Anything).Return(rows, nil).Once()
+
+		page, err := service.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), page.Items[0].ID)
+		assert.Equal(t, int64(4), page.Items[1].ID)
+		assert.False(t, page.HasMore)
+		assert.NotEmpty(t, page.NextCursor)
+		assert.Empty(t, page.PrevCursor)
+	})
+	t.Run("facets come with the first page", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		fs := &models.ProductFilterSearch{
+			Attributes: []models.AttributeFilter{{Name: "brand", Values: []string{"apple"}}},
+			Sort:       models.ProductSort{Field: models.SortByID},
+		}
+		facets := []*models.Facet{{Attribute: "brand", Values: []*models.FacetValue{{Value: "apple", Count: 3}}}}
+		mockRepo.On("GetAllProducts", mock.Anything, mock.Anything).Return([]*models.Product{{ID: 1}}, nil).Once()
+		mockRepo.On("ProductFacets", mock.Anything, fs).Return(facets, nil).Once()
+
+		page, err := service.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+		assert.Equal(t, facets, page.Facets)
+
+		fs.Cursor = &models.ProductCursor{ID: 1, Sort: "id"}
+		mockRepo.On("GetAllProducts", mock.Anything, mock.Anything).Return([]*models.Product{{ID: 2}}, nil).Once()
+
+		page, err = service.GetAllProducts(context.Background(), fs)
+		assert.NoError(t, err)
+		assert.Nil(t, page.Facets)
+	})
+	t.Run("facet error", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		fs := &models.ProductFilterSearch{}
+
+		mockRepo.On("GetAllProducts", mock.Anything, mock.Anything).Return([]*models.Product{}, nil).Once()
+		mockRepo.On("ProductFacets", mock.Anything, fs).Return(nil, errors.New("db error")).Once()
+
+		page, err := service.GetAllProducts(context.Background(), fs)
+		assert.EqualError(t, err, "failed to get facets")
+		assert.Nil(t, page)
+	})
+	t.Run("relevance without search", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		fs := &models.ProductFilterSearch{Sort: models.ProductSort{Field: models.SortByRelevance, Desc: true}}
+
+		page, err := service.GetAllProducts(context.Background(), fs)
+		assert.ErrorIs(t, err, models.ErrInvalidSort)
+		assert.Nil(t, page)
+	})
+	t.Run("price without currency", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		fs := &models.ProductFilterSearch{Sort: models.ProductSort{Field: models.SortByPrice}}
+
+		page, err := service.GetAllProducts(context.Background(), fs)
+		assert.ErrorIs(t, err, models.ErrInvalidSort)
+		assert.Nil(t, page)
+		mockRepo.AssertNotCalled(t, "GetAllProducts", mock.Anything, mock.Anything)
+	})
+	t.Run("cursor from another sort", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		fs := &models.ProductFilterSearch{
+			Cursor: &models.ProductCursor{ID: 5, Sort: "-price"},
+			Sort:   models.ProductSort{Field: models.SortByTitle},
+		}
+
+		page, err := service.GetAllProducts(context.Background(), fs)
+		assert.ErrorIs(t, err, models.ErrInvalidCursor)
+		assert.Nil(t, page)
 	})
 }
 
+func TestService_GetAllProducts_Localized(t *testing.T) {
+	mockRepo := new(products.MockRepo)
+	service := newService(mockRepo)
+	defer mockRepo.AssertExpectations(t)
+
+	ctx := models.WithLocales(context.Background(), []string{"de"})
+	fs := &models.ProductFilterSearch{Limit: 1, Sort: models.ProductSort{Field: models.SortByTitle}}
+	rows := []*models.Product{{ID: 1, Title: "Clock"}, {ID: 2, Title: "Watch"}}
+	mockRepo.On("GetAllProducts", mock.Anything, mock.Anything).Return(rows, nil).Once()
+	mockRepo.On("ProductFacets", mock.Anything, fs).Return([]*models.Facet{}, nil).Once()
+	mockRepo.On("BestTranslations", mock.Anything, []int64{1}, []string{"de"}).
+		Return(map[int64]*models.ProductTranslation{1: {ProductID: 1, Locale: "de", Title: "Uhr"}}, nil).Once()
+
+	page, err := service.GetAllProducts(ctx, fs)
+	assert.NoError(t, err)
+	assert.Equal(t, "Uhr", page.Items[0].Title)
+
+	cur, err := models.DecodeProductCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, "Clock", cur.Title)
+}
+
 func TestService_GetProduct(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		id := int64(1)
 		mockRepo.On("GetProductByID", mock.Anything, id).Return(&models.Product{}, nil).Once()
 		prod, err := service.GetProduct(context.Background(), id)
 		assert.NoError(t, err)
 		assert.NotNil(t, prod)
 	})
+	t.Run("picks the best locale", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		ctx := models.WithLocales(context.Background(), []string{"de-CH", "de", "fr"})
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Title: "Watch", Description: "A watch"}, nil).Once()
+		mockRepo.On("BestTranslations", mock.Anything, []int64{1}, []string{"de-CH", "de", "fr"}).
+			Return(map[int64]*models.ProductTranslation{1: {ProductID: 1, Locale: "de", Title: "Uhr"}}, nil).Once()
+
+		prod, err := service.GetProduct(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Uhr", prod.Title)
+		assert.Equal(t, "A watch", prod.Description)
+		assert.Equal(t, "de", prod.Locale)
+	})
+	t.Run("without a translation keeps the original", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		ctx := models.WithLocales(context.Background(), []string{"fr"})
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1, Title: "Watch"}, nil).Once()
+		mockRepo.On("BestTranslations", mock.Anything, []int64{1}, []string{"fr"}).
+			Return(map[int64]*models.ProductTranslation{}, nil).Once()
+
+		prod, err := service.GetProduct(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Watch", prod.Title)
+		assert.Empty(t, prod.Locale)
+	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		id := int64(1)
 		mockRepo.On("GetProductByID", mock.Anything, id).Return(&models.Product{}, errors.New("db error")).Once()
 		prod, err := service.GetProduct(context.Background(), id)
@@ -89,10 +482,53 @@ func TestService_GetProduct(t *testing.T) {
 	})
 }
 
+func TestService_GetProductBySKU(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductBySKU", mock.Anything, "SP-1").Return(&models.Product{ID: 1}, nil).Once()
+		prod, err := service.GetProductBySKU(context.Background(), "SP-1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), prod.ID)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductBySKU", mock.Anything, "SP-1").Return(nil, products.ErrNotFound).Once()
+		_, err := service.GetProductBySKU(context.Background(), "SP-1")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestService_GetProductByBarcode(t *testing.T) {
+	t.Run("upc and ean forms find the same product", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByGTIN", mock.Anything, "00036000291452").Return(&models.Product{ID: 1}, nil).Twice()
+		for _, code := range []string{"036000291452", "0036000291452"} {
+			prod, err := service.GetProductByBarcode(context.Background(), code)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), prod.ID)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("wrong check digit", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		_, err := service.GetProductByBarcode(context.Background(), "036000291453")
+		assert.ErrorIs(t, err, models.ErrInvalidGTIN)
+		mockRepo.AssertNotCalled(t, "GetProductByGTIN", mock.Anything, mock.Anything)
+	})
+}
+
 func TestService_UpdateProduct(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		existProduct := &models.Product{
 			ID:    1,
 			Title: "exist Product",
@@ -110,9 +546,135 @@ func TestService_UpdateProduct(t *testing.T) {
 		mockRepo.AssertNumberOfCalls(t, "UpdateProduct", 1)
 		mockRepo.AssertCalled(t, "UpdateProduct", mock.Anything, updatedProduct)
 	})
+	t.Run("merges the details", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		weight := 180
+		dims := &models.Dimensions{LengthMM: 160, WidthMM: 80, HeightMM: 10}
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Title: "Phone", Brand: "Acme", Description: "old"}, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.Title == "Phone" && p.Brand == "Acme" && p.Description == "new" &&
+				p.WeightGrams == &weight && p.Dimensions == dims
+		})).Return(nil).Once()
+
+		err := service.UpdateProduct(context.Background(), &models.Product{
+			ID: 1, Description: "new", WeightGrams: &weight, Dimensions: dims,
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("invalid dimensions", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		err := service.UpdateProduct(context.Background(), &models.Product{
+			ID: 1, Dimensions: &models.Dimensions{LengthMM: 160, WidthMM: 80},
+		})
+		assert.ErrorIs(t, err, models.ErrInvalidDetails)
+		mockRepo.AssertNotCalled(t, "GetProductByID", mock.Anything, mock.Anything)
+	})
+	t.Run("raising the threshold alerts", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		mockAlerts := new(alerts.MockService)
+		defer mockAlerts.AssertExpectations(t)
+		service := newService(mockRepo)
+		service.alerts = mockAlerts
+
+		threshold := 10
+		ls := &models.LowStock{ProductID: 1, OnHand: 4, Threshold: 10}
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Title: "exist Product", Quantity: 4}, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.ReorderThreshold != nil && *p.ReorderThreshold == 10 && p.Title == "exist Product"
+		})).Return(nil).Once()
+		mockAlerts.On("Check", mock.Anything, int64(1)).Return(ls, nil)
+		mockAlerts.On("Notify", mock.Anything, ls).Return()
+
+		err := service.UpdateProduct(context.Background(), &models.Product{ID: 1, ReorderThreshold: &threshold})
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("attributes are replaced", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		defs := []*models.AttributeDefinition{{Name: "ram", Type: models.AttributeNumber}}
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, CategoryID: 3, Attributes: map[string]any{"ram": 8.0}}, nil).Once()
+		mockRepo.On("AttributeDefinitions", mock.Anything, 3).Return(defs, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.Attributes["ram"] == 16.0
+		})).Return(nil).Once()
+
+		err := service.UpdateProduct(context.Background(),
+			&models.Product{ID: 1, Attributes: map[string]any{"ram": 16.0}})
+		assert.NoError(t, err)
+	})
+	t.Run("the attributes have to fit a new category", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, CategoryID: 3, Attributes: map[string]any{"ram": 8.0}}, nil).Once()
+		mockRepo.On("AttributeDefinitions", mock.Anything, 4).Return([]*models.AttributeDefinition{}, nil).Once()
+
+		err := service.UpdateProduct(context.Background(), &models.Product{ID: 1, CategoryID: 4})
+		assert.ErrorIs(t, err, models.ErrInvalidAttribute)
+		mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("a new price becomes the regular price", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Price: usd(800), RegularPrice: usd(1000)}, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.RegularPrice == usd(1200)
+		})).Return(nil).Once()
+
+		assert.NoError(t, service.UpdateProduct(context.Background(), &models.Product{ID: 1, Price: usd(1200)}))
+	})
+	t.Run("the price in effect is sent back unchanged", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Price: usd(800), RegularPrice: usd(1000)}, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.RegularPrice == usd(1000)
+		})).Return(nil).Once()
+
+		assert.NoError(t, service.UpdateProduct(context.Background(), &models.Product{ID: 1, Price: usd(800)}))
+	})
+	t.Run("price in another currency", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Title: "exist Product", Price: usd(1000)}, nil).Once()
+
+		err := service.UpdateProduct(context.Background(),
+			&models.Product{ID: 1, Price: models.Money{Currency: "EUR", Amount: 900}})
+		assert.ErrorIs(t, err, models.ErrCurrencyMismatch)
+		mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("negative reorder threshold", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		threshold := -1
+		err := service.UpdateProduct(context.Background(), &models.Product{ID: 1, ReorderThreshold: &threshold})
+		assert.ErrorIs(t, err, models.ErrInvalidThreshold)
+		mockRepo.AssertNotCalled(t, "GetProductByID", mock.Anything, mock.Anything)
+	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		existProduct := &models.Product{
 			ID:    1,
 			Title: "exist Product",
@@ -130,64 +692,268 @@ func TestService_UpdateProduct(t *testing.T) {
 		mockRepo.AssertNumberOfCalls(t, "UpdateProduct", 1)
 		mockRepo.AssertCalled(t, "UpdateProduct", mock.Anything, updatedProduct)
 	})
+	t.Run("illegal status transition", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Status: models.StatusArchived}, nil).Once()
+
+		err := service.UpdateProduct(context.Background(), &models.Product{ID: 1, Status: models.StatusActive})
+		assert.ErrorIs(t, err, models.ErrInvalidTransition)
+		mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("quantity is managed by the ledger", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Quantity: 3}, nil).Once()
+
+		err := service.UpdateProduct(context.Background(), &models.Product{ID: 1, Quantity: 5})
+		assert.ErrorIs(t, err, models.ErrStockManaged)
+		mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("unchanged quantity is accepted", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Title: "old", Quantity: 3}, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.Title == "new" && p.Quantity == 3
+		})).Return(nil).Once()
+
+		err := service.UpdateProduct(context.Background(), &models.Product{ID: 1, Title: "new", Quantity: 3})
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		existProduct := &models.Product{ID: 1, Title: "exist Product", Version: 5}
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(existProduct, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
+			return p.Version == 4
+		})).Return(products.ErrVersionConflict).Once()
+
+		err := service.UpdateProduct(context.Background(), &models.Product{ID: 1, Title: "new", Version: 4})
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return((*models.Product)(nil), products.ErrNotFound).Once()
+
+		err := service.UpdateProduct(context.Background(), &models.Product{ID: 1, Version: 1})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+// conflictingTx runs fn and then fails like a transaction that still
+// conflicted with concurrent ones after its retries, calls nested in it
+// join it.
+type conflictingTx struct {
+	postgres.MockTransactor
+}
+
+func (conflictingTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(inTxKey{}) != nil {
+		return fn(ctx)
+	}
+	if err := fn(context.WithValue(ctx, inTxKey{}, true)); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: failed to commit transaction", postgres.ErrSerialization)
+}
+
+func TestService_SerializationConflict(t *testing.T) {
+	mockRepo := new(products.MockRepo)
+	service := newService(mockRepo)
+	service.tx = conflictingTx{}
+
+	mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+		Return(&models.Product{ID: 1, Status: models.StatusDraft, Price: usd(1000), Version: 2}, nil)
+	mockRepo.On("GetDeletedProduct", mock.Anything, int64(1)).Return(&models.Product{ID: 1, Version: 2}, nil)
+	mockRepo.On("UpdateProduct", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("DeleteProduct", mock.Anything, int64(1), int64(2)).Return(nil)
+	mockRepo.On("RestoreProduct", mock.Anything, int64(1), int64(2)).Return(nil)
+	mockRepo.On("CreatePrice", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("ListTranslations", mock.Anything, mock.Anything, mock.Anything).
+		Return([]*models.ProductTranslation{}, nil).Maybe()
+
+	ctx := context.Background()
+	for name, write := range map[string]func() error{
+		"update":  func() error { return service.UpdateProduct(ctx, &models.Product{ID: 1, Brand: "Acme", Version: 2}) },
+		"delete":  func() error { return service.DeleteProduct(ctx, 1, 2) },
+		"status":  func() error { return service.UpdateProductStatus(ctx, 1, models.StatusActive, 2) },
+		"restore": func() error { return service.RestoreProduct(ctx, 1, 2) },
+		"price": func() error {
+			return service.SchedulePrice(ctx, &models.ProductPrice{
+				ProductID: 1, Price: usd(900), ValidFrom: time.Now().Add(time.Hour),
+			})
+		},
+	} {
+		assert.ErrorIs(t, write(), ErrVersionConflict, name)
+	}
+
+	report, err := service.BatchProducts(ctx, &models.Batch{Operations: []models.BatchOperation{
+		{Action: models.BatchActionDelete, IDs: []int64{1}},
+	}})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, report.Results[0].Err, ErrVersionConflict)
+
+	_, err = service.BatchProducts(ctx, &models.Batch{AllOrNothing: true, Operations: []models.BatchOperation{
+		{Action: models.BatchActionDelete, IDs: []int64{1}},
+	}})
+	assert.ErrorIs(t, err, ErrVersionConflict)
 }
 
 func TestService_DeleteProduct(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		id := int64(1)
-		mockRepo.On("DeleteProduct", mock.Anything, id).Return(nil).Once()
-		err := service.DeleteProduct(context.Background(), id)
+		mockRepo.On("GetProductByID", mock.Anything, id).Return(&models.Product{ID: id, Version: 2}, nil).Once()
+		mockRepo.On("DeleteProduct", mock.Anything, id, int64(2)).Return(nil).Once()
+		err := service.DeleteProduct(context.Background(), id, 2)
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
 		mockRepo.AssertNumberOfCalls(t, "DeleteProduct", 1)
-		mockRepo.AssertCalled(t, "DeleteProduct", mock.Anything, id)
 	})
 	t.Run("error from repository", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		id := int64(1)
-		mockRepo.On("DeleteProduct", mock.Anything, id).Return(errors.New("db error")).Once()
-		err := service.DeleteProduct(context.Background(), id)
+		mockRepo.On("GetProductByID", mock.Anything, id).Return(&models.Product{ID: id, Version: 2}, nil).Once()
+		mockRepo.On("DeleteProduct", mock.Anything, id, int64(2)).Return(errors.New("db error")).Once()
+		err := service.DeleteProduct(context.Background(), id, 2)
 		assert.Error(t, err)
 		assert.EqualError(t, err, "failed to delete product")
 	})
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1, Version: 3}, nil).Once()
+		mockRepo.On("DeleteProduct", mock.Anything, int64(1), int64(2)).Return(products.ErrVersionConflict).Once()
+		err := service.DeleteProduct(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return((*models.Product)(nil), products.ErrNotFound).Once()
+		err := service.DeleteProduct(context.Background(), 1, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+		mockRepo.AssertNotCalled(t, "DeleteProduct", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("records the deleted product", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		auditRepo := new(audit.MockRepo)
+		defer auditRepo.AssertExpectations(t)
+
+		service := New(mockRepo, auditRepo, postgres.MockTransactor{}, nil)
+		ctx := models.WithPrincipal(context.Background(), models.Principal{Role: "admin", UserID: 5})
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Title: "Phone", CategoryName: "Mobiles", Version: 1}, nil).Once()
+		mockRepo.On("DeleteProduct", mock.Anything, int64(1), int64(1)).Return(nil).Once()
+		auditRepo.On("Record", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+			return *e.ActorID == 5 && *e.EntityID == 1 && e.Action == models.AuditActionDelete &&
+				e.After == nil && !strings.Contains(string(e.Before), "category_name") &&
+				strings.Contains(string(e.Changes), `"title":{"from":"Phone","to":null}`)
+		})).Return(nil).Once()
+
+		assert.NoError(t, service.DeleteProduct(ctx, 1, 1))
+	})
 }
 
 func TestService_UpdateProductStatus(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 		existProduct := &models.Product{
-			ID:     1,
-			Status: "available",
-		}
-
-		updatedProduct := &models.Product{
-			ID:     1,
-			Status: "not available",
+			ID:      1,
+			Status:  models.StatusActive,
+			Version: 3,
 		}
 
 		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(existProduct, nil).Once()
 		mockRepo.On("UpdateProduct", mock.Anything, mock.MatchedBy(func(p *models.Product) bool {
-			return updatedProduct.ID == 1 && updatedProduct.Status == "not available"
+			return p.ID == 1 && p.Status == models.StatusOutOfStock && p.Version == 3
 		})).Return(nil).Once()
 
-		err := service.UpdateProductStatus(context.Background(), 1, "not available")
+		err := service.UpdateProductStatus(context.Background(), 1, models.StatusOutOfStock, 3)
 		assert.NoError(t, err)
 
 		mockRepo.AssertExpectations(t)
 
 	})
+	t.Run("same status is a no-op", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Status: models.StatusActive, Version: 2}, nil).Once()
+
+		err := service.UpdateProductStatus(context.Background(), 1, models.StatusActive, 2)
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Status: models.StatusActive, Version: 3}, nil).Once()
+
+		err := service.UpdateProductStatus(context.Background(), 1, models.StatusActive, 2)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("illegal transition", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Status: models.StatusDraft, Version: 1}, nil).Once()
+
+		err := service.UpdateProductStatus(context.Background(), 1, models.StatusDiscontinued, 1)
+		assert.ErrorIs(t, err, models.ErrInvalidTransition)
+		assert.EqualError(t, err,
+			"invalid status transition: draft can not change to discontinued, allowed: active, archived")
+		mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("unknown status", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		err := service.UpdateProductStatus(context.Background(), 1, "availble", 1)
+		assert.ErrorIs(t, err, models.ErrInvalidStatus)
+		mockRepo.AssertNotCalled(t, "GetProductByID", mock.Anything, mock.Anything)
+	})
+	t.Run("concurrent change", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).
+			Return(&models.Product{ID: 1, Status: models.StatusActive, Version: 1}, nil).Once()
+		mockRepo.On("UpdateProduct", mock.Anything, mock.Anything).Return(products.ErrVersionConflict).Once()
+
+		err := service.UpdateProductStatus(context.Background(), 1, models.StatusArchived, 1)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
 	t.Run("product_not_found", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := &Service{repository: mockRepo}
+		service := newService(mockRepo)
 
 		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{}, products.ErrNotFound).Once()
 
-		err := service.UpdateProductStatus(context.Background(), 1, "not available")
-		assert.Error(t, err)
+		err := service.UpdateProductStatus(context.Background(), 1, models.StatusArchived, 1)
+		assert.ErrorIs(t, err, ErrNotFound)
 		assert.Contains(t, err.Error(), "product not found")
 
 		mockRepo.AssertExpectations(t)
@@ -195,21 +961,207 @@ func TestService_UpdateProductStatus(t *testing.T) {
 
 }
 
+func TestService_GetStatusHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		actor := int64(7)
+		history := []*models.StatusChange{{ID: 1, ProductID: 1, FromStatus: "draft", ToStatus: "active", ActorID: &actor}}
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1}, nil).Once()
+		mockRepo.On("ListStatusHistory", mock.Anything, int64(1)).Return(history, nil).Once()
+
+		got, err := service.GetStatusHistory(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, history, got)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return((*models.Product)(nil), products.ErrNotFound).Once()
+
+		_, err := service.GetStatusHistory(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestService_SchedulePrice(t *testing.T) {
+	from := time.Now().Add(24 * time.Hour)
+	to := from.Add(48 * time.Hour)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		pr := &models.ProductPrice{ProductID: 1, Price: usd(800), ValidFrom: from, ValidTo: &to}
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1, Price: usd(1000)}, nil).Once()
+		mockRepo.On("CreatePrice", mock.Anything, pr).Return(nil).Once()
+
+		assert.NoError(t, service.SchedulePrice(context.Background(), pr))
+		assert.Equal(t, time.UTC, pr.ValidFrom.Location())
+	})
+	t.Run("invalid schedule", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		past := time.Now().Add(-time.Hour)
+		for _, pr := range []*models.ProductPrice{
+			{ProductID: 1, Price: usd(800), ValidFrom: past},
+			{ProductID: 1, Price: usd(800), ValidFrom: to, ValidTo: &from},
+			{ProductID: 1, Price: usd(800)},
+		} {
+			assert.ErrorIs(t, service.SchedulePrice(context.Background(), pr), models.ErrInvalidSchedule)
+		}
+		err := service.SchedulePrice(context.Background(), &models.ProductPrice{ProductID: 1, ValidFrom: from})
+		assert.ErrorIs(t, err, models.ErrInvalidPrice)
+		mockRepo.AssertNotCalled(t, "CreatePrice", mock.Anything, mock.Anything)
+	})
+	t.Run("price in another currency", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1, Price: usd(1000)}, nil).Once()
+
+		err := service.SchedulePrice(context.Background(),
+			&models.ProductPrice{ProductID: 1, Price: models.Money{Currency: "EUR", Amount: 800}, ValidFrom: from})
+		assert.ErrorIs(t, err, models.ErrCurrencyMismatch)
+		mockRepo.AssertNotCalled(t, "CreatePrice", mock.Anything, mock.Anything)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return((*models.Product)(nil), products.ErrNotFound).Once()
+
+		err := service.SchedulePrice(context.Background(),
+			&models.ProductPrice{ProductID: 1, Price: usd(800), ValidFrom: from})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestService_GetPriceHistory(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		prices := []*models.ProductPrice{{ID: 1, ProductID: 1, Price: usd(1000)}}
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1}, nil).Once()
+		mockRepo.On("ListPrices", mock.Anything, int64(1)).Return(prices, nil).Once()
+
+		got, err := service.GetPriceHistory(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, prices, got)
+	})
+	t.Run("error from repository", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1}, nil).Once()
+		mockRepo.On("ListPrices", mock.Anything, int64(1)).Return([]*models.ProductPrice(nil), errors.New("db error")).Once()
+
+		_, err := service.GetPriceHistory(context.Background(), 1)
+		assert.EqualError(t, err, "failed to get price history")
+	})
+}
+
+func TestService_UpsertTranslation(t *testing.T) {
+	t.Run("creates a translation", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		mockAudit := new(audit.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		defer mockAudit.AssertExpectations(t)
+		service := newService(mockRepo)
+		service.audit = mockAudit
+
+		tr := &models.ProductTranslation{ProductID: 1, Locale: "de_ch", Title: "Uhr"}
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1}, nil).Once()
+		mockRepo.On("ListTranslations", mock.Anything, int64(1)).
+			Return([]*models.ProductTranslation{{ProductID: 1, Locale: "de", Title: "Uhr"}}, nil).Once()
+		mockRepo.On("UpsertTranslation", mock.Anything, tr).Return(nil).Once()
+		mockAudit.On("Record", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+			return e.Action == models.AuditActionCreate && e.Entity == models.AuditEntityTranslation &&
+				*e.EntityID == 1
+		})).Return(nil).Once()
+
+		assert.NoError(t, service.UpsertTranslation(context.Background(), tr))
+		assert.Equal(t, "de-CH", tr.Locale)
+	})
+	t.Run("replaces a translation", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		mockAudit := new(audit.MockRepo)
+		defer mockAudit.AssertExpectations(t)
+		service := newService(mockRepo)
+		service.audit = mockAudit
+
+		tr := &models.ProductTranslation{ProductID: 1, Locale: "de", Title: "Armbanduhr"}
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1}, nil).Once()
+		mockRepo.On("ListTranslations", mock.Anything, int64(1)).
+			Return([]*models.ProductTranslation{{ProductID: 1, Locale: "de", Title: "Uhr"}}, nil).Once()
+		mockRepo.On("UpsertTranslation", mock.Anything, tr).Return(nil).Once()
+		mockAudit.On("Record", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+			return e.Action == models.AuditActionUpdate && strings.Contains(string(e.Changes), "Armbanduhr")
+		})).Return(nil).Once()
+
+		assert.NoError(t, service.UpsertTranslation(context.Background(), tr))
+	})
+	t.Run("invalid locale", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		err := service.UpsertTranslation(context.Background(), &models.ProductTranslation{
+			ProductID: 1, Locale: "deutsch", Title: "Uhr",
+		})
+		assert.ErrorIs(t, err, models.ErrInvalidLocale)
+		mockRepo.AssertNotCalled(t, "UpsertTranslation", mock.Anything, mock.Anything)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return((*models.Product)(nil), products.ErrNotFound).Once()
+
+		err := service.UpsertTranslation(context.Background(), &models.ProductTranslation{
+			ProductID: 1, Locale: "de", Title: "Uhr",
+		})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
 func TestService_RestoreProduct(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := Service{repository: mockRepo}
-		mockRepo.On("RestoreProduct", mock.Anything, int64(1)).Return(nil).Once()
-		err := service.RestoreProduct(context.Background(), 1)
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedProduct", mock.Anything, int64(1)).Return(&models.Product{ID: 1, Version: 2}, nil).Once()
+		mockRepo.On("RestoreProduct", mock.Anything, int64(1), int64(2)).Return(nil).Once()
+		mockRepo.On("GetProductByID", mock.Anything, int64(1)).Return(&models.Product{ID: 1}, nil).Once()
+		err := service.RestoreProduct(context.Background(), 1, 2)
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
 	})
+	t.Run("not deleted", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedProduct", mock.Anything, int64(1)).Return((*models.Product)(nil), products.ErrNotFound).Once()
+		err := service.RestoreProduct(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, ErrNotFound)
+		mockRepo.AssertNotCalled(t, "RestoreProduct", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(products.MockRepo)
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedProduct", mock.Anything, int64(1)).Return(&models.Product{ID: 1, Version: 3}, nil).Once()
+		mockRepo.On("RestoreProduct", mock.Anything, int64(1), int64(2)).Return(products.ErrVersionConflict).Once()
+		err := service.RestoreProduct(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
 	t.Run("restore failure", func(t *testing.T) {
 		mockRepo := new(products.MockRepo)
-		service := Service{repository: mockRepo}
-		mockRepo.On("RestoreProduct", mock.Anything, int64(1)).Return(products.ErrNotFound).Once()
-		err := service.RestoreProduct(context.Background(), 1)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to restore product")
+		service := newService(mockRepo)
+		mockRepo.On("GetDeletedProduct", mock.Anything, int64(1)).Return(&models.Product{ID: 1, Version: 2}, nil).Once()
+		mockRepo.On("RestoreProduct", mock.Anything, int64(1), int64(2)).Return(errors.New("db error")).Once()
+		err := service.RestoreProduct(context.Background(), 1, 2)
+		assert.EqualError(t, err, "failed to restore product")
 	})
 }
@@ -0,0 +1,47 @@
+package reservations
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) Reserve(ctx context.Context, r *models.Reservation, ttl time.Duration) error {
+	args := m.Called(ctx, r, ttl)
+	return args.Error(0)
+}
+
+func (m *MockService) GetReservation(ctx context.Context, id int64) (*models.Reservation, error) {
+	args := m.Called(ctx, id)
+	if r, ok := args.Get(0).(*models.Reservation); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) Confirm(ctx context.Context, id int64) (*models.Reservation, error) {
+	args := m.Called(ctx, id)
+	if r, ok := args.Get(0).(*models.Reservation); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) Release(ctx context.Context, id int64) (*models.Reservation, error) {
+	args := m.Called(ctx, id)
+	if r, ok := args.Get(0).(*models.Reservation); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) ExpireReservations(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
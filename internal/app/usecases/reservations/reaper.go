@@ -0,0 +1,73 @@
+package reservations
+
+import (
+	"context"
+	"log"
+	"prodigo/pkg/config"
+	"time"
+)
+
+const DefaultReapInterval = 30 * time.Second
+
+// Reaper expires the reservations nobody confirmed or released in time.
+// Expired holds already stop counting against the stock, the reaper only
+// makes their status say so.
+type Reaper struct {
+	service  ServiceInterface
+	stop     chan struct{}
+	done     chan struct{}
+	interval time.Duration
+}
+
+func NewReaper(service ServiceInterface, conf *config.Config) *Reaper {
+	interval := conf.AppReaperInterval
+	if interval <= 0 {
+		interval = DefaultReapInterval
+	}
+	return &Reaper{service: service, interval: interval, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (r *Reaper) Start(_ context.Context) error {
+	go r.run()
+	return nil
+}
+
+// Stop waits for a running pass to finish, or for ctx to end.
+func (r *Reaper) Stop(ctx context.Context) error {
+	close(r.stop)
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Reaper) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.reap()
+		}
+	}
+}
+
+func (r *Reaper) reap() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+
+	n, err := r.service.ExpireReservations(ctx)
+	if err != nil {
+		log.Printf("reservation reaper: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("reservation reaper: expired %d reservations", n)
+	}
+}
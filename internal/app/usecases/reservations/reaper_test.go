@@ -0,0 +1,37 @@
+package reservations
+
+import (
+	"context"
+	"prodigo/pkg/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReaper(t *testing.T) {
+	service := new(MockService)
+	expired := make(chan struct{}, 1)
+	service.On("ExpireReservations", mock.Anything).Run(func(mock.Arguments) {
+		select {
+		case expired <- struct{}{}:
+		default:
+		}
+	}).Return(int64(1), nil)
+
+	reaper := NewReaper(service, &config.Config{AppReaperInterval: time.Millisecond})
+	assert.NoError(t, reaper.Start(context.Background()))
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("reaper did not run")
+	}
+	assert.NoError(t, reaper.Stop(context.Background()))
+}
+
+func TestNewReaper_DefaultInterval(t *testing.T) {
+	reaper := NewReaper(new(MockService), &config.Config{})
+	assert.Equal(t, DefaultReapInterval, reaper.interval)
+}
@@ -0,0 +1,200 @@
+package reservations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/inventory"
+	"prodigo/internal/app/repository/reservations"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/config"
+	"prodigo/pkg/db/postgres"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ServiceInterface interface {
+	Reserve(ctx context.Context, r *models.Reservation, ttl time.Duration) error
+	GetReservation(ctx context.Context, id int64) (*models.Reservation, error)
+	Confirm(ctx context.Context, id int64) (*models.Reservation, error)
+	Release(ctx context.Context, id int64) (*models.Reservation, error)
+	ExpireReservations(ctx context.Context) (int64, error)
+}
+
+var (
+	ErrNotFound        = errors.New("reservation not found")
+	ErrProductNotFound = errors.New("product not found")
+	ErrVariantNotFound = errors.New("variant not found")
+)
+
+const (
+	DefaultTTL = 15 * time.Minute
+	MaxTTL     = 24 * time.Hour
+)
+
+type Service struct {
+	repository reservations.Repository
+	inventory  inventory.Repository
+	tx         postgres.Transactor
+	alerts     alerts.ServiceInterface
+	defaultTTL time.Duration
+}
+
+func New(
+	repository reservations.Repository, inventoryRepo inventory.Repository, tx postgres.Transactor,
+	alertService alerts.ServiceInterface, conf *config.Config,
+) ServiceInterface {
+	ttl := conf.AppReservationTTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Service{
+		repository: repository, inventory: inventoryRepo, tx: tx, alerts: alertService, defaultTTL: min(ttl, MaxTTL),
+	}
+}
+
+// Reserve holds r.Quantity units for ttl, or for the configured default
+// when ttl is zero. Only units that are on hand and not held by another
+// active reservation can be reserved, otherwise it fails with
+// models.ErrInsufficientStock.
+func (s *Service) Reserve(ctx context.Context, r *models.Reservation, ttl time.Duration) error {
+	switch {
+	case r.Quantity <= 0:
+		return fmt.Errorf("%w: quantity must be positive", models.ErrInvalidReservation)
+	case ttl < 0 || ttl > MaxTTL:
+		return fmt.Errorf("%w: ttl must be between 0 and %s", models.ErrInvalidReservation, MaxTTL)
+	case ttl == 0:
+		ttl = s.defaultTTL
+	}
+	r.Reference = strings.TrimSpace(r.Reference)
+
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if r.VariantID == nil {
+			hasVariants, err := s.inventory.HasVariants(ctx, r.ProductID)
+			if err != nil {
+				return errors.New("failed to check variants")
+			}
+			if hasVariants {
+				return fmt.Errorf("%w: variant_id is required for a product with variants", models.ErrInvalidReservation)
+			}
+		}
+		available, err := s.repository.Available(ctx, r.ProductID, r.VariantID)
+		if err != nil {
+			return mapError(err, "failed to get available stock")
+		}
+		if available < r.Quantity {
+			return fmt.Errorf("%w: %d available", models.ErrInsufficientStock, max(available, 0))
+		}
+		if err = s.repository.Create(ctx, r, ttl); err != nil {
+			return errors.New("failed to create reservation")
+		}
+		return nil
+	})
+}
+
+func (s *Service) GetReservation(ctx context.Context, id int64) (*models.Reservation, error) {
+	r, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, mapError(err, "failed to get reservation")
+	}
+	return r, nil
+}
+
+// Confirm turns an active reservation into a sale of its units. A sale
+// that takes the product below its reorder threshold raises a low stock
+// alert.
+func (s *Service) Confirm(ctx context.Context, id int64) (*models.Reservation, error) {
+	var (
+		r        *models.Reservation
+		lowStock *models.LowStock
+	)
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if r, err = s.lockActive(ctx, id); err != nil {
+			return err
+		}
+		m := &models.StockMovement{
+			ProductID: r.ProductID,
+			VariantID: r.VariantID,
+			Kind:      models.MovementSale,
+			Delta:     -r.Quantity,
+			Reason:    "reservation " + strconv.FormatInt(r.ID, 10),
+		}
+		if err = s.inventory.ApplyMovement(ctx, m); err != nil {
+			if errors.Is(err, inventory.ErrInsufficientStock) {
+				return models.ErrInsufficientStock
+			}
+			return errors.New("failed to record sale")
+		}
+		if err = s.setStatus(ctx, r, models.ReservationConfirmed); err != nil {
+			return err
+		}
+		lowStock, err = s.alerts.Check(ctx, r.ProductID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.alerts.Notify(ctx, lowStock)
+	return r, nil
+}
+
+// Release gives the units of an active reservation back.
+func (s *Service) Release(ctx context.Context, id int64) (*models.Reservation, error) {
+	var r *models.Reservation
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if r, err = s.lockActive(ctx, id); err != nil {
+			return err
+		}
+		return s.setStatus(ctx, r, models.ReservationReleased)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ExpireReservations marks the holds past their expiry as expired.
+func (s *Service) ExpireReservations(ctx context.Context) (int64, error) {
+	n, err := s.repository.Expire(ctx)
+	if err != nil {
+		return 0, errors.New("failed to expire reservations")
+	}
+	return n, nil
+}
+
+func (s *Service) lockActive(ctx context.Context, id int64) (*models.Reservation, error) {
+	r, err := s.repository.Lock(ctx, id)
+	if err != nil {
+		return nil, mapError(err, "failed to get reservation")
+	}
+	switch r.Status {
+	case models.ReservationActive:
+		return r, nil
+	case models.ReservationExpired:
+		return nil, models.ErrReservationExpired
+	}
+	return nil, fmt.Errorf("%w: %s", models.ErrReservationClosed, r.Status)
+}
+
+func (s *Service) setStatus(ctx context.Context, r *models.Reservation, status string) error {
+	if err := s.repository.SetStatus(ctx, r, status); err != nil {
+		return mapError(err, "failed to update reservation")
+	}
+	return nil
+}
+
+func mapError(err error, msg string) error {
+	switch {
+	case errors.Is(err, reservations.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, reservations.ErrProductNotFound):
+		return ErrProductNotFound
+	case errors.Is(err, reservations.ErrVariantNotFound):
+		return ErrVariantNotFound
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,239 @@
+package reservations
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/inventory"
+	"prodigo/internal/app/repository/reservations"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/config"
+	"prodigo/pkg/db/postgres"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newService(repo reservations.Repository, inventoryRepo inventory.Repository) *Service {
+	mockAlerts := new(alerts.MockService)
+	mockAlerts.On("Check", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockAlerts.On("Notify", mock.Anything, mock.Anything).Maybe()
+	return &Service{
+		repository: repo, inventory: inventoryRepo, tx: postgres.MockTransactor{}, alerts: mockAlerts,
+		defaultTTL: DefaultTTL,
+	}
+}
+
+func TestNew(t *testing.T) {
+	s := New(nil, nil, nil, nil, &config.Config{AppReservationTTL: 48 * time.Hour}).(*Service)
+	assert.Equal(t, MaxTTL, s.defaultTTL)
+
+	s = New(nil, nil, nil, nil, &config.Config{}).(*Service)
+	assert.Equal(t, DefaultTTL, s.defaultTTL)
+}
+
+func TestService_Reserve(t *testing.T) {
+	t.Run("success with default ttl", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo, inventoryRepo)
+
+		r := &models.Reservation{ProductID: 1, Quantity: 2, Reference: " cart 9 "}
+		inventoryRepo.On("HasVariants", mock.Anything, int64(1)).Return(false, nil)
+		mockRepo.On("Available", mock.Anything, int64(1), (*int64)(nil)).Return(2, nil)
+		mockRepo.On("Create", mock.Anything, r, DefaultTTL).Return(nil)
+
+		assert.NoError(t, service.Reserve(context.Background(), r, 0))
+		assert.Equal(t, "cart 9", r.Reference)
+	})
+	t.Run("variant skips the variants check", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo, inventoryRepo)
+
+		variantID := int64(2)
+		r := &models.Reservation{ProductID: 1, VariantID: &variantID, Quantity: 1}
+		mockRepo.On("Available", mock.Anything, int64(1), &variantID).Return(1, nil)
+		mockRepo.On("Create", mock.Anything, r, time.Minute).Return(nil)
+
+		assert.NoError(t, service.Reserve(context.Background(), r, time.Minute))
+		inventoryRepo.AssertNotCalled(t, "HasVariants", mock.Anything, mock.Anything)
+	})
+	t.Run("not enough available", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		service := newService(mockRepo, inventoryRepo)
+
+		inventoryRepo.On("HasVariants", mock.Anything, int64(1)).Return(false, nil)
+		mockRepo.On("Available", mock.Anything, int64(1), (*int64)(nil)).Return(1, nil)
+
+		err := service.Reserve(context.Background(), &models.Reservation{ProductID: 1, Quantity: 2}, 0)
+		assert.ErrorIs(t, err, models.ErrInsufficientStock)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("variant required", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		service := newService(mockRepo, inventoryRepo)
+
+		inventoryRepo.On("HasVariants", mock.Anything, int64(1)).Return(true, nil)
+
+		err := service.Reserve(context.Background(), &models.Reservation{ProductID: 1, Quantity: 1}, 0)
+		assert.ErrorIs(t, err, models.ErrInvalidReservation)
+	})
+	t.Run("invalid input", func(t *testing.T) {
+		service := newService(new(reservations.MockRepo), new(inventory.MockRepo))
+
+		err := service.Reserve(context.Background(), &models.Reservation{ProductID: 1}, 0)
+		assert.ErrorIs(t, err, models.ErrInvalidReservation)
+
+		err = service.Reserve(context.Background(), &models.Reservation{ProductID: 1, Quantity: 1}, MaxTTL+time.Second)
+		assert.ErrorIs(t, err, models.ErrInvalidReservation)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		service := newService(mockRepo, inventoryRepo)
+
+		inventoryRepo.On("HasVariants", mock.Anything, int64(1)).Return(false, nil)
+		mockRepo.On("Available", mock.Anything, int64(1), (*int64)(nil)).Return(0, reservations.ErrProductNotFound)
+
+		err := service.Reserve(context.Background(), &models.Reservation{ProductID: 1, Quantity: 1}, 0)
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+}
+
+func TestService_Confirm(t *testing.T) {
+	t.Run("alerts on low stock", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		mockAlerts := new(alerts.MockService)
+		defer mockAlerts.AssertExpectations(t)
+		service := newService(mockRepo, inventoryRepo)
+		service.alerts = mockAlerts
+
+		r := &models.Reservation{ID: 5, ProductID: 1, Quantity: 2, Status: models.ReservationActive}
+		ls := &models.LowStock{ProductID: 1, OnHand: 1, Threshold: 3}
+		mockRepo.On("Lock", mock.Anything, int64(5)).Return(r, nil)
+		inventoryRepo.On("ApplyMovement", mock.Anything, mock.Anything).Return(nil)
+		mockRepo.On("SetStatus", mock.Anything, r, models.ReservationConfirmed).Return(nil)
+		mockAlerts.On("Check", mock.Anything, int64(1)).Return(ls, nil)
+		mockAlerts.On("Notify", mock.Anything, ls).Return()
+
+		_, err := service.Confirm(context.Background(), 5)
+		assert.NoError(t, err)
+	})
+	t.Run("records a sale", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		defer inventoryRepo.AssertExpectations(t)
+		service := newService(mockRepo, inventoryRepo)
+
+		r := &models.Reservation{ID: 5, ProductID: 1, Quantity: 2, Status: models.ReservationActive}
+		mockRepo.On("Lock", mock.Anything, int64(5)).Return(r, nil)
+		inventoryRepo.On("ApplyMovement", mock.Anything, mock.MatchedBy(func(m *models.StockMovement) bool {
+			return m.ProductID == 1 && m.Kind == models.MovementSale && m.Delta == -2 && m.Reason == "reservation 5"
+		})).Return(nil)
+		mockRepo.On("SetStatus", mock.Anything, r, models.ReservationConfirmed).Return(nil)
+
+		got, err := service.Confirm(context.Background(), 5)
+		assert.NoError(t, err)
+		assert.Same(t, r, got)
+	})
+	t.Run("expired", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		service := newService(mockRepo, inventoryRepo)
+
+		mockRepo.On("Lock", mock.Anything, int64(5)).
+			Return(&models.Reservation{ID: 5, Status: models.ReservationExpired}, nil)
+
+		_, err := service.Confirm(context.Background(), 5)
+		assert.ErrorIs(t, err, models.ErrReservationExpired)
+		inventoryRepo.AssertNotCalled(t, "ApplyMovement", mock.Anything, mock.Anything)
+	})
+	t.Run("already released", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		service := newService(mockRepo, new(inventory.MockRepo))
+
+		mockRepo.On("Lock", mock.Anything, int64(5)).
+			Return(&models.Reservation{ID: 5, Status: models.ReservationReleased}, nil)
+
+		_, err := service.Confirm(context.Background(), 5)
+		assert.ErrorIs(t, err, models.ErrReservationClosed)
+	})
+	t.Run("stock gone", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		inventoryRepo := new(inventory.MockRepo)
+		service := newService(mockRepo, inventoryRepo)
+
+		mockRepo.On("Lock", mock.Anything, int64(5)).
+			Return(&models.Reservation{ID: 5, ProductID: 1, Quantity: 2, Status: models.ReservationActive}, nil)
+		inventoryRepo.On("ApplyMovement", mock.Anything, mock.Anything).Return(inventory.ErrInsufficientStock)
+
+		_, err := service.Confirm(context.Background(), 5)
+		assert.ErrorIs(t, err, models.ErrInsufficientStock)
+		mockRepo.AssertNotCalled(t, "SetStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		service := newService(mockRepo, new(inventory.MockRepo))
+
+		mockRepo.On("Lock", mock.Anything, int64(5)).Return(nil, reservations.ErrNotFound)
+
+		_, err := service.Confirm(context.Background(), 5)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestService_Release(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo, new(inventory.MockRepo))
+
+		r := &models.Reservation{ID: 5, Status: models.ReservationActive}
+		mockRepo.On("Lock", mock.Anything, int64(5)).Return(r, nil)
+		mockRepo.On("SetStatus", mock.Anything, r, models.ReservationReleased).Return(nil)
+
+		_, err := service.Release(context.Background(), 5)
+		assert.NoError(t, err)
+	})
+	t.Run("already confirmed", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		service := newService(mockRepo, new(inventory.MockRepo))
+
+		mockRepo.On("Lock", mock.Anything, int64(5)).
+			Return(&models.Reservation{ID: 5, Status: models.ReservationConfirmed}, nil)
+
+		_, err := service.Release(context.Background(), 5)
+		assert.ErrorIs(t, err, models.ErrReservationClosed)
+	})
+}
+
+func TestService_ExpireReservations(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		service := newService(mockRepo, new(inventory.MockRepo))
+
+		mockRepo.On("Expire", mock.Anything).Return(int64(3), nil)
+
+		n, err := service.ExpireReservations(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), n)
+	})
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(reservations.MockRepo)
+		service := newService(mockRepo, new(inventory.MockRepo))
+
+		mockRepo.On("Expire", mock.Anything).Return(int64(0), errors.New("db down"))
+
+		_, err := service.ExpireReservations(context.Background())
+		assert.EqualError(t, err, "failed to expire reservations")
+	})
+}
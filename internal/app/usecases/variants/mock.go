@@ -0,0 +1,58 @@
+package variants
+
+import (
+	"context"
+	"prodigo/internal/app/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) ListOptions(ctx context.Context, productID int64) ([]*models.ProductOption, error) {
+	args := m.Called(ctx, productID)
+	if opts, ok := args.Get(0).([]*models.ProductOption); ok {
+		return opts, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) ReplaceOptions(
+	ctx context.Context, productID, version int64, opts []*models.ProductOption,
+) (int64, error) {
+	args := m.Called(ctx, productID, version, opts)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockService) ListVariants(ctx context.Context, productID int64) ([]*models.ProductVariant, error) {
+	args := m.Called(ctx, productID)
+	if variants, ok := args.Get(0).([]*models.ProductVariant); ok {
+		return variants, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) GetVariant(ctx context.Context, productID, variantID int64) (*models.ProductVariant, error) {
+	args := m.Called(ctx, productID, variantID)
+	if v, ok := args.Get(0).(*models.ProductVariant); ok {
+		return v, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockService) CreateVariant(ctx context.Context, v *models.ProductVariant) error {
+	args := m.Called(ctx, v)
+	return args.Error(0)
+}
+
+func (m *MockService) UpdateVariant(ctx context.Context, v *models.ProductVariant) error {
+	args := m.Called(ctx, v)
+	return args.Error(0)
+}
+
+func (m *MockService) DeleteVariant(ctx context.Context, productID, variantID, version int64) error {
+	args := m.Called(ctx, productID, variantID, version)
+	return args.Error(0)
+}
@@ -0,0 +1,274 @@
+package variants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
+	"prodigo/internal/app/repository/variants"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/db/postgres"
+	"strings"
+)
+
+type ServiceInterface interface {
+	ListOptions(ctx context.Context, productID int64) ([]*models.ProductOption, error)
+	ReplaceOptions(ctx context.Context, productID, version int64, opts []*models.ProductOption) (int64, error)
+	ListVariants(ctx context.Context, productID int64) ([]*models.ProductVariant, error)
+	GetVariant(ctx context.Context, productID, variantID int64) (*models.ProductVariant, error)
+	CreateVariant(ctx context.Context, v *models.ProductVariant) error
+	UpdateVariant(ctx context.Context, v *models.ProductVariant) error
+	DeleteVariant(ctx context.Context, productID, variantID, version int64) error
+}
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrNotFound        = errors.New("variant not found")
+	ErrVersionConflict = errors.New("variant was modified by another request")
+	ErrProductConflict = errors.New("product was modified by another request")
+	ErrDuplicateSKU    = errors.New("sku is already in use")
+	ErrDuplicate       = errors.New("a variant with these options already exists")
+	ErrOptionsInUse    = errors.New("options do not fit the existing variants")
+	ErrReserved        = errors.New("variant has active reservations")
+)
+
+type Service struct {
+	repository variants.Repository
+	audit      audit.Repository
+	tx         postgres.Transactor
+	alerts     alerts.ServiceInterface
+}
+
+func New(
+	repository variants.Repository, auditRepo audit.Repository, tx postgres.Transactor, alertService alerts.ServiceInterface,
+) ServiceInterface {
+	return &Service{repository: repository, audit: auditRepo, tx: tx, alerts: alertService}
+}
+
+func (s *Service) ListOptions(ctx context.Context, productID int64) ([]*models.ProductOption, error) {
+	opts, err := s.repository.ListOptions(ctx, productID)
+	if err != nil {
+		return nil, errors.New("failed to get options")
+	}
+	return opts, nil
+}
+
+// ReplaceOptions swaps the option definitions of a product for opts. Every
+// existing variant has to fit the new options, otherwise the variants
+// have to be changed or deleted first. The options are part of the
+// product, version is the version of the product the caller last saw and
+// the new one is returned.
+func (s *Service) ReplaceOptions(
+	ctx context.Context, productID, version int64, opts []*models.ProductOption,
+) (int64, error) {
+	for _, opt := range opts {
+		opt.Name = strings.TrimSpace(opt.Name)
+		for i, value := range opt.Values {
+			opt.Values[i] = strings.TrimSpace(value)
+		}
+	}
+	if err := models.CheckOptions(opts); err != nil {
+		return 0, err
+	}
+
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.lockProduct(ctx, productID); err != nil {
+			return err
+		}
+		before, err := s.repository.ListOptions(ctx, productID)
+		if err != nil {
+			return errors.New("failed to get options")
+		}
+		existing, err := s.repository.ListVariants(ctx, productID)
+		if err != nil {
+			return errors.New("failed to get variants")
+		}
+		for _, v := range existing {
+			if err = models.CheckVariant(opts, v); err != nil {
+				return fmt.Errorf("%w: variant %q: %w", ErrOptionsInUse, v.SKU, err)
+			}
+		}
+
+		if version, err = s.repository.ReplaceOptions(ctx, productID, version, opts); err != nil {
+			return mapError(err, "failed to replace options")
+		}
+		return s.record(ctx, models.AuditActionUpdate, models.AuditEntityOptions, productID,
+			map[string]any{"options": before}, map[string]any{"options": opts})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *Service) ListVariants(ctx context.Context, productID int64) ([]*models.ProductVariant, error) {
+	vs, err := s.repository.ListVariants(ctx, productID)
+	if err != nil {
+		return nil, errors.New("failed to get variants")
+	}
+	return vs, nil
+}
+
+func (s *Service) GetVariant(ctx context.Context, productID, variantID int64) (*models.ProductVariant, error) {
+	v, err := s.repository.GetVariant(ctx, productID, variantID)
+	if err != nil {
+		return nil, mapError(err, "failed to get variant")
+	}
+	return v, nil
+}
+
+// CreateVariant adds a variant with its opening stock. The stock of a
+// product with variants is theirs, so the first variant or one without
+// stock can take the product below its reorder threshold, which raises a
+// low stock alert.
+func (s *Service) CreateVariant(ctx context.Context, v *models.ProductVariant) error {
+	v.SKU = strings.TrimSpace(v.SKU)
+	var lowStock *models.LowStock
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.checkVariant(ctx, v); err != nil {
+			return err
+		}
+		if err := s.repository.CreateVariant(ctx, v); err != nil {
+			return mapError(err, "failed to create variant")
+		}
+		if err := s.record(ctx, models.AuditActionCreate, models.AuditEntityVariant, v.ID, nil, v); err != nil {
+			return err
+		}
+		var err error
+		lowStock, err = s.alerts.Check(ctx, v.ProductID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.alerts.Notify(ctx, lowStock)
+	return nil
+}
+
+// UpdateVariant replaces the SKU, options, price and quantity of a
+// variant. v.Version is the version the caller last saw, 0 skips the check.
+func (s *Service) UpdateVariant(ctx context.Context, v *models.ProductVariant) error {
+	v.SKU = strings.TrimSpace(v.SKU)
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.checkVariant(ctx, v); err != nil {
+			return err
+		}
+		before, err := s.repository.GetVariant(ctx, v.ProductID, v.ID)
+		if err != nil {
+			return mapError(err, "failed to get variant")
+		}
+		if v.Version == 0 {
+			v.Version = before.Version
+		}
+		// the stock only moves through the ledger, sending it back unchanged is fine
+		if v.Quantity != 0 && v.Quantity != before.Quantity {
+			return models.ErrStockManaged
+		}
+		v.Quantity = before.Quantity
+
+		if err = s.repository.UpdateVariant(ctx, v); err != nil {
+			return mapError(err, "failed to update variant")
+		}
+		v.CreatedAt = before.CreatedAt
+		return s.record(ctx, models.AuditActionUpdate, models.AuditEntityVariant, v.ID, before, v)
+	})
+}
+
+// DeleteVariant removes a variant the caller last saw at version, its
+// stock movements are kept. A variant
+// that active reservations hold stock of is ErrReserved, the holds have to
+// be confirmed or released first. Taking away the stock of the variant
+// can raise a low stock alert for the product.
+func (s *Service) DeleteVariant(ctx context.Context, productID, variantID, version int64) error {
+	var lowStock *models.LowStock
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.lockProduct(ctx, productID); err != nil {
+			return err
+		}
+		before, err := s.repository.GetVariant(ctx, productID, variantID)
+		if err != nil {
+			return mapError(err, "failed to get variant")
+		}
+		if before.Version != version {
+			return ErrVersionConflict
+		}
+		held, err := s.repository.Reserved(ctx, productID, variantID)
+		if err != nil {
+			return mapError(err, "failed to check reservations")
+		}
+		if held {
+			return ErrReserved
+		}
+		if err = s.repository.DeleteVariant(ctx, productID, variantID, version); err != nil {
+			return mapError(err, "failed to delete variant")
+		}
+		if err = s.record(ctx, models.AuditActionDelete, models.AuditEntityVariant, variantID, before, nil); err != nil {
+			return err
+		}
+		lowStock, err = s.alerts.Check(ctx, productID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.alerts.Notify(ctx, lowStock)
+	return nil
+}
+
+// checkVariant locks the product of v and validates v against its
+// options, a price of its own has to be in the currency of the product.
+func (s *Service) checkVariant(ctx context.Context, v *models.ProductVariant) error {
+	currency, err := s.lockProduct(ctx, v.ProductID)
+	if err != nil {
+		return err
+	}
+	if v.Price != nil && v.Price.Currency != currency {
+		return fmt.Errorf("%w: the price has to be in %s", models.ErrCurrencyMismatch, currency)
+	}
+	opts, err := s.repository.ListOptions(ctx, v.ProductID)
+	if err != nil {
+		return errors.New("failed to get options")
+	}
+	return models.CheckVariant(opts, v)
+}
+
+// lockProduct locks the product and returns its currency.
+func (s *Service) lockProduct(ctx context.Context, productID int64) (string, error) {
+	currency, err := s.repository.LockProduct(ctx, productID)
+	if err != nil {
+		return "", mapError(err, "failed to lock product")
+	}
+	return currency, nil
+}
+
+// record writes an audit entry for a mutation of a variant or of the
+// options of a product, it has to run in the transaction of the mutation.
+func (s *Service) record(ctx context.Context, action, entity string, id int64, before, after any) error {
+	e, err := models.NewAuditEntry(ctx, action, entity, &id, before, after)
+	if err != nil {
+		return errors.New("failed to build audit entry")
+	}
+	if err = s.audit.Record(ctx, e); err != nil {
+		return errors.New("failed to record audit entry")
+	}
+	return nil
+}
+
+func mapError(err error, msg string) error {
+	switch {
+	case errors.Is(err, variants.ErrProductNotFound):
+		return ErrProductNotFound
+	case errors.Is(err, variants.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, variants.ErrVersionConflict):
+		return ErrVersionConflict
+	case errors.Is(err, variants.ErrProductConflict):
+		return ErrProductConflict
+	case errors.Is(err, variants.ErrDuplicateSKU):
+		return ErrDuplicateSKU
+	case errors.Is(err, variants.ErrDuplicate):
+		return ErrDuplicate
+	}
+	return errors.New(msg)
+}
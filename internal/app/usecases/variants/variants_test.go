@@ -0,0 +1,338 @@
+package variants
+
+import (
+	"context"
+	"errors"
+	"prodigo/internal/app/models"
+	"prodigo/internal/app/repository/audit"
+	"prodigo/internal/app/repository/variants"
+	"prodigo/internal/app/usecases/alerts"
+	"prodigo/pkg/db/postgres"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newService records audit entries into a mock that accepts anything.
+func newService(repo variants.Repository) *Service {
+	auditRepo := new(audit.MockRepo)
+	auditRepo.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockAlerts := new(alerts.MockService)
+	mockAlerts.On("Check", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockAlerts.On("Notify", mock.Anything, mock.Anything).Maybe()
+	return &Service{repository: repo, audit: auditRepo, tx: postgres.MockTransactor{}, alerts: mockAlerts}
+}
+
+func sizeOption() []*models.ProductOption {
+	return []*models.ProductOption{{Name: "size", Values: []string{"S", "M", "L"}}}
+}
+
+func TestService_ReplaceOptions(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo)
+
+		opts := []*models.ProductOption{{Name: " size ", Values: []string{"S", " M"}}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return([]*models.ProductOption{}, nil)
+		mockRepo.On("ListVariants", mock.Anything, int64(1)).Return([]*models.ProductVariant{
+			{SKU: "TS-S", Options: map[string]string{"size": "S"}},
+		}, nil)
+		mockRepo.On("ReplaceOptions", mock.Anything, int64(1), int64(3), opts).Return(int64(4), nil)
+
+		version, err := service.ReplaceOptions(context.Background(), 1, 3, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), version)
+		assert.Equal(t, "size", opts[0].Name)
+		assert.Equal(t, []string{"S", "M"}, opts[0].Values)
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		opts := []*models.ProductOption{{Name: "size", Values: []string{"S", "S"}}}
+		_, err := service.ReplaceOptions(context.Background(), 1, 3, opts)
+		assert.ErrorIs(t, err, models.ErrInvalidOptions)
+		mockRepo.AssertNotCalled(t, "LockProduct", mock.Anything, mock.Anything)
+	})
+	t.Run("value in use", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("ListVariants", mock.Anything, int64(1)).Return([]*models.ProductVariant{
+			{SKU: "TS-L", Options: map[string]string{"size": "L"}},
+		}, nil)
+
+		opts := []*models.ProductOption{{Name: "size", Values: []string{"S", "M"}}}
+		_, err := service.ReplaceOptions(context.Background(), 1, 3, opts)
+		assert.ErrorIs(t, err, ErrOptionsInUse)
+		assert.ErrorIs(t, err, models.ErrInvalidVariant)
+		mockRepo.AssertNotCalled(t, "ReplaceOptions", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("product changed", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return([]*models.ProductOption{}, nil)
+		mockRepo.On("ListVariants", mock.Anything, int64(1)).Return([]*models.ProductVariant{}, nil)
+		mockRepo.On("ReplaceOptions", mock.Anything, int64(1), int64(3), mock.Anything).
+			Return(int64(0), variants.ErrProductConflict)
+
+		_, err := service.ReplaceOptions(context.Background(), 1, 3, sizeOption())
+		assert.ErrorIs(t, err, ErrProductConflict)
+	})
+	t.Run("product not found", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("", variants.ErrProductNotFound)
+
+		_, err := service.ReplaceOptions(context.Background(), 1, 3, sizeOption())
+		assert.ErrorIs(t, err, ErrProductNotFound)
+	})
+}
+
+func TestService_CreateVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ProductID: 1, SKU: " TS-M ", Options: map[string]string{"size": "M"}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("CreateVariant", mock.Anything, v).Return(nil)
+
+		err := service.CreateVariant(context.Background(), v)
+		assert.NoError(t, err)
+		assert.Equal(t, "TS-M", v.SKU)
+	})
+	t.Run("first variant below the reorder threshold", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		mockAlerts := new(alerts.MockService)
+		defer mockAlerts.AssertExpectations(t)
+		service := newService(mockRepo)
+		service.alerts = mockAlerts
+		alert := &models.LowStock{ProductID: 1, OnHand: 2, Threshold: 5}
+
+		v := &models.ProductVariant{ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}, Quantity: 2}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("CreateVariant", mock.Anything, v).Return(nil)
+		mockAlerts.On("Check", mock.Anything, int64(1)).Return(alert, nil).Once()
+		mockAlerts.On("Notify", mock.Anything, alert).Once()
+
+		assert.NoError(t, service.CreateVariant(context.Background(), v))
+	})
+	t.Run("no alert when the create fails", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		mockAlerts := new(alerts.MockService)
+		service := newService(mockRepo)
+		service.alerts = mockAlerts
+
+		v := &models.ProductVariant{ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("CreateVariant", mock.Anything, v).Return(variants.ErrDuplicateSKU)
+
+		assert.ErrorIs(t, service.CreateVariant(context.Background(), v), ErrDuplicateSKU)
+		mockAlerts.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+	})
+	t.Run("unknown value", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ProductID: 1, SKU: "TS-XL", Options: map[string]string{"size": "XL"}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+
+		err := service.CreateVariant(context.Background(), v)
+		assert.ErrorIs(t, err, models.ErrInvalidVariant)
+		mockRepo.AssertNotCalled(t, "CreateVariant", mock.Anything, mock.Anything)
+	})
+	t.Run("price in another currency", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{
+			ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"},
+			Price: &models.Money{Currency: "EUR", Amount: 1500},
+		}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+
+		err := service.CreateVariant(context.Background(), v)
+		assert.ErrorIs(t, err, models.ErrCurrencyMismatch)
+		mockRepo.AssertNotCalled(t, "CreateVariant", mock.Anything, mock.Anything)
+	})
+	t.Run("missing sku", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ProductID: 1, Options: map[string]string{"size": "M"}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+
+		err := service.CreateVariant(context.Background(), v)
+		assert.ErrorIs(t, err, models.ErrInvalidVariant)
+	})
+	t.Run("duplicate sku", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("CreateVariant", mock.Anything, v).Return(variants.ErrDuplicateSKU)
+
+		err := service.CreateVariant(context.Background(), v)
+		assert.ErrorIs(t, err, ErrDuplicateSKU)
+	})
+}
+
+func TestService_UpdateVariant(t *testing.T) {
+	t.Run("keeps current version", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Version: 4}, nil)
+		mockRepo.On("UpdateVariant", mock.Anything, mock.MatchedBy(func(v *models.ProductVariant) bool {
+			return v.Version == 4
+		})).Return(nil)
+
+		err := service.UpdateVariant(context.Background(), v)
+		assert.NoError(t, err)
+	})
+	t.Run("keeps current quantity", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Quantity: 5, Version: 4}, nil)
+		mockRepo.On("UpdateVariant", mock.Anything, mock.MatchedBy(func(v *models.ProductVariant) bool {
+			return v.Quantity == 5
+		})).Return(nil)
+
+		err := service.UpdateVariant(context.Background(), v)
+		assert.NoError(t, err)
+	})
+	t.Run("quantity is managed by the ledger", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}, Quantity: 9}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Quantity: 5}, nil)
+
+		err := service.UpdateVariant(context.Background(), v)
+		assert.ErrorIs(t, err, models.ErrStockManaged)
+		mockRepo.AssertNotCalled(t, "UpdateVariant", mock.Anything, mock.Anything)
+	})
+	t.Run("version conflict", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}, Version: 3}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductVariant{ID: 2, ProductID: 1, Version: 4}, nil)
+		mockRepo.On("UpdateVariant", mock.Anything, v).Return(variants.ErrVersionConflict)
+
+		err := service.UpdateVariant(context.Background(), v)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+	t.Run("not found", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		v := &models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Options: map[string]string{"size": "M"}}
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("ListOptions", mock.Anything, int64(1)).Return(sizeOption(), nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).Return(nil, variants.ErrNotFound)
+
+		err := service.UpdateVariant(context.Background(), v)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestService_DeleteVariant(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		auditRepo := new(audit.MockRepo)
+		defer auditRepo.AssertExpectations(t)
+		mockAlerts := new(alerts.MockService)
+		defer mockAlerts.AssertExpectations(t)
+		service := &Service{repository: mockRepo, audit: auditRepo, tx: postgres.MockTransactor{}, alerts: mockAlerts}
+		alert := &models.LowStock{ProductID: 1, OnHand: 3, Threshold: 5}
+
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).
+			Return(&models.ProductVariant{ID: 2, ProductID: 1, SKU: "TS-M", Version: 3}, nil)
+		mockRepo.On("Reserved", mock.Anything, int64(1), int64(2)).Return(false, nil)
+		mockRepo.On("DeleteVariant", mock.Anything, int64(1), int64(2), int64(3)).Return(nil)
+		auditRepo.On("Record", mock.Anything, mock.MatchedBy(func(e *models.AuditEntry) bool {
+			return e.Action == models.AuditActionDelete && e.Entity == models.AuditEntityVariant &&
+				*e.EntityID == 2 && e.After == nil
+		})).Return(nil)
+		mockAlerts.On("Check", mock.Anything, int64(1)).Return(alert, nil).Once()
+		mockAlerts.On("Notify", mock.Anything, alert).Once()
+
+		err := service.DeleteVariant(context.Background(), 1, 2, 3)
+		assert.NoError(t, err)
+	})
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).Return(&models.ProductVariant{ID: 2, Version: 3}, nil)
+		mockRepo.On("Reserved", mock.Anything, int64(1), int64(2)).Return(false, nil)
+		mockRepo.On("DeleteVariant", mock.Anything, int64(1), int64(2), int64(3)).Return(errors.New("db error"))
+
+		err := service.DeleteVariant(context.Background(), 1, 2, 3)
+		assert.EqualError(t, err, "failed to delete variant")
+	})
+	t.Run("active reservations", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+		defer mockRepo.AssertExpectations(t)
+
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).Return(&models.ProductVariant{ID: 2, Version: 3}, nil)
+		mockRepo.On("Reserved", mock.Anything, int64(1), int64(2)).Return(true, nil)
+
+		err := service.DeleteVariant(context.Background(), 1, 2, 3)
+		assert.ErrorIs(t, err, ErrReserved)
+		mockRepo.AssertNotCalled(t, "DeleteVariant", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(variants.MockRepo)
+		service := newService(mockRepo)
+
+		mockRepo.On("LockProduct", mock.Anything, int64(1)).Return("USD", nil)
+		mockRepo.On("GetVariant", mock.Anything, int64(1), int64(2)).Return(&models.ProductVariant{ID: 2, Version: 4}, nil)
+
+		err := service.DeleteVariant(context.Background(), 1, 2, 3)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		mockRepo.AssertNotCalled(t, "Reserved", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "DeleteVariant", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,3 @@
+UPDATE products
+SET image = './uploads/' || image
+WHERE image LIKE 'products/%';
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- images used to be addressed by their path on the app host,
+-- they are now keys relative to the storage root
+UPDATE products
+SET image = regexp_replace(image, '^(\./)?uploads/', '')
+WHERE image ~ '^(\./)?uploads/products/';
\ No newline at end of file
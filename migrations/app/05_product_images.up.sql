@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS product_images (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_images_product_idx ON product_images (product_id, position);
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_images_primary_idx ON product_images (product_id) WHERE is_primary;
+
+-- the single uploaded image becomes the primary image of the gallery
+INSERT INTO product_images (product_id, storage_key, content_type, is_primary)
+SELECT id, image, CASE WHEN image LIKE '%.png' THEN 'image/png' ELSE 'image/jpeg' END, TRUE
+FROM products
+WHERE image LIKE 'products/%';
\ No newline at end of file
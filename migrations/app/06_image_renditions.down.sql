@@ -0,0 +1,4 @@
+ALTER TABLE product_images
+    DROP COLUMN renditions,
+    DROP COLUMN height,
+    DROP COLUMN width;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE product_images
+    ADD COLUMN width INT NOT NULL DEFAULT 0,
+    ADD COLUMN height INT NOT NULL DEFAULT 0,
+    ADD COLUMN renditions TEXT[] NOT NULL DEFAULT '{}';
\ No newline at end of file
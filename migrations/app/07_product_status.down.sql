@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS product_status_history;
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_status_check,
+    ALTER COLUMN status DROP DEFAULT;
\ No newline at end of file
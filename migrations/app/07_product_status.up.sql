@@ -0,0 +1,24 @@
+-- map free text statuses onto the lifecycle, anything unknown starts over as a draft
+UPDATE products
+SET status = CASE
+    WHEN lower(trim(status)) IN ('draft', 'active', 'out_of_stock', 'discontinued', 'archived') THEN lower(trim(status))
+    WHEN lower(trim(status)) IN ('available', 'availble', 'in_stock', 'published') THEN 'active'
+    WHEN lower(trim(status)) IN ('out of stock', 'sold_out', 'unavailable') THEN 'out_of_stock'
+    ELSE 'draft'
+END;
+
+ALTER TABLE products
+    ALTER COLUMN status SET DEFAULT 'draft',
+    ADD CONSTRAINT products_status_check
+        CHECK (status IN ('draft', 'active', 'out_of_stock', 'discontinued', 'archived'));
+
+CREATE TABLE IF NOT EXISTS product_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    actor_id BIGINT,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_status_history_product_idx ON product_status_history (product_id, changed_at);
\ No newline at end of file
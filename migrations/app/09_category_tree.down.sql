@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS categories_parent_idx;
+DROP INDEX IF EXISTS categories_parent_name_idx;
+
+ALTER TABLE categories
+    ADD CONSTRAINT categories_name_key UNIQUE (name),
+    DROP CONSTRAINT IF EXISTS categories_parent_check,
+    DROP COLUMN IF EXISTS parent_id;
\ No newline at end of file
@@ -0,0 +1,9 @@
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES categories(id),
+    ADD CONSTRAINT categories_parent_check CHECK (parent_id <> id);
+
+-- names only have to differ between siblings, so every branch can have its own Accessories
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS categories_parent_name_idx ON categories (COALESCE(parent_id, 0), name);
+
+CREATE INDEX IF NOT EXISTS categories_parent_idx ON categories (parent_id);
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS products_archived_with_idx;
+DROP INDEX IF EXISTS categories_deleted_with_idx;
+
+ALTER TABLE products DROP COLUMN IF EXISTS archived_with_category;
+ALTER TABLE categories DROP COLUMN IF EXISTS deleted_with_category;
\ No newline at end of file
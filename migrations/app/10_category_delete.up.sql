@@ -0,0 +1,11 @@
+-- subcategories deleted along with a category remember it, so restoring the category can bring exactly them back
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS deleted_with_category INTEGER REFERENCES categories(id);
+
+-- the cascade policy archives the products of a deleted category instead of deleting them, restoring the
+-- category brings them back as drafts like any archived product
+ALTER TABLE products ADD COLUMN IF NOT EXISTS archived_with_category INTEGER REFERENCES categories(id);
+
+CREATE INDEX IF NOT EXISTS categories_deleted_with_idx ON categories (deleted_with_category)
+    WHERE deleted_with_category IS NOT NULL;
+CREATE INDEX IF NOT EXISTS products_archived_with_idx ON products (archived_with_category)
+    WHERE archived_with_category IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS product_variants;
+DROP TABLE IF EXISTS product_options;
\ No newline at end of file
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS product_options (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    option_values TEXT[] NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT product_options_name_key UNIQUE (product_id, name)
+);
+
+-- options maps every option name of the product to one of its values,
+-- price overrides the product price unless it is NULL. A deleted variant
+-- stays for the stock ledger and the reservations that point to it, its
+-- sku and options are free to use again.
+CREATE TABLE IF NOT EXISTS product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku TEXT NOT NULL,
+    options JSONB NOT NULL DEFAULT '{}',
+    price INTEGER CHECK (price >= 0),
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_variants_sku_key ON product_variants (sku) WHERE deleted_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_variants_options_key ON product_variants (product_id, options)
+    WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS product_variants_product_idx ON product_variants (product_id) WHERE deleted_at IS NULL;
\ No newline at end of file
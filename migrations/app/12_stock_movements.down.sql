@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS stock_movements;
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_quantity_check,
+    ADD CONSTRAINT products_quantity_check CHECK (quantity > 0) NOT VALID;
\ No newline at end of file
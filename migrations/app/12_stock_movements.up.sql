@@ -0,0 +1,34 @@
+-- zero stock is a valid state, products.quantity only goes negative by mistake
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_quantity_check,
+    ADD CONSTRAINT products_quantity_check CHECK (quantity >= 0);
+
+-- every change of products.quantity or product_variants.quantity is a row
+-- here, balance is the quantity right after the movement. Variants are only
+-- soft-deleted, so their movements are kept.
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id INTEGER REFERENCES product_variants(id),
+    kind TEXT NOT NULL CHECK (kind IN ('receipt', 'sale', 'adjustment', 'return')),
+    delta INTEGER NOT NULL CHECK (delta <> 0),
+    balance INTEGER NOT NULL CHECK (balance >= 0),
+    reason TEXT NOT NULL DEFAULT '',
+    actor_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_product_idx ON stock_movements (product_id, id);
+
+CREATE INDEX IF NOT EXISTS stock_movements_variant_idx ON stock_movements (variant_id, id) WHERE variant_id IS NOT NULL;
+
+-- the stock on hand so far becomes the opening balance of the ledger
+INSERT INTO stock_movements (product_id, kind, delta, balance, reason)
+SELECT id, 'adjustment', quantity, quantity, 'opening balance'
+FROM products
+WHERE quantity > 0;
+
+INSERT INTO stock_movements (product_id, variant_id, kind, delta, balance, reason)
+SELECT product_id, id, 'adjustment', quantity, quantity, 'opening balance'
+FROM product_variants
+WHERE quantity > 0;
\ No newline at end of file
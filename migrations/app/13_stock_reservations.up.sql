@@ -0,0 +1,19 @@
+-- a hold of stock for a checkout, it counts against the available
+-- quantity while active and unexpired, confirming it records a sale. A
+-- variant with active holds can not be deleted.
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id INTEGER REFERENCES product_variants(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'confirmed', 'released', 'expired')),
+    reference TEXT NOT NULL DEFAULT '',
+    actor_id BIGINT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS stock_reservations_product_idx ON stock_reservations (product_id) WHERE status = 'active';
+
+CREATE INDEX IF NOT EXISTS stock_reservations_expires_idx ON stock_reservations (expires_at) WHERE status = 'active';
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE products
+    DROP COLUMN IF EXISTS low_stock_at,
+    DROP COLUMN IF EXISTS reorder_threshold;
\ No newline at end of file
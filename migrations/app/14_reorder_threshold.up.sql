@@ -0,0 +1,6 @@
+-- 0 turns alerts off, otherwise a product is low while its stock on hand
+-- is below the threshold. low_stock_at is set when it drops there, so an
+-- alert goes out once per drop.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS reorder_threshold INTEGER NOT NULL DEFAULT 0 CHECK (reorder_threshold >= 0),
+    ADD COLUMN IF NOT EXISTS low_stock_at TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- rounding up keeps every product price above zero
+ALTER TABLE product_variants
+    ALTER COLUMN price TYPE INTEGER USING CEIL(price / 100.0)::INTEGER;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS currency,
+    ALTER COLUMN price TYPE INTEGER USING CEIL(price / 100.0)::INTEGER;
\ No newline at end of file
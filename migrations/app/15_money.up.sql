@@ -0,0 +1,11 @@
+-- prices become minor units of an ISO 4217 currency. They used to be whole
+-- units without a currency, existing products are taken to be in RUB,
+-- which has two minor digits. Variant prices are in the product currency.
+ALTER TABLE products
+    ALTER COLUMN price TYPE BIGINT USING price::BIGINT * 100,
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$');
+
+ALTER TABLE products ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE product_variants
+    ALTER COLUMN price TYPE BIGINT USING price::BIGINT * 100;
\ No newline at end of file
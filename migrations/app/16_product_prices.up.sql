@@ -0,0 +1,21 @@
+-- every price a product had or will have. valid_to is empty for a regular
+-- price, which lasts until the next one replaces it, and set for a
+-- scheduled one like a sale, which wins over the regular price while it
+-- lasts. products.price keeps the regular price of the last update.
+CREATE TABLE IF NOT EXISTS product_prices (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    valid_from TIMESTAMP NOT NULL,
+    valid_to TIMESTAMP CHECK (valid_to > valid_from),
+    actor_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_prices_product_idx ON product_prices (product_id, valid_from);
+
+-- the current prices become the first regular ones
+INSERT INTO product_prices (product_id, amount, currency, valid_from)
+SELECT id, price, currency, created_at
+FROM products;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- the attributes the products of a category can have, type is the
+-- jsonb_typeof their values need
+CREATE TABLE IF NOT EXISTS category_attributes (
+    id SERIAL PRIMARY KEY,
+    category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('string', 'number', 'boolean')),
+    CONSTRAINT category_attributes_name_key UNIQUE (category_id, name)
+);
+
+-- attribute values of a product by name, jsonb_path_ops serves the @>
+-- lookups of the attribute filters
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS products_attributes_idx ON products USING GIN (attributes jsonb_path_ops);
\ No newline at end of file
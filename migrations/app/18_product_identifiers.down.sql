@@ -0,0 +1,7 @@
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_slug_key,
+    DROP CONSTRAINT IF EXISTS products_gtin_key,
+    DROP CONSTRAINT IF EXISTS products_sku_key,
+    DROP COLUMN IF EXISTS slug,
+    DROP COLUMN IF EXISTS gtin,
+    DROP COLUMN IF EXISTS sku;
\ No newline at end of file
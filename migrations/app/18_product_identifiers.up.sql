@@ -0,0 +1,25 @@
+-- gtin is stored as GTIN-14, shorter EAN and UPC codes are padded with
+-- zeros. Existing products get a slug from their title, titles that are
+-- taken already get the product ID appended.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS sku TEXT,
+    ADD COLUMN IF NOT EXISTS gtin TEXT CHECK (gtin ~ '^[0-9]{14}$'),
+    ADD COLUMN IF NOT EXISTS slug TEXT;
+
+WITH slugs AS (
+    SELECT id, COALESCE(NULLIF(trim(BOTH '-' FROM regexp_replace(lower(title), '[^[:alnum:]]+', '-', 'g')), ''),
+        'product') AS slug
+    FROM products
+), numbered AS (
+    SELECT id, slug, ROW_NUMBER() OVER (PARTITION BY slug ORDER BY id) AS n FROM slugs
+)
+UPDATE products p
+SET slug = CASE WHEN numbered.n = 1 THEN numbered.slug ELSE numbered.slug || '-' || p.id END
+FROM numbered
+WHERE numbered.id = p.id;
+
+ALTER TABLE products
+    ALTER COLUMN slug SET NOT NULL,
+    ADD CONSTRAINT products_sku_key UNIQUE (sku),
+    ADD CONSTRAINT products_gtin_key UNIQUE (gtin),
+    ADD CONSTRAINT products_slug_key UNIQUE (slug);
\ No newline at end of file
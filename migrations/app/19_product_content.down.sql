@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS product_translations;
+
+DROP INDEX IF EXISTS products_search_vector_idx;
+
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE products
+    ADD COLUMN search_vector tsvector
+        GENERATED ALWAYS AS (to_tsvector('english', coalesce(title, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS products_search_vector_idx ON products USING GIN (search_vector);
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_dimensions_check,
+    DROP COLUMN IF EXISTS height_mm,
+    DROP COLUMN IF EXISTS width_mm,
+    DROP COLUMN IF EXISTS length_mm,
+    DROP COLUMN IF EXISTS weight_grams,
+    DROP COLUMN IF EXISTS brand,
+    DROP COLUMN IF EXISTS description;
\ No newline at end of file
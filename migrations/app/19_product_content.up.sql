@@ -0,0 +1,36 @@
+-- the dimensions are all set or all empty. Search also covers the brand
+-- and description, weighted below the title. product_translations holds
+-- the title and description of a product per locale, a BCP 47 tag like
+-- de-CH; the columns of products are the fallback.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS brand TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS weight_grams INTEGER CHECK (weight_grams >= 0),
+    ADD COLUMN IF NOT EXISTS length_mm INTEGER CHECK (length_mm > 0),
+    ADD COLUMN IF NOT EXISTS width_mm INTEGER CHECK (width_mm > 0),
+    ADD COLUMN IF NOT EXISTS height_mm INTEGER CHECK (height_mm > 0),
+    ADD CONSTRAINT products_dimensions_check
+        CHECK (num_nulls(length_mm, width_mm, height_mm) IN (0, 3));
+
+DROP INDEX IF EXISTS products_search_vector_idx;
+
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE products
+    ADD COLUMN search_vector tsvector
+        GENERATED ALWAYS AS (
+            setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+            setweight(to_tsvector('english', brand), 'B') ||
+            setweight(to_tsvector('english', description), 'C')
+        ) STORED;
+
+CREATE INDEX IF NOT EXISTS products_search_vector_idx ON products USING GIN (search_vector);
+
+CREATE TABLE IF NOT EXISTS product_translations (
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    title TEXT NOT NULL CHECK (title <> ''),
+    description TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (product_id, locale)
+);
\ No newline at end of file
@@ -2,23 +2,58 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	AppMigrate    string `mapstructure:"APP_MIGRATE"`
-	AppCasbin     string `mapstructure:"APP_CASBIN"`
-	AppPolicy     string `mapstructure:"APP_POLICY"`
-	AppHost       string `mapstructure:"APP_HOST"`
-	AppPort       string `mapstructure:"APP_PORT"`
-	AppPostgres   string `mapstructure:"APP_POSTGRES"`
-	AuthMigrate   string `mapstructure:"AUTH_MIGRATE"`
-	AuthHost      string `mapstructure:"AUTH_HOST"`
-	AuthPort      string `mapstructure:"AUTH_PORT"`
-	AuthRedis     string `mapstructure:"AUTH_REDIS"`
-	AuthPostgres  string `mapstructure:"AUTH_POSTGRES"`
-	AuthSecretKey string `mapstructure:"AUTH_SECRET_KEY"`
+	AppMigrate         string        `mapstructure:"APP_MIGRATE"`
+	AppCasbin          string        `mapstructure:"APP_CASBIN"`
+	AppPolicy          string        `mapstructure:"APP_POLICY"`
+	AppHost            string        `mapstructure:"APP_HOST"`
+	AppPort            string        `mapstructure:"APP_PORT"`
+	AppPostgres        string        `mapstructure:"APP_POSTGRES"`
+	AppStorageDriver   string        `mapstructure:"APP_STORAGE_DRIVER"`
+	AppStoragePath     string        `mapstructure:"APP_STORAGE_PATH"`
+	AppS3Endpoint      string        `mapstructure:"APP_S3_ENDPOINT"`
+	AppS3AccessKey     string        `mapstructure:"APP_S3_ACCESS_KEY"`
+	AppS3SecretKey     string        `mapstructure:"APP_S3_SECRET_KEY"`
+	AppS3Bucket        string        `mapstructure:"APP_S3_BUCKET"`
+	AppS3Region        string        `mapstructure:"APP_S3_REGION"`
+	AppImageRenditions string        `mapstructure:"APP_IMAGE_RENDITIONS"`
+	AppNotifier        string        `mapstructure:"APP_NOTIFIER"`
+	AppNotifierURL     string        `mapstructure:"APP_NOTIFIER_WEBHOOK_URL"`
+	AppTxIsolation     string        `mapstructure:"APP_TX_ISOLATION"`
+	AppMetricsAddr     string        `mapstructure:"APP_METRICS_ADDR"`
+	AppReplicas        string        `mapstructure:"APP_POSTGRES_REPLICAS"`
+	AuthMigrate        string        `mapstructure:"AUTH_MIGRATE"`
+	AuthHost           string        `mapstructure:"AUTH_HOST"`
+	AuthPort           string        `mapstructure:"AUTH_PORT"`
+	AuthRedis          string        `mapstructure:"AUTH_REDIS"`
+	AuthPostgres       string        `mapstructure:"AUTH_POSTGRES"`
+	AuthSecretKey      string        `mapstructure:"AUTH_SECRET_KEY"`
+	AuthMetricsAddr    string        `mapstructure:"AUTH_METRICS_ADDR"`
+	AppStorageURLTTL   time.Duration `mapstructure:"APP_STORAGE_URL_TTL"`
+	AppReservationTTL  time.Duration `mapstructure:"APP_RESERVATION_TTL"`
+	AppReaperInterval  time.Duration `mapstructure:"APP_RESERVATION_REAP_INTERVAL"`
+	AppNotifierTimeout time.Duration `mapstructure:"APP_NOTIFIER_TIMEOUT"`
+	AppReplicaCheck    time.Duration `mapstructure:"APP_POSTGRES_REPLICA_CHECK_INTERVAL"`
+	AppPgIdleTime      time.Duration `mapstructure:"APP_POSTGRES_MAX_CONN_IDLE_TIME"`
+	AppPgHealthCheck   time.Duration `mapstructure:"APP_POSTGRES_HEALTH_CHECK_PERIOD"`
+	AppPgStmtTimeout   time.Duration `mapstructure:"APP_POSTGRES_STATEMENT_TIMEOUT"`
+	AuthPgIdleTime     time.Duration `mapstructure:"AUTH_POSTGRES_MAX_CONN_IDLE_TIME"`
+	AuthPgHealthCheck  time.Duration `mapstructure:"AUTH_POSTGRES_HEALTH_CHECK_PERIOD"`
+	AuthPgStmtTimeout  time.Duration `mapstructure:"AUTH_POSTGRES_STATEMENT_TIMEOUT"`
+	AuthRedisIdleTime  time.Duration `mapstructure:"AUTH_REDIS_MAX_CONN_IDLE_TIME"`
+	AuthRedisTimeout   time.Duration `mapstructure:"AUTH_REDIS_TIMEOUT"`
+	AppImageQuality    int           `mapstructure:"APP_IMAGE_QUALITY"`
+	AppTxRetries       int           `mapstructure:"APP_TX_RETRIES"`
+	AuthRedisPoolSize  int           `mapstructure:"AUTH_REDIS_POOL_SIZE"`
+	AppPgMaxConns      int32         `mapstructure:"APP_POSTGRES_MAX_CONNS"`
+	AuthPgMaxConns     int32         `mapstructure:"AUTH_POSTGRES_MAX_CONNS"`
+	AppS3UseSSL        bool          `mapstructure:"APP_S3_USE_SSL"`
+	AppStorageRedirect bool          `mapstructure:"APP_STORAGE_REDIRECT"`
 }
 
 func New() (*Config, error) {
@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"expvar"
 	"prodigo/pkg/config"
 	"prodigo/pkg/db/postgres"
 	"prodigo/pkg/db/redis"
+	"strings"
 
 	"go.uber.org/fx"
 )
@@ -13,21 +15,85 @@ var Module = fx.Module("db",
 	fx.Provide(
 		fx.Annotate(
 			func(conf *config.Config) (postgres.Pool, error) {
-				return postgres.New(context.Background(), conf.AuthPostgres)
+				pool, err := postgres.New(context.Background(), conf.AuthPostgres, postgres.Options{
+					MaxConns:          conf.AuthPgMaxConns,
+					MaxConnIdleTime:   conf.AuthPgIdleTime,
+					HealthCheckPeriod: conf.AuthPgHealthCheck,
+					StatementTimeout:  conf.AuthPgStmtTimeout,
+				})
+				if err != nil {
+					return nil, err
+				}
+				publish("postgres_auth", func() any { return postgres.PoolStats(pool) })
+				return pool, nil
 			},
 			fx.ResultTags(`name:"auth_postgres"`),
 		),
 		fx.Annotate(
 			func(conf *config.Config) (redis.Client, error) {
-				return redis.New(context.Background(), conf.AuthRedis)
+				client, err := redis.New(context.Background(), conf.AuthRedis, redis.Options{
+					PoolSize:        conf.AuthRedisPoolSize,
+					ConnMaxIdleTime: conf.AuthRedisIdleTime,
+					Timeout:         conf.AuthRedisTimeout,
+				})
+				if err != nil {
+					return nil, err
+				}
+				publish("redis_auth", func() any { return redis.PoolStats(client) })
+				return client, nil
 			},
 			fx.ResultTags(`name:"auth_redis"`),
 		),
 		fx.Annotate(
-			func(conf *config.Config) (postgres.Pool, error) {
-				return postgres.New(context.Background(), conf.AppPostgres)
+			func(lc fx.Lifecycle, conf *config.Config) (postgres.Pool, error) {
+				var replicas []string
+				for _, url := range strings.Split(conf.AppReplicas, ",") {
+					if url = strings.TrimSpace(url); url != "" {
+						replicas = append(replicas, url)
+					}
+				}
+				pool, err := postgres.NewWithReplicas(
+					context.Background(), conf.AppPostgres, replicas, conf.AppReplicaCheck, postgres.Options{
+						MaxConns:          conf.AppPgMaxConns,
+						MaxConnIdleTime:   conf.AppPgIdleTime,
+						HealthCheckPeriod: conf.AppPgHealthCheck,
+						StatementTimeout:  conf.AppPgStmtTimeout,
+					},
+				)
+				if err != nil {
+					return nil, err
+				}
+				lc.Append(fx.Hook{OnStop: func(context.Context) error {
+					pool.Close()
+					return nil
+				}})
+				publish("postgres_app", func() any { return postgres.PoolStats(pool) })
+				return pool, nil
 			},
 			fx.ResultTags(`name:"app_postgres"`),
 		),
+		fx.Annotate(
+			func(pool postgres.Pool, conf *config.Config) (postgres.Transactor, error) {
+				level, err := postgres.ParseIsolation(conf.AppTxIsolation)
+				if err != nil {
+					return nil, err
+				}
+				opts := []postgres.TxOption{postgres.WithIsolation(level)}
+				// zero keeps the default, a negative count turns retries off
+				if conf.AppTxRetries != 0 {
+					opts = append(opts, postgres.WithRetries(conf.AppTxRetries))
+				}
+				return postgres.NewTransactor(pool, opts...), nil
+			},
+			fx.ParamTags(`name:"app_postgres"`),
+		),
 	),
 )
+
+// publish exposes the pool stats under name in /debug/vars, expvar panics
+// on a second publication so the first pool keeps the name.
+func publish(name string, stats func() any) {
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(stats))
+	}
+}
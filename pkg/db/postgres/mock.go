@@ -36,8 +36,88 @@ func (m *MockPool) Exec(ctx context.Context, sql string, arguments ...any) (pgco
 	return called.Get(0).(pgconn.CommandTag), called.Error(1)
 }
 
+func (m *MockPool) CopyFrom(
+	ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource,
+) (int64, error) {
+	called := m.Called(ctx, table, columns, src)
+	return called.Get(0).(int64), called.Error(1)
+}
+
+func (m *MockPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	called := m.Called(ctx)
+	return called.Get(0).(pgx.Tx), called.Error(1)
+}
+
+func (m *MockPool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	called := m.Called(ctx, txOptions)
+	return called.Get(0).(pgx.Tx), called.Error(1)
+}
+
 var _ Pool = (*MockPool)(nil)
 
+type MockTx struct {
+	mock.Mock
+}
+
+func (m *MockTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	called := m.Called(ctx)
+	return called.Get(0).(pgx.Tx), called.Error(1)
+}
+
+func (m *MockTx) Commit(ctx context.Context) error {
+	called := m.Called(ctx)
+	return called.Error(0)
+}
+
+func (m *MockTx) Rollback(ctx context.Context) error {
+	called := m.Called(ctx)
+	return called.Error(0)
+}
+
+func (m *MockTx) CopyFrom(
+	ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource,
+) (int64, error) {
+	called := m.Called(ctx, table, columns, src)
+	return called.Get(0).(int64), called.Error(1)
+}
+
+func (m *MockTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	called := m.Called(ctx, b)
+	return called.Get(0).(pgx.BatchResults)
+}
+
+func (m *MockTx) LargeObjects() pgx.LargeObjects {
+	called := m.Called()
+	return called.Get(0).(pgx.LargeObjects)
+}
+
+func (m *MockTx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	called := m.Called(ctx, name, sql)
+	return called.Get(0).(*pgconn.StatementDescription), called.Error(1)
+}
+
+func (m *MockTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	called := m.Called(ctx, sql, arguments)
+	return called.Get(0).(pgconn.CommandTag), called.Error(1)
+}
+
+func (m *MockTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	called := m.Called(ctx, sql, args)
+	return called.Get(0).(pgx.Rows), called.Error(1)
+}
+
+func (m *MockTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	called := m.Called(ctx, sql, args)
+	return called.Get(0).(pgx.Row)
+}
+
+func (m *MockTx) Conn() *pgx.Conn {
+	called := m.Called()
+	return called.Get(0).(*pgx.Conn)
+}
+
+var _ pgx.Tx = (*MockTx)(nil)
+
 type MockRow struct {
 	mock.Mock
 }
@@ -87,3 +167,14 @@ func (m *MockRow) Scan(dest ...any) error {
 }
 
 var _ pgx.Row = (*MockRow)(nil)
+
+// MockTransactor runs fn right away, without a transaction.
+type MockTransactor struct{}
+
+func (MockTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (MockTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error, _ ...TxOption) error {
+	return fn(ctx)
+}
@@ -3,6 +3,8 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -13,28 +15,63 @@ type Pool interface {
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
 	Ping(ctx context.Context) error
 	Close()
 }
 
+// Options tune the pool, zero values keep the pgxpool defaults.
+type Options struct {
+	MaxConns          int32
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	StatementTimeout  time.Duration
+}
+
 type conn struct {
 	*pgxpool.Pool
 }
 
-func New(ctx context.Context, url string) (Pool, error) {
+func New(ctx context.Context, url string, opts Options) (Pool, error) {
+	pool, err := connect(ctx, url, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping pool: %w", err)
+	}
+
+	return pool, nil
+}
+
+// connect creates the pool without waiting for the server to answer.
+func connect(ctx context.Context, url string, opts Options) (Pool, error) {
 	conf, err := pgxpool.ParseConfig(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	conf.ConnConfig.Tracer = conflictTracer{}
+	if opts.MaxConns > 0 {
+		conf.MaxConns = opts.MaxConns
+	}
+	if opts.MaxConnIdleTime > 0 {
+		conf.MaxConnIdleTime = opts.MaxConnIdleTime
+	}
+	if opts.HealthCheckPeriod > 0 {
+		conf.HealthCheckPeriod = opts.HealthCheckPeriod
+	}
+	if opts.StatementTimeout > 0 {
+		conf.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, conf)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create pool: %w", err)
 	}
 
-	if err := pool.Ping(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping pool: %w", err)
-	}
-
 	return &conn{Pool: pool}, nil
 }
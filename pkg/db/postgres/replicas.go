@@ -0,0 +1,280 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DefaultHealthInterval is how often replicas are pinged.
+const DefaultHealthInterval = 5 * time.Second
+
+// writes matches statements a replica can not run, row locks included.
+var writes = regexp.MustCompile(`(?i)\b(insert|update|delete|merge|share|nextval|setval|pg_advisory\w*)\b`)
+
+type primaryKey struct{}
+
+type writtenKey struct{}
+
+// WithPrimary sends the reads made with ctx to the primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// ReadYourWrites sends the reads made with ctx to the primary once a write
+// went through it, so a request reads back what it wrote instead of a
+// replica that has not caught up yet.
+func ReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writtenKey{}, new(atomic.Bool))
+}
+
+type replica struct {
+	pool    Pool
+	name    string
+	healthy atomic.Bool
+}
+
+// router sends reads to the healthy replicas in turn and everything else
+// to the primary. Replicas failing a ping or a query are ejected until a
+// ping succeeds again, with none left reads go to the primary too.
+type router struct {
+	primary  Pool
+	replicas []*replica
+	next     atomic.Uint64
+	stop     chan struct{}
+	done     chan struct{}
+	interval time.Duration
+}
+
+// NewWithReplicas connects to the primary at url and to the replicas at
+// replicaURLs, all tuned with opts. A replica that can not be reached yet
+// starts out ejected. Without replicas it is New.
+func NewWithReplicas(
+	ctx context.Context, url string, replicaURLs []string, interval time.Duration, opts Options,
+) (Pool, error) {
+	primary, err := New(ctx, url, opts)
+	if err != nil || len(replicaURLs) == 0 {
+		return primary, err
+	}
+
+	replicas := make([]Pool, 0, len(replicaURLs))
+	for i, replicaURL := range replicaURLs {
+		pool, err := connect(ctx, replicaURL, opts)
+		if err != nil {
+			primary.Close()
+			for _, r := range replicas {
+				r.Close()
+			}
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		replicas = append(replicas, pool)
+	}
+	r := newRouter(primary, replicas, interval)
+	r.check(ctx)
+	go r.run()
+	return r, nil
+}
+
+func newRouter(primary Pool, replicas []Pool, interval time.Duration) *router {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	r := &router{
+		primary:  primary,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for i, pool := range replicas {
+		rep := &replica{pool: pool, name: fmt.Sprintf("replica %d", i+1)}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+	return r
+}
+
+func (r *router) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	markWritten(ctx)
+	return r.primary.Exec(ctx, sql, arguments...)
+}
+
+// Query falls back to the primary when the replica can not be reached.
+func (r *router) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if rep := r.reader(ctx, sql); rep != nil {
+		rows, err := rep.pool.Query(ctx, sql, args...)
+		if err == nil || !r.unreachable(ctx, rep, err) {
+			return rows, err
+		}
+	}
+	return r.primary.Query(ctx, sql, args...)
+}
+
+// QueryRow falls back to the primary like Query, only that a row finds out
+// about an unreachable replica when it is scanned.
+func (r *router) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if rep := r.reader(ctx, sql); rep != nil {
+		return &replicaRow{Row: rep.pool.QueryRow(ctx, sql, args...), ctx: ctx, r: r, rep: rep, sql: sql, args: args}
+	}
+	return r.primary.QueryRow(ctx, sql, args...)
+}
+
+func (r *router) CopyFrom(
+	ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource,
+) (int64, error) {
+	markWritten(ctx)
+	return r.primary.CopyFrom(ctx, table, columns, src)
+}
+
+// Begin and BeginTx count as writes, the transaction most likely is one.
+func (r *router) Begin(ctx context.Context) (pgx.Tx, error) {
+	markWritten(ctx)
+	return r.primary.Begin(ctx)
+}
+
+func (r *router) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	markWritten(ctx)
+	return r.primary.BeginTx(ctx, txOptions)
+}
+
+func (r *router) Ping(ctx context.Context) error {
+	return r.primary.Ping(ctx)
+}
+
+func (r *router) Close() {
+	close(r.stop)
+	<-r.done
+	for _, rep := range r.replicas {
+		rep.pool.Close()
+	}
+	r.primary.Close()
+}
+
+// reader picks the replica for sql, or nil for the primary. That is where
+// anything but a plain read goes, as do reads in a transaction, reads with
+// WithPrimary, reads after a write with ReadYourWrites and reads while no
+// replica is healthy.
+func (r *router) reader(ctx context.Context, sql string) *replica {
+	if !isRead(sql) {
+		markWritten(ctx)
+		return nil
+	}
+	if onPrimary(ctx) {
+		return nil
+	}
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := range n {
+		if rep := r.replicas[(start+i)%n]; rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// unreachable ejects rep when err says the connection to it failed, not
+// when the server answered with an error or the caller gave up.
+func (r *router) unreachable(ctx context.Context, rep *replica, err error) bool {
+	var (
+		connErr *pgconn.ConnectError
+		netErr  net.Error
+	)
+	if ctx.Err() != nil ||
+		!errors.As(err, &connErr) && !errors.As(err, &netErr) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false
+	}
+	r.eject(rep, err)
+	return true
+}
+
+type replicaRow struct {
+	pgx.Row
+	ctx  context.Context
+	r    *router
+	rep  *replica
+	sql  string
+	args []any
+}
+
+func (row *replicaRow) Scan(dest ...any) error {
+	err := row.Row.Scan(dest...)
+	if err == nil || !row.r.unreachable(row.ctx, row.rep, err) {
+		return err
+	}
+	return row.r.primary.QueryRow(row.ctx, row.sql, row.args...).Scan(dest...)
+}
+
+func (r *router) eject(rep *replica, err error) {
+	if rep.healthy.Swap(false) {
+		log.Printf("postgres: %s ejected: %v", rep.name, err)
+	}
+}
+
+func (r *router) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.check(context.Background())
+		}
+	}
+}
+
+// check pings every replica, ejecting the failing ones and taking back
+// those that answer again.
+func (r *router) check(ctx context.Context) {
+	for _, rep := range r.replicas {
+		ctx, cancel := context.WithTimeout(ctx, r.interval)
+		err := rep.pool.Ping(ctx)
+		cancel()
+		if err != nil {
+			r.eject(rep, err)
+			continue
+		}
+		if !rep.healthy.Swap(true) {
+			log.Printf("postgres: %s is back", rep.name)
+		}
+	}
+}
+
+func onPrimary(ctx context.Context) bool {
+	if _, ok := stateFrom(ctx); ok {
+		return true
+	}
+	if forced, _ := ctx.Value(primaryKey{}).(bool); forced {
+		return true
+	}
+	written, ok := ctx.Value(writtenKey{}).(*atomic.Bool)
+	return ok && written.Load()
+}
+
+func markWritten(ctx context.Context) {
+	if written, ok := ctx.Value(writtenKey{}).(*atomic.Bool); ok {
+		written.Store(true)
+	}
+}
+
+// isRead reports a SELECT, or a WITH query made of them, that changes
+// nothing and locks no rows.
+func isRead(sql string) bool {
+	keyword := strings.TrimLeftFunc(sql, func(r rune) bool { return r == '(' || unicode.IsSpace(r) })
+	if end := strings.IndexFunc(keyword, unicode.IsSpace); end >= 0 {
+		keyword = keyword[:end]
+	}
+	return (strings.EqualFold(keyword, "select") || strings.EqualFold(keyword, "with")) && !writes.MatchString(sql)
+}
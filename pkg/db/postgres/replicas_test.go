@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var refused = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+const (
+	selectProduct = "SELECT id, name FROM products WHERE id = $1"
+	updateProduct = "UPDATE products SET name = $1 WHERE id = $2"
+)
+
+func newReplicas(n int) (*MockPool, []*MockPool, *router) {
+	primary := new(MockPool)
+	mocks := make([]*MockPool, n)
+	pools := make([]Pool, n)
+	for i := range mocks {
+		mocks[i] = new(MockPool)
+		pools[i] = mocks[i]
+	}
+	return primary, mocks, newRouter(primary, pools, 0)
+}
+
+func TestRouter(t *testing.T) {
+	t.Run("reads go round the replicas", func(t *testing.T) {
+		primary, replicas, r := newReplicas(2)
+		row := new(MockRow)
+		for _, replica := range replicas {
+			replica.On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(row).Twice()
+		}
+
+		for range 4 {
+			r.QueryRow(context.Background(), selectProduct, 1)
+		}
+		for _, replica := range replicas {
+			replica.AssertExpectations(t)
+		}
+		primary.AssertNotCalled(t, "QueryRow", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("writes and locking reads go to the primary", func(t *testing.T) {
+		primary, replicas, r := newReplicas(1)
+		defer primary.AssertExpectations(t)
+		row := new(MockRow)
+		locking := selectProduct + " FOR UPDATE"
+
+		primary.On("Exec", mock.Anything, updateProduct, mock.Anything).Return(pgconn.CommandTag{}, nil).Once()
+		primary.On("QueryRow", mock.Anything, locking, mock.Anything).Return(row).Once()
+
+		_, err := r.Exec(context.Background(), updateProduct, "Watch", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, row, r.QueryRow(context.Background(), locking, 1))
+		replicas[0].AssertNotCalled(t, "QueryRow", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("reads after a write stay on the primary", func(t *testing.T) {
+		primary, replicas, r := newReplicas(1)
+		defer primary.AssertExpectations(t)
+		defer replicas[0].AssertExpectations(t)
+		row := new(MockRow)
+		ctx := ReadYourWrites(context.Background())
+
+		replicas[0].On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(row).Once()
+		primary.On("Exec", mock.Anything, updateProduct, mock.Anything).Return(pgconn.CommandTag{}, nil).Once()
+		primary.On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(row).Once()
+
+		r.QueryRow(ctx, selectProduct, 1)
+		_, err := r.Exec(ctx, updateProduct, "Watch", 1)
+		assert.NoError(t, err)
+		r.QueryRow(ctx, selectProduct, 1)
+	})
+	t.Run("forced primary reads", func(t *testing.T) {
+		primary, replicas, r := newReplicas(1)
+		defer primary.AssertExpectations(t)
+		row := new(MockRow)
+
+		primary.On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(row).Once()
+
+		r.QueryRow(WithPrimary(context.Background()), selectProduct, 1)
+		replicas[0].AssertNotCalled(t, "QueryRow", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("unreachable replica is ejected", func(t *testing.T) {
+		primary, replicas, r := newReplicas(2)
+		defer primary.AssertExpectations(t)
+		rows := new(MockRow)
+
+		replicas[1].On("Query", mock.Anything, selectProduct, mock.Anything).
+			Return((*MockRow)(nil), refused).Once()
+		primary.On("Query", mock.Anything, selectProduct, mock.Anything).Return(rows, nil).Once()
+		replicas[0].On("Query", mock.Anything, selectProduct, mock.Anything).Return(rows, nil).Twice()
+
+		for range 3 {
+			got, err := r.Query(context.Background(), selectProduct, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, rows, got)
+		}
+		replicas[0].AssertExpectations(t)
+		replicas[1].AssertExpectations(t)
+	})
+	t.Run("unreachable replica is ejected on scan", func(t *testing.T) {
+		primary, replicas, r := newReplicas(1)
+		defer primary.AssertExpectations(t)
+		defer replicas[0].AssertExpectations(t)
+		dead, row := new(MockRow), new(MockRow)
+		var name string
+
+		replicas[0].On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(dead).Once()
+		dead.On("Scan", &name).Return(refused).Once()
+		primary.On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(row).Twice()
+		row.On("Scan", &name).Return(nil).Twice()
+
+		assert.NoError(t, r.QueryRow(context.Background(), selectProduct, 1).Scan(&name))
+		assert.False(t, r.replicas[0].healthy.Load())
+		assert.NoError(t, r.QueryRow(context.Background(), selectProduct, 1).Scan(&name))
+	})
+	t.Run("no rows keep the replica", func(t *testing.T) {
+		_, replicas, r := newReplicas(1)
+		row := new(MockRow)
+		var name string
+
+		replicas[0].On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(row).Once()
+		row.On("Scan", &name).Return(pgx.ErrNoRows).Once()
+
+		assert.ErrorIs(t, r.QueryRow(context.Background(), selectProduct, 1).Scan(&name), pgx.ErrNoRows)
+		assert.True(t, r.replicas[0].healthy.Load())
+	})
+	t.Run("server errors keep the replica", func(t *testing.T) {
+		_, replicas, r := newReplicas(1)
+		pgErr := &pgconn.PgError{Code: "42P01"}
+
+		replicas[0].On("Query", mock.Anything, selectProduct, mock.Anything).Return((*MockRow)(nil), pgErr).Once()
+
+		_, err := r.Query(context.Background(), selectProduct, 1)
+		assert.ErrorIs(t, err, pgErr)
+		assert.True(t, r.replicas[0].healthy.Load())
+	})
+	t.Run("health check ejects and takes back", func(t *testing.T) {
+		primary, replicas, r := newReplicas(1)
+		row := new(MockRow)
+
+		replicas[0].On("Ping", mock.Anything).Return(errors.New("timeout")).Once()
+		primary.On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(row).Once()
+		r.check(context.Background())
+		r.QueryRow(context.Background(), selectProduct, 1)
+
+		replicas[0].On("Ping", mock.Anything).Return(nil).Once()
+		replicas[0].On("QueryRow", mock.Anything, selectProduct, mock.Anything).Return(row).Once()
+		r.check(context.Background())
+		r.QueryRow(context.Background(), selectProduct, 1)
+
+		primary.AssertExpectations(t)
+		replicas[0].AssertExpectations(t)
+	})
+}
+
+func TestIsRead(t *testing.T) {
+	tests := map[string]bool{
+		selectProduct: true,
+		"\n\t\tWITH ids AS (SELECT id FROM products) SELECT count(*) FROM ids": true,
+		"(SELECT 1) UNION (SELECT 2)":                                          true,
+		updateProduct:                                                          false,
+		"WITH moved AS (DELETE FROM reservations RETURNING id) SELECT count(*) FROM moved": false,
+		selectProduct + " FOR SHARE":                       false,
+		"SELECT nextval('products_id_seq')":                false,
+		"SELECT pg_advisory_xact_lock(1)":                  false,
+		"INSERT INTO products (name) VALUES ($1)":          false,
+		"select id from products order by updated_at desc": true,
+		"SELECT\n\tid\nFROM products":                      true,
+	}
+	for sql, want := range tests {
+		assert.Equal(t, want, isRead(sql), sql)
+	}
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import "time"
+
+// Stats is what pgxpool.Stat reports about a pool, waits are acquires
+// that found no idle connection.
+type Stats struct {
+	AcquireCount         int64         `json:"acquire_count"`
+	AcquireDuration      time.Duration `json:"acquire_duration_ns"`
+	AcquiredConns        int32         `json:"acquired_conns"`
+	IdleConns            int32         `json:"idle_conns"`
+	ConstructingConns    int32         `json:"constructing_conns"`
+	TotalConns           int32         `json:"total_conns"`
+	MaxConns             int32         `json:"max_conns"`
+	WaitCount            int64         `json:"wait_count"`
+	WaitDuration         time.Duration `json:"wait_duration_ns"`
+	CanceledAcquireCount int64         `json:"canceled_acquire_count"`
+	NewConnsCount        int64         `json:"new_conns_count"`
+	IdleDestroyCount     int64         `json:"idle_destroy_count"`
+	LifetimeDestroyCount int64         `json:"lifetime_destroy_count"`
+}
+
+// PoolStats returns the stats of the primary and, when reads are routed to
+// them, of every replica by its name. Pools not made by New report none.
+func PoolStats(pool Pool) map[string]Stats {
+	switch p := pool.(type) {
+	case *conn:
+		return map[string]Stats{"primary": p.stats()}
+	case *router:
+		stats := PoolStats(p.primary)
+		for _, rep := range p.replicas {
+			if c, ok := rep.pool.(*conn); ok {
+				stats[rep.name] = c.stats()
+			}
+		}
+		return stats
+	}
+	return map[string]Stats{}
+}
+
+func (c *conn) stats() Stats {
+	s := c.Stat()
+	return Stats{
+		AcquireCount:         s.AcquireCount(),
+		AcquireDuration:      s.AcquireDuration(),
+		AcquiredConns:        s.AcquiredConns(),
+		IdleConns:            s.IdleConns(),
+		ConstructingConns:    s.ConstructingConns(),
+		TotalConns:           s.TotalConns(),
+		MaxConns:             s.MaxConns(),
+		WaitCount:            s.EmptyAcquireCount(),
+		WaitDuration:         s.EmptyAcquireWaitTime(),
+		CanceledAcquireCount: s.CanceledAcquireCount(),
+		NewConnsCount:        s.NewConnsCount(),
+		IdleDestroyCount:     s.MaxIdleDestroyCount(),
+		LifetimeDestroyCount: s.MaxLifetimeDestroyCount(),
+	}
+}
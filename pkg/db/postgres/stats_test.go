@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolStats(t *testing.T) {
+	pool, err := connect(context.Background(), "postgres://prodigo@localhost:1/prodigo", Options{
+		MaxConns:         7,
+		StatementTimeout: 2 * time.Second,
+	})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	c := pool.(*conn)
+	assert.Equal(t, "2000", c.Config().ConnConfig.RuntimeParams["statement_timeout"])
+
+	r := newRouter(pool, []Pool{new(MockPool)}, 0)
+	stats := PoolStats(r)
+	assert.Equal(t, int32(7), stats["primary"].MaxConns)
+	assert.Len(t, stats, 1)
+	assert.Empty(t, PoolStats(new(MockPool)))
+}
@@ -0,0 +1,231 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrSerialization is returned when a transaction still conflicts with
+// concurrent ones after its retries, callers usually answer it like a
+// version conflict.
+var ErrSerialization = errors.New("transaction conflicted with a concurrent one")
+
+const (
+	ReadCommitted  = pgx.ReadCommitted
+	RepeatableRead = pgx.RepeatableRead
+	Serializable   = pgx.Serializable
+)
+
+// DefaultRetries is how often a transaction is run again after a
+// serialization failure or a deadlock unless configured otherwise.
+const DefaultRetries = 3
+
+// Querier is what a Pool and a pgx.Tx have in common, repositories run
+// their statements on it so they take part in a surrounding transaction.
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error)
+}
+
+// Transactor runs several repository calls as one transaction.
+type Transactor interface {
+	// InTx runs fn with the default options of the transactor.
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// WithinTx runs fn with opts on top of the defaults.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error
+}
+
+// TxOptions are the isolation level and access mode of a transaction and
+// how often it is retried after a serialization failure or a deadlock.
+type TxOptions struct {
+	IsoLevel pgx.TxIsoLevel
+	ReadOnly bool
+	Retries  int
+}
+
+type TxOption func(*TxOptions)
+
+func WithIsolation(level pgx.TxIsoLevel) TxOption {
+	return func(o *TxOptions) { o.IsoLevel = level }
+}
+
+func WithRetries(n int) TxOption {
+	return func(o *TxOptions) { o.Retries = max(n, 0) }
+}
+
+func ReadOnly() TxOption {
+	return func(o *TxOptions) { o.ReadOnly = true }
+}
+
+// ParseIsolation reads an isolation level as written in SQL, "repeatable
+// read" or "REPEATABLE_READ". An empty level is read committed.
+func ParseIsolation(level string) (pgx.TxIsoLevel, error) {
+	switch strings.ToLower(strings.NewReplacer("_", " ", "-", " ").Replace(strings.TrimSpace(level))) {
+	case "", "read committed":
+		return ReadCommitted, nil
+	case "repeatable read":
+		return RepeatableRead, nil
+	case "serializable":
+		return Serializable, nil
+	}
+	return "", fmt.Errorf("unknown isolation level %q", level)
+}
+
+// txState is what a Transactor puts in the context. conflict is set by
+// the tracer when a statement of the transaction failed in a way a retry
+// can fix, repositories do not keep the *pgconn.PgError in their errors.
+type txState struct {
+	tx       pgx.Tx
+	conflict atomic.Bool
+}
+
+type txKey struct{}
+
+func stateFrom(ctx context.Context) (*txState, bool) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	return state, ok
+}
+
+// Conn returns the transaction started by a Transactor for ctx, or pool
+// outside of one.
+func Conn(ctx context.Context, pool Pool) Querier {
+	if state, ok := stateFrom(ctx); ok {
+		return state.tx
+	}
+	return pool
+}
+
+// Begin starts a transaction of its own, nested as a savepoint when ctx
+// already carries one.
+func Begin(ctx context.Context, pool Pool) (pgx.Tx, error) {
+	if state, ok := stateFrom(ctx); ok {
+		return state.tx.Begin(ctx)
+	}
+	return pool.Begin(ctx)
+}
+
+type transactor struct {
+	pool     Pool
+	defaults TxOptions
+}
+
+// NewTransactor runs read committed transactions with DefaultRetries
+// unless opts say otherwise.
+func NewTransactor(pool Pool, opts ...TxOption) Transactor {
+	t := &transactor{pool: pool, defaults: TxOptions{IsoLevel: ReadCommitted, Retries: DefaultRetries}}
+	for _, opt := range opts {
+		opt(&t.defaults)
+	}
+	return t
+}
+
+func (t *transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return t.WithinTx(ctx, fn)
+}
+
+// WithinTx commits when fn succeeds and rolls back otherwise. Calls nested
+// in fn join the outer transaction and keep its options. A transaction
+// that hit a serialization failure or a deadlock is run again from the
+// start, so fn must not have effects outside of it. Once the retries are
+// used up the error wraps ErrSerialization.
+func (t *transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error {
+	if _, ok := stateFrom(ctx); ok {
+		return fn(ctx)
+	}
+
+	o := t.defaults
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for attempt := 0; ; attempt++ {
+		err := t.run(ctx, o, fn)
+		if !errors.Is(err, ErrSerialization) || attempt >= o.Retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryDelay(attempt)):
+		}
+	}
+}
+
+func (t *transactor) run(ctx context.Context, o TxOptions, fn func(ctx context.Context) error) error {
+	txOpts := pgx.TxOptions{IsoLevel: o.IsoLevel}
+	if o.ReadOnly {
+		txOpts.AccessMode = pgx.ReadOnly
+	}
+	tx, err := t.pool.BeginTx(ctx, txOpts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	state := &txState{tx: tx}
+	if err = fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		if state.conflict.Load() || retryable(err) {
+			return fmt.Errorf("%w: %w", ErrSerialization, err)
+		}
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		if retryable(err) {
+			return fmt.Errorf("%w: failed to commit transaction: %w", ErrSerialization, err)
+		}
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// retryDelay backs off exponentially from 10ms with jitter, so the
+// transactions that conflicted do not meet again right away.
+func retryDelay(attempt int) time.Duration {
+	d := 10 * time.Millisecond << min(attempt, 6)
+	return d/2 + rand.N(d/2)
+}
+
+// retryable reports a serialization failure or a deadlock, both are
+// resolved by running the transaction again.
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
+
+// conflictTracer marks the transaction of ctx when one of its statements
+// fails with a serialization failure or a deadlock.
+type conflictTracer struct{}
+
+func (conflictTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (conflictTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	markConflict(ctx, data.Err)
+}
+
+func (conflictTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceCopyFromStartData) context.Context {
+	return ctx
+}
+
+func (conflictTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	markConflict(ctx, data.Err)
+}
+
+func markConflict(ctx context.Context, err error) {
+	if state, ok := stateFrom(ctx); ok && retryable(err) {
+		state.conflict.Store(true)
+	}
+}
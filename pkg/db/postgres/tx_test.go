@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var readCommitted = pgx.TxOptions{IsoLevel: pgx.ReadCommitted}
+
+func TestTransactor_InTx(t *testing.T) {
+	t.Run("commits and exposes the tx", func(t *testing.T) {
+		pool := new(MockPool)
+		tx := new(MockTx)
+		defer tx.AssertExpectations(t)
+
+		pool.On("BeginTx", mock.Anything, readCommitted).Return(tx, nil).Once()
+		tx.On("Commit", mock.Anything).Return(nil).Once()
+		tx.On("Rollback", mock.Anything).Return(nil)
+
+		err := NewTransactor(pool).InTx(context.Background(), func(ctx context.Context) error {
+			assert.Equal(t, tx, Conn(ctx, pool))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("rolls back on error", func(t *testing.T) {
+		pool := new(MockPool)
+		tx := new(MockTx)
+		defer tx.AssertExpectations(t)
+
+		pool.On("BeginTx", mock.Anything, readCommitted).Return(tx, nil).Once()
+		tx.On("Rollback", mock.Anything).Return(nil).Once()
+
+		fail := errors.New("boom")
+		err := NewTransactor(pool).InTx(context.Background(), func(context.Context) error { return fail })
+		assert.ErrorIs(t, err, fail)
+		tx.AssertNotCalled(t, "Commit", mock.Anything)
+	})
+	t.Run("nested calls join", func(t *testing.T) {
+		pool := new(MockPool)
+		tx := new(MockTx)
+
+		pool.On("BeginTx", mock.Anything, readCommitted).Return(tx, nil).Once()
+		tx.On("Commit", mock.Anything).Return(nil).Once()
+		tx.On("Rollback", mock.Anything).Return(nil)
+
+		transactor := NewTransactor(pool)
+		err := transactor.InTx(context.Background(), func(ctx context.Context) error {
+			return transactor.InTx(ctx, func(ctx context.Context) error {
+				assert.Equal(t, tx, Conn(ctx, pool))
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		pool.AssertNumberOfCalls(t, "BeginTx", 1)
+	})
+	t.Run("pool outside of a transaction", func(t *testing.T) {
+		pool := new(MockPool)
+		assert.Equal(t, pool, Conn(context.Background(), pool))
+	})
+}
+
+func TestTransactor_WithinTx(t *testing.T) {
+	conflict := &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+
+	t.Run("applies the options", func(t *testing.T) {
+		pool := new(MockPool)
+		tx := new(MockTx)
+		defer pool.AssertExpectations(t)
+
+		pool.On("BeginTx", mock.Anything, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly}).
+			Return(tx, nil).Once()
+		tx.On("Commit", mock.Anything).Return(nil).Once()
+		tx.On("Rollback", mock.Anything).Return(nil)
+
+		transactor := NewTransactor(pool, WithIsolation(RepeatableRead))
+		err := transactor.WithinTx(context.Background(), func(context.Context) error { return nil },
+			WithIsolation(Serializable), ReadOnly())
+		assert.NoError(t, err)
+	})
+	t.Run("retries a serialization failure", func(t *testing.T) {
+		pool := new(MockPool)
+		tx := new(MockTx)
+
+		pool.On("BeginTx", mock.Anything, readCommitted).Return(tx, nil).Twice()
+		tx.On("Commit", mock.Anything).Return(nil).Once()
+		tx.On("Rollback", mock.Anything).Return(nil)
+
+		runs := 0
+		err := NewTransactor(pool).WithinTx(context.Background(), func(ctx context.Context) error {
+			if runs++; runs == 1 {
+				// repositories drop the *pgconn.PgError, the tracer still sees it
+				markConflict(ctx, conflict)
+				return errors.New("failed to update product")
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, runs)
+	})
+	t.Run("retries a failed commit", func(t *testing.T) {
+		pool := new(MockPool)
+		tx := new(MockTx)
+
+		pool.On("BeginTx", mock.Anything, readCommitted).Return(tx, nil).Twice()
+		tx.On("Commit", mock.Anything).Return(conflict).Once()
+		tx.On("Commit", mock.Anything).Return(nil).Once()
+		tx.On("Rollback", mock.Anything).Return(nil)
+
+		err := NewTransactor(pool).WithinTx(context.Background(), func(context.Context) error { return nil })
+		assert.NoError(t, err)
+		tx.AssertNumberOfCalls(t, "Commit", 2)
+	})
+	t.Run("gives up after the retries", func(t *testing.T) {
+		pool := new(MockPool)
+		tx := new(MockTx)
+
+		pool.On("BeginTx", mock.Anything, readCommitted).Return(tx, nil)
+		tx.On("Rollback", mock.Anything).Return(nil)
+
+		err := NewTransactor(pool, WithRetries(1)).WithinTx(context.Background(), func(context.Context) error {
+			return fmt.Errorf("failed to move category: %w", conflict)
+		})
+		assert.ErrorIs(t, err, ErrSerialization)
+		assert.ErrorIs(t, err, conflict)
+		pool.AssertNumberOfCalls(t, "BeginTx", 2)
+	})
+	t.Run("other errors are not retried", func(t *testing.T) {
+		pool := new(MockPool)
+		tx := new(MockTx)
+
+		pool.On("BeginTx", mock.Anything, readCommitted).Return(tx, nil).Once()
+		tx.On("Rollback", mock.Anything).Return(nil)
+
+		fail := errors.New("boom")
+		err := NewTransactor(pool).WithinTx(context.Background(), func(context.Context) error { return fail })
+		assert.ErrorIs(t, err, fail)
+		assert.NotErrorIs(t, err, ErrSerialization)
+		pool.AssertExpectations(t)
+	})
+}
+
+func TestParseIsolation(t *testing.T) {
+	tests := map[string]pgx.TxIsoLevel{
+		"":                ReadCommitted,
+		"read committed":  ReadCommitted,
+		"REPEATABLE_READ": RepeatableRead,
+		"serializable":    Serializable,
+	}
+	for level, want := range tests {
+		got, err := ParseIsolation(level)
+		assert.NoError(t, err, level)
+		assert.Equal(t, want, got, level)
+	}
+	_, err := ParseIsolation("snapshot")
+	assert.Error(t, err)
+}
@@ -14,15 +14,32 @@ type Client interface {
 	Ping(context.Context) *redis.StatusCmd
 }
 
+// Options tune the client, zero values keep the go-redis defaults.
+type Options struct {
+	PoolSize        int
+	ConnMaxIdleTime time.Duration
+	Timeout         time.Duration
+}
+
 type conn struct {
 	*redis.Client
 }
 
-func New(ctx context.Context, url string) (Client, error) {
+func New(ctx context.Context, url string, opts Options) (Client, error) {
 	opt, err := redis.ParseURL(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse redis url: %w", err)
 	}
+	if opts.PoolSize > 0 {
+		opt.PoolSize = opts.PoolSize
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		opt.ConnMaxIdleTime = opts.ConnMaxIdleTime
+	}
+	if opts.Timeout > 0 {
+		opt.ReadTimeout = opts.Timeout
+		opt.WriteTimeout = opts.Timeout
+	}
 
 	client := redis.NewClient(opt)
 	if err := client.Ping(ctx).Err(); err != nil {
@@ -31,3 +48,31 @@ func New(ctx context.Context, url string) (Client, error) {
 
 	return &conn{Client: client}, nil
 }
+
+// Stats is what the pool of a client reports, hits found an idle
+// connection and timeouts gave up waiting for one.
+type Stats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+}
+
+// PoolStats returns the pool stats of a client made by New, nil otherwise.
+func PoolStats(client Client) *Stats {
+	c, ok := client.(*conn)
+	if !ok {
+		return nil
+	}
+	s := c.Client.PoolStats()
+	return &Stats{
+		Hits:       s.Hits,
+		Misses:     s.Misses,
+		Timeouts:   s.Timeouts,
+		TotalConns: s.TotalConns,
+		IdleConns:  s.IdleConns,
+		StaleConns: s.StaleConns,
+	}
+}
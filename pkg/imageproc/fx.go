@@ -0,0 +1,22 @@
+package imageproc
+
+import (
+	"prodigo/pkg/config"
+
+	"go.uber.org/fx"
+)
+
+var Module = fx.Module("imageproc", fx.Provide(NewFromConfig))
+
+// NewFromConfig builds the processor from APP_IMAGE_RENDITIONS and APP_IMAGE_QUALITY.
+func NewFromConfig(conf *config.Config) (*Processor, error) {
+	spec := conf.AppImageRenditions
+	if spec == "" {
+		spec = DefaultRenditions
+	}
+	renditions, err := ParseRenditions(spec)
+	if err != nil {
+		return nil, err
+	}
+	return New(renditions, conf.AppImageQuality), nil
+}
@@ -0,0 +1,179 @@
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register decoders for DecodeConfig
+	_ "image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+var (
+	ErrInvalidImage = errors.New("invalid image")
+	ErrTooLarge     = errors.New("image dimensions are too large")
+)
+
+// DefaultRenditions are used when APP_IMAGE_RENDITIONS is empty.
+const DefaultRenditions = "thumb=200,medium=800,large=1600"
+
+const (
+	DefaultQuality = 85
+	// MaxPixels guards against decompression bombs, a 10MB upload can
+	// still declare a huge canvas.
+	MaxPixels = 50_000_000
+)
+
+// Rendition is a named size, the longest side of the image is scaled
+// down to Size pixels.
+type Rendition struct {
+	Name string
+	Size int
+}
+
+// Image is an encoded image ready to be stored.
+type Image struct {
+	ContentType string
+	Ext         string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+// Result holds the normalized original and the renditions smaller than it,
+// a rendition the original already fits into is left out.
+type Result struct {
+	Renditions map[string]*Image
+	Original   *Image
+}
+
+type Processor struct {
+	renditions []Rendition
+	quality    int
+}
+
+func New(renditions []Rendition, quality int) *Processor {
+	if quality <= 0 || quality > 100 {
+		quality = DefaultQuality
+	}
+	return &Processor{renditions: renditions, quality: quality}
+}
+
+// Renditions returns the configured renditions in configuration order.
+func (p *Processor) Renditions() []Rendition {
+	return p.renditions
+}
+
+// Has reports whether name is a configured rendition.
+func (p *Processor) Has(name string) bool {
+	for _, r := range p.renditions {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Process decodes a JPEG or PNG, applies the EXIF orientation and
+// re-encodes it, which drops all metadata. Opaque images become JPEG,
+// images with transparency stay PNG.
+func (p *Processor) Process(r io.Reader) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	encode := p.encodeJPEG
+	if !opaque(img) {
+		encode = encodePNG
+	}
+
+	res := &Result{Renditions: make(map[string]*Image, len(p.renditions))}
+	if res.Original, err = encode(img); err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	for _, rend := range p.renditions {
+		if bounds.Dx() <= rend.Size && bounds.Dy() <= rend.Size {
+			continue
+		}
+		if res.Renditions[rend.Name], err = encode(imaging.Fit(img, rend.Size, rend.Size, imaging.Lanczos)); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (p *Processor) encodeJPEG(img image.Image) (*Image, error) {
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(p.quality)); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return newImage(img, "image/jpeg", ".jpg", buf.Bytes()), nil
+}
+
+func encodePNG(img image.Image) (*Image, error) {
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, imaging.PNG); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return newImage(img, "image/png", ".png", buf.Bytes()), nil
+}
+
+func newImage(img image.Image, contentType, ext string, data []byte) *Image {
+	b := img.Bounds()
+	return &Image{ContentType: contentType, Ext: ext, Data: data, Width: b.Dx(), Height: b.Dy()}
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// ParseRenditions reads a list like "thumb=200,medium=800".
+func ParseRenditions(s string) ([]Rendition, error) {
+	var renditions []Rendition
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, size, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || name == "original" {
+			return nil, fmt.Errorf("invalid rendition %q", part)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid rendition size %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate rendition %q", name)
+		}
+		seen[name] = true
+		renditions = append(renditions, Rendition{Name: name, Size: n})
+	}
+	return renditions, nil
+}
@@ -0,0 +1,121 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF segment carrying only the orientation tag.
+func withOrientation(data []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2a")
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(8))
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(1))
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(1))
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xff, 0xe1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func TestProcessor_Process(t *testing.T) {
+	p := New([]Rendition{{Name: "thumb", Size: 100}, {Name: "large", Size: 1000}}, 0)
+
+	t.Run("jpeg renditions", func(t *testing.T) {
+		res, err := p.Process(bytes.NewReader(testJPEG(t, 400, 300)))
+		require.NoError(t, err)
+
+		assert.Equal(t, "image/jpeg", res.Original.ContentType)
+		assert.Equal(t, ".jpg", res.Original.Ext)
+		assert.Equal(t, 400, res.Original.Width)
+		assert.Equal(t, 300, res.Original.Height)
+
+		require.Contains(t, res.Renditions, "thumb")
+		assert.Equal(t, 100, res.Renditions["thumb"].Width)
+		assert.Equal(t, 75, res.Renditions["thumb"].Height)
+		assert.NotContains(t, res.Renditions, "large", "images are never scaled up")
+
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(res.Renditions["thumb"].Data))
+		require.NoError(t, err)
+		assert.Equal(t, 100, cfg.Width)
+	})
+	t.Run("orientation is applied and exif dropped", func(t *testing.T) {
+		data := withOrientation(testJPEG(t, 400, 300), 6)
+		require.Contains(t, string(data), "Exif")
+
+		res, err := p.Process(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, 300, res.Original.Width)
+		assert.Equal(t, 400, res.Original.Height)
+		assert.NotContains(t, string(res.Original.Data), "Exif")
+	})
+	t.Run("transparent png stays png", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 200, 200))
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, img))
+
+		res, err := p.Process(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", res.Original.ContentType)
+		assert.Equal(t, ".png", res.Renditions["thumb"].Ext)
+	})
+	t.Run("opaque png becomes jpeg", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 50, 50))
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xff
+		}
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, img))
+
+		res, err := p.Process(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, "image/jpeg", res.Original.ContentType)
+		assert.Empty(t, res.Renditions)
+	})
+	t.Run("not an image", func(t *testing.T) {
+		_, err := p.Process(bytes.NewReader([]byte("hello")))
+		assert.ErrorIs(t, err, ErrInvalidImage)
+	})
+	t.Run("truncated image", func(t *testing.T) {
+		data := testJPEG(t, 400, 300)
+		_, err := p.Process(bytes.NewReader(data[:len(data)/2]))
+		assert.ErrorIs(t, err, ErrInvalidImage)
+	})
+}
+
+func TestParseRenditions(t *testing.T) {
+	renditions, err := ParseRenditions(DefaultRenditions)
+	require.NoError(t, err)
+	assert.Equal(t, []Rendition{{"thumb", 200}, {"medium", 800}, {"large", 1600}}, renditions)
+
+	for _, spec := range []string{"thumb", "thumb=0", "thumb=abc", "=100", "original=100", "a=1,a=2"} {
+		_, err = ParseRenditions(spec)
+		assert.Error(t, err, spec)
+	}
+}
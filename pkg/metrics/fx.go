@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"prodigo/pkg/config"
+
+	"go.uber.org/fx"
+)
+
+// Module serves the stats on the address addr takes from the config.
+func Module(addr func(conf *config.Config) string) fx.Option {
+	return fx.Module("metrics", fx.Invoke(func(lc fx.Lifecycle, conf *config.Config) {
+		s := New(addr(conf))
+		lc.Append(fx.Hook{OnStart: s.Start, OnStop: s.Stop})
+	}))
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server serves the expvar stats, the pool stats among them, on an address
+// of its own. They show the command line and the database topology, so the
+// address is meant to stay inside the network.
+type Server struct {
+	addr string
+	ln   net.Listener
+	srv  *http.Server
+}
+
+// New serves on addr, nothing is served when it is empty.
+func New(addr string) *Server {
+	return &Server{addr: addr}
+}
+
+func (s *Server) Start(_ context.Context) error {
+	if s.addr == "" {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+	s.ln = ln
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	s.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to stop metrics server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("serves the stats", func(t *testing.T) {
+		s := New("127.0.0.1:0")
+		require.NoError(t, s.Start(context.Background()))
+		defer func() { _ = s.Stop(context.Background()) }()
+
+		resp, err := http.Get("http://" + s.ln.Addr().String() + "/debug/vars")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(body), "memstats")
+	})
+	t.Run("off without an address", func(t *testing.T) {
+		s := New("")
+		assert.NoError(t, s.Start(context.Background()))
+		assert.Nil(t, s.srv)
+		assert.NoError(t, s.Stop(context.Background()))
+	})
+}
@@ -0,0 +1,27 @@
+package notifier
+
+import (
+	"fmt"
+	"prodigo/pkg/config"
+
+	"go.uber.org/fx"
+)
+
+const (
+	DriverLog     = "log"
+	DriverWebhook = "webhook"
+)
+
+var Module = fx.Module("notifier", fx.Provide(New))
+
+// New picks the notifier named by APP_NOTIFIER, the log by default.
+func New(conf *config.Config) (Notifier, error) {
+	switch conf.AppNotifier {
+	case "", DriverLog:
+		return NewLog(nil), nil
+	case DriverWebhook:
+		return NewWebhook(conf.AppNotifierURL, conf.AppNotifierTimeout)
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", conf.AppNotifier)
+	}
+}
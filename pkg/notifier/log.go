@@ -0,0 +1,30 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Log writes events to a logger, it is the default when no webhook is
+// configured.
+type Log struct {
+	logger *log.Logger
+}
+
+func NewLog(logger *log.Logger) *Log {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Log{logger: logger}
+}
+
+func (l *Log) Notify(_ context.Context, e Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	l.logger.Printf("notify %s: %s", e.Type, data)
+	return nil
+}
@@ -0,0 +1,16 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, e Event) error {
+	args := m.Called(ctx, e)
+	return args.Error(0)
+}
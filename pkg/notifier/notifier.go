@@ -0,0 +1,21 @@
+// Package notifier sends events, like a product running low on stock, to
+// whoever has to act on them, behind one interface so the target can be a
+// log or a webhook.
+package notifier
+
+import (
+	"context"
+	"time"
+)
+
+// Event is one notification. Type names what happened, Data carries the
+// details and is encoded as JSON.
+type Event struct {
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+	Type       string    `json:"type"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
@@ -0,0 +1,77 @@
+package notifier_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"prodigo/pkg/config"
+	"prodigo/pkg/notifier"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var event = notifier.Event{
+	OccurredAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	Type:       "product.low_stock",
+	Data:       map[string]int{"product_id": 1},
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	n := notifier.NewLog(log.New(&buf, "", 0))
+
+	require.NoError(t, n.Notify(context.Background(), event))
+	assert.Equal(t, "notify product.low_stock: {\"product_id\":1}\n", buf.String())
+}
+
+func TestWebhook(t *testing.T) {
+	t.Run("posts the event", func(t *testing.T) {
+		var got map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		n, err := notifier.NewWebhook(srv.URL, time.Second)
+		require.NoError(t, err)
+		require.NoError(t, n.Notify(context.Background(), event))
+		assert.Equal(t, "product.low_stock", got["type"])
+		assert.Equal(t, map[string]any{"product_id": float64(1)}, got["data"])
+	})
+	t.Run("error status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		n, err := notifier.NewWebhook(srv.URL, time.Second)
+		require.NoError(t, err)
+		assert.ErrorContains(t, n.Notify(context.Background(), event), "502")
+	})
+	t.Run("url required", func(t *testing.T) {
+		_, err := notifier.NewWebhook("", 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestNew(t *testing.T) {
+	n, err := notifier.New(&config.Config{})
+	require.NoError(t, err)
+	assert.IsType(t, &notifier.Log{}, n)
+
+	n, err = notifier.New(&config.Config{AppNotifier: notifier.DriverWebhook, AppNotifierURL: "http://hooks"})
+	require.NoError(t, err)
+	assert.IsType(t, &notifier.Webhook{}, n)
+
+	_, err = notifier.New(&config.Config{AppNotifier: "sms"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultWebhookTimeout = 5 * time.Second
+
+// Webhook posts every event as JSON to a URL. Any status outside 2xx is
+// an error.
+type Webhook struct {
+	client *http.Client
+	url    string
+}
+
+func NewWebhook(url string, timeout time.Duration) (*Webhook, error) {
+	if url == "" {
+		return nil, errors.New("webhook notifier needs a url")
+	}
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &Webhook{client: &http.Client{Timeout: timeout}, url: url}, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
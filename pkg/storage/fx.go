@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"prodigo/pkg/config"
+
+	"go.uber.org/fx"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+var Module = fx.Module("storage", fx.Provide(New))
+
+// New picks the backend named by APP_STORAGE_DRIVER, local disk by default.
+func New(conf *config.Config) (Storage, error) {
+	switch conf.AppStorageDriver {
+	case "", DriverLocal:
+		root := conf.AppStoragePath
+		if root == "" {
+			root = "./uploads"
+		}
+		return NewLocal(root)
+	case DriverS3:
+		return NewS3(context.Background(), S3Config{
+			Endpoint:  conf.AppS3Endpoint,
+			AccessKey: conf.AppS3AccessKey,
+			SecretKey: conf.AppS3SecretKey,
+			Bucket:    conf.AppS3Bucket,
+			Region:    conf.AppS3Region,
+			UseSSL:    conf.AppS3UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", conf.AppStorageDriver)
+	}
+}
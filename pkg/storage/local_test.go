@@ -0,0 +1,62 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"prodigo/pkg/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	t.Run("put and get", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "products/1/image.png", strings.NewReader("png"), 3, "image/png"))
+
+		obj, err := store.Get(ctx, "products/1/image.png")
+		require.NoError(t, err)
+		defer obj.Body.Close()
+
+		data, err := io.ReadAll(obj.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "png", string(data))
+		assert.Equal(t, "image/png", obj.ContentType)
+		assert.Equal(t, int64(3), obj.Size)
+	})
+	t.Run("put overwrites", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "a.txt", strings.NewReader("one"), 3, "text/plain"))
+		require.NoError(t, store.Put(ctx, "a.txt", strings.NewReader("two"), 3, "text/plain"))
+
+		obj, err := store.Get(ctx, "a.txt")
+		require.NoError(t, err)
+		defer obj.Body.Close()
+
+		data, _ := io.ReadAll(obj.Body)
+		assert.Equal(t, "two", string(data))
+	})
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.Put(ctx, "b.txt", strings.NewReader("b"), 1, "text/plain"))
+		require.NoError(t, store.Delete(ctx, "b.txt"))
+		require.NoError(t, store.Delete(ctx, "b.txt"))
+
+		_, err := store.Get(ctx, "b.txt")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+	t.Run("keys stay inside the root", func(t *testing.T) {
+		_, err := store.Get(ctx, "../secret")
+		assert.ErrorIs(t, err, storage.ErrInvalidKey)
+		err = store.Put(ctx, "/etc/passwd", strings.NewReader(""), 0, "")
+		assert.ErrorIs(t, err, storage.ErrInvalidKey)
+	})
+	t.Run("presign unsupported", func(t *testing.T) {
+		_, err := store.PresignGet(ctx, "a.txt", time.Minute)
+		assert.ErrorIs(t, err, storage.ErrPresignUnsupported)
+	})
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStorage struct {
+	mock.Mock
+}
+
+func (m *MockStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	args := m.Called(ctx, key, r, size, contentType)
+	return args.Error(0)
+}
+
+func (m *MockStorage) Get(ctx context.Context, key string) (*Object, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(*Object), args.Error(1)
+}
+
+func (m *MockStorage) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	args := m.Called(ctx, key, expiry)
+	return args.String(0), args.Error(1)
+}
+
+var _ Storage = (*MockStorage)(nil)
@@ -0,0 +1,64 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"prodigo/pkg/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestS3 runs against a real S3-compatible server, for example the MinIO
+// from deployments/app: STORAGE_TEST_S3_ENDPOINT=localhost:9000 go test ./pkg/storage
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("STORAGE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("STORAGE_TEST_S3_ENDPOINT is not set")
+	}
+
+	ctx := context.Background()
+	store, err := storage.NewS3(ctx, storage.S3Config{
+		Endpoint:  endpoint,
+		AccessKey: envOr("STORAGE_TEST_S3_ACCESS_KEY", "minioadmin"),
+		SecretKey: envOr("STORAGE_TEST_S3_SECRET_KEY", "minioadmin"),
+		Bucket:    "prodigo-test",
+	})
+	require.NoError(t, err)
+
+	key := "products/1/image.png"
+	require.NoError(t, store.Put(ctx, key, strings.NewReader("png"), 3, "image/png"))
+
+	obj, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(obj.Body)
+	require.NoError(t, err)
+	require.NoError(t, obj.Body.Close())
+	assert.Equal(t, "png", string(data))
+	assert.Equal(t, "image/png", obj.ContentType)
+
+	url, err := store.PresignGet(ctx, key, time.Minute)
+	require.NoError(t, err)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, store.Delete(ctx, key))
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
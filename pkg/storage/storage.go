@@ -0,0 +1,32 @@
+// Package storage keeps binary objects, like product images, behind one
+// interface so the app can run on a local disk or on S3-compatible storage.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound           = errors.New("object not found")
+	ErrInvalidKey         = errors.New("invalid object key")
+	ErrPresignUnsupported = errors.New("presigned urls are not supported")
+)
+
+type Object struct {
+	ModTime     time.Time
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns the object stored under key, the caller closes its Body.
+	Get(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL that serves key without credentials until expiry.
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
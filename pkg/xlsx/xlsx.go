@@ -0,0 +1,166 @@
+// Package xlsx writes single sheet spreadsheets row by row. The sheet is
+// the last part of the archive, so rows go straight to the underlying
+// writer instead of being collected first.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+const header = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+var parts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", header +
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", header +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" ` +
+		`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" ` +
+		`Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", header +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" ` +
+		`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" ` +
+		`Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+type Writer struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+}
+
+// NewWriter starts a workbook with one sheet called name.
+func NewWriter(w io.Writer, name string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range parts {
+		if err := writePart(zw, part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	var workbook strings.Builder
+	workbook.WriteString(header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`)
+	if err := xml.EscapeText(&workbook, []byte(name)); err != nil {
+		return nil, fmt.Errorf("failed to escape sheet name: %w", err)
+	}
+	workbook.WriteString(`" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+	if err := writePart(zw, "xl/workbook.xml", workbook.String()); err != nil {
+		return nil, err
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sheet: %w", err)
+	}
+	bw := bufio.NewWriter(sheet)
+	if _, err := bw.WriteString(header +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, fmt.Errorf("failed to write sheet: %w", err)
+	}
+	return &Writer{zip: zw, sheet: bw}, nil
+}
+
+// WriteRow appends a row, numbers and booleans keep their cell type,
+// times are written in RFC 3339 and everything else as text.
+func (w *Writer) WriteRow(values ...any) error {
+	var row strings.Builder
+	row.WriteString("<row>")
+	for _, v := range values {
+		switch v := v.(type) {
+		case int:
+			cell(&row, "n", strconv.Itoa(v))
+		case int64:
+			cell(&row, "n", strconv.FormatInt(v, 10))
+		case float64:
+			cell(&row, "n", strconv.FormatFloat(v, 'g', -1, 64))
+		case bool:
+			value := "0"
+			if v {
+				value = "1"
+			}
+			cell(&row, "b", value)
+		case time.Time:
+			text(&row, v.Format(time.RFC3339))
+		case string:
+			text(&row, v)
+		default:
+			text(&row, fmt.Sprint(v))
+		}
+	}
+	row.WriteString("</row>")
+
+	if _, err := w.sheet.WriteString(row.String()); err != nil {
+		return fmt.Errorf("failed to write row: %w", err)
+	}
+	return nil
+}
+
+// Flush pushes buffered rows to the underlying writer.
+func (w *Writer) Flush() error {
+	if err := w.sheet.Flush(); err != nil {
+		return fmt.Errorf("failed to flush sheet: %w", err)
+	}
+	if err := w.zip.Flush(); err != nil {
+		return fmt.Errorf("failed to flush archive: %w", err)
+	}
+	return nil
+}
+
+// Close finishes the sheet and the archive, it does not close the underlying writer.
+func (w *Writer) Close() error {
+	if _, err := w.sheet.WriteString("</sheetData></worksheet>"); err != nil {
+		return fmt.Errorf("failed to write sheet: %w", err)
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return fmt.Errorf("failed to flush sheet: %w", err)
+	}
+	if err := w.zip.Close(); err != nil {
+		return fmt.Errorf("failed to close archive: %w", err)
+	}
+	return nil
+}
+
+func cell(row *strings.Builder, typ, value string) {
+	row.WriteString(`<c t="` + typ + `"><v>` + value + `</v></c>`)
+}
+
+func text(row *strings.Builder, s string) {
+	row.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+	// writes to a strings.Builder never fail
+	_ = xml.EscapeText(row, []byte(s))
+	row.WriteString(`</t></is></c>`)
+}
+
+func writePart(zw *zip.Writer, name, content string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if _, err := io.WriteString(f, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package xlsx_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"prodigo/pkg/xlsx"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := xlsx.NewWriter(&buf, "Products & more")
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteRow("id", "title", "active", "created_at"))
+	require.NoError(t, w.WriteRow(int64(1), "<watch>", true, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	require.NoError(t, w.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[f.Name] = string(data)
+	}
+
+	assert.Contains(t, files, "[Content_Types].xml")
+	assert.Contains(t, files, "_rels/.rels")
+	assert.Contains(t, files, "xl/_rels/workbook.xml.rels")
+	assert.Contains(t, files["xl/workbook.xml"], `<sheet name="Products &amp; more"`)
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c t="n"><v>1</v></c>`)
+	assert.Contains(t, sheet, `<t xml:space="preserve">&lt;watch&gt;</t>`)
+	assert.Contains(t, sheet, `<c t="b"><v>1</v></c>`)
+	assert.Contains(t, sheet, `2024-01-02T03:04:05Z`)
+	assert.Contains(t, sheet, `</row></sheetData></worksheet>`)
+}